@@ -3,11 +3,12 @@ package main
 import (
 	"log"
 	"os"
+	_ "time/tzdata" // ฐานข้อมูลเขตเวลาในตัว (image alpine ไม่มี) ใช้กับ CRON_TZ ของงานตั้งเวลา
 
 	"github.com/PawornpratKongdaeng/soccer/database"
+	"github.com/PawornpratKongdaeng/soccer/models"
 	"github.com/PawornpratKongdaeng/soccer/routes"
 	"github.com/PawornpratKongdaeng/soccer/services"
-	"github.com/robfig/cron/v3"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
@@ -17,6 +18,19 @@ func main() {
 	// 1. Initialize Database
 	database.InitDB()
 
+	// ย้ายยอดเครดิตเดิมเข้า Ledger (ครั้งแรกหลังอัปเกรด / Admin ที่เพิ่ง Seed)
+	if err := services.PostOpeningBalances(); err != nil {
+		log.Fatalf("❌ [Ledger] Error: %v", err)
+	}
+
+	// ที่เก็บไฟล์อัปโหลด (STORAGE_DRIVER=local|s3)
+	if err := services.InitStorage(); err != nil {
+		log.Fatalf("❌ [Storage] Error: %v", err)
+	}
+
+	// ผู้ให้บริการชำระเงิน (PAYMENT_MOCK_ENABLED=true เปิดตัวจำลอง)
+	services.InitPayments()
+
 	// 2. Setup Fiber App
 	app := fiber.New(fiber.Config{
 		BodyLimit: 10 * 1024 * 1024,
@@ -36,35 +50,61 @@ func main() {
 	// 4. Setup Routes
 	routes.SetupRoutes(app)
 
-	// 5. Initialize Cron Job
-	c := cron.New(cron.WithChain(
-		cron.Recover(cron.DefaultLogger),
-	))
-
-	// Task 1: Auto-Settlement (Every 5 mins)
-	_, err := c.AddFunc("*/5 * * * *", func() {
-		log.Println("⏰ [Cron] Task: Auto-Settlement running...")
-		services.AutoSettlement()
-	})
-
-	// Task 2: Sync Matches (Every 10 mins)
-	_, err = c.AddFunc("*/10 * * * *", func() {
-		log.Println("⏰ [Cron] Task Started: Syncing matches...")
-		errSync := services.SyncMatchesFromAPI("moung")
-
-		if errSync != nil {
-			log.Printf("❌ [Cron] Sync Error: %v", errSync)
-		} else {
-			log.Println("✅ [Cron] Sync Completed")
-		}
-	})
-
-	if err != nil {
+	// 5. Initialize Cron Jobs (เวลา/feed path แก้ได้ที่ /admin/jobs ค่าด้านล่างคือค่าเริ่มต้น)
+	services.Jobs.Register("settlement", "Auto-Settlement: เคลียร์บิลที่รู้ผลแล้ว", "*/5 * * * *", "moung",
+		func(cfg models.JobConfig) error {
+			return services.AutoSettlement(cfg.FeedPath)
+		})
+
+	services.Jobs.Register("match-sync", "Sync Matches: ดึงคู่บอลลง DB", "*/10 * * * *", "moung",
+		func(cfg models.JobConfig) error {
+			return services.SyncMatchesFromAPI(cfg.FeedPath)
+		})
+
+	services.Jobs.Register("commission", "Commission Rebate: จ่ายค่าคอม (รอบ daily/weekly ตั้งใน Settings)", "0 5 * * *", "",
+		func(cfg models.JobConfig) error {
+			return services.RunScheduledCommission()
+		})
+
+	services.Jobs.Register("reconcile", "Reconciliation: กระทบยอดเครดิตกับ Ledger/ประวัติ", "30 4 * * *", "",
+		func(cfg models.JobConfig) error {
+			return services.RunReconciliation()
+		})
+
+	services.Jobs.Register("credit-clear", "Credit Line: เคลียร์ยอดบัญชีเครดิตปิดรอบรายสัปดาห์", "CRON_TZ=Asia/Bangkok 0 6 * * 1", "",
+		func(cfg models.JobConfig) error {
+			return services.RunCreditClear()
+		})
+
+	services.Jobs.Register("agent-period", "Agent Settlement: ปิดรอบสัปดาห์ที่แล้วและออกใบแจ้งยอด", "CRON_TZ=Asia/Bangkok 0 7 * * 1", "",
+		func(cfg models.JobConfig) error {
+			return services.RunAgentPeriodClose()
+		})
+
+	services.Jobs.Register("bonus-expire", "Promotion: ริบโบนัสที่หมดอายุก่อนทำเทิร์นครบ", "0 * * * *", "",
+		func(cfg models.JobConfig) error {
+			return services.RunBonusExpiry()
+		})
+
+	services.Jobs.Register("cashback", "Cashback: สร้างรอบคืนยอดเสีย (รอ Admin อนุมัติ รอบ daily/weekly ตั้งใน Settings)", "30 5 * * *", "",
+		func(cfg models.JobConfig) error {
+			return services.RunScheduledCashback()
+		})
+
+	services.Jobs.Register("deposit-intent-expire", "PromptPay: ปฏิเสธคำขอฝาก QR ที่เลยเวลาชำระและไม่มียอดเข้า", "15 * * * *", "",
+		func(cfg models.JobConfig) error {
+			return services.RunDepositIntentExpiry()
+		})
+
+	services.Jobs.Register("payment-sync", "Payment Gateway: ถามสถานะคำสั่งฝาก/จ่ายที่ยังไม่ได้รับ Webhook", "*/5 * * * *", "",
+		func(cfg models.JobConfig) error {
+			return services.RunPaymentSync()
+		})
+
+	if err := services.Jobs.Start(); err != nil {
 		log.Fatalf("❌ [Cron] Error: %v", err)
 	}
-
-	c.Start()
-	log.Println("🚀 Cron System: Active (Settlement & Sync)")
+	log.Println("🚀 Cron System: Active (Job Registry)")
 
 	// 6. Start Server
 	port := os.Getenv("PORT")
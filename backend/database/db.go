@@ -54,18 +54,10 @@ func InitDB() {
 	DB.Exec("ALTER TABLE IF EXISTS matches DROP CONSTRAINT IF EXISTS fk_bet_slips_match")
 
 	// 2. [สำคัญ] รัน AutoMigrate ก่อน เพื่อสร้างตารางให้เสร็จ
-	DB.AutoMigrate(
-		&models.User{},
-		&models.BetSlip{},
-		&models.ParlayTicket{},
-		&models.ParlayItem{},
-		&models.Transaction{},
-		&models.Match{},
-		&models.BankAccount{},
-		&models.SystemSetting{},
-		&models.BetSlip{},
-		&models.BetItem{},
-	)
+	DB.AutoMigrate(Models()...)
+
+	// แก้ข้อมูลเดิมที่ค้างจากเวอร์ชันก่อน (แต่ละขั้นรันครั้งเดียว)
+	runMigrations()
 
 	// 3. หลังจากมีตารางแล้ว ค่อยเช็ค Column (ถ้า AutoMigrate ทำงานปกติ ตัวนี้อาจไม่จำเป็นแล้วครับ)
 	FixMissingColumns()
@@ -106,9 +98,55 @@ func seedAdmin() {
 			Username: "TideKung",
 			Password: string(hashedPassword),
 			Role:     "admin",
-			Credit:   10000,
+			Credit:   models.NewMoney(10000),
 		}
 		DB.Create(&admin)
 		log.Println("✅ Default Admin 'TideKung' created!")
 	}
 }
+
+// Models: ทุกตารางที่ AutoMigrate สร้าง (ใช้ร่วมกับเทสต์ที่ต่อ Postgres จริง)
+func Models() []interface{} {
+	return []interface{}{
+		&models.User{},
+		&models.BetSlip{},
+		&models.ParlayTicket{},
+		&models.ParlayItem{},
+		&models.Transaction{},
+		&models.Match{},
+		&models.BankAccount{},
+		&models.SystemSetting{},
+		&models.BetSlip{},
+		&models.BetItem{},
+		&models.CommissionRebate{},
+		&models.ShareAllocation{},
+		&models.JobConfig{},
+		&models.LedgerJournal{},
+		&models.LedgerEntry{},
+		&models.BalanceDrift{},
+		&models.AgentPeriod{},
+		&models.AgentInvoice{},
+		&models.AgentInvoicePayment{},
+		&models.CurrencyLimit{},
+		&models.ExchangeRate{},
+		&models.Promotion{},
+		&models.BonusGrant{},
+		&models.ReferralCode{},
+		&models.ReferralAttribution{},
+		&models.CashbackTier{},
+		&models.CashbackBatch{},
+		&models.CashbackItem{},
+		&models.SlipFingerprint{},
+		&models.SlipAccessLog{},
+		&models.DepositIntent{},
+		&models.UserBankAssignment{},
+		&models.WithdrawalStep{},
+		&models.Notification{},
+		&models.BankStatementMapping{},
+		&models.BankStatementImport{},
+		&models.BankStatementLine{},
+		&models.PaymentOrder{},
+		&models.PaymentWebhookEvent{},
+		&models.SchemaMigration{},
+	}
+}
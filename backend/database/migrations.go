@@ -0,0 +1,67 @@
+package database
+
+import (
+	"log"
+	"time"
+
+	"github.com/PawornpratKongdaeng/soccer/models"
+	"gorm.io/gorm"
+)
+
+// migration: แก้ข้อมูลเดิมหลัง AutoMigrate 1 ขั้น (รันครั้งเดียวต่อฐานข้อมูล บันทึกไว้ใน schema_migrations)
+type migration struct {
+	Version string
+	Run     func(tx *gorm.DB) error
+}
+
+// migrations: เรียงตามลำดับที่ต้องรัน ห้ามแก้ Version หรือลบขั้นที่ออกไปแล้ว (เพิ่มต่อท้ายเท่านั้น)
+var migrations = []migration{
+	{
+		// ดึงเครดิตคืนโดย Agent/Admin เคยบันทึกเป็น withdraw ที่อนุมัติแล้ว = ปนในคิวจ่ายเงิน
+		// แยกเป็น transfer_out ก่อน แล้วรายการถอนที่อนุมัติก่อนมีขั้นตอน "paid" (ตัดยอดไปแล้วตอนอนุมัติ) = จ่ายแล้ว
+		Version: "2026_10_withdraw_pullback_and_paid",
+		Run: func(tx *gorm.DB) error {
+			if err := tx.Exec(`UPDATE transactions SET type = 'transfer_out', status = 'approved' WHERE type = 'withdraw'
+				AND journal_id IN (SELECT id FROM ledger_journals WHERE type = 'transfer')`).Error; err != nil {
+				return err
+			}
+			return tx.Exec(`UPDATE transactions SET status = 'paid' WHERE type = 'withdraw' AND status = 'approved'
+				AND NOT EXISTS (SELECT 1 FROM withdrawal_steps s WHERE s.transaction_id = transactions.id)`).Error
+		},
+	},
+	{
+		// บัญชีรับเงินของสมาชิกที่มีอยู่ก่อนมีการยืนยัน = ยืนยันแล้ว (ตั้งใหม่หลังจากนี้จะมี bank_changed_at เสมอ)
+		Version: "2026_10_bank_verified_backfill",
+		Run: func(tx *gorm.DB) error {
+			return tx.Exec("UPDATE users SET bank_verified_at = created_at WHERE bank_account <> '' AND bank_verified_at IS NULL AND bank_changed_at IS NULL").Error
+		},
+	},
+	{
+		// งานปิดรอบรายสัปดาห์ยึดเวลาไทย (ค่าเดิมใช้เวลาเครื่อง) ตรงกับขอบรอบของ AgentPeriodBounds
+		// แก้เฉพาะค่าเริ่มต้นเดิม ตารางที่ Admin ตั้งเองไม่แตะ
+		Version: "2026_10_weekly_jobs_bangkok_tz",
+		Run: func(tx *gorm.DB) error {
+			return tx.Exec("UPDATE job_configs SET schedule = 'CRON_TZ=Asia/Bangkok ' || schedule WHERE name IN ('credit-clear', 'agent-period') AND schedule IN ('0 6 * * 1', '0 7 * * 1')").Error
+		},
+	},
+}
+
+// runMigrations: รันขั้นที่ยังไม่เคยรัน ทีละขั้นใน Transaction เดียวกับการบันทึก Version
+func runMigrations() {
+	for _, m := range migrations {
+		err := DB.Transaction(func(tx *gorm.DB) error {
+			var count int64
+			if err := tx.Model(&models.SchemaMigration{}).Where("version = ?", m.Version).Count(&count).Error; err != nil || count > 0 {
+				return err
+			}
+			if err := m.Run(tx); err != nil {
+				return err
+			}
+			log.Printf("🛠️ Migration %s applied", m.Version)
+			return tx.Create(&models.SchemaMigration{Version: m.Version, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			log.Fatalf("❌ Migration %s failed: %v", m.Version, err)
+		}
+	}
+}
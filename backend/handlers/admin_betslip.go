@@ -66,7 +66,7 @@ func GetAdminBetSlips(c *fiber.Ctx) error {
 		VoucherID   string            `json:"voucher_id"`
 		Username    string            `json:"username"`
 		Remark      string            `json:"remark"`
-		TotalAmount float64           `json:"total_amount"`
+		TotalAmount models.Money      `json:"total_amount"`
 		BetDate     time.Time         `json:"bet_date"`
 		Action      string            `json:"action"`
 		Items       []BetItemResponse `json:"items"`
@@ -1,15 +1,18 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 
 	"github.com/PawornpratKongdaeng/soccer/database"
 	"github.com/PawornpratKongdaeng/soccer/models"
+	"github.com/PawornpratKongdaeng/soccer/services"
 	"github.com/gofiber/fiber/v2"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // ==========================================
@@ -17,20 +20,51 @@ import (
 // ==========================================
 
 type FinanceSummaryResponse struct {
-	TotalDeposit  float64 `json:"total_deposit"`
-	TotalWithdraw float64 `json:"total_withdraw"`
+	BaseCurrency  string                     `json:"base_currency"`
+	TotalDeposit  models.Money               `json:"total_deposit"`  // แปลงเป็นสกุลหลักแล้ว
+	TotalWithdraw models.Money               `json:"total_withdraw"` // แปลงเป็นสกุลหลักแล้ว
+	ByCurrency    map[string]CurrencyFinance `json:"by_currency"`    // ยอดตามสกุลจริงของกระเป๋า
 }
 
-// GetAdminBank: ดึงข้อมูลบัญชีธนาคารของเว็บ (ใช้ ID 1)
+type CurrencyFinance struct {
+	Deposit  models.Money `json:"deposit"`
+	Withdraw models.Money `json:"withdraw"`
+}
+
+// GetAdminBank: บัญชีรับเงินที่ให้โอนเข้า
+// สมาชิกที่ Login = บัญชีที่แจกให้ตามกลยุทธ์หมุนเวียน, ผู้เยี่ยมชม = บัญชีแรกที่เปิดใช้ (ไม่แจก)
 func GetAdminBank(c *fiber.Ctx) error {
+	if user, ok := c.Locals("user").(*models.User); ok {
+		bank, err := services.AssignBankAccount(database.DB, user)
+		if errors.Is(err, services.ErrNoBankAccount) {
+			return c.Status(404).JSON(fiber.Map{"error": "ขณะนี้ยังไม่มีบัญชีรับเงินที่เปิดใช้ กรุณาติดต่อแอดมิน"})
+		}
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "ดึงบัญชีรับเงินไม่สำเร็จ"})
+		}
+		return c.JSON(publicBank(bank))
+	}
+
 	var bank models.BankAccount
-	if err := database.DB.First(&bank, 1).Error; err != nil {
+	if err := database.DB.Where("is_active = ?", true).Order("id").First(&bank).Error; err != nil {
 		return c.Status(404).JSON(fiber.Map{"error": "ยังไม่ได้ตั้งค่าบัญชีธนาคาร"})
 	}
-	return c.JSON(bank)
+	return c.JSON(publicBank(&bank))
 }
 
-// UpdateAdminBank: อัปเดตบัญชีธนาคารเว็บ
+// publicBank: ข้อมูลบัญชีรับเงินที่ให้สมาชิกเห็น (ไม่ส่งวงเงิน/กลุ่ม/หมายเหตุของ Admin)
+func publicBank(bank *models.BankAccount) fiber.Map {
+	return fiber.Map{
+		"id":             bank.ID,
+		"bank_name":      bank.BankName,
+		"account_name":   bank.AccountName,
+		"account_number": bank.AccountNumber,
+		"promptpay_id":   bank.PromptPayID,
+		"is_active":      bank.IsActive,
+	}
+}
+
+// UpdateAdminBank: อัปเดตบัญชีธนาคารเว็บ (ของเดิม แก้บัญชีแรก) หลายบัญชีใช้ /admin/bank-accounts
 func UpdateAdminBank(c *fiber.Ctx) error {
 	var req models.BankAccount
 	if err := c.BodyParser(&req); err != nil {
@@ -38,12 +72,12 @@ func UpdateAdminBank(c *fiber.Ctx) error {
 	}
 
 	var bank models.BankAccount
-	result := database.DB.First(&bank, 1)
+	result := database.DB.Order("id").First(&bank)
 
-	bank.ID = 1
 	bank.BankName = req.BankName
 	bank.AccountName = req.AccountName
 	bank.AccountNumber = req.AccountNumber
+	bank.PromptPayID = req.PromptPayID
 
 	if result.Error != nil {
 		if err := database.DB.Create(&bank).Error; err != nil {
@@ -58,17 +92,50 @@ func UpdateAdminBank(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{"message": "อัปเดตบัญชีธนาคารสำเร็จ", "data": bank})
 }
 
-// GetFinanceSummary: สรุปยอดเงินฝาก-ถอนทั้งหมด
+// GetFinanceSummary: สรุปยอดเงินฝาก-ถอนทั้งหมด (รวมทุกสกุลเป็นสกุลหลักด้วยเรทสิ้นวันของแต่ละรายการ)
 func GetFinanceSummary(c *fiber.Ctx) error {
-	var summary FinanceSummaryResponse
-
-	database.DB.Model(&models.Transaction{}).
-		Where("type = ? AND status = ?", "deposit", "approved").
-		Select("COALESCE(SUM(amount), 0)").Scan(&summary.TotalDeposit)
+	type dayRow struct {
+		Currency string
+		Type     string
+		Day      time.Time
+		Total    models.Money
+	}
+	var rows []dayRow
+	day := services.BangkokDaySQL("transactions.created_at")
+	if err := database.DB.Table("transactions").
+		Select("users.currency, transactions.type, "+day+" AS day, COALESCE(SUM(transactions.amount), 0) AS total").
+		Joins("JOIN users ON users.id = transactions.user_id").
+		Where("(transactions.type = ? AND transactions.status = ?) OR (transactions.type = ? AND transactions.status = ?)",
+			"deposit", "approved", "withdraw", "paid"). // ถอนนับเมื่อโอนเงินจริงแล้ว
+		Group("users.currency, transactions.type, " + day).
+		Scan(&rows).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "ดึงข้อมูลไม่สำเร็จ"})
+	}
+
+	book, err := services.LoadRateBook(database.DB)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "ดึงข้อมูลไม่สำเร็จ"})
+	}
 
-	database.DB.Model(&models.Transaction{}).
-		Where("type = ? AND status = ?", "withdraw", "approved").
-		Select("COALESCE(SUM(amount), 0)").Scan(&summary.TotalWithdraw)
+	summary := FinanceSummaryResponse{
+		BaseCurrency: models.BaseCurrency,
+		ByCurrency:   make(map[string]CurrencyFinance),
+	}
+	for _, r := range rows {
+		base, err := book.DayToBase(r.Total, r.Currency, r.Day)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "ยังไม่ได้ตั้งอัตราแลกเปลี่ยน (" + err.Error() + ")"})
+		}
+		byCurrency := summary.ByCurrency[r.Currency]
+		if r.Type == "deposit" {
+			summary.TotalDeposit += base
+			byCurrency.Deposit += r.Total
+		} else {
+			summary.TotalWithdraw += base
+			byCurrency.Withdraw += r.Total
+		}
+		summary.ByCurrency[r.Currency] = byCurrency
+	}
 
 	return c.JSON(summary)
 }
@@ -80,8 +147,8 @@ func GetFinanceSummary(c *fiber.Ctx) error {
 // GetPendingTransactions: ดึงรายการรอตรวจสอบ (ฝาก/ถอน)
 func GetPendingTransactions(c *fiber.Ctx) error {
 	var transactions []models.Transaction
-	// Preload User เพื่อให้เห็นชื่อคนทำรายการ
-	result := database.DB.Preload("User").Where("status = ?", "pending").Order("created_at desc").Find(&transactions)
+	// Preload User เพื่อให้เห็นชื่อคนทำรายการ + สลิปที่ซ้ำกับรายการเดิม (พร้อมรายการเดิมที่ตรงกัน)
+	result := database.DB.Preload("User").Preload("Slip.DuplicateOf.User").Preload("Steps.Admin").Where("status = ?", "pending").Order("created_at desc").Find(&transactions)
 
 	if result.Error != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "ดึงข้อมูลล้มเหลว"})
@@ -99,10 +166,15 @@ func GetTransactionHistory(c *fiber.Ctx) error {
 // ApproveTransaction: อนุมัติ (ฝาก=เติมเงิน, ถอน=เปลี่ยนสถานะ)
 func ApproveTransaction(c *fiber.Ctx) error {
 	txID := c.Params("id")
+	adminID := GetUserID(c)
+	var req struct {
+		OverrideReason string `json:"override_reason"` // จำเป็นเมื่อสลิปฝากติดธงซ้ำ
+	}
+	c.BodyParser(&req)
 
 	return database.DB.Transaction(func(tx *gorm.DB) error {
 		var transaction models.Transaction
-		if err := tx.First(&transaction, txID).Error; err != nil {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&transaction, txID).Error; err != nil {
 			return c.Status(404).JSON(fiber.Map{"error": "ไม่พบรายการ"})
 		}
 
@@ -110,30 +182,61 @@ func ApproveTransaction(c *fiber.Ctx) error {
 			return c.Status(400).JSON(fiber.Map{"error": "รายการนี้ดำเนินการไปแล้ว"})
 		}
 
-		// ฝากเงิน: เพิ่มเครดิต
-		if transaction.Type == "deposit" {
-			if err := tx.Model(&models.User{}).Where("id = ?", transaction.UserID).
-				Update("credit", gorm.Expr("credit + ?", transaction.Amount)).Error; err != nil {
+		var bonus *models.BonusGrant
+		switch transaction.Type {
+		case "deposit":
+			// สลิปซ้ำกับรายการเดิม: ต้องมีเหตุผลจาก Admin ก่อน
+			if err := services.CheckSlipApproval(tx, transaction.ID, strings.TrimSpace(req.OverrideReason), adminID); err != nil {
+				return slipApprovalError(c, err)
+			}
+			// ฝากเงิน: เพิ่มเครดิต + โบนัสโปร + รางวัลแนะนำ
+			grant, err := services.ApproveDeposit(tx, &transaction, &adminID)
+			if err != nil {
 				return err
 			}
+			bonus = grant
+		case "withdraw":
+			// ถอนเงิน: อนุมัติยังไม่ตัดยอดพัก (ตัดตอนบันทึกว่าโอนแล้วที่ /admin/withdrawals/:id/paid)
+			remaining, err := services.ApproveWithdrawal(tx, &transaction, adminID, time.Now())
+			if err != nil {
+				return withdrawalError(c, err)
+			}
+			if remaining > 0 {
+				return c.JSON(fiber.Map{
+					"message":             fmt.Sprintf("บันทึกการอนุมัติแล้ว รอผู้อนุมัติอีก %d คน", remaining),
+					"remaining_approvals": remaining,
+				})
+			}
+			return c.JSON(fiber.Map{"message": "อนุมัติรายการถอนแล้ว รอโอนเงิน", "remaining_approvals": 0})
 		}
-		// ถอนเงิน: ตัดเครดิตไปแล้วตอนแจ้งถอน แค่อัปเดตสถานะ
 
 		transaction.Status = "approved"
 		if err := tx.Save(&transaction).Error; err != nil {
 			return err
 		}
-		return c.JSON(fiber.Map{"message": "อนุมัติรายการสำเร็จ"})
+		return c.JSON(fiber.Map{"message": "อนุมัติรายการสำเร็จ", "bonus": bonus})
 	})
 }
 
+// slipApprovalError: สลิปติดธงซ้ำ = 409 ให้หน้า Admin ถามเหตุผลแล้วส่ง override_reason มาใหม่
+func slipApprovalError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, services.ErrDuplicateSlip) {
+		return c.Status(409).JSON(fiber.Map{
+			"error":         "สลิปนี้ซ้ำกับรายการฝากเดิม กรุณาตรวจสอบและระบุเหตุผลก่อนอนุมัติ (" + err.Error() + ")",
+			"need_override": true,
+		})
+	}
+	return err
+}
+
 // RejectTransaction: ปฏิเสธ (ถ้าถอนต้องคืนเงิน)
 func RejectTransaction(c *fiber.Ctx) error {
 	txID := c.Params("id")
+	adminID := GetUserID(c)
 
 	return database.DB.Transaction(func(tx *gorm.DB) error {
 		var transaction models.Transaction
-		if err := tx.First(&transaction, txID).Error; err != nil {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&transaction, txID).Error; err != nil {
 			return c.Status(404).JSON(fiber.Map{"error": "ไม่พบรายการ"})
 		}
 
@@ -141,10 +244,9 @@ func RejectTransaction(c *fiber.Ctx) error {
 			return c.Status(400).JSON(fiber.Map{"error": "รายการนี้ดำเนินการไปแล้ว"})
 		}
 
-		// ถ้าเป็นถอนเงิน แล้วปฏิเสธ -> ต้องคืนเงินลูกค้า
+		// ถ้าเป็นถอนเงิน แล้วปฏิเสธ -> ปล่อยยอดพักคืนยอดใช้ได้ (อยู่ใน Transaction เดียวกับการเปลี่ยนสถานะ)
 		if transaction.Type == "withdraw" {
-			if err := tx.Model(&models.User{}).Where("id = ?", transaction.UserID).
-				Update("credit", gorm.Expr("credit + ?", transaction.Amount)).Error; err != nil {
+			if err := services.PostWithdrawRefund(tx, &transaction, &adminID); err != nil {
 				return err
 			}
 		}
@@ -157,74 +259,6 @@ func RejectTransaction(c *fiber.Ctx) error {
 	})
 }
 
-// RequestWithdraw: User แจ้งถอนเงิน
-func RequestWithdraw(c *fiber.Ctx) error {
-	type WithdrawReq struct {
-		Amount float64 `json:"amount"`
-	}
-	var req WithdrawReq
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "ข้อมูลไม่ถูกต้อง"})
-	}
-
-	// ดึง UserID จาก JWT Middleware
-	userIDInterface := c.Locals("user_id")
-	if userIDInterface == nil {
-		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
-	}
-
-	// แปลง Interface เป็น uint
-	var userID uint
-	switch v := userIDInterface.(type) {
-	case float64:
-		userID = uint(v)
-	case int:
-		userID = uint(v)
-	case uint:
-		userID = v
-	default:
-		return c.Status(500).JSON(fiber.Map{"error": "User ID Error"})
-	}
-
-	tx := database.DB.Begin()
-
-	var user models.User
-	if err := tx.First(&user, userID).Error; err != nil {
-		tx.Rollback()
-		return c.Status(404).JSON(fiber.Map{"error": "ไม่พบผู้ใช้งาน"})
-	}
-
-	if req.Amount < 100 {
-		tx.Rollback()
-		return c.Status(400).JSON(fiber.Map{"error": "ถอนขั้นต่ำ 100 บาท"})
-	}
-	if user.Credit < req.Amount {
-		tx.Rollback()
-		return c.Status(400).JSON(fiber.Map{"error": "เครดิตไม่เพียงพอ"})
-	}
-
-	// ตัดเครดิตทันที
-	user.Credit -= req.Amount
-	if err := tx.Save(&user).Error; err != nil {
-		tx.Rollback()
-		return c.Status(500).JSON(fiber.Map{"error": "ตัดเครดิตล้มเหลว"})
-	}
-
-	newTx := models.Transaction{
-		UserID: userID,
-		Amount: req.Amount,
-		Type:   "withdraw",
-		Status: "pending",
-	}
-	if err := tx.Create(&newTx).Error; err != nil {
-		tx.Rollback()
-		return c.Status(500).JSON(fiber.Map{"error": "บันทึกรายการล้มเหลว"})
-	}
-
-	tx.Commit()
-	return c.JSON(fiber.Map{"message": "แจ้งถอนเงินสำเร็จ", "new_credit": user.Credit})
-}
-
 // ==========================================
 // 3. หมวดจัดการ User (Admin Manage Users)
 // ==========================================
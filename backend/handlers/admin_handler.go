@@ -7,6 +7,7 @@ import (
 
 	"github.com/PawornpratKongdaeng/soccer/database"
 	"github.com/PawornpratKongdaeng/soccer/models"
+	"github.com/PawornpratKongdaeng/soccer/services"
 	"github.com/gofiber/fiber/v2"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
@@ -71,9 +72,15 @@ func UpdateUser(c *fiber.Ctx) error {
 	}
 
 	// รับข้อมูลที่จะอัปเดต (เช่น ชื่อ, นามสกุล, เบอร์โทร)
+	old := user
 	if err := c.BodyParser(&user); err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "ข้อมูลไม่ถูกต้อง"})
 	}
+	// สถานะยืนยันบัญชีรับเงินแก้ผ่าน /users/:id/bank เท่านั้น เปลี่ยนเลขบัญชีตรงนี้ = ต้องยืนยันใหม่
+	newBankName, newBankAccount := user.BankName, user.BankAccount
+	user.BankName, user.BankAccount = old.BankName, old.BankAccount
+	user.BankVerifiedAt, user.BankChangedAt = old.BankVerifiedAt, old.BankChangedAt
+	services.TrackBankChange(&user, newBankName, newBankAccount, time.Now())
 
 	if err := database.DB.Save(&user).Error; err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "อัปเดตข้อมูลไม่สำเร็จ"})
@@ -102,9 +109,9 @@ func AdjustUserBalance(c *fiber.Ctx) error {
 	targetUserID := c.Params("id")
 
 	type Request struct {
-		Amount float64 `json:"amount"`
-		Type   string  `json:"type"`
-		Note   string  `json:"note"`
+		Amount models.Money `json:"amount"`
+		Type   string       `json:"type"`
+		Note   string       `json:"note"`
 	}
 
 	var body Request
@@ -126,6 +133,15 @@ func AdjustUserBalance(c *fiber.Ctx) error {
 			return fmt.Errorf("ไม่พบข้อมูลลูกค้า")
 		}
 
+		if agent.Currency != targetUser.Currency {
+			return fmt.Errorf("สกุลเงินของกระเป๋าไม่ตรงกัน (%s / %s)", agent.Currency, targetUser.Currency)
+		}
+
+		note := fmt.Sprintf("[AGENT:%s] %s", agent.Username, body.Note)
+		ledger := services.Journal{Type: "transfer", Note: note, CreatedBy: &agentID}
+
+		var journal *models.LedgerJournal
+		var err error
 		if body.Type == "deposit" {
 			// เช็คว่า Agent มีเงินพอให้หักไหม
 			if agent.Credit < body.Amount {
@@ -133,29 +149,31 @@ func AdjustUserBalance(c *fiber.Ctx) error {
 			}
 
 			// --- ขั้นตอนการโยกเงิน ---
-			// หักเงิน Agent
-			tx.Model(&agent).Update("credit", gorm.Expr("credit - ?", body.Amount))
-			// เพิ่มเงิน User
-			tx.Model(&targetUser).Update("credit", gorm.Expr("credit + ?", body.Amount))
-
+			// หักเงิน Agent -> เพิ่มเงิน User
+			journal, err = services.PostTransfer(tx, agent.ID, targetUser.ID, body.Amount, ledger)
 		} else {
 			// กรณี Withdraw (ดึงเงินลูกค้ากลับเข้ากระเป๋า Agent)
 			if targetUser.Credit < body.Amount {
 				return fmt.Errorf("ยอดเงินลูกค้าไม่เพียงพอให้ดึงกลับ")
 			}
-			tx.Model(&targetUser).Update("credit", gorm.Expr("credit - ?", body.Amount))
-			tx.Model(&agent).Update("credit", gorm.Expr("credit + ?", body.Amount))
+			journal, err = services.PostTransfer(tx, targetUser.ID, agent.ID, body.Amount, ledger)
+		}
+		if err != nil {
+			return err
 		}
 
-		// 4. บันทึก Transaction Log (ฝั่ง User)
-		tx.Create(&models.Transaction{
-			UserID:       targetUser.ID,
-			Amount:       body.Amount,
-			Type:         body.Type,
-			Status:       "approved",
-			BalanceAfter: targetUser.Credit + body.Amount, // คำนวณยอดหลังทำรายการ
-			Note:         fmt.Sprintf("[AGENT:%s] %s", agent.Username, body.Note),
-		})
+		// 4. บันทึก Transaction Log (ฝั่ง User และฝั่ง Agent)
+		if _, err := services.RecordTransaction(tx, journal, targetUser.ID, models.Transaction{
+			AdminID: &agentID,
+			Type:    creditTransferType(body.Type),
+			Status:  "approved",
+			Note:    note,
+		}); err != nil {
+			return err
+		}
+		if err := services.RecordTransferSide(tx, journal, agent.ID, &agentID, note); err != nil {
+			return err
+		}
 
 		return c.JSON(fiber.Map{"message": "ดำเนินการเรียบร้อย"})
 	})
@@ -194,13 +212,14 @@ func CreateUser(c *fiber.Ctx) error {
 
 	// Struct สำหรับรับค่า
 	type CreateUserRequest struct {
-		Username  string  `json:"username"`
-		Password  string  `json:"password"`
-		FirstName string  `json:"first_name"`
-		LastName  string  `json:"last_name"`
-		Phone     string  `json:"phone"`
-		Role      string  `json:"role"`   // admin ส่งมาเป็น 'agent' หรือ 'user'
-		Credit    float64 `json:"credit"` // เผื่อกรณีสร้าง Agent แล้วอยากใส่เครดิตตั้งต้นเลย
+		Username  string       `json:"username"`
+		Password  string       `json:"password"`
+		FirstName string       `json:"first_name"`
+		LastName  string       `json:"last_name"`
+		Phone     string       `json:"phone"`
+		Role      string       `json:"role"`     // admin ส่งมาเป็น 'agent' หรือ 'user'
+		Credit    models.Money `json:"credit"`   // เผื่อกรณีสร้าง Agent แล้วอยากใส่เครดิตตั้งต้นเลย
+		Currency  string       `json:"currency"` // Admin เลือกได้ ส่วน Agent สร้าง = สกุลของ Agent
 	}
 
 	var body CreateUserRequest
@@ -242,6 +261,16 @@ func CreateUser(c *fiber.Ctx) error {
 		return c.Status(403).JSON(fiber.Map{"error": "ไม่มีสิทธิ์สร้างบัญชี"})
 	}
 
+	// สกุลเงินของกระเป๋า (ลูกสายของ Agent ต้องสกุลเดียวกับ Agent)
+	var creator models.User
+	if err := database.DB.Select("id", "role", "currency").First(&creator, creatorID).Error; err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized: ไม่พบข้อมูลผู้ใช้งาน"})
+	}
+	currency, err := services.NewMemberCurrency(creator, body.Currency)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "สกุลเงินไม่ถูกต้อง"})
+	}
+
 	// 5. Hash Password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(body.Password), 14)
 	if err != nil {
@@ -255,14 +284,40 @@ func CreateUser(c *fiber.Ctx) error {
 		FirstName: body.FirstName,
 		LastName:  body.LastName,
 		Phone:     body.Phone,
-		Role:      targetRole,  // ✅ Role ที่ผ่าน Logic แล้ว
-		ParentID:  parentID,    // ✅ ParentID ที่ถูกต้อง
-		Credit:    body.Credit, // ใส่เครดิตตั้งต้น (ถ้ามี)
+		Role:      targetRole, // ✅ Role ที่ผ่าน Logic แล้ว
+		ParentID:  parentID,   // ✅ ParentID ที่ถูกต้อง
+		Currency:  currency,
 		Status:    "active",
 	}
 
-	// 7. บันทึกลง Database
-	if err := database.DB.Create(&newUser).Error; err != nil {
+	// 7. บันทึกลง Database (เครดิตตั้งต้นลงบัญชีผ่าน Ledger ใน Transaction เดียวกัน)
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&newUser).Error; err != nil {
+			return err
+		}
+		if body.Credit <= 0 {
+			return nil
+		}
+		journal, err := services.PostWallet(tx, newUser.ID, body.Credit, models.LedgerAccountAdjustment, services.Journal{
+			Type:      "opening",
+			Note:      "เครดิตตั้งต้นตอนสร้างบัญชี",
+			CreatedBy: &creatorID,
+		})
+		if err != nil {
+			return err
+		}
+		opening, err := services.RecordTransaction(tx, journal, newUser.ID, models.Transaction{
+			AdminID: &creatorID,
+			Type:    "adjustment",
+			Note:    journal.Note,
+		})
+		if err != nil {
+			return err
+		}
+		newUser.Credit = opening.BalanceAfter
+		return nil
+	})
+	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Database error: " + err.Error()})
 	}
 
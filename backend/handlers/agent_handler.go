@@ -3,9 +3,11 @@ package handlers
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/PawornpratKongdaeng/soccer/database"
 	"github.com/PawornpratKongdaeng/soccer/models"
+	"github.com/PawornpratKongdaeng/soccer/services"
 	"github.com/gofiber/fiber/v2"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
@@ -27,8 +29,9 @@ func CreateDownline(c *fiber.Ctx) error {
 		FirstName string  `json:"first_name"`
 		LastName  string  `json:"last_name"`
 		Phone     string  `json:"phone"`
-		Share     float64 `json:"share"` // % ถือสู้
-		Com       float64 `json:"com"`   // % ค่าคอม
+		Share     float64 `json:"share"`    // % ถือสู้
+		Com       float64 `json:"com"`      // % ค่าคอม
+		Currency  string  `json:"currency"` // Admin เลือกได้, Master/Agent ได้สกุลของตัวเองเสมอ
 	}
 
 	var req Request
@@ -61,6 +64,11 @@ func CreateDownline(c *fiber.Ctx) error {
 		}
 	}
 
+	currency, err := services.NewMemberCurrency(*creator, req.Currency)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "สกุลเงินต้องตรงกับต้นสาย (" + creator.Currency + ")"})
+	}
+
 	// เข้ารหัส Password
 	hashed, _ := bcrypt.GenerateFromPassword([]byte(req.Password), 10)
 
@@ -75,6 +83,7 @@ func CreateDownline(c *fiber.Ctx) error {
 		Share:     req.Share,
 		Com:       req.Com,
 		Credit:    0,
+		Currency:  currency,
 		Status:    "active",
 	}
 
@@ -90,9 +99,9 @@ func HandleCreditTransfer(c *fiber.Ctx) error {
 	parentID := c.Locals("user_id").(uint) // ID ของคนโอน (Agent)
 
 	type Request struct {
-		ToUserID uint    `json:"to_user_id"`
-		Amount   float64 `json:"amount"`
-		Type     string  `json:"type"` // deposit หรือ withdraw
+		ToUserID uint         `json:"to_user_id"`
+		Amount   models.Money `json:"amount"`
+		Type     string       `json:"type"` // deposit หรือ withdraw
 	}
 
 	var req Request
@@ -116,36 +125,59 @@ func HandleCreditTransfer(c *fiber.Ctx) error {
 		if parent.Role != "admin" && (child.ParentID == nil || *child.ParentID != parent.ID) {
 			return errors.New("สมาชิกท่านนี้ไม่ได้อยู่ในสายงานของท่าน")
 		}
+		if parent.Role != "admin" && parent.Currency != child.Currency {
+			return errors.New("สกุลเงินของกระเป๋าไม่ตรงกัน โอนระหว่างกันไม่ได้")
+		}
 
-		var beforeBal, afterBal float64
-		beforeBal = child.Credit
+		ledger := services.Journal{Type: "transfer", CreatedBy: &parent.ID}
 
+		var journal *models.LedgerJournal
+		var err error
 		if req.Type == "deposit" {
 			// [เติมเงิน]
 			if parent.Role != "admin" && parent.Credit < req.Amount {
 				return errors.New("เครดิตของคุณไม่เพียงพอสำหรับการโอน")
 			}
-			afterBal = child.Credit + req.Amount
 
 			if parent.Role != "admin" {
-				tx.Model(&parent).Update("credit", gorm.Expr("credit - ?", req.Amount))
+				journal, err = services.PostTransfer(tx, parent.ID, child.ID, req.Amount, ledger)
+			} else {
+				// Admin เติมให้ ไม่หักจากกระเป๋า Admin (ออกเครดิตจากบัญชีปรับยอด)
+				journal, err = services.PostWallet(tx, child.ID, req.Amount, models.LedgerAccountAdjustment, ledger)
 			}
-			tx.Model(&child).Update("credit", afterBal)
 
 		} else if req.Type == "withdraw" {
 			// [ดึงเงินกลับ]
 			if child.Credit < req.Amount {
 				return errors.New("เครดิตของลูกข่ายไม่เพียงพอสำหรับการดึงคืน")
 			}
-			afterBal = child.Credit - req.Amount
 
 			if parent.Role != "admin" {
-				tx.Model(&parent).Update("credit", gorm.Expr("credit + ?", req.Amount))
+				journal, err = services.PostTransfer(tx, child.ID, parent.ID, req.Amount, ledger)
+			} else {
+				journal, err = services.PostWallet(tx, child.ID, -req.Amount, models.LedgerAccountAdjustment, ledger)
 			}
-			tx.Model(&child).Update("credit", afterBal)
 		} else {
 			return errors.New("ประเภทรายการไม่ถูกต้อง")
 		}
+		if err != nil {
+			return err
+		}
+		beforeBal, afterBal := services.WalletChange(journal, child.ID)
+
+		// บันทึกประวัติเครดิตของทั้งสองฝั่ง (ฝั่ง Admin ไม่มีกระเป๋าเปลี่ยน จึงไม่มีรายการ)
+		if _, err := services.RecordTransaction(tx, journal, child.ID, models.Transaction{
+			AdminID: &parent.ID,
+			Type:    creditTransferType(req.Type),
+			Status:  "approved",
+		}); err != nil {
+			return err
+		}
+		if parent.Role != "admin" {
+			if err := services.RecordTransferSide(tx, journal, parent.ID, &parent.ID, ""); err != nil {
+				return err
+			}
+		}
 
 		// บันทึก Log การเงิน
 		log := models.CreditLog{
@@ -166,36 +198,34 @@ func HandleCreditTransfer(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{"message": "ทำรายการเครดิตสำเร็จ"})
 }
 
+// creditTransferType: ประเภท Transaction ฝั่งลูกข่ายของการเติม/ดึงเครดิต
+// ดึงคืนไม่ใช่การถอนเงินจริง (ไม่มีบัญชีรับเงิน ไม่พักยอด) จึงบันทึกเป็น transfer_out ไม่ให้เข้าคิวจ่ายเงินถอน
+func creditTransferType(reqType string) string {
+	if reqType == "deposit" {
+		return "deposit"
+	}
+	return "transfer_out"
+}
+
 // --- 3. สรุปยอด Win/Loss และค่าคอม (Settlement Summary) ---
 func GetSettlementSummary(c *fiber.Ctx) error {
-	agentID := c.Locals("user_id").(uint)
-	var agent models.User
-	database.DB.First(&agent, agentID)
+	agentID := GetUserID(c)
 
-	type Result struct {
-		TotalTurnover float64 `json:"total_turnover"`
-		TotalWinLoss  float64 `json:"total_win_loss"`  // ยอดแพ้ชนะรวมของลูกทีม
-		AgentShareAmt float64 `json:"agent_share_amt"` // ส่วนที่ Agent ต้องรับผิดชอบ
-		AgentComAmt   float64 `json:"agent_com_amt"`   // ค่าคอมที่ Agent ได้
-		NetSettlement float64 `json:"net_settlement"`  // ยอดสุทธิที่ต้องเคลียร์
+	// ยอดที่ยังไม่ปิดรอบ (รอบปัจจุบัน + บิลที่เคลียร์หลังปิดรอบก่อน) คิดแบบเดียวกับใบแจ้งยอดตอนปิดรอบ
+	preview, err := services.PreviewAgentInvoice(database.DB, agentID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "ดึงข้อมูลไม่สำเร็จ"})
+	}
+	if preview == nil {
+		preview = &models.AgentInvoice{AgentID: agentID}
 	}
 
-	var res Result
-
-	// Query คำนวณยอดจาก Table Bets โดย Join กับลูกทีม
-	database.DB.Table("users").
-		Select("COALESCE(SUM(bets.amount), 0) as total_turnover, "+
-			"COALESCE(SUM(bets.amount - bets.payout), 0) as total_win_loss").
-		Joins("INNER JOIN bets ON bets.user_id = users.id").
-		Where("users.parent_id = ?", agent.ID).
-		Scan(&res)
-
-	// คำนวณตามสัดส่วนของ Agent
-	res.AgentShareAmt = res.TotalWinLoss * (agent.Share / 100)
-	res.AgentComAmt = res.TotalTurnover * (agent.Com / 100)
-	res.NetSettlement = res.AgentShareAmt + res.AgentComAmt
-
-	return c.JSON(res)
+	start, end := services.AgentPeriodBounds(time.Now())
+	return c.JSON(fiber.Map{
+		"period_start": start,
+		"period_end":   end,
+		"summary":      preview,
+	})
 }
 
 // --- 4. ดึงรายชื่อลูกทีมพร้อม Pagination ---
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/PawornpratKongdaeng/soccer/database"
+	"github.com/PawornpratKongdaeng/soccer/models"
+	"github.com/PawornpratKongdaeng/soccer/services"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// filterInvoices: กรองใบแจ้งยอดตาม ?period_id=&status=
+func filterInvoices(c *fiber.Ctx, query *gorm.DB) *gorm.DB {
+	if periodID := c.Query("period_id"); periodID != "" {
+		query = query.Where("period_id = ?", periodID)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	return query
+}
+
+// GET /api/v3/agent/invoices?as=agent|upline
+// [AGENT/MASTER] ใบแจ้งยอดของตัวเอง (as=agent) หรือของลูกสายที่ต้องเคลียร์กับเรา (as=upline)
+func GetMyInvoices(c *fiber.Ctx) error {
+	userID := GetUserID(c)
+
+	query := database.DB.Model(&models.AgentInvoice{}).Order("id desc")
+	switch c.Query("as") {
+	case "upline":
+		query = query.Where("upline_id = ?", userID)
+	case "agent", "":
+		query = query.Where("agent_id = ?", userID)
+	default:
+		return c.Status(400).JSON(fiber.Map{"error": "ข้อมูลไม่ถูกต้อง"})
+	}
+	query = filterInvoices(c, query)
+
+	var invoices []models.AgentInvoice
+	if err := query.Limit(500).Find(&invoices).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "ดึงข้อมูลไม่สำเร็จ"})
+	}
+	return c.JSON(invoices)
+}
+
+// GET /api/v3/agent/invoices/:id
+// [AGENT/MASTER/ADMIN] รายละเอียดใบแจ้งยอดพร้อมประวัติการชำระ (เฉพาะเจ้าของหรือต้นสาย)
+func GetInvoice(c *fiber.Ctx) error {
+	var invoice models.AgentInvoice
+	if err := database.DB.First(&invoice, c.Params("id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "ไม่พบใบแจ้งยอด"})
+	}
+
+	viewerID := GetUserID(c)
+	if role, _ := c.Locals("role").(string); role != "admin" &&
+		invoice.AgentID != viewerID && (invoice.UplineID == nil || *invoice.UplineID != viewerID) {
+		return c.Status(403).JSON(fiber.Map{"error": "ไม่มีสิทธิ์ดูใบแจ้งยอดนี้"})
+	}
+
+	var period models.AgentPeriod
+	database.DB.First(&period, invoice.PeriodID)
+
+	var payments []models.AgentInvoicePayment
+	database.DB.Where("invoice_id = ?", invoice.ID).Order("id").Find(&payments)
+	if payments == nil {
+		payments = []models.AgentInvoicePayment{}
+	}
+
+	return c.JSON(fiber.Map{
+		"invoice":   invoice,
+		"period":    period,
+		"payments":  payments,
+		"remaining": invoice.Amount.Abs() - invoice.Paid,
+	})
+}
+
+// POST /api/v3/agent/invoices/:id/payments {"amount": 1500, "note": "โอนผ่าน KBank"}
+// [AGENT/MASTER/ADMIN] ฝั่งผู้รับเงินยืนยันการชำระ (ทยอยจ่ายได้ ครบแล้วสถานะเป็น settled)
+func RecordInvoicePayment(c *fiber.Ctx) error {
+	invoiceID, err := c.ParamsInt("id")
+	if err != nil || invoiceID <= 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "รหัสใบแจ้งยอดไม่ถูกต้อง"})
+	}
+
+	var req struct {
+		Amount models.Money `json:"amount"`
+		Note   string       `json:"note"`
+	}
+	if err := c.BodyParser(&req); err != nil || req.Amount <= 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "จำนวนเงินไม่ถูกต้อง"})
+	}
+
+	var recorder models.User
+	if err := database.DB.First(&recorder, GetUserID(c)).Error; err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	invoice, err := services.RecordInvoicePayment(uint(invoiceID), req.Amount, req.Note, recorder)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(404).JSON(fiber.Map{"error": "ไม่พบใบแจ้งยอด"})
+	case errors.Is(err, services.ErrInvoiceNotAllowed):
+		return c.Status(403).JSON(fiber.Map{"error": "ต้องให้ฝั่งผู้รับเงินเป็นผู้ยืนยันการชำระ"})
+	case errors.Is(err, services.ErrInvoiceOverpayment):
+		return c.Status(400).JSON(fiber.Map{"error": "ยอดชำระเกินยอดคงค้าง (" + err.Error() + ")"})
+	case err != nil:
+		return c.Status(500).JSON(fiber.Map{"error": "บันทึกไม่สำเร็จ"})
+	}
+	return c.JSON(fiber.Map{"message": "บันทึกการชำระเรียบร้อย", "invoice": invoice})
+}
+
+// GET /api/v3/admin/agent-periods
+func GetAgentPeriods(c *fiber.Ctx) error {
+	var periods []models.AgentPeriod
+	if err := database.DB.Order("start desc").Limit(100).Find(&periods).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "ดึงข้อมูลไม่สำเร็จ"})
+	}
+	return c.JSON(periods)
+}
+
+// POST /api/v3/admin/agent-periods/close {"start": "2024-01-01"} (ไม่ระบุ = สัปดาห์ที่แล้ว)
+func CloseAgentPeriod(c *fiber.Ctx) error {
+	var req struct {
+		Start string `json:"start"`
+	}
+	c.BodyParser(&req)
+
+	start, _ := services.AgentPeriodBounds(time.Now())
+	start = start.AddDate(0, 0, -7)
+	if req.Start != "" {
+		t, err := time.Parse("2006-01-02", req.Start)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "วันที่ไม่ถูกต้อง"})
+		}
+		start = t
+	}
+
+	adminID := GetUserID(c)
+	period, err := services.CloseAgentPeriod(start, &adminID)
+	switch {
+	case errors.Is(err, services.ErrPeriodNotEnded):
+		return c.Status(400).JSON(fiber.Map{"error": "รอบนี้ยังไม่สิ้นสุด"})
+	case errors.Is(err, services.ErrPeriodClosed):
+		return c.Status(400).JSON(fiber.Map{"error": "รอบนี้ปิดไปแล้ว"})
+	case errors.Is(err, services.ErrPeriodOutOfOrder):
+		return c.Status(400).JSON(fiber.Map{"error": "มีรอบที่ใหม่กว่าปิดไปแล้ว"})
+	case err != nil:
+		return c.Status(500).JSON(fiber.Map{"error": "ปิดรอบไม่สำเร็จ"})
+	}
+	return c.JSON(fiber.Map{"message": "ปิดรอบเรียบร้อย", "period": period})
+}
+
+// GET /api/v3/admin/invoices?period_id=&status=&agent_id=
+func GetAgentInvoices(c *fiber.Ctx) error {
+	query := filterInvoices(c, database.DB.Model(&models.AgentInvoice{}).Order("id desc"))
+	if agentID := c.Query("agent_id"); agentID != "" {
+		query = query.Where("agent_id = ?", agentID)
+	}
+
+	var invoices []models.AgentInvoice
+	if err := query.Limit(500).Find(&invoices).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "ดึงข้อมูลไม่สำเร็จ"})
+	}
+	return c.JSON(invoices)
+}
@@ -7,9 +7,11 @@ import (
 
 	"github.com/PawornpratKongdaeng/soccer/database"
 	"github.com/PawornpratKongdaeng/soccer/models"
+	"github.com/PawornpratKongdaeng/soccer/services"
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 var jwtKey = []byte("SECRET_KEY_NA_KRUB")
@@ -62,12 +64,27 @@ func Register(c *fiber.Ctx) error {
 		FirstName string `json:"first_name"` // จากหน้าเว็บ
 		LastName  string `json:"last_name"`
 		Role      string `json:"role"`
+		Ref       string `json:"ref"` // รหัสแนะนำ (จากลิงก์ ?ref=)
 	}
 
 	var body RegisterRequest
 	if err := c.BodyParser(&body); err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "ข้อมูลไม่ถูกต้อง"})
 	}
+	if body.Ref == "" {
+		body.Ref = c.Query("ref")
+	}
+
+	// 0. รหัสแนะนำ: ผูกต้นสายและสกุลกระเป๋าตามผู้แนะนำ
+	var refCode *models.ReferralCode
+	var referrer *models.User
+	if body.Ref != "" {
+		var err error
+		refCode, referrer, err = services.ResolveReferral(database.DB, body.Ref)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "รหัสแนะนำไม่ถูกต้องหรือถูกปิดใช้งานแล้ว"})
+		}
+	}
 
 	// 1. ตรวจสอบเบอร์โทรซ้ำ
 	if body.Phone != "" {
@@ -116,8 +133,21 @@ func Register(c *fiber.Ctx) error {
 		BankName:    "",
 		BankAccount: "",
 	}
+	if referrer != nil {
+		user.ParentID = services.ReferralParent(referrer)
+		user.Currency = referrer.Currency
+	}
 
-	if err := database.DB.Create(&user).Error; err != nil {
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		if refCode != nil {
+			return services.AttachReferral(tx, &user, refCode, c.IP())
+		}
+		return nil
+	})
+	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "สมัครไม่สำเร็จ: " + err.Error()})
 	}
 
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/PawornpratKongdaeng/soccer/database"
+	"github.com/PawornpratKongdaeng/soccer/models"
+	"github.com/PawornpratKongdaeng/soccer/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+// bankAccountRequest: ช่องที่ Admin แก้ได้ของบัญชีรับเงิน
+type bankAccountRequest struct {
+	BankName         string       `json:"bank_name"`
+	AccountName      string       `json:"account_name"`
+	AccountNumber    string       `json:"account_number"`
+	PromptPayID      string       `json:"promptpay_id"`
+	IsActive         *bool        `json:"is_active"`
+	Group            string       `json:"group"`
+	DailyAmountLimit models.Money `json:"daily_amount_limit"`
+	DailyCountLimit  int          `json:"daily_count_limit"`
+	Note             string       `json:"note"`
+}
+
+func (r *bankAccountRequest) apply(bank *models.BankAccount) bool {
+	if r.BankName == "" || r.AccountName == "" || r.AccountNumber == "" || r.DailyAmountLimit < 0 || r.DailyCountLimit < 0 {
+		return false
+	}
+	bank.BankName = r.BankName
+	bank.AccountName = r.AccountName
+	bank.AccountNumber = r.AccountNumber
+	bank.PromptPayID = r.PromptPayID
+	bank.Group = r.Group
+	bank.DailyAmountLimit = r.DailyAmountLimit
+	bank.DailyCountLimit = r.DailyCountLimit
+	bank.Note = r.Note
+	if r.IsActive != nil {
+		bank.IsActive = *r.IsActive
+	}
+	return true
+}
+
+// GET /api/v3/admin/bank-accounts
+// [ADMIN] บัญชีรับเงินทั้งหมด พร้อมยอดฝากวันนี้เทียบวงเงิน
+func GetBankAccounts(c *fiber.Ctx) error {
+	var banks []models.BankAccount
+	if err := database.DB.Order("id").Find(&banks).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "ดึงข้อมูลไม่สำเร็จ"})
+	}
+	usage, err := services.BankUsageToday(database.DB, time.Now())
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "ดึงข้อมูลไม่สำเร็จ"})
+	}
+
+	rows := make([]fiber.Map, 0, len(banks))
+	for _, b := range banks {
+		rows = append(rows, fiber.Map{"account": b, "today": usage[b.ID]})
+	}
+	return c.JSON(rows)
+}
+
+// POST /api/v3/admin/bank-accounts
+func CreateBankAccount(c *fiber.Ctx) error {
+	var req bankAccountRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "ข้อมูลไม่ถูกต้อง"})
+	}
+	bank := models.BankAccount{IsActive: true}
+	if !req.apply(&bank) {
+		return c.Status(400).JSON(fiber.Map{"error": "กรุณากรอกธนาคาร ชื่อบัญชี เลขบัญชี และวงเงินต้องไม่ติดลบ"})
+	}
+	// is_active มี default:true ต้อง Create ก่อนแล้วค่อยปิด
+	if err := database.DB.Create(&bank).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "สร้างบัญชีไม่สำเร็จ"})
+	}
+	if !bank.IsActive {
+		database.DB.Model(&bank).Update("is_active", false)
+	}
+	return c.JSON(fiber.Map{"message": "เพิ่มบัญชีรับเงินเรียบร้อย", "data": bank})
+}
+
+// PUT /api/v3/admin/bank-accounts/:id
+func UpdateBankAccount(c *fiber.Ctx) error {
+	var bank models.BankAccount
+	if err := database.DB.First(&bank, c.Params("id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "ไม่พบบัญชีรับเงิน"})
+	}
+	var req bankAccountRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "ข้อมูลไม่ถูกต้อง"})
+	}
+	if !req.apply(&bank) {
+		return c.Status(400).JSON(fiber.Map{"error": "กรุณากรอกธนาคาร ชื่อบัญชี เลขบัญชี และวงเงินต้องไม่ติดลบ"})
+	}
+	if err := database.DB.Select("bank_name", "account_name", "account_number", "prompt_pay_id", "is_active",
+		"bank_group", "daily_amount_limit", "daily_count_limit", "note").Save(&bank).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "บันทึกไม่สำเร็จ"})
+	}
+	return c.JSON(fiber.Map{"message": "บันทึกบัญชีรับเงินเรียบร้อย", "data": bank})
+}
+
+// DELETE /api/v3/admin/bank-accounts/:id
+// ลบแบบ soft delete (รายการฝากเดิมยังอ้างถึงได้) สมาชิกที่ถือบัญชีนี้จะได้บัญชีใหม่ตอนเปิดหน้าฝากครั้งถัดไป
+func DeleteBankAccount(c *fiber.Ctx) error {
+	res := database.DB.Delete(&models.BankAccount{}, c.Params("id"))
+	if res.Error != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "ลบไม่สำเร็จ"})
+	}
+	if res.RowsAffected == 0 {
+		return c.Status(404).JSON(fiber.Map{"error": "ไม่พบบัญชีรับเงิน"})
+	}
+	return c.JSON(fiber.Map{"message": "ลบบัญชีรับเงินเรียบร้อย"})
+}
+
+// GET /api/v3/admin/bank-accounts/report?start=2026-01-01&end=2026-01-31
+// [ADMIN] ยอดฝากแยกตามบัญชีรับเงิน (ค่าเริ่มต้น 30 วันล่าสุด)
+func GetBankAccountReport(c *fiber.Ctx) error {
+	from, to, err := services.StatementRange(c.Query("start"), c.Query("end"), time.Now())
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "ช่วงวันที่ไม่ถูกต้อง"})
+	}
+	rows, err := services.BankAccountReport(database.DB, from, to)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "ดึงข้อมูลไม่สำเร็จ"})
+	}
+	return c.JSON(fiber.Map{"start": from, "end": to, "accounts": rows})
+}
+
+// PUT /api/v3/admin/users/:id/bank-group {"bank_group": "vip"}
+// [ADMIN] กลุ่มบัญชีรับเงินของสมาชิก (ใช้เมื่อตั้งกลยุทธ์ group) ว่าง = บัญชีกลาง
+func SetUserBankGroup(c *fiber.Ctx) error {
+	var req struct {
+		BankGroup string `json:"bank_group"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "ข้อมูลไม่ถูกต้อง"})
+	}
+	res := database.DB.Model(&models.User{}).Where("id = ?", c.Params("id")).Update("bank_group", req.BankGroup)
+	if res.Error != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "บันทึกไม่สำเร็จ"})
+	}
+	if res.RowsAffected == 0 {
+		return c.Status(404).JSON(fiber.Map{"error": "ไม่พบผู้ใช้งาน"})
+	}
+	// ให้เลือกบัญชีใหม่ตามกลุ่มตอนเปิดหน้าฝากครั้งถัดไป
+	database.DB.Where("user_id = ?", c.Params("id")).Delete(&models.UserBankAssignment{})
+	return c.JSON(fiber.Map{"message": "บันทึกกลุ่มบัญชีรับเงินเรียบร้อย"})
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/PawornpratKongdaeng/soccer/database"
+	"github.com/PawornpratKongdaeng/soccer/models"
+	"github.com/PawornpratKongdaeng/soccer/services"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// POST /api/v3/admin/statements/import (multipart: file, bank_account_id, bank = KBANK/SCB/...)
+// [ADMIN] นำเข้า Statement ของบัญชีรับเงิน แล้วจับคู่ยอดเข้ากับรายการฝากที่รออยู่
+func ImportBankStatement(c *fiber.Ctx) error {
+	bankAccountID, err := strconv.Atoi(c.FormValue("bank_account_id"))
+	if err != nil || bankAccountID <= 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "กรุณาเลือกบัญชีรับเงิน"})
+	}
+	fh, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "กรุณาแนบไฟล์ Statement (CSV)"})
+	}
+
+	bank := c.FormValue("bank")
+	if bank == "" {
+		var account models.BankAccount
+		if err := database.DB.First(&account, bankAccountID).Error; err != nil {
+			return c.Status(404).JSON(fiber.Map{"error": "ไม่พบบัญชีรับเงิน"})
+		}
+		bank = account.BankName
+	}
+
+	f, err := fh.Open()
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "อ่านไฟล์ไม่สำเร็จ"})
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, 5<<20+1))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "อ่านไฟล์ไม่สำเร็จ"})
+	}
+
+	imp, err := services.ImportStatement(database.DB, uint(bankAccountID), bank, fh.Filename, data, GetUserID(c))
+	switch {
+	case errors.Is(err, services.ErrStatementTooLarge):
+		return c.Status(400).JSON(fiber.Map{"error": "ไฟล์ใหญ่เกินไป (ไม่เกิน 5MB)"})
+	case errors.Is(err, services.ErrStatementBank):
+		return c.Status(400).JSON(fiber.Map{"error": "ไม่รู้จักรูปแบบไฟล์ของธนาคารนี้ กรุณาเลือกธนาคาร (" + err.Error() + ")"})
+	case errors.Is(err, services.ErrStatementEmpty):
+		return c.Status(400).JSON(fiber.Map{"error": "อ่านรายการจากไฟล์ไม่ได้ ตรวจสอบธนาคารที่เลือกหรือรูปแบบคอลัมน์ (" + err.Error() + ")"})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(404).JSON(fiber.Map{"error": "ไม่พบบัญชีรับเงิน"})
+	case err != nil:
+		log.Printf("❌ [Statement] import: %v", err)
+		return c.Status(400).JSON(fiber.Map{"error": "นำเข้าไฟล์ไม่สำเร็จ (" + err.Error() + ")"})
+	}
+	return c.JSON(fiber.Map{"message": "นำเข้า Statement เรียบร้อย", "import": imp})
+}
+
+// GET /api/v3/admin/statements/imports
+func GetStatementImports(c *fiber.Ctx) error {
+	var imports []models.BankStatementImport
+	if err := database.DB.Order("id desc").Limit(100).Find(&imports).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "ดึงข้อมูลไม่สำเร็จ"})
+	}
+	return c.JSON(imports)
+}
+
+// GET /api/v3/admin/statements/lines?status=review,unmatched&import_id=1
+// [ADMIN] คิวกระทบยอด (ค่าเริ่มต้น = รายการที่รอ Admin ตรวจ)
+func GetStatementLines(c *fiber.Ctx) error {
+	statuses := []string{models.StatementLineReview, models.StatementLineUnmatched}
+	if s := c.Query("status"); s != "" {
+		statuses = strings.Split(s, ",")
+	}
+	query := database.DB.Preload("Transaction.User").Where("status IN ?", statuses)
+	if id := c.QueryInt("import_id"); id > 0 {
+		query = query.Where("import_id = ?", id)
+	}
+	if id := c.QueryInt("bank_account_id"); id > 0 {
+		query = query.Where("bank_account_id = ?", id)
+	}
+
+	var lines []models.BankStatementLine
+	if err := query.Order("txn_at").Limit(500).Find(&lines).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "ดึงข้อมูลไม่สำเร็จ"})
+	}
+	return c.JSON(lines)
+}
+
+// GET /api/v3/admin/statements/lines/:id/candidates
+// [ADMIN] รายการฝากที่เลือกจับคู่ได้ (ยอดตรง ช่วง ±24 ชม.)
+func GetStatementLineCandidates(c *fiber.Ctx) error {
+	var line models.BankStatementLine
+	if err := database.DB.First(&line, c.Params("id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "ไม่พบรายการ"})
+	}
+	txs, err := services.StatementCandidates(database.DB, &line)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "ดึงข้อมูลไม่สำเร็จ"})
+	}
+	return c.JSON(fiber.Map{"line": line, "candidates": txs})
+}
+
+// POST /api/v3/admin/statements/lines/:id/match {"transaction_id": 10, "override_reason": ""}
+// [ADMIN] จับคู่เอง รายการฝากที่ยังรออยู่จะถูกอนุมัติไปด้วย
+func MatchStatementLine(c *fiber.Ctx) error {
+	var req struct {
+		TransactionID  uint   `json:"transaction_id"`
+		OverrideReason string `json:"override_reason"` // จำเป็นเมื่อสลิปฝากติดธงซ้ำ
+	}
+	if err := c.BodyParser(&req); err != nil || req.TransactionID == 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "กรุณาเลือกรายการฝาก"})
+	}
+	lineID, _ := c.ParamsInt("id")
+
+	bonus, err := services.ResolveStatementLine(database.DB, uint(lineID), req.TransactionID, GetUserID(c), req.OverrideReason)
+	switch {
+	case errors.Is(err, services.ErrStatementLineState):
+		return c.Status(400).JSON(fiber.Map{"error": "รายการนี้ถูกจัดการไปแล้ว"})
+	case errors.Is(err, services.ErrStatementMatch):
+		return c.Status(400).JSON(fiber.Map{"error": "รายการฝากนี้จับคู่ไม่ได้ (ยอดไม่ตรง ไม่ใช่รายการฝาก หรือถูกจับคู่ไปแล้ว)"})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(404).JSON(fiber.Map{"error": "ไม่พบรายการ"})
+	case err != nil:
+		return slipApprovalError(c, err)
+	}
+	return c.JSON(fiber.Map{"message": "จับคู่รายการเรียบร้อย", "bonus": bonus})
+}
+
+// POST /api/v3/admin/statements/lines/:id/ignore {"note": "โอนระหว่างบัญชีของเว็บ"}
+func IgnoreStatementLine(c *fiber.Ctx) error {
+	var req struct {
+		Note string `json:"note"`
+	}
+	c.BodyParser(&req)
+	lineID, _ := c.ParamsInt("id")
+
+	if err := services.IgnoreStatementLine(database.DB, uint(lineID), GetUserID(c), req.Note); err != nil {
+		if errors.Is(err, services.ErrStatementLineState) {
+			return c.Status(400).JSON(fiber.Map{"error": "ไม่พบรายการที่รอตรวจ"})
+		}
+		return c.Status(500).JSON(fiber.Map{"error": "บันทึกไม่สำเร็จ"})
+	}
+	return c.JSON(fiber.Map{"message": "ข้ามรายการเรียบร้อย"})
+}
+
+// GET /api/v3/admin/statement-mappings
+func GetStatementMappings(c *fiber.Ctx) error {
+	mappings, err := services.StatementMappings(database.DB)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "ดึงข้อมูลไม่สำเร็จ"})
+	}
+	return c.JSON(mappings)
+}
+
+// PUT /api/v3/admin/statement-mappings/:bank
+// [ADMIN] ตั้งชื่อคอลัมน์ของไฟล์ธนาคาร (ชื่อหัวคอลัมน์ หรือ "#3" = คอลัมน์ที่ 3)
+func UpdateStatementMapping(c *fiber.Ctx) error {
+	var m models.BankStatementMapping
+	if err := c.BodyParser(&m); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "ข้อมูลไม่ถูกต้อง"})
+	}
+	m.Bank = c.Params("bank")
+
+	saved, err := services.SaveStatementMapping(database.DB, m)
+	if errors.Is(err, services.ErrStatementBank) {
+		return c.Status(400).JSON(fiber.Map{"error": "กรุณากรอกคอลัมน์วันที่ ยอดเงินเข้า และรูปแบบวันที่"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "บันทึกไม่สำเร็จ"})
+	}
+	return c.JSON(fiber.Map{"message": "บันทึกเรียบร้อย", "mapping": saved})
+}
@@ -1,11 +1,11 @@
 package handlers
 
 import (
-	"math"
 	"strconv"
 
 	"github.com/PawornpratKongdaeng/soccer/database"
 	"github.com/PawornpratKongdaeng/soccer/models"
+	"github.com/PawornpratKongdaeng/soccer/services"
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
@@ -13,10 +13,9 @@ import (
 
 // โครงสร้างรับข้อมูล
 type PlaceBetRequest struct {
-	BetType     string  `json:"bet_type"`
-	TotalStake  float64 `json:"total_stake"`
-	TotalPayout float64 `json:"total_payout"`
-	TotalRisk   float64 `json:"total_risk"`
+	BetType    string       `json:"bet_type"`
+	TotalStake models.Money `json:"total_stake"`
+	TotalRisk  models.Money `json:"total_risk"`
 
 	MatchID     string  `json:"match_id"`
 	HomeTeam    string  `json:"home_team"`
@@ -33,12 +32,19 @@ type ParlayItemRequest struct {
 	MatchID     string  `json:"match_id"`
 	HomeTeam    string  `json:"home_team"`
 	AwayTeam    string  `json:"away_team"`
-	Pick        string  `json:"side"`
+	Side        string  `json:"side"` // Frontend ส่ง side หรือ pick
+	Pick        string  `json:"pick"` // รองรับทั้งสองชื่อ
 	Hdp         float64 `json:"hdp"`
 	Price       int     `json:"price"`
 	IsHomeUpper bool    `json:"is_home_upper"`
 }
 
+// จำนวนคู่ในบอลสเต็ป (ตรงกับหน้าเว็บ MIN_PARLAY / MAX_PARLAY)
+const (
+	minParlayLegs = 2
+	maxParlayLegs = 10
+)
+
 func PlaceBet(c *fiber.Ctx) error {
 	var req PlaceBetRequest
 	if err := c.BodyParser(&req); err != nil {
@@ -55,6 +61,26 @@ func PlaceBet(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "ยอดเดิมพันต้องมากกว่า 0"})
 	}
 
+	// ตรวจสอบราคาของแต่ละคู่ในสเต็ปก่อนเริ่ม Transaction
+	if req.BetType != "single" {
+		if len(req.Items) < minParlayLegs || len(req.Items) > maxParlayLegs {
+			return c.Status(400).JSON(fiber.Map{"error": "บอลสเต็ปต้องมี " + strconv.Itoa(minParlayLegs) + "-" + strconv.Itoa(maxParlayLegs) + " คู่"})
+		}
+		for i := range req.Items {
+			if req.Items[i].Pick == "" {
+				req.Items[i].Pick = req.Items[i].Side
+			}
+			switch req.Items[i].Pick {
+			case "home", "away", "over", "under":
+			default:
+				return c.Status(400).JSON(fiber.Map{"error": "ตัวเลือกการแทงไม่ถูกต้อง"})
+			}
+			if req.Items[i].Price < -100 || req.Items[i].Price > 100 {
+				return c.Status(400).JSON(fiber.Map{"error": "ค่าน้ำไม่ถูกต้อง"})
+			}
+		}
+	}
+
 	return database.DB.Transaction(func(tx *gorm.DB) error {
 		var user models.User
 		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, userID).Error; err != nil {
@@ -67,17 +93,45 @@ func PlaceBet(c *fiber.Ctx) error {
 			amountToDeduct = req.TotalRisk
 		}
 
-		if user.Credit < amountToDeduct {
-			return c.Status(400).JSON(fiber.Map{"error": "เครดิตไม่เพียงพอ"})
+		// ขั้นต่ำ/เพดานตามสกุลกระเป๋าของสมาชิก
+		limit, err := services.CurrencyLimitFor(tx, user.Currency)
+		if err != nil {
+			return err
+		}
+		if req.TotalStake < limit.MinBet {
+			return c.Status(400).JSON(fiber.Map{"error": "แทงขั้นต่ำ " + formatAmount(limit.MinBet, limit.Currency)})
+		}
+		if req.TotalStake > limit.MaxBet {
+			return c.Status(400).JSON(fiber.Map{"error": "แทงสูงสุด " + formatAmount(limit.MaxBet, limit.Currency)})
+		}
+		// ยอดจ่ายสูงสุดคิดเองจากทุนและจำนวนคู่ (ไม่ใช้ total_payout ที่หน้าเว็บส่งมา)
+		legs := 1
+		if req.BetType != "single" {
+			legs = len(req.Items)
+		}
+		maxPayout := services.MaxPayout(req.TotalStake, legs)
+		if maxPayout > limit.MaxPayout {
+			return c.Status(400).JSON(fiber.Map{"error": "ยอดจ่ายสูงสุดต่อบิล " + formatAmount(limit.MaxPayout, limit.Currency)})
 		}
 
-		balanceBefore := user.Credit
-		balanceAfter := user.Credit - amountToDeduct
+		if user.PlayableCredit() < amountToDeduct {
+			return c.Status(400).JSON(fiber.Map{"error": "เครดิตไม่เพียงพอ"})
+		}
 
-		if err := tx.Model(&user).Update("credit", balanceAfter).Error; err != nil {
-			return err
+		// บัญชีเงินสดตัดเงินจริงก่อน ขาดเท่าไรค่อยตัดจากกระเป๋าโบนัส
+		fromWallet, fromBonus := amountToDeduct, models.Money(0)
+		if user.AccountMode != models.AccountModeCredit && user.Credit < amountToDeduct {
+			fromWallet = user.Credit
+			if fromWallet < 0 {
+				fromWallet = 0
+			}
+			fromBonus = amountToDeduct - fromWallet
 		}
 
+		// เก็บประเภท/ID ของบิลไว้อ้างอิงในสมุดบัญชี
+		refType := "single"
+		var refID uint
+
 		if req.BetType == "single" {
 			// แปลง MatchID จาก String เป็น Uint
 			mID, err := strconv.ParseUint(req.MatchID, 10, 32)
@@ -97,7 +151,7 @@ func PlaceBet(c *fiber.Ctx) error {
 				Price:       req.Price,
 				IsHomeUpper: req.IsHomeUpper,
 				Amount:      req.TotalStake,
-				Payout:      req.TotalPayout,
+				Payout:      maxPayout,
 				Status:      "pending",
 			}
 
@@ -105,31 +159,32 @@ func PlaceBet(c *fiber.Ctx) error {
 			if err := tx.Create(&betSlip).Error; err != nil {
 				return err
 			}
+			refID = betSlip.ID
 		} else {
 			// กรณีบอลสเต็ป (Parlay)
 			ticket := models.ParlayTicket{
 				UserID: userID,
 				Amount: req.TotalStake,
-				Payout: req.TotalPayout,
+				Payout: maxPayout,
 				Status: "pending",
 			}
 			if err := tx.Create(&ticket).Error; err != nil {
 				return err
 			}
+			refType, refID = "parlay", ticket.ID
 
 			for _, item := range req.Items {
-				// 🔥 แก้ไข: แปลง MatchID ในลูปบอลสเต็ปด้วย
-
+				// เก็บราคาและแต้มต่อที่รับตอนแทง ไว้ใช้คิดผลรายคู่
 				parlayItem := models.ParlayItem{
 					TicketID:    ticket.ID,
-					MatchID:     item.MatchID, // ใส่ค่า uint ที่แปลงแล้ว
+					MatchID:     item.MatchID,
 					HomeTeam:    item.HomeTeam,
 					AwayTeam:    item.AwayTeam,
 					Hdp:         item.Hdp,
 					Price:       item.Price,
 					IsHomeUpper: item.IsHomeUpper,
 					Pick:        item.Pick,
-					Status:      "pending",
+					Status:      models.BetStatusPending,
 				}
 				if err := tx.Create(&parlayItem).Error; err != nil {
 					return err
@@ -137,127 +192,38 @@ func PlaceBet(c *fiber.Ctx) error {
 			}
 		}
 
-		tx.Create(&models.Transaction{
-			UserID:        userID,
-			Amount:        amountToDeduct,
-			Type:          "bet",
-			Status:        "success",
-			BalanceBefore: balanceBefore,
-			BalanceAfter:  balanceAfter,
-		})
-
-		return c.JSON(fiber.Map{"status": "success", "credit": balanceAfter})
-	})
-}
-
-// ... (SettleBets และ calculateBurmeseHandicap ใช้ตัวเดิมได้เลยครับ Logic ดูถูกต้องแล้ว) ...
-func SettleBets(db *gorm.DB, results []models.HtayMatchResult) error {
-	for _, res := range results {
-		if res.Status != "completed" {
-			continue
+		// ตัดเครดิตเข้าบัญชีเจ้ามือ
+		postings := []services.Posting{services.SystemPosting(models.LedgerAccountHouse, amountToDeduct)}
+		if fromWallet > 0 {
+			postings = append(postings, services.WalletPosting(userID, -fromWallet))
 		}
-
-		// 1. คิดผลบอลเต็ง (Single Bet)
-		var bets []models.BetSlip
-		db.Where("match_id = ? AND status = ?", res.ID, "pending").Find(&bets)
-
-		for _, bet := range bets {
-			resultStatus := calculateBurmeseHandicap(
-				res.Scores.FullTime.Home,
-				res.Scores.FullTime.Away,
-				int(bet.Hdp),
-				bet.Price,
-				bet.IsHomeUpper,
-				bet.Pick,
-			)
-
-			db.Transaction(func(tx *gorm.DB) error {
-				tx.Model(&bet).Update("status", resultStatus)
-
-				var refundAmount float64
-				priceFactor := math.Abs(float64(bet.Price)) / 100.0
-
-				switch resultStatus {
-				case "win":
-					refundAmount = bet.Payout
-				case "win_half":
-					refundAmount = bet.Amount + (bet.Amount * priceFactor)
-					if bet.Price < 0 { // กรณีน้ำแดง
-						riskAmount := bet.Amount * priceFactor
-						refundAmount = riskAmount + riskAmount
-					}
-				case "lost_half":
-					refundAmount = bet.Amount * (1 - priceFactor)
-				case "draw":
-					refundAmount = bet.Amount
-				}
-
-				if refundAmount > 0 {
-					tx.Model(&models.User{}).Where("id = ?", bet.UserID).Update("credit", gorm.Expr("credit + ?", refundAmount))
-				}
-				return nil
-			})
+		if fromBonus > 0 {
+			postings = append(postings, services.BonusPosting(userID, -fromBonus))
 		}
-
-		// 2. คิดผลบอลสเต็ป (Parlay - เฉพาะรายคู่)
-		var parlayItems []models.ParlayItem
-		db.Where("match_id = ? AND status = ?", res.ID, "pending").Find(&parlayItems)
-
-		for _, item := range parlayItems {
-			resultStatus := calculateBurmeseHandicap(
-				res.Scores.FullTime.Home,
-				res.Scores.FullTime.Away,
-				int(item.Hdp),
-				item.Price,
-				item.IsHomeUpper,
-				item.Pick,
-			)
-
-			db.Transaction(func(tx *gorm.DB) error {
-				tx.Model(&item).Update("status", resultStatus)
-				return nil
-			})
+		journal, err := services.PostJournal(tx, services.Journal{
+			Type:          "bet",
+			RefType:       refType,
+			RefID:         refID,
+			UseCreditLine: true,
+			Postings:      postings,
+		})
+		if err != nil {
+			return err
 		}
-	}
-	return nil
-}
-
-func calculateBurmeseHandicap(homeScore, awayScore int, odds int, price int, isHomeUpper bool, pick string) string {
-	diff := homeScore - awayScore
-	if !isHomeUpper {
-		diff = awayScore - homeScore
-	}
-
-	var upperResult string
-	if diff > odds {
-		upperResult = "win"
-	} else if diff < odds {
-		upperResult = "lost"
-	} else {
-		// กรณีแต้มประตูเท่ากับแต้มต่อ
-		if price < 0 {
-			upperResult = "lost_half"
-		} else {
-			upperResult = "win_half"
+		betTx, err := services.RecordTransaction(tx, journal, userID, models.Transaction{
+			Type:          "bet",
+			BonusAmount:   fromBonus,
+			BalanceBefore: user.Credit,
+			BalanceAfter:  user.Credit,
+		})
+		if err != nil {
+			return err
 		}
-	}
-
-	isUserPickUpper := (pick == "home" && isHomeUpper) || (pick == "away" && !isHomeUpper)
-
-	if isUserPickUpper {
-		return upperResult
-	}
 
-	switch upperResult {
-	case "win":
-		return "lost"
-	case "lost":
-		return "win"
-	case "win_half":
-		return "lost_half"
-	case "lost_half":
-		return "win_half"
-	default:
-		return "draw"
-	}
+		return c.JSON(fiber.Map{
+			"status":       "success",
+			"credit":       betTx.BalanceAfter,
+			"bonus_credit": user.BonusCredit - fromBonus,
+		})
+	})
 }
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/PawornpratKongdaeng/soccer/database"
+	"github.com/PawornpratKongdaeng/soccer/models"
+	"github.com/PawornpratKongdaeng/soccer/services"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// PATCH /api/v3/agent/members/:id/credit-line {"account_mode": "credit", "credit_limit": 5000}
+// [AGENT/MASTER/ADMIN] ตั้งโหมดบัญชีและวงเงินเครดิตของลูกสายตรง (Admin ตั้งได้ทุกคน)
+func UpdateCreditLine(c *fiber.Ctx) error {
+	memberID, err := c.ParamsInt("id")
+	if err != nil || memberID <= 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "รหัสสมาชิกไม่ถูกต้อง"})
+	}
+
+	var req struct {
+		AccountMode string       `json:"account_mode"`
+		CreditLimit models.Money `json:"credit_limit"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "ข้อมูลไม่ถูกต้อง"})
+	}
+
+	var setter models.User
+	if err := database.DB.First(&setter, GetUserID(c)).Error; err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	member, err := services.SetCreditLine(database.DB, setter, uint(memberID), req.AccountMode, req.CreditLimit)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(404).JSON(fiber.Map{"error": "ไม่พบสมาชิก"})
+	case errors.Is(err, services.ErrInvalidAccountMode):
+		return c.Status(400).JSON(fiber.Map{"error": "โหมดบัญชีต้องเป็น cash หรือ credit"})
+	case errors.Is(err, services.ErrNotDirectDownline):
+		return c.Status(403).JSON(fiber.Map{"error": "สมาชิกท่านนี้ไม่ได้อยู่ในสายงานของท่าน"})
+	case errors.Is(err, services.ErrLimitBelowOutstanding):
+		return c.Status(400).JSON(fiber.Map{"error": "วงเงินต้องไม่น้อยกว่ายอดค้างชำระ"})
+	case errors.Is(err, services.ErrCreditLimitExceeded):
+		return c.Status(400).JSON(fiber.Map{"error": "วงเงินรวมของลูกสายเกินวงเงินของท่าน (" + err.Error() + ")"})
+	case err != nil:
+		return c.Status(500).JSON(fiber.Map{"error": "บันทึกไม่สำเร็จ"})
+	}
+
+	return c.JSON(fiber.Map{
+		"message":      "ตั้งวงเงินเรียบร้อย",
+		"user_id":      member.ID,
+		"account_mode": member.AccountMode,
+		"credit_limit": member.CreditLimit,
+		"available":    member.AvailableCredit(),
+	})
+}
+
+// GET /api/v3/agent/credit-lines
+// [AGENT/MASTER/ADMIN] ยอดค้างชำระของบัญชีเครดิตในสายงาน (Admin เห็นทุกบัญชี)
+func GetCreditLines(c *fiber.Ctx) error {
+	var viewer models.User
+	if err := database.DB.First(&viewer, GetUserID(c)).Error; err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	rootID := viewer.ID
+	if viewer.Role == "admin" {
+		rootID = 0
+	}
+
+	rows, err := services.CreditLines(database.DB, rootID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "ดึงข้อมูลไม่สำเร็จ"})
+	}
+
+	var totalLimit, totalOutstanding, allocated models.Money
+	for _, r := range rows {
+		totalLimit += r.CreditLimit
+		totalOutstanding += r.Outstanding
+		if r.ParentID != nil && *r.ParentID == viewer.ID {
+			allocated += r.CreditLimit
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"accounts":          rows,
+		"total_limit":       totalLimit,
+		"total_outstanding": totalOutstanding,
+		"own_limit":         viewer.CreditLimit,
+		"allocated":         allocated, // วงเงินที่แจกให้ลูกสายตรงแล้ว
+	})
+}
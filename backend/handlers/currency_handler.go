@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/PawornpratKongdaeng/soccer/database"
+	"github.com/PawornpratKongdaeng/soccer/models"
+	"github.com/PawornpratKongdaeng/soccer/services"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// limitForUser: ขั้นต่ำ/เพดานตามสกุลกระเป๋าของ userID
+func limitForUser(userID uint) (models.CurrencyLimit, error) {
+	var user models.User
+	if err := database.DB.Select("id", "currency").First(&user, userID).Error; err != nil {
+		return models.CurrencyLimit{}, err
+	}
+	return services.CurrencyLimitFor(database.DB, user.Currency)
+}
+
+// formatAmount: ยอดเงินพร้อมหน่วย เช่น "100.00 บาท"
+func formatAmount(m models.Money, currency string) string {
+	return fmt.Sprintf("%s %s", m, models.CurrencyLabel(currency))
+}
+
+// GET /api/v3/admin/currencies
+// [ADMIN] สกุลที่รองรับ พร้อมขั้นต่ำ/เพดานและเรทล่าสุด
+func GetCurrencies(c *fiber.Ctx) error {
+	limits, err := services.CurrencyLimits(database.DB)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "ดึงข้อมูลไม่สำเร็จ"})
+	}
+	book, err := services.LoadRateBook(database.DB)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "ดึงข้อมูลไม่สำเร็จ"})
+	}
+
+	return c.JSON(fiber.Map{
+		"base_currency": models.BaseCurrency,
+		"limits":        limits,
+		"rates":         book.Latest(),
+	})
+}
+
+// PUT /api/v3/admin/currencies/:code/limits {"min_deposit": 100, "min_withdraw": 100, "min_bet": 50, "max_bet": 50000, "max_payout": 200000}
+func UpdateCurrencyLimit(c *fiber.Ctx) error {
+	var limit models.CurrencyLimit
+	if err := c.BodyParser(&limit); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "ข้อมูลไม่ถูกต้อง"})
+	}
+	limit.Currency = c.Params("code")
+
+	saved, err := services.SaveCurrencyLimit(database.DB, limit)
+	switch {
+	case errors.Is(err, services.ErrUnsupportedCurrency):
+		return c.Status(400).JSON(fiber.Map{"error": "ไม่รองรับสกุลเงินนี้"})
+	case errors.Is(err, services.ErrInvalidCurrencyLimit):
+		return c.Status(400).JSON(fiber.Map{"error": "ขั้นต่ำ/เพดานไม่ถูกต้อง"})
+	case err != nil:
+		return c.Status(500).JSON(fiber.Map{"error": "บันทึกไม่สำเร็จ"})
+	}
+	return c.JSON(fiber.Map{"message": "บันทึกเรียบร้อย", "limit": saved})
+}
+
+// GET /api/v3/admin/exchange-rates?currency=MMK
+// [ADMIN] ประวัติเรท (ล่าสุดก่อน)
+func GetExchangeRates(c *fiber.Ctx) error {
+	query := database.DB.Model(&models.ExchangeRate{}).Order("effective_at desc, id desc")
+	if currency := c.Query("currency"); currency != "" {
+		query = query.Where("currency = ?", currency)
+	}
+
+	var rates []models.ExchangeRate
+	if err := query.Limit(500).Find(&rates).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "ดึงข้อมูลไม่สำเร็จ"})
+	}
+	return c.JSON(rates)
+}
+
+// POST /api/v3/admin/exchange-rates {"currency": "MMK", "rate": 0.0165, "effective_at": "2024-01-01T00:00:00+07:00", "note": ""}
+// [ADMIN] ตั้งเรทใหม่ (ไม่ระบุ effective_at = มีผลทันที)
+func CreateExchangeRate(c *fiber.Ctx) error {
+	var req struct {
+		Currency    string     `json:"currency"`
+		Rate        float64    `json:"rate"`
+		EffectiveAt *time.Time `json:"effective_at"`
+		Note        string     `json:"note"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "ข้อมูลไม่ถูกต้อง"})
+	}
+
+	var effectiveAt time.Time
+	if req.EffectiveAt != nil {
+		effectiveAt = *req.EffectiveAt
+	}
+
+	adminID := GetUserID(c)
+	rate, err := services.SetExchangeRate(database.DB, req.Currency, req.Rate, effectiveAt, req.Note, &adminID)
+	switch {
+	case errors.Is(err, services.ErrUnsupportedCurrency):
+		return c.Status(400).JSON(fiber.Map{"error": "ไม่รองรับสกุลเงินนี้ (สกุลหลักไม่ต้องตั้งเรท)"})
+	case errors.Is(err, services.ErrInvalidExchangeRate):
+		return c.Status(400).JSON(fiber.Map{"error": "อัตราแลกเปลี่ยนต้องมากกว่า 0"})
+	case err != nil:
+		return c.Status(500).JSON(fiber.Map{"error": "บันทึกไม่สำเร็จ"})
+	}
+	return c.JSON(fiber.Map{"message": "บันทึกอัตราแลกเปลี่ยนเรียบร้อย", "rate": rate})
+}
+
+// PATCH /api/v3/admin/users/:id/currency {"currency": "MMK"}
+// [ADMIN] เปลี่ยนสกุลกระเป๋าของสมาชิกและลูกสายทุกชั้น (ต้องไม่มียอดค้างทั้งสาย)
+func UpdateUserCurrency(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "รหัสสมาชิกไม่ถูกต้อง"})
+	}
+	var req struct {
+		Currency string `json:"currency"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "ข้อมูลไม่ถูกต้อง"})
+	}
+
+	user, err := services.SetUserCurrency(database.DB, uint(id), req.Currency)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(404).JSON(fiber.Map{"error": "ไม่พบผู้ใช้งาน"})
+	case errors.Is(err, services.ErrUnsupportedCurrency):
+		return c.Status(400).JSON(fiber.Map{"error": "ไม่รองรับสกุลเงินนี้"})
+	case errors.Is(err, services.ErrCurrencyMismatch):
+		return c.Status(400).JSON(fiber.Map{"error": "สกุลเงินต้องตรงกับต้นสาย"})
+	case errors.Is(err, services.ErrCurrencyInUse):
+		return c.Status(400).JSON(fiber.Map{"error": "เปลี่ยนสกุลไม่ได้ ยังมียอดเงิน โบนัส วงเงิน บิลค้าง หรือแจ้งฝากรออนุมัติในสายงาน"})
+	case err != nil:
+		return c.Status(500).JSON(fiber.Map{"error": "บันทึกไม่สำเร็จ"})
+	}
+	return c.JSON(fiber.Map{"message": "เปลี่ยนสกุลเงินเรียบร้อย", "user_id": user.ID, "currency": user.Currency})
+}
@@ -1,13 +1,13 @@
 package handlers
 
 import (
-	"fmt"
+	"errors"
 	"log"
-	"os"
 	"time"
 
 	"github.com/PawornpratKongdaeng/soccer/database"
 	"github.com/PawornpratKongdaeng/soccer/models"
+	"github.com/PawornpratKongdaeng/soccer/services"
 	"github.com/gofiber/fiber/v2"
 )
 
@@ -32,12 +32,22 @@ func SubmitDeposit(c *fiber.Ctx) error {
 	}
 
 	// 2. รับค่าจำนวนเงิน
-	amountStr := c.FormValue("amount")
-	var amount float64
-	_, err := fmt.Sscanf(amountStr, "%f", &amount)
+	amount, err := models.ParseMoney(c.FormValue("amount"))
 	if err != nil || amount <= 0 {
 		return c.Status(400).JSON(fiber.Map{"error": "จำนวนเงินต้องมากกว่า 0 และเป็นตัวเลขเท่านั้น"})
 	}
+	limit, err := limitForUser(userID)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "ไม่พบผู้ใช้งาน"})
+	}
+	if amount < limit.MinDeposit {
+		return c.Status(400).JSON(fiber.Map{"error": "ยอดฝากขั้นต่ำ " + formatAmount(limit.MinDeposit, limit.Currency)})
+	}
+	// โปรที่เลือก (ไม่บังคับ) ตรวจเงื่อนไขจริงอีกครั้งตอนอนุมัติ
+	promotionID, err := depositPromotion(c)
+	if err != nil {
+		return promotionError(c, err)
+	}
 
 	// 3. จัดการไฟล์รูปสลิป
 	file, err := c.FormFile("slip")
@@ -45,46 +55,60 @@ func SubmitDeposit(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "กรุณาแนบรูปสลิปที่ถูกต้อง"})
 	}
 
-	// ✅ ตรวจสอบและสร้างโฟลเดอร์ uploads หากยังไม่มี
-	uploadDir := "./uploads"
-	if _, err := os.Stat(uploadDir); os.IsNotExist(err) {
-		log.Println("Creating uploads directory...")
-		err := os.MkdirAll(uploadDir, 0777)
-		if err != nil {
-			log.Printf("❌ Failed to create directory: %v", err)
-			return c.Status(500).JSON(fiber.Map{"error": "เซิร์ฟเวอร์ไม่สามารถสร้างโฟลเดอร์เก็บรูปได้"})
-		}
-	}
-
-	// ตั้งชื่อไฟล์ป้องกันชื่อซ้ำ: slip_userID_timestamp_filename
-	fileName := fmt.Sprintf("slip_%d_%d_%s", userID, time.Now().Unix(), file.Filename)
-	filePath := fmt.Sprintf("%s/%s", uploadDir, fileName)
-
-	// ✅ เซฟไฟล์ลงเครื่อง
-	if err := c.SaveFile(file, filePath); err != nil {
-		log.Printf("❌ Upload Error: %v | Path: %s", err, filePath)
-		return c.Status(500).JSON(fiber.Map{"error": "ไม่สามารถบันทึกรูปภาพได้ กรุณาเช็ค Permission"})
+	// ✅ ตรวจชนิดไฟล์จากไบต์จริง ลบ EXIF ตั้งชื่อสุ่ม แล้วเก็บลง Storage (พร้อมรูปย่อ)
+	upload, err := services.SaveSlipUpload(file)
+	if err != nil {
+		return slipUploadError(c, err)
 	}
 
 	// 4. บันทึกลงฐานข้อมูล (ใช้รุ่น Transaction ตามที่คุณปรับมา)
 	request := models.Transaction{
-		UserID:    userID,
-		Amount:    amount,
-		Type:      "deposit",
-		SlipURL:   "/uploads/" + fileName,
-		Status:    "pending",
-		CreatedAt: time.Now(),
+		UserID:        userID,
+		Amount:        amount,
+		Type:          "deposit",
+		BankAccountID: services.AssignedBankAccountID(database.DB, userID),
+		SlipPath:      uploadURL(upload.Key),
+		SlipThumbPath: uploadURL(upload.ThumbKey),
+		Status:        "pending",
+		PromotionID:   promotionID,
+		CreatedAt:     time.Now(),
 	}
 
 	if err := database.DB.Create(&request).Error; err != nil {
 		log.Printf("❌ Database Error: %v", err)
 		// ถ้าพังตรงนี้ ให้ลบไฟล์ที่เพิ่งเซฟไปทิ้งเพื่อไม่ให้ไฟล์ขยะค้าง
-		os.Remove(filePath)
+		upload.Remove()
 		return c.Status(500).JSON(fiber.Map{"error": "เกิดข้อผิดพลาดในการบันทึกข้อมูล: " + err.Error()})
 	}
+	recordDepositSlip(upload, &request)
 
 	return c.JSON(fiber.Map{
 		"message": "แจ้งฝากเรียบร้อยแล้ว รอแอดมินตรวจสอบ",
 		"data":    request,
 	})
 }
+
+// recordDepositSlip: เก็บลายนิ้วมือสลิปไว้เทียบสลิปซ้ำ (ผลซ้ำแสดงให้ Admin ดูในรายการรออนุมัติเท่านั้น)
+// เก็บไม่สำเร็จแค่ log ไว้ ไม่ให้การแจ้งฝากล้ม
+func recordDepositSlip(upload *services.SlipUpload, t *models.Transaction) {
+	fp, err := services.RecordSlip(database.DB, t, upload.Original)
+	if err != nil {
+		log.Printf("❌ [Slip] fingerprint deposit #%d: %v", t.ID, err)
+		return
+	}
+	if fp.DuplicateOfID != nil {
+		log.Printf("⚠️ [Slip] deposit #%d slip %s match with #%d (distance %d)", t.ID, fp.Match, *fp.DuplicateOfID, fp.Distance)
+	}
+}
+
+// slipUploadError: แปลง error จาก SaveSlipUpload เป็นข้อความให้ลูกค้า
+func slipUploadError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrUploadTooLarge):
+		return c.Status(400).JSON(fiber.Map{"error": "ไฟล์สลิปใหญ่เกินไป (ไม่เกิน 5MB)"})
+	case errors.Is(err, services.ErrUploadType):
+		return c.Status(400).JSON(fiber.Map{"error": "รองรับเฉพาะรูปสลิป JPG หรือ PNG"})
+	}
+	log.Printf("❌ [Upload] save slip: %v", err)
+	return c.Status(500).JSON(fiber.Map{"error": "บันทึกไฟล์สลิปไม่สำเร็จ"})
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"log"
+
+	"github.com/PawornpratKongdaeng/soccer/database"
+	"github.com/PawornpratKongdaeng/soccer/models"
+	"github.com/PawornpratKongdaeng/soccer/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+const promptPayQRScale = 8 // พิกเซลต่อ module ของรูป QR
+
+// POST /api/v3/user/deposit/promptpay {"amount": 500, "bank_account_id": 0, "promotion_id": 0, "promo_code": ""}
+// สร้างคำขอฝาก: ได้ยอดโอนที่มีเศษสตางค์เฉพาะตัว + QR PromptPay ของบัญชีรับเงิน (Transaction ฝาก pending)
+func CreatePromptPayDeposit(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*models.User)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	var req struct {
+		Amount        models.Money `json:"amount"`
+		BankAccountID uint         `json:"bank_account_id"` // 0 = ให้ระบบเลือก
+		PromotionID   uint         `json:"promotion_id"`
+		PromoCode     string       `json:"promo_code"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "ข้อมูลไม่ถูกต้อง"})
+	}
+
+	limit, err := services.CurrencyLimitFor(database.DB, user.Currency)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "ดึงข้อมูลขั้นต่ำไม่สำเร็จ"})
+	}
+	if req.Amount < limit.MinDeposit {
+		return c.Status(400).JSON(fiber.Map{"error": "ยอดฝากขั้นต่ำ " + formatAmount(limit.MinDeposit, limit.Currency)})
+	}
+	promotionID, err := resolveDepositPromotion(req.PromotionID, req.PromoCode)
+	if err != nil {
+		return promotionError(c, err)
+	}
+
+	intent, tx, err := services.CreateDepositIntent(database.DB, user, req.BankAccountID, req.Amount, promotionID)
+	switch {
+	case errors.Is(err, services.ErrPromptPayCurrency):
+		return c.Status(400).JSON(fiber.Map{"error": "ฝากผ่าน QR PromptPay ได้เฉพาะบัญชีสกุลเงินบาท"})
+	case errors.Is(err, services.ErrPromptPayUnavailable):
+		return c.Status(400).JSON(fiber.Map{"error": "ยังไม่มีบัญชีรับเงินที่รองรับ PromptPay"})
+	case errors.Is(err, services.ErrIntentSlotsFull):
+		return c.Status(429).JSON(fiber.Map{"error": "มีผู้ฝากยอดนี้จำนวนมาก กรุณาลองยอดอื่นหรือรอสักครู่"})
+	case err != nil:
+		log.Printf("❌ [PromptPay] create intent user %d: %v", user.ID, err)
+		return c.Status(500).JSON(fiber.Map{"error": "สร้างคำขอฝากไม่สำเร็จ"})
+	}
+
+	qr, err := promptPayPNG(intent)
+	if err != nil {
+		log.Printf("❌ [PromptPay] qr #%d: %v", intent.ID, err)
+		return c.Status(500).JSON(fiber.Map{"error": "สร้าง QR ไม่สำเร็จ"})
+	}
+	return c.JSON(fiber.Map{
+		"message":      "สแกน QR เพื่อโอนยอดตามที่แสดง (ต้องตรงทุกสตางค์)",
+		"data":         tx,
+		"intent":       intent,
+		"amount":       intent.Amount,
+		"expires_at":   intent.ExpiresAt,
+		"payload":      intent.Payload,
+		"qr_png":       "data:image/png;base64," + base64.StdEncoding.EncodeToString(qr),
+		"account_name": tx.AccountName,
+		"bank_name":    tx.BankName,
+	})
+}
+
+// GET /api/v3/user/deposit/promptpay/:id/qr
+// รูป QR (PNG) ของคำขอฝากของตัวเอง (:id = transaction id)
+func GetPromptPayQR(c *fiber.Ctx) error {
+	var intent models.DepositIntent
+	if err := database.DB.Where("transaction_id = ? AND user_id = ?", c.Params("id"), getIDFromLocals(c)).First(&intent).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "ไม่พบคำขอฝาก"})
+	}
+	qr, err := promptPayPNG(&intent)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "สร้าง QR ไม่สำเร็จ"})
+	}
+	c.Set(fiber.HeaderContentType, "image/png")
+	c.Set(fiber.HeaderCacheControl, "private, no-store")
+	return c.Send(qr)
+}
+
+func promptPayPNG(intent *models.DepositIntent) ([]byte, error) {
+	qr, err := services.EncodeQR(intent.Payload)
+	if err != nil {
+		return nil, err
+	}
+	return qr.PNG(promptPayQRScale)
+}
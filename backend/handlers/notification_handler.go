@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/PawornpratKongdaeng/soccer/database"
+	"github.com/PawornpratKongdaeng/soccer/models"
+	"github.com/PawornpratKongdaeng/soccer/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+// GET /api/v3/notifications?unread=1
+// แจ้งเตือนของผู้ใช้ที่ Login (ล่าสุดก่อน) พร้อมจำนวนที่ยังไม่อ่าน
+func GetMyNotifications(c *fiber.Ctx) error {
+	userID := GetUserID(c)
+
+	query := database.DB.Where("user_id = ?", userID)
+	if c.Query("unread") != "" {
+		query = query.Where("read_at IS NULL")
+	}
+	var items []models.Notification
+	if err := query.Order("id desc").Limit(100).Find(&items).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "ดึงข้อมูลไม่สำเร็จ"})
+	}
+
+	var unread int64
+	database.DB.Model(&models.Notification{}).Where("user_id = ? AND read_at IS NULL", userID).Count(&unread)
+	return c.JSON(fiber.Map{"unread": unread, "notifications": items})
+}
+
+// POST /api/v3/notifications/read {"ids": [1, 2]} (ไม่ส่ง ids = อ่านทั้งหมด)
+func ReadMyNotifications(c *fiber.Ctx) error {
+	var req struct {
+		IDs []uint `json:"ids"`
+	}
+	c.BodyParser(&req)
+
+	count, err := services.MarkNotificationsRead(database.DB, GetUserID(c), req.IDs, time.Now())
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "บันทึกไม่สำเร็จ"})
+	}
+	return c.JSON(fiber.Map{"message": "อ่านแจ้งเตือนแล้ว", "count": count})
+}
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/PawornpratKongdaeng/soccer/database"
+	"github.com/PawornpratKongdaeng/soccer/models"
+	"github.com/PawornpratKongdaeng/soccer/services"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// paymentError: แปลง error ของคำสั่งชำระเงินเป็นข้อความ
+func paymentError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrPaymentProviderUnknown):
+		return c.Status(400).JSON(fiber.Map{"error": "ไม่มีช่องทางชำระเงินนี้"})
+	case errors.Is(err, services.ErrPaymentProvider):
+		return c.Status(502).JSON(fiber.Map{"error": "ผู้ให้บริการชำระเงินไม่รับคำสั่ง (" + err.Error() + ")"})
+	case errors.Is(err, services.ErrPayoutInFlight), errors.Is(err, services.ErrWithdrawalState):
+		return withdrawalError(c, err)
+	case errors.Is(err, gorm.ErrRecordNotFound), errors.Is(err, services.ErrPaymentOrderNotFound):
+		return c.Status(404).JSON(fiber.Map{"error": "ไม่พบรายการ"})
+	}
+	log.Printf("❌ [Payment] %v", err)
+	return c.Status(500).JSON(fiber.Map{"error": "ทำรายการชำระเงินไม่สำเร็จ"})
+}
+
+// POST /api/v3/payments/:provider/webhook (header X-Payment-Timestamp, X-Payment-Signature)
+// ผู้ให้บริการแจ้งผล: ลายเซ็นผิด/เวลาเกิน = 401, event ซ้ำ = 200 ไม่ทำอะไร, error อื่น = 5xx ให้ผู้ให้บริการส่งซ้ำ
+func PaymentWebhook(c *fiber.Ctx) error {
+	name := c.Params("provider")
+	p, err := services.PaymentProviderFor(name)
+	if err != nil || name == "" {
+		return c.Status(404).JSON(fiber.Map{"error": "unknown provider"})
+	}
+	order, duplicate, err := services.ProcessWebhook(database.DB, p, func(name string) string { return c.Get(name) }, c.Body(), time.Now())
+	switch {
+	case errors.Is(err, services.ErrWebhookSignature), errors.Is(err, services.ErrWebhookExpired):
+		log.Printf("⚠️ [Payment] %s webhook rejected from %s: %v", p.Name(), c.IP(), err)
+		return c.Status(401).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrPaymentOrderNotFound):
+		return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+	case err != nil:
+		log.Printf("❌ [Payment] %s webhook: %v", p.Name(), err)
+		return c.Status(500).JSON(fiber.Map{"error": "webhook processing failed"})
+	}
+	return c.JSON(fiber.Map{"ok": true, "duplicate": duplicate, "status": order.Status})
+}
+
+// POST /api/v3/payments/mock/:ref/complete {"status": "succeeded", "amount": 0, "reason": ""}
+// จำลองผู้ให้บริการ mock ชำระ/จ่ายเสร็จ (มีเฉพาะตอนเปิด PAYMENT_MOCK_ENABLED)
+func CompleteMockPayment(c *fiber.Ctx) error {
+	if services.MockPayments == nil {
+		return c.Status(404).JSON(fiber.Map{"error": "mock provider disabled"})
+	}
+	req := struct {
+		Status string       `json:"status"`
+		Amount models.Money `json:"amount"`
+		Reason string       `json:"reason"`
+	}{Status: models.PaymentSucceeded}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "ข้อมูลไม่ถูกต้อง"})
+		}
+	}
+	order, duplicate, err := services.MockPayments.Complete(database.DB, c.Params("ref"), req.Status, req.Reason, req.Amount)
+	if err != nil {
+		return paymentError(c, err)
+	}
+	return c.JSON(fiber.Map{"message": "ส่ง Webhook จำลองแล้ว", "duplicate": duplicate, "data": order})
+}
+
+// POST /api/v3/user/deposit/gateway {"amount": 500, "provider": "", "promotion_id": 0, "promo_code": ""}
+// ฝากผ่านผู้ให้บริการชำระเงิน: ได้ pay_url ไปชำระ เครดิตเข้าเองเมื่อผู้ให้บริการแจ้งว่าสำเร็จ
+func CreateGatewayDeposit(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*models.User)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	var req struct {
+		Amount      models.Money `json:"amount"`
+		Provider    string       `json:"provider"` // "" = ค่าเริ่มต้น
+		PromotionID uint         `json:"promotion_id"`
+		PromoCode   string       `json:"promo_code"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "ข้อมูลไม่ถูกต้อง"})
+	}
+
+	limit, err := services.CurrencyLimitFor(database.DB, user.Currency)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "ดึงข้อมูลขั้นต่ำไม่สำเร็จ"})
+	}
+	if req.Amount < limit.MinDeposit {
+		return c.Status(400).JSON(fiber.Map{"error": "ยอดฝากขั้นต่ำ " + formatAmount(limit.MinDeposit, limit.Currency)})
+	}
+	promotionID, err := resolveDepositPromotion(req.PromotionID, req.PromoCode)
+	if err != nil {
+		return promotionError(c, err)
+	}
+	p, err := services.PaymentProviderFor(req.Provider)
+	if err != nil {
+		return paymentError(c, err)
+	}
+
+	order, tx, err := services.CreateGatewayDeposit(database.DB, p, user, req.Amount, promotionID)
+	if err != nil {
+		return paymentError(c, err)
+	}
+	return c.JSON(fiber.Map{
+		"message": "ไปชำระเงินที่หน้าผู้ให้บริการ เครดิตจะเข้าอัตโนมัติเมื่อชำระสำเร็จ",
+		"data":    tx,
+		"order":   order,
+		"pay_url": order.PayURL,
+	})
+}
+
+// GET /api/v3/admin/payments?status=pending&kind=payout
+// [ADMIN] คำสั่งชำระเงินผ่านผู้ให้บริการ (mismatch = ต้องตรวจเอง)
+func GetPaymentOrders(c *fiber.Ctx) error {
+	query := database.DB.Model(&models.PaymentOrder{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if kind := c.Query("kind"); kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+	var orders []models.PaymentOrder
+	if err := query.Order("id desc").Limit(500).Find(&orders).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "ดึงข้อมูลไม่สำเร็จ"})
+	}
+	return c.JSON(fiber.Map{"data": orders, "providers": services.PaymentProviderNames()})
+}
+
+// POST /api/v3/admin/payments/:id/sync
+// [ADMIN] ถามสถานะคำสั่งจากผู้ให้บริการทันที (ไม่ต้องรอ job payment-sync)
+func SyncPaymentOrder(c *fiber.Ctx) error {
+	var order models.PaymentOrder
+	if err := database.DB.First(&order, c.Params("id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "ไม่พบรายการ"})
+	}
+	if err := services.SyncPayment(database.DB, &order, time.Now()); err != nil {
+		return paymentError(c, err)
+	}
+	return c.JSON(fiber.Map{"message": "อัปเดตสถานะแล้ว", "data": order})
+}
+
+// POST /api/v3/admin/withdrawals/:id/payout {"provider": ""}
+// [ADMIN] สั่งจ่ายรายการถอนที่อนุมัติครบแล้วผ่านผู้ให้บริการ (ผลเข้ามาทาง Webhook)
+func PayoutWithdrawal(c *fiber.Ctx) error {
+	var req struct {
+		Provider string `json:"provider"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "ข้อมูลไม่ถูกต้อง"})
+		}
+	}
+	p, err := services.PaymentProviderFor(req.Provider)
+	if err != nil {
+		return paymentError(c, err)
+	}
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "รหัสรายการไม่ถูกต้อง"})
+	}
+
+	order, err := services.CreateGatewayPayout(database.DB, p, uint(id), GetUserID(c))
+	if err != nil {
+		return paymentError(c, err)
+	}
+	return c.JSON(fiber.Map{"message": "ส่งคำสั่งจ่ายให้ผู้ให้บริการแล้ว", "data": order})
+}
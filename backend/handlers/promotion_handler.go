@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PawornpratKongdaeng/soccer/database"
+	"github.com/PawornpratKongdaeng/soccer/models"
+	"github.com/PawornpratKongdaeng/soccer/services"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// depositPromotion: โปรที่สมาชิกเลือกตอนแจ้งฝาก (form: promotion_id หรือ promo_code) nil = ไม่รับโปร
+// เช็คเงื่อนไขจริงอีกครั้งตอน Admin อนุมัติ
+func depositPromotion(c *fiber.Ctx) (*uint, error) {
+	id, _ := strconv.ParseUint(c.FormValue("promotion_id"), 10, 32)
+	return resolveDepositPromotion(uint(id), c.FormValue("promo_code"))
+}
+
+// resolveDepositPromotion: ตรวจโปรที่เลือกตอนแจ้งฝาก (ไม่เลือก = nil)
+func resolveDepositPromotion(id uint, code string) (*uint, error) {
+	if code == "" && id == 0 {
+		return nil, nil
+	}
+	p, err := services.ResolvePromotion(database.DB, id, code)
+	if err != nil {
+		return nil, err
+	}
+	if p.Type == models.PromoTypeFreeCredit {
+		return nil, fmt.Errorf("%w: free credit is claimed with a code", services.ErrPromotionNotEligible)
+	}
+	return &p.ID, nil
+}
+
+// promotionError: แปลง error ของโปรโมชั่นเป็นข้อความตอบกลับ
+func promotionError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrPromotionNotFound):
+		return c.Status(404).JSON(fiber.Map{"error": "ไม่พบโปรโมชั่นหรือโค้ดไม่ถูกต้อง"})
+	case errors.Is(err, services.ErrBonusActive):
+		return c.Status(400).JSON(fiber.Map{"error": "ยังมีโบนัสที่ทำเทิร์นไม่ครบ ต้องจบโปรเดิมก่อน"})
+	case errors.Is(err, services.ErrPromotionNotEligible):
+		return c.Status(400).JSON(fiber.Map{"error": "ไม่ตรงเงื่อนไขโปรโมชั่น (" + err.Error() + ")"})
+	case errors.Is(err, services.ErrBonusClosed):
+		return c.Status(400).JSON(fiber.Map{"error": "โบนัสนี้จบไปแล้ว"})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(404).JSON(fiber.Map{"error": "ไม่พบรายการ"})
+	}
+	return c.Status(500).JSON(fiber.Map{"error": "ทำรายการไม่สำเร็จ"})
+}
+
+// GET /api/v3/user/promotions
+// [USER] โปรที่เลือกได้ตอนแจ้งฝาก (ตามสกุลกระเป๋า)
+func GetAvailablePromotions(c *fiber.Ctx) error {
+	var user models.User
+	if err := database.DB.Select("id", "currency").First(&user, getIDFromLocals(c)).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "User not found"})
+	}
+	promos, err := services.AvailablePromotions(database.DB, user.Currency, time.Now())
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "ดึงข้อมูลไม่สำเร็จ"})
+	}
+	return c.JSON(promos)
+}
+
+// POST /api/v3/user/promotions/claim {"code": "FREE50"}
+// [USER] รับเครดิตฟรีด้วยโค้ด
+func ClaimPromotion(c *fiber.Ctx) error {
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := c.BodyParser(&req); err != nil || strings.TrimSpace(req.Code) == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "กรุณากรอกโค้ด"})
+	}
+
+	grant, err := services.ClaimPromotion(getIDFromLocals(c), req.Code)
+	if err != nil {
+		return promotionError(c, err)
+	}
+	return c.JSON(fiber.Map{"message": "รับโบนัสเรียบร้อย", "bonus": grant})
+}
+
+// GET /api/v3/user/bonus
+// [USER] โบนัสที่กำลังทำเทิร์น + ประวัติโบนัส
+func GetMyBonus(c *fiber.Ctx) error {
+	userID := getIDFromLocals(c)
+
+	active, err := services.ActiveBonus(database.DB, userID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "ดึงข้อมูลไม่สำเร็จ"})
+	}
+	var history []models.BonusGrant
+	if err := database.DB.Where("user_id = ?", userID).Order("id desc").Limit(50).Find(&history).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "ดึงข้อมูลไม่สำเร็จ"})
+	}
+
+	resp := fiber.Map{"active": active, "history": history}
+	if active != nil {
+		resp["remaining_turnover"] = active.Remaining()
+	}
+	return c.JSON(resp)
+}
+
+// POST /api/v3/user/bonus/:id/forfeit
+// [USER] ยกเลิกโบนัสที่ทำเทิร์นไม่ครบ (ยอดโบนัสที่เหลือถูกริบ แล้วถอนเงินได้)
+func ForfeitMyBonus(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "รหัสโบนัสไม่ถูกต้อง"})
+	}
+	userID := getIDFromLocals(c)
+	grant, err := services.CancelBonus(uint(id), userID, "สมาชิกขอยกเลิกโบนัส", &userID)
+	if err != nil {
+		return promotionError(c, err)
+	}
+	return c.JSON(fiber.Map{"message": "ยกเลิกโบนัสเรียบร้อย", "bonus": grant})
+}
+
+// GET /api/v3/admin/promotions
+func GetPromotions(c *fiber.Ctx) error {
+	var promos []models.Promotion
+	if err := database.DB.Order("id desc").Find(&promos).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "ดึงข้อมูลไม่สำเร็จ"})
+	}
+	return c.JSON(promos)
+}
+
+// POST /api/v3/admin/promotions
+func CreatePromotion(c *fiber.Ctx) error {
+	var p models.Promotion
+	if err := c.BodyParser(&p); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "ข้อมูลไม่ถูกต้อง"})
+	}
+	p.ID = 0
+	adminID := GetUserID(c)
+	p.CreatedBy = &adminID
+
+	if err := services.ValidatePromotion(&p); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "ข้อมูลโปรโมชั่นไม่ถูกต้อง (" + err.Error() + ")"})
+	}
+	if err := database.DB.Create(&p).Error; err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "บันทึกไม่สำเร็จ (โค้ดซ้ำหรือไม่)"})
+	}
+	return c.JSON(fiber.Map{"message": "สร้างโปรโมชั่นเรียบร้อย", "promotion": p})
+}
+
+// PUT /api/v3/admin/promotions/:id
+// แก้ไขมีผลกับการรับโปรครั้งถัดไปเท่านั้น (โบนัสที่ออกไปแล้วใช้เงื่อนไขตอนรับ)
+func UpdatePromotion(c *fiber.Ctx) error {
+	var p models.Promotion
+	if err := database.DB.First(&p, c.Params("id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "ไม่พบโปรโมชั่น"})
+	}
+	id, createdBy, createdAt := p.ID, p.CreatedBy, p.CreatedAt
+	if err := c.BodyParser(&p); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "ข้อมูลไม่ถูกต้อง"})
+	}
+	p.ID, p.CreatedBy, p.CreatedAt = id, createdBy, createdAt
+
+	if err := services.ValidatePromotion(&p); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "ข้อมูลโปรโมชั่นไม่ถูกต้อง (" + err.Error() + ")"})
+	}
+	if err := database.DB.Save(&p).Error; err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "บันทึกไม่สำเร็จ (โค้ดซ้ำหรือไม่)"})
+	}
+	return c.JSON(fiber.Map{"message": "บันทึกเรียบร้อย", "promotion": p})
+}
+
+// GET /api/v3/admin/promotions/:id/grants?status=
+// [ADMIN] โบนัสที่ออกจากโปรนี้ พร้อมยอดสรุป
+func GetPromotionGrants(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "รหัสโปรโมชั่นไม่ถูกต้อง"})
+	}
+
+	query := database.DB.Where("promotion_id = ?", id).Order("id desc")
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var grants []models.BonusGrant
+	if err := query.Limit(500).Find(&grants).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "ดึงข้อมูลไม่สำเร็จ"})
+	}
+	stats, err := services.GetPromotionStats(database.DB, uint(id))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "ดึงข้อมูลไม่สำเร็จ"})
+	}
+	return c.JSON(fiber.Map{"stats": stats, "grants": grants})
+}
+
+// POST /api/v3/admin/bonus-grants/:id/forfeit {"reason": "..."}
+// [ADMIN] ยกเลิกโบนัสของสมาชิก (ริบยอดโบนัสที่เหลือ)
+func ForfeitBonusGrant(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "รหัสโบนัสไม่ถูกต้อง"})
+	}
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	c.BodyParser(&req)
+	if req.Reason == "" {
+		req.Reason = "Admin ยกเลิกโบนัส"
+	}
+
+	adminID := GetUserID(c)
+	grant, err := services.CancelBonus(uint(id), 0, req.Reason, &adminID)
+	if err != nil {
+		return promotionError(c, err)
+	}
+	return c.JSON(fiber.Map{"message": "ยกเลิกโบนัสเรียบร้อย", "bonus": grant})
+}
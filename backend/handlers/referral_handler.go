@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/PawornpratKongdaeng/soccer/database"
+	"github.com/PawornpratKongdaeng/soccer/models"
+	"github.com/PawornpratKongdaeng/soccer/services"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// GET /api/v3/ref/:code
+// [PUBLIC] หน้าเว็บเรียกตอนเปิดลิงก์แนะนำ (นับคลิก + เช็ครหัสก่อนแสดงฟอร์มสมัคร)
+func OpenReferralLink(c *fiber.Ctx) error {
+	rc, err := services.RecordReferralClick(database.DB, c.Params("code"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "รหัสแนะนำไม่ถูกต้องหรือถูกปิดใช้งานแล้ว"})
+	}
+	return c.JSON(fiber.Map{"code": rc.Code, "valid": true})
+}
+
+// GET /api/v3/referral
+// รหัสแนะนำของตัวเอง พร้อมลิงก์และยอดสรุป (Master/Agent ได้รหัสแรกอัตโนมัติ)
+func GetMyReferrals(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*models.User)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	if !services.CanRefer(database.DB, user) {
+		return c.Status(403).JSON(fiber.Map{"error": "บัญชีนี้ยังไม่เปิดใช้ระบบแนะนำเพื่อน"})
+	}
+	if err := services.EnsureReferralCode(database.DB, user); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "สร้างรหัสแนะนำไม่สำเร็จ"})
+	}
+
+	stats, err := services.GetReferralStats(database.DB, user)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "ดึงข้อมูลไม่สำเร็จ"})
+	}
+	return c.JSON(stats)
+}
+
+// GET /api/v3/referral/members
+// สมาชิกที่สมัครผ่านรหัสของตัวเอง (ยอดฝากแรก / รางวัลที่ได้)
+func GetMyReferredMembers(c *fiber.Ctx) error {
+	var attrs []models.ReferralAttribution
+	if err := database.DB.Preload("User", func(db *gorm.DB) *gorm.DB {
+		return db.Unscoped().Select("id", "username", "status", "created_at")
+	}).Where("referrer_id = ?", getIDFromLocals(c)).Order("id desc").Limit(500).Find(&attrs).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "ดึงข้อมูลไม่สำเร็จ"})
+	}
+	return c.JSON(attrs)
+}
+
+// POST /api/v3/referral/codes {"code": "MYSHOP", "label": "Facebook"}
+// code ว่าง = สุ่มรหัสให้
+func CreateMyReferralCode(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*models.User)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	var req struct {
+		Code  string `json:"code"`
+		Label string `json:"label"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "ข้อมูลไม่ถูกต้อง"})
+	}
+
+	rc, err := services.CreateReferralCode(database.DB, user, req.Code, req.Label)
+	switch {
+	case errors.Is(err, services.ErrReferralNotAllowed):
+		return c.Status(403).JSON(fiber.Map{"error": "บัญชีนี้ยังไม่เปิดใช้ระบบแนะนำเพื่อน"})
+	case errors.Is(err, services.ErrInvalidReferral):
+		return c.Status(400).JSON(fiber.Map{"error": "รหัสแนะนำไม่ถูกต้องหรือมีคนใช้แล้ว (" + err.Error() + ")"})
+	case err != nil:
+		return c.Status(500).JSON(fiber.Map{"error": "สร้างรหัสแนะนำไม่สำเร็จ"})
+	}
+	return c.JSON(fiber.Map{"message": "สร้างรหัสแนะนำเรียบร้อย", "code": rc})
+}
+
+// PATCH /api/v3/referral/codes/:id {"label": "...", "active": false}
+// แก้ชื่อช่องทาง / ปิดรหัส (สมาชิกที่สมัครไปแล้วยังนับเป็นของรหัสนี้)
+func UpdateMyReferralCode(c *fiber.Ctx) error {
+	var rc models.ReferralCode
+	if err := database.DB.Where("id = ? AND user_id = ?", c.Params("id"), getIDFromLocals(c)).First(&rc).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "ไม่พบรหัสแนะนำ"})
+	}
+
+	var req struct {
+		Label  *string `json:"label"`
+		Active *bool   `json:"active"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "ข้อมูลไม่ถูกต้อง"})
+	}
+	if req.Label != nil {
+		rc.Label = *req.Label
+	}
+	if req.Active != nil {
+		rc.Active = *req.Active
+	}
+	if err := database.DB.Select("label", "active").Save(&rc).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "บันทึกไม่สำเร็จ"})
+	}
+	return c.JSON(fiber.Map{"message": "บันทึกเรียบร้อย", "code": rc})
+}
+
+// GET /api/v3/admin/referrals?referrer_id=&code=
+// [ADMIN] ที่มาของสมาชิกที่สมัครผ่านรหัสแนะนำ
+func GetReferralAttributions(c *fiber.Ctx) error {
+	query := database.DB.Preload("User", func(db *gorm.DB) *gorm.DB {
+		return db.Unscoped().Select("id", "username", "status", "parent_id", "created_at")
+	}).Order("id desc")
+	if referrerID := c.Query("referrer_id"); referrerID != "" {
+		query = query.Where("referrer_id = ?", referrerID)
+	}
+	if code := c.Query("code"); code != "" {
+		query = query.Where("code = ?", services.NormalizeReferralCode(code))
+	}
+
+	var attrs []models.ReferralAttribution
+	if err := query.Limit(500).Find(&attrs).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "ดึงข้อมูลไม่สำเร็จ"})
+	}
+	return c.JSON(attrs)
+}
+
+// GET /api/v3/admin/users/:id/referrals
+// [ADMIN] รหัสแนะนำและยอดสรุปของสมาชิก/Agent คนหนึ่ง
+func GetUserReferralStats(c *fiber.Ctx) error {
+	var user models.User
+	if err := database.DB.First(&user, c.Params("id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "ไม่พบผู้ใช้งาน"})
+	}
+	stats, err := services.GetReferralStats(database.DB, &user)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "ดึงข้อมูลไม่สำเร็จ"})
+	}
+	return c.JSON(stats)
+}
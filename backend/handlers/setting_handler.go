@@ -3,6 +3,7 @@ package handlers
 import (
 	"github.com/PawornpratKongdaeng/soccer/database"
 	"github.com/PawornpratKongdaeng/soccer/models"
+	"github.com/PawornpratKongdaeng/soccer/services"
 	"github.com/gofiber/fiber/v2"
 )
 
@@ -15,12 +16,16 @@ func GetSettings(c *fiber.Ctx) error {
 		settings = models.SystemSetting{
 			ID:        1, 
 			SiteName:  "Soccer App", 
-			MinBet:    50,
-			MaxBet:    50000,
-			MaxPayout: 200000,
 		}
 		database.DB.Create(&settings)
 	}
+
+	// ขั้นต่ำ/เพดานแยกตามสกุลเงิน
+	limits, err := services.CurrencyLimits(database.DB)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "ดึงข้อมูลไม่สำเร็จ"})
+	}
+	settings.CurrencyLimits = limits
 	return c.JSON(settings)
 }
 
@@ -39,6 +44,13 @@ func UpdateSettings(c *fiber.Ctx) error {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to update settings"})
 	}
 
+	// ส่ง currency_limits มาด้วย = อัปเดตขั้นต่ำ/เพดานของสกุลนั้น
+	for _, limit := range body.CurrencyLimits {
+		if _, err := services.SaveCurrencyLimit(database.DB, limit); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "ขั้นต่ำ/เพดานของสกุล " + limit.Currency + " ไม่ถูกต้อง"})
+		}
+	}
+
 	// ✅ เปลี่ยนจาก fiber.H เป็น fiber.Map
 	return c.JSON(fiber.Map{
 		"message": "Settings updated successfully",
@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"sort"
+	"time"
+
+	"github.com/PawornpratKongdaeng/soccer/database"
+	"github.com/PawornpratKongdaeng/soccer/models"
+	"github.com/PawornpratKongdaeng/soccer/services"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// filterSettledRange: กรองช่วงวันที่เคลียร์บิล (?start=2024-01-01&end=2024-01-31)
+func filterSettledRange(c *fiber.Ctx, query *gorm.DB) *gorm.DB {
+	if start := c.Query("start"); start != "" {
+		query = query.Where("share_allocations.settled_at >= ?", start)
+	}
+	if end := c.Query("end"); end != "" {
+		query = query.Where("share_allocations.settled_at < (?::date + 1)", end)
+	}
+	return query
+}
+
+// GET /api/v3/agent/share/pnl
+// [AGENT/MASTER] ได้เสียตามหุ้นที่ตัวเองถือ แยกตามสมาชิก
+func GetMySharePnL(c *fiber.Ctx) error {
+	holderID := GetUserID(c)
+
+	type MemberRow struct {
+		MemberID    uint    `json:"member_id"`
+		Username    string  `json:"username"`
+		Tickets     int64   `json:"tickets"`
+		Stake       float64 `json:"stake"`
+		MemberWL    float64 `json:"member_win_loss"`
+		ShareWL     float64 `json:"share_win_loss"` // ได้เสียส่วนของเรา
+		AvgSharePct float64 `json:"avg_share_pct"`
+	}
+
+	var rows []MemberRow
+	query := database.DB.Table("share_allocations").
+		Select("share_allocations.member_id, users.username, COUNT(*) as tickets, "+
+			"COALESCE(SUM(share_allocations.stake), 0) as stake, "+
+			"COALESCE(SUM(share_allocations.member_win_loss), 0) as member_wl, "+
+			"COALESCE(SUM(share_allocations.amount), 0) as share_wl, "+
+			"COALESCE(AVG(share_allocations.share_pct), 0) as avg_share_pct").
+		Joins("LEFT JOIN users ON users.id = share_allocations.member_id").
+		Where("share_allocations.holder_id = ?", holderID)
+	query = filterSettledRange(c, query)
+
+	if err := query.Group("share_allocations.member_id, users.username").Scan(&rows).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "ดึงข้อมูลไม่สำเร็จ"})
+	}
+
+	var totalStake, totalShare float64
+	for _, r := range rows {
+		totalStake += r.Stake
+		totalShare += r.ShareWL
+	}
+	if rows == nil {
+		rows = []MemberRow{}
+	}
+
+	return c.JSON(fiber.Map{
+		"members":        rows,
+		"total_stake":    totalStake,
+		"total_share_wl": totalShare,
+		"holder_id":      holderID,
+	})
+}
+
+// GET /api/v3/agent/share/tickets
+// [AGENT/MASTER] รายการได้เสียตามหุ้นรายบิล
+func GetMyShareTickets(c *fiber.Ctx) error {
+	holderID := GetUserID(c)
+
+	var allocations []models.ShareAllocation
+	query := database.DB.Model(&models.ShareAllocation{}).
+		Where("holder_id = ?", holderID).
+		Order("settled_at desc")
+	query = filterSettledRange(c, query)
+
+	if err := query.Limit(500).Find(&allocations).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "ดึงข้อมูลไม่สำเร็จ"})
+	}
+	return c.JSON(allocations)
+}
+
+// GET /api/v3/agent/share/positions
+// [AGENT/MASTER] ยอดถือสู้ในบิลที่ยังไม่เคลียร์ของสายงาน
+func GetMyOpenPositions(c *fiber.Ctx) error {
+	holderID := GetUserID(c)
+
+	positions, err := services.OpenPositions(database.DB, holderID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "ดึงข้อมูลไม่สำเร็จ"})
+	}
+
+	var totalStake, totalExposure models.Money
+	for _, p := range positions {
+		totalStake += p.Stake.Percent(p.SharePct)
+		totalExposure += p.Exposure
+	}
+
+	return c.JSON(fiber.Map{
+		"positions":      positions,
+		"total_stake":    totalStake,
+		"total_exposure": totalExposure,
+	})
+}
+
+// GET /api/v3/admin/share/pnl
+// [ADMIN] สรุปได้เสียตามหุ้นของทุกชั้น (holder_id = null คือบริษัท)
+// ยอดเป็นสกุลหลัก (models.BaseCurrency) แปลงด้วยเรทสิ้นวันที่เคลียร์บิล (บริษัทถือหุ้นได้หลายสกุลจากหลายสายงาน)
+func GetSharePnLSummary(c *fiber.Ctx) error {
+	type HolderRow struct {
+		HolderID   *uint   `json:"holder_id"`
+		Username   string  `json:"username"`
+		HolderRole string  `json:"holder_role"`
+		Tickets    int64   `json:"tickets"`
+		Stake      float64 `json:"stake"`
+		ShareWL    float64 `json:"share_win_loss"`
+	}
+	type dayRow struct {
+		HolderID   *uint
+		Username   string
+		HolderRole string
+		Currency   string
+		Day        time.Time
+		Tickets    int64
+		Stake      models.Money
+		ShareWL    models.Money
+	}
+
+	var days []dayRow
+	day := services.BangkokDaySQL("share_allocations.settled_at")
+	query := database.DB.Table("share_allocations").
+		Select("share_allocations.holder_id, COALESCE(users.username, 'company') as username, share_allocations.holder_role, " +
+			"members.currency, " + day + " as day, " +
+			"COUNT(*) as tickets, COALESCE(SUM(share_allocations.stake), 0) as stake, " +
+			"COALESCE(SUM(share_allocations.amount), 0) as share_wl").
+		Joins("LEFT JOIN users ON users.id = share_allocations.holder_id").
+		Joins("LEFT JOIN users members ON members.id = share_allocations.member_id")
+	query = filterSettledRange(c, query)
+
+	if err := query.Group("share_allocations.holder_id, users.username, share_allocations.holder_role, members.currency, " + day).
+		Scan(&days).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "ดึงข้อมูลไม่สำเร็จ"})
+	}
+
+	book, err := services.LoadRateBook(database.DB)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "ดึงข้อมูลไม่สำเร็จ"})
+	}
+
+	// รวมรายวัน/รายสกุลกลับเป็นแถวละผู้ถือหุ้น
+	type holderKey struct {
+		HolderID uint
+		Role     string
+	}
+	totals := make(map[holderKey]*HolderRow)
+	stakes := make(map[holderKey]models.Money)
+	shares := make(map[holderKey]models.Money)
+	var order []holderKey
+	for _, d := range days {
+		key := holderKey{Role: d.HolderRole}
+		if d.HolderID != nil {
+			key.HolderID = *d.HolderID
+		}
+		if totals[key] == nil {
+			totals[key] = &HolderRow{HolderID: d.HolderID, Username: d.Username, HolderRole: d.HolderRole}
+			order = append(order, key)
+		}
+		stake, err := book.DayToBase(d.Stake, d.Currency, d.Day)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "ยังไม่ได้ตั้งอัตราแลกเปลี่ยน (" + err.Error() + ")"})
+		}
+		share, err := book.DayToBase(d.ShareWL, d.Currency, d.Day)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "ยังไม่ได้ตั้งอัตราแลกเปลี่ยน (" + err.Error() + ")"})
+		}
+		totals[key].Tickets += d.Tickets
+		stakes[key] += stake
+		shares[key] += share
+	}
+
+	rows := make([]HolderRow, 0, len(order))
+	for _, key := range order {
+		row := *totals[key]
+		row.Stake = stakes[key].Float64()
+		row.ShareWL = shares[key].Float64()
+		rows = append(rows, row)
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].ShareWL > rows[j].ShareWL })
+
+	return c.JSON(rows)
+}
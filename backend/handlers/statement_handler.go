@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/PawornpratKongdaeng/soccer/database"
+	"github.com/PawornpratKongdaeng/soccer/services"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// renderStatement: ส่ง Statement ของ userID ตาม ?format=json|csv|pdf (ค่าเริ่มต้น json)
+func renderStatement(c *fiber.Ctx, userID uint) error {
+	from, to, err := services.StatementRange(c.Query("start"), c.Query("end"), time.Now())
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "ช่วงวันที่ไม่ถูกต้อง (ไม่เกิน 366 วัน)"})
+	}
+
+	st, err := services.BuildStatement(database.DB, userID, from, to)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": "ไม่พบสมาชิก"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "ดึงข้อมูลไม่สำเร็จ"})
+	}
+
+	fileName := fmt.Sprintf("statement_%s_%s_%s", st.Username, c.Query("start", from.Format("2006-01-02")), to.AddDate(0, 0, -1).Format("2006-01-02"))
+
+	switch c.Query("format") {
+	case "csv":
+		data, err := st.CSV()
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "สร้างไฟล์ไม่สำเร็จ"})
+		}
+		c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+		c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s.csv"`, fileName))
+		return c.Send(data)
+	case "pdf":
+		c.Set(fiber.HeaderContentType, "application/pdf")
+		c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`inline; filename="%s.pdf"`, fileName))
+		return c.Send(st.PDF())
+	}
+	return c.JSON(st)
+}
+
+// GET /api/v3/user/statement?start=2024-01-01&end=2024-01-31&format=csv
+// [USER] Statement ของตัวเอง
+func GetMyStatement(c *fiber.Ctx) error {
+	userID := getIDFromLocals(c)
+	if userID == 0 {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	return renderStatement(c, userID)
+}
+
+// GET /api/v3/admin/users/:id/statement
+// GET /api/v3/agent/members/:id/statement (เฉพาะสมาชิกในสายงาน)
+func GetMemberStatement(c *fiber.Ctx) error {
+	memberID, err := c.ParamsInt("id")
+	if err != nil || memberID <= 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "รหัสสมาชิกไม่ถูกต้อง"})
+	}
+
+	if role, _ := c.Locals("role").(string); role != "admin" {
+		viewerID := GetUserID(c)
+		if uint(memberID) != viewerID && !services.IsInDownline(database.DB, viewerID, uint(memberID)) {
+			return c.Status(403).JSON(fiber.Map{"error": "สมาชิกท่านนี้ไม่ได้อยู่ในสายงานของท่าน"})
+		}
+	}
+	return renderStatement(c, uint(memberID))
+}
@@ -1,15 +1,17 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
-	"os"
-	"strconv"
+	"strings"
 	"time"
 
 	"github.com/PawornpratKongdaeng/soccer/database"
 	"github.com/PawornpratKongdaeng/soccer/models"
+	"github.com/PawornpratKongdaeng/soccer/services"
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // [USER] แจ้งฝากเงิน (แก้ไขจาก Supabase เป็น Local Storage)
@@ -22,10 +24,17 @@ func CreateDeposit(c *fiber.Ctx) error {
 		userID = val.(uint)
 	}
 
-	amountStr := c.FormValue("amount")
-	amount, _ := strconv.ParseFloat(amountStr, 64)
-	if amount < 100 {
-		return c.Status(400).JSON(fiber.Map{"error": "ยอดฝากขั้นต่ำ 100 บาท"})
+	limit, err := limitForUser(userID)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "ไม่พบผู้ใช้งาน"})
+	}
+	amount, _ := models.ParseMoney(c.FormValue("amount"))
+	if amount < limit.MinDeposit {
+		return c.Status(400).JSON(fiber.Map{"error": "ยอดฝากขั้นต่ำ " + formatAmount(limit.MinDeposit, limit.Currency)})
+	}
+	promotionID, err := depositPromotion(c)
+	if err != nil {
+		return promotionError(c, err)
 	}
 
 	fileHeader, err := c.FormFile("slip")
@@ -33,30 +42,32 @@ func CreateDeposit(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "กรุณาแนบไฟล์สลิป"})
 	}
 
-	uploadDir := "./uploads"
-	os.MkdirAll(uploadDir, 0755)
-
-	fileName := fmt.Sprintf("slip_%d_%d_%s", userID, time.Now().Unix(), fileHeader.Filename)
-	filePath := fmt.Sprintf("%s/%s", uploadDir, fileName)
-
-	if err := c.SaveFile(fileHeader, filePath); err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "บันทึกไฟล์ล้มเหลว"})
+	upload, err := services.SaveSlipUpload(fileHeader)
+	if err != nil {
+		return slipUploadError(c, err)
 	}
 
 	tx := models.Transaction{
-		UserID:    userID,
-		Amount:    amount,
-		Type:      "deposit",
-		Status:    "pending",
-		SlipURL:   "/uploads/" + fileName, // Path สำหรับ Static Route
-		CreatedAt: time.Now(),
+		UserID:        userID,
+		Amount:        amount,
+		Type:          "deposit",
+		BankAccountID: services.AssignedBankAccountID(database.DB, userID),
+		Status:        "pending",
+		SlipPath:      uploadURL(upload.Key),
+		SlipThumbPath: uploadURL(upload.ThumbKey),
+		PromotionID:   promotionID,
+		CreatedAt:     time.Now(),
 	}
 
-	database.DB.Create(&tx)
+	if err := database.DB.Create(&tx).Error; err != nil {
+		upload.Remove()
+		return c.Status(500).JSON(fiber.Map{"error": "บันทึกรายการล้มเหลว"})
+	}
+	recordDepositSlip(upload, &tx)
 	return c.JSON(fiber.Map{"message": "แจ้งฝากสำเร็จ", "data": tx})
 }
 
-// [USER] แจ้งถอนเงิน
+// [USER] แจ้งถอนเงิน (POST /user/withdraw และเส้นทางเดิม /transaction/withdraw-request)
 func CreateWithdraw(c *fiber.Ctx) error {
 	val := c.Locals("user_id")
 	var userID uint
@@ -68,11 +79,9 @@ func CreateWithdraw(c *fiber.Ctx) error {
 		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
 	}
 
+	// โอนเข้าบัญชีรับเงินในโปรไฟล์เท่านั้น (ไม่รับเลขบัญชีจาก Request)
 	type Request struct {
-		Amount        float64 `json:"amount"`
-		BankName      string  `json:"bank_name"`
-		AccountNumber string  `json:"account_number"`
-		AccountName   string  `json:"account_name"`
+		Amount models.Money `json:"amount"`
 	}
 
 	var body Request
@@ -80,49 +89,69 @@ func CreateWithdraw(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "ข้อมูลไม่ถูกต้อง"})
 	}
 
-	if body.Amount < 100 {
-		return c.Status(400).JSON(fiber.Map{"error": "ถอนขั้นต่ำ 100 บาท"})
-	}
-
 	return database.DB.Transaction(func(tx *gorm.DB) error {
+		// ล็อกสมาชิกไว้จนบันทึกรายการเสร็จ กันคำขอพร้อมกันผ่านวงเงิน/จำนวนครั้ง/เทิร์นไปพร้อมกัน
 		var user models.User
-		if err := tx.First(&user, userID).Error; err != nil {
-			return err
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, userID).Error; err != nil {
+			return c.Status(404).JSON(fiber.Map{"error": "ไม่พบผู้ใช้งาน"})
 		}
 
+		limit, err := services.CurrencyLimitFor(tx, user.Currency)
+		if err != nil {
+			return err
+		}
+		if body.Amount < limit.MinWithdraw {
+			return c.Status(400).JSON(fiber.Map{"error": "ถอนขั้นต่ำ " + formatAmount(limit.MinWithdraw, limit.Currency)})
+		}
 		if user.Credit < body.Amount {
-			return fmt.Errorf("ยอดเงินไม่เพียงพอ")
+			return c.Status(400).JSON(fiber.Map{"error": "ยอดเงินไม่เพียงพอ"})
 		}
-
-		// หักเครดิต
-		if err := tx.Model(&user).Update("credit", gorm.Expr("credit - ?", body.Amount)).Error; err != nil {
+		if err := services.CheckWithdrawable(tx, userID); err != nil {
+			if errors.Is(err, services.ErrWageringIncomplete) {
+				return c.Status(400).JSON(fiber.Map{"error": "ยังทำเทิร์นโบนัสไม่ครบ (" + err.Error() + ")"})
+			}
 			return err
 		}
+		if err := services.CheckWithdrawRules(tx, &user, body.Amount, time.Now()); err != nil {
+			return withdrawRuleError(c, err)
+		}
 
 		newTx := models.Transaction{
-			UserID:        userID,
-			Amount:        body.Amount,
-			Type:          "withdraw",
-			Status:        "pending",
-			BankName:      body.BankName,
-			BankAccount:   body.AccountNumber,
-			AccountName:   body.AccountName,
-			BalanceBefore: user.Credit,
-			BalanceAfter:  user.Credit - body.Amount,
-			CreatedAt:     time.Now(),
+			UserID:      userID,
+			Amount:      body.Amount,
+			Type:        "withdraw",
+			Status:      "pending",
+			BankName:    user.BankName,
+			BankAccount: user.BankAccount,
+			AccountName: accountHolder(&user),
+			CreatedAt:   time.Now(),
 		}
 
 		if err := tx.Create(&newTx).Error; err != nil {
 			return err
 		}
 
-		return c.JSON(fiber.Map{"message": "ส่งคำขอถอนเงินแล้ว"})
+		// ย้ายยอดไปพักไว้ (ยอดใช้ได้ลด ยอดพักเพิ่ม) จนกว่า Admin จะอนุมัติ/ปฏิเสธ
+		if err := services.PostWithdrawRequest(tx, &newTx); err != nil {
+			return err
+		}
+
+		return c.JSON(fiber.Map{
+			"message":     "ส่งคำขอถอนเงินแล้ว",
+			"available":   newTx.BalanceAfter,
+			"new_credit":  newTx.BalanceAfter, // ชื่อเดิมของเส้นทาง /transaction/withdraw-request
+			"held_credit": user.HeldCredit + newTx.Amount,
+		})
 	})
 }
 
 // [ADMIN] อนุมัติการฝาก (Approve Deposit)
 func ApproveDepositSlipOnly(c *fiber.Ctx) error {
 	id := c.Params("id")
+	var req struct {
+		OverrideReason string `json:"override_reason"` // จำเป็นเมื่อสลิปติดธงซ้ำ
+	}
+	c.BodyParser(&req)
 
 	return database.DB.Transaction(func(tx *gorm.DB) error {
 		var transaction models.Transaction
@@ -133,6 +162,9 @@ func ApproveDepositSlipOnly(c *fiber.Ctx) error {
 		if transaction.Status != "pending" {
 			return c.Status(400).JSON(fiber.Map{"error": "รายการนี้ถูกดำเนินการไปแล้ว"})
 		}
+		if err := services.CheckSlipApproval(tx, transaction.ID, strings.TrimSpace(req.OverrideReason), GetUserID(c)); err != nil {
+			return slipApprovalError(c, err)
+		}
 
 		// อัปเดตสถานะเป็น verified หรือ approved โดย "ไม่บวก Credit"
 		transaction.Status = "approved"
@@ -154,7 +186,7 @@ func RejectWithdraw(c *fiber.Ctx) error {
 
 	return database.DB.Transaction(func(dbTx *gorm.DB) error {
 		var transaction models.Transaction
-		if err := dbTx.First(&transaction, txID).Error; err != nil {
+		if err := dbTx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&transaction, txID).Error; err != nil {
 			return err
 		}
 
@@ -163,11 +195,14 @@ func RejectWithdraw(c *fiber.Ctx) error {
 		}
 
 		// คืนเงินให้ลูกค้า
-		dbTx.Model(&models.User{}).Where("id = ?", transaction.UserID).
-			Update("credit", gorm.Expr("credit + ?", transaction.Amount))
+		if err := services.PostWithdrawRefund(dbTx, &transaction, nil); err != nil {
+			return err
+		}
 
 		transaction.Status = "rejected"
-		dbTx.Save(&transaction)
+		if err := dbTx.Save(&transaction).Error; err != nil {
+			return err
+		}
 
 		return c.JSON(fiber.Map{"message": "ปฏิเสธรายการและคืนเครดิตเรียบร้อย"})
 	})
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/PawornpratKongdaeng/soccer/database"
+	"github.com/PawornpratKongdaeng/soccer/models"
+	"github.com/PawornpratKongdaeng/soccer/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+// uploadURL: ที่อยู่ไฟล์ใน Storage ที่เก็บลง Transaction.SlipPath (ไม่ได้เปิดเป็น URL สาธารณะ)
+func uploadURL(key string) string {
+	return "/uploads/" + key
+}
+
+// GET /api/v3/slips/:id?size=thumb
+// เปิดสลิปด้วย Session (Header หรือ Cookie) เจ้าของ / Admin / Agent ต้นสาย
+func ViewSlip(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*models.User)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "ไม่พบสลิป"})
+	}
+	return sendSlip(c, user, uint(id), services.NormalizeSlipVariant(c.Query("size")), "session")
+}
+
+// GET /api/v3/slips/:id/url
+// ขอ URL ลงลายเซ็นอายุสั้นไว้ใส่ <img> (ตรวจสิทธิ์อีกครั้งตอนเปิด)
+func GetSlipURL(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*models.User)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	var t models.Transaction
+	if err := database.DB.First(&t, c.Params("id")).Error; err != nil || t.SlipPath == "" {
+		return c.Status(404).JSON(fiber.Map{"error": "ไม่พบสลิป"})
+	}
+	if !services.CanViewSlip(database.DB, user, &t) {
+		return c.Status(403).JSON(fiber.Map{"error": "ไม่มีสิทธิ์ดูสลิปนี้"})
+	}
+
+	now := time.Now()
+	url, exp := services.SignSlipURL(t.ID, user.ID, "full", now)
+	thumbURL, _ := services.SignSlipURL(t.ID, user.ID, "thumb", now)
+	return c.JSON(fiber.Map{"url": url, "thumb_url": thumbURL, "expires_at": exp})
+}
+
+// GET /api/v3/slip-files/:id?size=&uid=&exp=&sig=
+// [PUBLIC] เปิดสลิปจาก URL ลงลายเซ็น (ไม่ต้องมี Token แต่ URL หมดอายุตาม services.SlipURLTTL)
+func ServeSignedSlip(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "ไม่พบสลิป"})
+	}
+	uid, _ := strconv.ParseUint(c.Query("uid"), 10, 64)
+	exp, _ := strconv.ParseInt(c.Query("exp"), 10, 64)
+	variant := services.NormalizeSlipVariant(c.Query("size"))
+
+	if err := services.VerifySlipURL(uint(id), uint(uid), variant, exp, c.Query("sig"), time.Now()); err != nil {
+		return c.Status(403).JSON(fiber.Map{"error": "ลิงก์สลิปหมดอายุหรือไม่ถูกต้อง"})
+	}
+	var viewer models.User
+	if err := database.DB.First(&viewer, uid).Error; err != nil {
+		return c.Status(403).JSON(fiber.Map{"error": "ลิงก์สลิปหมดอายุหรือไม่ถูกต้อง"})
+	}
+	return sendSlip(c, &viewer, uint(id), variant, "signed")
+}
+
+func sendSlip(c *fiber.Ctx, viewer *models.User, id uint, variant, via string) error {
+	data, contentType, err := services.OpenSlip(database.DB, viewer, id, variant, via, c.IP())
+	switch {
+	case errors.Is(err, services.ErrSlipForbidden):
+		return c.Status(403).JSON(fiber.Map{"error": "ไม่มีสิทธิ์ดูสลิปนี้"})
+	case errors.Is(err, services.ErrSlipNotFound):
+		return c.Status(404).JSON(fiber.Map{"error": "ไม่พบสลิป"})
+	case err != nil:
+		log.Printf("❌ [Slip] open #%d: %v", id, err)
+		return c.Status(500).JSON(fiber.Map{"error": "เปิดสลิปไม่สำเร็จ"})
+	}
+
+	c.Set(fiber.HeaderContentType, contentType)
+	c.Set(fiber.HeaderXContentTypeOptions, "nosniff")
+	c.Set(fiber.HeaderCacheControl, "private, no-store")
+	return c.Send(data)
+}
+
+// GET /api/v3/admin/slip-access?transaction_id=&viewer_id=&owner_id=
+// [ADMIN] ประวัติการเปิดดูสลิปของ Admin / Agent
+func GetSlipAccessLogs(c *fiber.Ctx) error {
+	query := database.DB.Order("id desc")
+	for _, field := range []string{"transaction_id", "viewer_id", "owner_id"} {
+		if v := c.Query(field); v != "" {
+			query = query.Where(field+" = ?", v)
+		}
+	}
+
+	var logs []models.SlipAccessLog
+	if err := query.Limit(500).Find(&logs).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "ดึงข้อมูลไม่สำเร็จ"})
+	}
+	return c.JSON(logs)
+}
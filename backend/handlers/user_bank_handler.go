@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"strings"
+	"time"
+
+	"github.com/PawornpratKongdaeng/soccer/database"
+	"github.com/PawornpratKongdaeng/soccer/models"
+	"github.com/PawornpratKongdaeng/soccer/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+type userBankRequest struct {
+	BankName    string `json:"bank_name"`
+	BankAccount string `json:"bank_account"`
+	Verified    bool   `json:"verified"` // Admin เท่านั้น: ยืนยันบัญชีให้เลย
+}
+
+// PUT /api/v3/user/bank {"bank_name": "KBANK", "bank_account": "1234567890"}
+// [USER] ตั้ง/เปลี่ยนบัญชีรับเงินถอน (ต้องรอ Admin ยืนยัน และพ้นช่วงรอหลังเปลี่ยนจึงถอนได้)
+func UpdateMyBank(c *fiber.Ctx) error {
+	var req userBankRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "ข้อมูลไม่ถูกต้อง"})
+	}
+	if strings.TrimSpace(req.BankName) == "" || strings.TrimSpace(req.BankAccount) == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "กรุณากรอกธนาคารและเลขบัญชี"})
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, GetUserID(c)).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "ไม่พบผู้ใช้งาน"})
+	}
+	if err := services.SetUserBank(database.DB, &user, req.BankName, req.BankAccount, nil, time.Now()); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "บันทึกบัญชีไม่สำเร็จ"})
+	}
+	return c.JSON(fiber.Map{"message": "บันทึกบัญชีรับเงินแล้ว รอแอดมินยืนยันก่อนแจ้งถอน", "user": user})
+}
+
+// PUT /api/v3/admin/users/:id/bank {"bank_name": "KBANK", "bank_account": "1234567890", "verified": true}
+// [ADMIN] แก้บัญชีรับเงินถอนของสมาชิก
+func UpdateUserBank(c *fiber.Ctx) error {
+	var req userBankRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "ข้อมูลไม่ถูกต้อง"})
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, c.Params("id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "ไม่พบผู้ใช้งาน"})
+	}
+	var verifiedBy *uint
+	if req.Verified {
+		adminID := GetUserID(c)
+		verifiedBy = &adminID
+	}
+	if err := services.SetUserBank(database.DB, &user, req.BankName, req.BankAccount, verifiedBy, time.Now()); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "บันทึกบัญชีไม่สำเร็จ"})
+	}
+	return c.JSON(fiber.Map{"message": "บันทึกบัญชีรับเงินเรียบร้อย", "user": user})
+}
+
+// POST /api/v3/admin/users/:id/bank/verify
+// [ADMIN] ยืนยันบัญชีรับเงินถอนของสมาชิก (ช่วงรอหลังเปลี่ยนบัญชียังนับต่อ)
+func VerifyUserBank(c *fiber.Ctx) error {
+	var user models.User
+	if err := database.DB.First(&user, c.Params("id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "ไม่พบผู้ใช้งาน"})
+	}
+	if user.BankAccount == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "สมาชิกยังไม่มีบัญชีรับเงิน"})
+	}
+	adminID := GetUserID(c)
+	if err := services.SetUserBank(database.DB, &user, user.BankName, user.BankAccount, &adminID, time.Now()); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "บันทึกไม่สำเร็จ"})
+	}
+	return c.JSON(fiber.Map{"message": "ยืนยันบัญชีรับเงินเรียบร้อย", "user": user})
+}
@@ -2,10 +2,10 @@ package handlers
 
 import (
 	"fmt"
-	"time"
 
 	"github.com/PawornpratKongdaeng/soccer/database"
 	"github.com/PawornpratKongdaeng/soccer/models"
+	"github.com/PawornpratKongdaeng/soccer/services"
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
 )
@@ -24,15 +24,26 @@ func getIDFromLocals(c *fiber.Ctx) uint {
 
 // GET /api/v3/user/balance
 func GetBalance(c *fiber.Ctx) error {
+	userID := getIDFromLocals(c)
+	if userID == 0 {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
 	var user models.User
-	// สมมติว่าเป็น User ID 1 เสมอ
-	if err := database.DB.First(&user, 1).Error; err != nil {
+	if err := database.DB.First(&user, userID).Error; err != nil {
 		return c.Status(404).JSON(fiber.Map{"error": "User not found"})
 	}
 
+	// balance = ยอดใช้ได้, held = ยอดพักรอถอน, bonus = กระเป๋าโบนัส (แทงได้แต่ถอนไม่ได้), total = รวมทั้งหมดที่เป็นของสมาชิก
 	return c.JSON(fiber.Map{
-		"username": user.Username,
-		"balance":  user.Credit,
+		"username":  user.Username,
+		"currency":  user.Currency,
+		"balance":   user.Credit,
+		"available": user.AvailableCredit(),
+		"held":      user.HeldCredit,
+		"bonus":     user.BonusCredit,
+		"playable":  user.PlayableCredit(),
+		"total":     user.Credit + user.HeldCredit + user.BonusCredit,
 	})
 }
 
@@ -49,6 +60,7 @@ func GetProfile(c *fiber.Ctx) error {
 		"id":       user.ID,
 		"username": user.Username,
 		"balance":  user.Credit,
+		"held":     user.HeldCredit,
 		"role":     user.Role,
 		"phone":    user.Phone, // ✅ เพิ่มบรรทัดนี้
 	})
@@ -61,7 +73,7 @@ func GetMe(c *fiber.Ctx) error {
 	}
 
 	var user models.User
-	if err := database.DB.Select("id", "username", "role", "credit", "phone", "full_name").First(&user, userID).Error; err != nil {
+	if err := database.DB.Select("id", "username", "role", "credit", "held_credit", "phone", "full_name").First(&user, userID).Error; err != nil {
 		return c.Status(404).JSON(fiber.Map{"error": "User not found"})
 	}
 
@@ -80,9 +92,9 @@ func UpdateUserCredit(c *fiber.Ctx) error {
 	targetUserID := c.Params("id")
 
 	type Request struct {
-		Amount float64 `json:"amount"`
-		Type   string  `json:"type"` // "deposit" หรือ "withdraw"
-		Note   string  `json:"note"`
+		Amount models.Money `json:"amount"`
+		Type   string       `json:"type"` // "deposit" หรือ "withdraw"
+		Note   string       `json:"note"`
 	}
 	var body Request
 	if err := c.BodyParser(&body); err != nil {
@@ -100,44 +112,45 @@ func UpdateUserCredit(c *fiber.Ctx) error {
 			return err
 		}
 
-		var newBalance float64
-		balanceBefore := user.Credit
+		if agent.Currency != user.Currency {
+			return fmt.Errorf("สกุลเงินของกระเป๋าไม่ตรงกัน (%s / %s)", agent.Currency, user.Currency)
+		}
+
+		var journal *models.LedgerJournal
+		var err error
+		ledger := services.Journal{Type: "transfer", Note: body.Note, CreatedBy: &agentID}
 
 		if body.Type == "deposit" {
 			if agent.Credit < body.Amount {
-				return fmt.Errorf("ยอดเงินในสต็อกของคุณไม่เพียงพอ (คงเหลือ: %.2f)", agent.Credit)
+				return fmt.Errorf("ยอดเงินในสต็อกของคุณไม่เพียงพอ (คงเหลือ: %s)", agent.Credit)
 			}
 			// หักเงินเอเย่นต์ และ เพิ่มเงินยูสเซอร์
-			tx.Model(&agent).Update("credit", gorm.Expr("credit - ?", body.Amount))
-			tx.Model(&user).Update("credit", gorm.Expr("credit + ?", body.Amount))
-			newBalance = balanceBefore + body.Amount
+			journal, err = services.PostTransfer(tx, agent.ID, user.ID, body.Amount, ledger)
 		} else {
 			if user.Credit < body.Amount {
 				return fmt.Errorf("ยอดเงินของลูกค้าไม่เพียงพอ")
 			}
 			// หักเงินยูสเซอร์ และ คืนเงินเข้าสต็อกเอเย่นต์
-			tx.Model(&user).Update("credit", gorm.Expr("credit - ?", body.Amount))
-			tx.Model(&agent).Update("credit", gorm.Expr("credit + ?", body.Amount))
-			newBalance = balanceBefore - body.Amount
+			journal, err = services.PostTransfer(tx, user.ID, agent.ID, body.Amount, ledger)
 		}
-
-		// บันทึก Log
-		newTx := models.Transaction{
-			UserID:        user.ID,
-			AdminID:       &agentID,
-			Amount:        body.Amount,
-			Type:          body.Type,
-			Status:        "approved",
-			BalanceBefore: balanceBefore,
-			BalanceAfter:  newBalance,
-			Note:          body.Note,
-			CreatedAt:     time.Now(),
+		if err != nil {
+			return err
 		}
 
-		if err := tx.Create(&newTx).Error; err != nil {
+		// บันทึก Log ทั้งฝั่งลูกค้าและฝั่งเอเย่นต์
+		newTx, err := services.RecordTransaction(tx, journal, user.ID, models.Transaction{
+			AdminID: &agentID,
+			Type:    creditTransferType(body.Type),
+			Status:  "approved",
+			Note:    body.Note,
+		})
+		if err != nil {
+			return err
+		}
+		if err := services.RecordTransferSide(tx, journal, agent.ID, &agentID, body.Note); err != nil {
 			return err
 		}
-		return c.JSON(fiber.Map{"message": "ดำเนินการสำเร็จ", "balance_after": newBalance})
+		return c.JSON(fiber.Map{"message": "ดำเนินการสำเร็จ", "balance_after": newTx.BalanceAfter})
 	})
 }
 func GetMyBets(c *fiber.Ctx) error {
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/PawornpratKongdaeng/soccer/database"
+	"github.com/PawornpratKongdaeng/soccer/models"
+	"github.com/PawornpratKongdaeng/soccer/services"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// withdrawalError: แปลง error ของขั้นตอนถอนเป็นข้อความให้ Admin
+func withdrawalError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrWithdrawalState):
+		return c.Status(400).JSON(fiber.Map{"error": "รายการไม่อยู่ในขั้นตอนที่ทำรายการนี้ได้"})
+	case errors.Is(err, services.ErrAlreadyApproved):
+		return c.Status(409).JSON(fiber.Map{"error": "คุณอนุมัติรายการนี้ไปแล้ว ต้องให้ Admin คนอื่นอนุมัติอีกคน"})
+	case errors.Is(err, services.ErrApprovalCapReached):
+		return c.Status(403).JSON(fiber.Map{"error": "เกินเพดานยอดอนุมัติถอนต่อวันของคุณ (" + err.Error() + ")"})
+	case errors.Is(err, services.ErrBankReferenceRequired):
+		return c.Status(400).JSON(fiber.Map{"error": "กรุณาระบุเลขอ้างอิงการโอนของธนาคาร"})
+	case errors.Is(err, services.ErrFailReasonRequired):
+		return c.Status(400).JSON(fiber.Map{"error": "กรุณาระบุสาเหตุที่โอนไม่สำเร็จ"})
+	case errors.Is(err, services.ErrPayoutInFlight):
+		return c.Status(409).JSON(fiber.Map{"error": "รายการนี้สั่งจ่ายผ่านผู้ให้บริการอยู่ รอผลก่อน"})
+	}
+	return err
+}
+
+// withdrawRuleError: แจ้งถอนไม่ผ่านกติกา = 400 พร้อมเหตุผลและ code ให้หน้าเว็บแยกกรณี
+func withdrawRuleError(c *fiber.Ctx, err error) error {
+	var rule *services.WithdrawRuleError
+	if errors.As(err, &rule) {
+		return c.Status(400).JSON(fiber.Map{"error": rule.Reason, "code": rule.Code})
+	}
+	log.Printf("❌ [Withdraw] check rules: %v", err)
+	return c.Status(500).JSON(fiber.Map{"error": "ตรวจสอบเงื่อนไขการถอนไม่สำเร็จ"})
+}
+
+// accountHolder: ชื่อบัญชีรับเงินของสมาชิก (ใช้ชื่อในโปรไฟล์)
+func accountHolder(user *models.User) string {
+	if user.FullName != "" {
+		return user.FullName
+	}
+	return strings.TrimSpace(user.FirstName + " " + user.LastName)
+}
+
+// GET /api/v3/admin/withdrawals?status=approved
+// [ADMIN] รายการถอนตามขั้นตอน (ค่าเริ่มต้น = อนุมัติแล้วรอโอน) พร้อมประวัติผู้อนุมัติ
+func GetWithdrawals(c *fiber.Ctx) error {
+	status := c.Query("status", "approved")
+	switch status {
+	case "pending", "approved", "paid", "failed", "rejected":
+	default:
+		return c.Status(400).JSON(fiber.Map{"error": "สถานะไม่ถูกต้อง"})
+	}
+
+	var txs []models.Transaction
+	if err := database.DB.Preload("User").Preload("Steps.Admin").
+		Where("type = ? AND status = ?", "withdraw", status).
+		Order("created_at").Limit(500).Find(&txs).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "ดึงข้อมูลไม่สำเร็จ"})
+	}
+	return c.JSON(txs)
+}
+
+// POST /api/v3/admin/withdrawals/:id/paid (multipart: bank_reference, proof = รูปสลิปการโอน)
+// [ADMIN] บันทึกว่าโอนเงินแล้ว ตัดยอดพัก แจ้งสมาชิกและ Agent ต้นสาย
+func PayWithdrawal(c *fiber.Ctx) error {
+	adminID := GetUserID(c)
+
+	var proof *services.SlipUpload
+	if fh, err := c.FormFile("proof"); err == nil {
+		if proof, err = services.SaveSlipUpload(fh); err != nil {
+			return slipUploadError(c, err)
+		}
+	}
+
+	paid := false
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var t models.Transaction
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&t, c.Params("id")).Error; err != nil {
+			return c.Status(404).JSON(fiber.Map{"error": "ไม่พบรายการ"})
+		}
+		if err := services.CheckNoPendingPayout(tx, t.ID); err != nil {
+			return withdrawalError(c, err)
+		}
+		if err := services.MarkWithdrawalPaid(tx, &t, adminID, c.FormValue("bank_reference"), proof, time.Now()); err != nil {
+			return withdrawalError(c, err)
+		}
+		paid = true
+		return c.JSON(fiber.Map{"message": "บันทึกการโอนเงินเรียบร้อย", "data": t})
+	})
+	if !paid && proof != nil {
+		proof.Remove()
+	}
+	if err != nil {
+		log.Printf("❌ [Withdraw] paid #%s: %v", c.Params("id"), err)
+		return c.Status(500).JSON(fiber.Map{"error": "บันทึกการโอนเงินไม่สำเร็จ"})
+	}
+	return nil
+}
+
+// POST /api/v3/admin/withdrawals/:id/failed {"reason": "เลขบัญชีปลายทางไม่ถูกต้อง"}
+// [ADMIN] โอนไม่สำเร็จ คืนยอดพักเข้ากระเป๋าสมาชิก
+func FailWithdrawal(c *fiber.Ctx) error {
+	adminID := GetUserID(c)
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "ข้อมูลไม่ถูกต้อง"})
+	}
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		var t models.Transaction
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&t, c.Params("id")).Error; err != nil {
+			return c.Status(404).JSON(fiber.Map{"error": "ไม่พบรายการ"})
+		}
+		if err := services.CheckNoPendingPayout(tx, t.ID); err != nil {
+			return withdrawalError(c, err)
+		}
+		if err := services.FailWithdrawal(tx, &t, adminID, req.Reason, time.Now()); err != nil {
+			return withdrawalError(c, err)
+		}
+		return c.JSON(fiber.Map{"message": "บันทึกโอนไม่สำเร็จและคืนเครดิตเรียบร้อย", "data": t})
+	})
+}
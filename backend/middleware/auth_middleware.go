@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"errors"
 	"strings"
 
 	"github.com/PawornpratKongdaeng/soccer/database"
@@ -12,65 +13,75 @@ import (
 // ต้องใช้ Key เดียวกับที่ใช้ใน handlers/auth.go (Login)
 var jwtKey = []byte("SECRET_KEY_NA_KRUB")
 
-// AuthMiddleware: ตรวจสอบ Token (รองรับทั้ง Header และ Cookie)
-func AuthMiddleware() fiber.Handler {
-	return func(c *fiber.Ctx) error {
-		var tokenString string
-
-		// ---------------------------------------------------------
-		// 1. ลองดึงจาก Header (Authorization: Bearer <token>)
-		// ---------------------------------------------------------
-		authHeader := c.Get("Authorization")
-		if authHeader != "" {
-			parts := strings.Split(authHeader, " ")
-			if len(parts) == 2 && parts[0] == "Bearer" {
-				tokenString = parts[1]
-			}
-		}
-
-		// ---------------------------------------------------------
-		// 2. ถ้าใน Header ไม่มี -> ให้ลองดึงจาก Cookie
-		// (สำคัญมากสำหรับระบบ Subdomain SSO)
-		// ---------------------------------------------------------
-		if tokenString == "" {
-			tokenString = c.Cookies("token") // ชื่อ cookie ต้องตรงกับตอน Set ใน Login
-		}
-
-		// ถ้าหาไม่เจอทั้งคู่ -> Error
-		if tokenString == "" {
-			return c.Status(401).JSON(fiber.Map{"error": "ไม่ได้ Login (No Token)"})
-		}
+var (
+	errNoToken      = errors.New("no token")
+	errInvalidToken = errors.New("invalid or expired token")
+	errTokenClaims  = errors.New("invalid token claims")
+	errUserNotFound = errors.New("user not found")
+)
 
-		// ---------------------------------------------------------
-		// 3. แกะ Token และตรวจสอบความถูกต้อง
-		// ---------------------------------------------------------
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			return jwtKey, nil
-		})
+// bearerToken: Token จาก Header (Authorization: Bearer <token>) ถ้าไม่มีใช้ Cookie "token"
+// (Cookie สำคัญมากสำหรับระบบ Subdomain SSO ชื่อต้องตรงกับตอน Set ใน Login)
+func bearerToken(c *fiber.Ctx) string {
+	if parts := strings.Split(c.Get("Authorization"), " "); len(parts) == 2 && parts[0] == "Bearer" && parts[1] != "" {
+		return parts[1]
+	}
+	return c.Cookies("token")
+}
 
-		if err != nil || !token.Valid {
-			return c.Status(401).JSON(fiber.Map{"error": "Token หมดอายุ หรือไม่ถูกต้อง"})
-		}
+// authenticate: แกะ Token ของ Request แล้วดึง User จาก DB (ใช้ร่วมกันทั้ง AuthMiddleware และ OptionalAuth)
+func authenticate(c *fiber.Ctx) (*models.User, error) {
+	tokenString := bearerToken(c)
+	if tokenString == "" {
+		return nil, errNoToken
+	}
 
-		// 4. *** ฝัง UserID และ Role เข้าไปใน Context ***
-		if claims, ok := token.Claims.(jwt.MapClaims); ok {
-			userID := uint(claims["user_id"].(float64))
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		return jwtKey, nil
+	})
+	if err != nil || !token.Valid {
+		return nil, errInvalidToken
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, errTokenClaims
+	}
+	id, ok := claims["user_id"].(float64)
+	if !ok {
+		return nil, errTokenClaims
+	}
 
-			// ดึง User จาก DB
-			var user models.User
-			if err := database.DB.First(&user, userID).Error; err != nil {
-				return c.Status(401).JSON(fiber.Map{"error": "ไม่พบข้อมูลผู้ใช้ในระบบ"})
-			}
+	var user models.User
+	if err := database.DB.First(&user, uint(id)).Error; err != nil {
+		return nil, errUserNotFound
+	}
+	return &user, nil
+}
 
-			// Save ลง Locals เพื่อใช้ต่อใน Handler ถัดไป
-			c.Locals("user", &user)
-			c.Locals("role", strings.ToLower(user.Role)) // แปลงเป็นตัวเล็กกันพลาด
-			c.Locals("user_id", user.ID)
+// setUser: ฝาก User, Role และ UserID ไว้ใน Locals เพื่อใช้ต่อใน Handler ถัดไป
+func setUser(c *fiber.Ctx, user *models.User) {
+	c.Locals("user", user)
+	c.Locals("role", strings.ToLower(user.Role)) // แปลงเป็นตัวเล็กกันพลาด
+	c.Locals("user_id", user.ID)
+}
 
-			return c.Next()
+// AuthMiddleware: ตรวจสอบ Token (รองรับทั้ง Header และ Cookie)
+func AuthMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, err := authenticate(c)
+		switch {
+		case errors.Is(err, errNoToken):
+			return c.Status(401).JSON(fiber.Map{"error": "ไม่ได้ Login (No Token)"})
+		case errors.Is(err, errInvalidToken):
+			return c.Status(401).JSON(fiber.Map{"error": "Token หมดอายุ หรือไม่ถูกต้อง"})
+		case errors.Is(err, errUserNotFound):
+			return c.Status(401).JSON(fiber.Map{"error": "ไม่พบข้อมูลผู้ใช้ในระบบ"})
+		case err != nil:
+			return c.Status(401).JSON(fiber.Map{"error": "ข้อมูลใน Token ผิดพลาด"})
 		}
 
-		return c.Status(401).JSON(fiber.Map{"error": "ข้อมูลใน Token ผิดพลาด"})
+		setUser(c, user)
+		return c.Next()
 	}
 }
 
@@ -110,3 +121,14 @@ func RequireAdminOrAgent() fiber.Handler {
 		return c.Next()
 	}
 }
+
+// OptionalAuth: มี Token ที่ถูกต้อง = ฝาก user ไว้ใน Locals เหมือน AuthMiddleware, ไม่มี/ไม่ถูกต้อง = ผ่านไปแบบผู้เยี่ยมชม
+// ใช้กับ Route สาธารณะที่ตอบต่างกันเมื่อรู้ว่าเป็นใคร (เช่น บัญชีรับเงินที่แจกให้สมาชิก)
+func OptionalAuth() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if user, err := authenticate(c); err == nil {
+			setUser(c, user)
+		}
+		return c.Next()
+	}
+}
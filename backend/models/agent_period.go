@@ -0,0 +1,53 @@
+package models
+
+import "time"
+
+// สถานะใบแจ้งยอด Agent
+const (
+	InvoiceStatusUnpaid  = "unpaid"
+	InvoiceStatusPartial = "partial"
+	InvoiceStatusSettled = "settled"
+)
+
+// AgentPeriod: รอบเคลียร์ยอด Agent (เช่น จันทร์-อาทิตย์) ปิดแล้วแก้ไม่ได้
+// ตอนปิดรอบ ShareAllocation / CommissionRebate ที่ยังไม่มีรอบจะถูกผูกกับรอบนี้ (ไม่นับซ้ำข้ามรอบ)
+type AgentPeriod struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Start     time.Time `gorm:"uniqueIndex" json:"start"`
+	End       time.Time `json:"end"` // ไม่รวมเวลานี้
+	ClosedBy  *uint     `json:"closed_by"`
+	Invoices  int       `json:"invoices"`
+	CreatedAt time.Time `json:"created_at"` // เวลาปิดรอบ
+}
+
+// AgentInvoice: ใบแจ้งยอดของ Agent 1 คนใน 1 รอบ (ยอดที่ Agent ต้องเคลียร์กับต้นสาย)
+// Amount บวก = Agent ต้องจ่ายต้นสาย, ติดลบ = ต้นสายต้องจ่าย Agent (ทุกยอดเป็นสกุล Currency ของ Agent)
+type AgentInvoice struct {
+	ID               uint       `gorm:"primaryKey" json:"id"`
+	PeriodID         uint       `gorm:"uniqueIndex:idx_agent_invoice" json:"period_id"`
+	AgentID          uint       `gorm:"uniqueIndex:idx_agent_invoice" json:"agent_id"`
+	UplineID         *uint      `gorm:"index" json:"upline_id"` // nil = บริษัท
+	Currency         string     `gorm:"size:3;default:THB" json:"currency"`
+	Tickets          int64      `json:"tickets"`
+	Turnover         Money      `json:"turnover"`          // ยอดแทงของสายงาน
+	MemberWinLoss    Money      `json:"member_win_loss"`   // ได้เสียฝั่งสมาชิก
+	ShareWinLoss     Money      `json:"share_win_loss"`    // ได้เสียตามหุ้นของ Agent เอง (ฝั่งเจ้ามือ)
+	UplineWinLoss    Money      `json:"upline_win_loss"`   // ได้เสียส่วนของชั้นที่อยู่เหนือ Agent (รวมบริษัท)
+	MemberCommission Money      `json:"member_commission"` // ค่าคอมที่ระบบจ่ายให้สมาชิกในสายงานแล้ว (หักจากยอดที่ต้องส่ง)
+	AgentCommission  Money      `json:"agent_commission"`  // ค่าคอมของ Agent เอง (จ่ายเข้ากระเป๋าแล้ว แสดงเพื่ออ้างอิง)
+	Amount           Money      `json:"amount"`            // UplineWinLoss - MemberCommission
+	Paid             Money      `json:"paid"`
+	Status           string     `gorm:"size:20;index;default:'unpaid'" json:"status"` // unpaid, partial, settled
+	SettledAt        *time.Time `json:"settled_at"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+// AgentInvoicePayment: การชำระใบแจ้งยอด (บันทึกการจ่ายเงินนอกระบบ ทยอยจ่ายได้)
+type AgentInvoicePayment struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	InvoiceID  uint      `gorm:"index" json:"invoice_id"`
+	Amount     Money     `json:"amount"`
+	Note       string    `json:"note"`
+	RecordedBy uint      `json:"recorded_by"`
+	CreatedAt  time.Time `json:"created_at"`
+}
@@ -0,0 +1,60 @@
+package models
+
+import "time"
+
+// สถานะของรายการเงินเข้าใน Statement
+const (
+	StatementLineApproved  = "approved"  // จับคู่ได้แน่นอน อนุมัติรายการฝากอัตโนมัติแล้ว
+	StatementLineReview    = "review"    // มีรายการฝากที่อาจตรงกัน แต่ไม่แน่ใจพอจะอนุมัติเอง (รอ Admin)
+	StatementLineUnmatched = "unmatched" // ไม่พบรายการฝากที่ตรงกัน (รอ Admin)
+	StatementLineResolved  = "resolved"  // Admin จับคู่เองแล้ว
+	StatementLineIgnored   = "ignored"   // Admin ระบุว่าไม่ใช่ยอดฝากของสมาชิก
+)
+
+// BankStatementMapping: วิธีอ่านไฟล์ CSV ของแต่ละธนาคาร (ชื่อหัวคอลัมน์ หรือ "#3" = คอลัมน์ที่ 3)
+type BankStatementMapping struct {
+	Bank              string    `gorm:"primaryKey;size:20" json:"bank"` // KBANK, SCB, BBL, KTB, BAY, TTB
+	Name              string    `json:"name"`
+	DateColumn        string    `json:"date_column"`
+	TimeColumn        string    `json:"time_column"`   // ว่าง = วันที่กับเวลาอยู่คอลัมน์เดียวกัน
+	DateLayout        string    `json:"date_layout"`   // รูปแบบ Go เช่น 02/01/2006 15:04 (ปี พ.ศ. แปลงให้อัตโนมัติ)
+	CreditColumn      string    `json:"credit_column"` // ยอดเงินเข้า (ไฟล์ที่มีคอลัมน์ยอดเดียวแบบมีเครื่องหมาย: อ่านเฉพาะยอดบวก)
+	DescriptionColumn string    `json:"description_column"`
+	ReferenceColumn   string    `json:"reference_column"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// BankStatementImport: ไฟล์ Statement ที่ Admin อัปโหลด 1 ครั้ง
+type BankStatementImport struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	BankAccountID uint      `gorm:"index" json:"bank_account_id"`
+	Bank          string    `gorm:"size:20" json:"bank"`
+	FileName      string    `json:"file_name"`
+	Rows          int       `json:"rows"`          // แถวข้อมูลทั้งหมดในไฟล์
+	Credits       int       `json:"credits"`       // รายการเงินเข้า (ไม่รวมที่เคยนำเข้าแล้ว)
+	Duplicates    int       `json:"duplicates"`    // รายการเงินเข้าที่เคยนำเข้าแล้ว (ข้าม)
+	AutoApproved  int       `json:"auto_approved"` // อนุมัติรายการฝากอัตโนมัติ
+	Queued        int       `json:"queued"`        // ส่งเข้าคิวรอ Admin ตรวจ
+	CreatedBy     uint      `json:"created_by"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// BankStatementLine: รายการเงินเข้า 1 บรรทัดจาก Statement
+type BankStatementLine struct {
+	ID            uint         `gorm:"primaryKey" json:"id"`
+	ImportID      uint         `gorm:"index" json:"import_id"`
+	BankAccountID uint         `gorm:"index" json:"bank_account_id"`
+	TxnAt         time.Time    `gorm:"index" json:"txn_at"`
+	Amount        Money        `json:"amount"`
+	Description   string       `json:"description"`
+	Reference     string       `gorm:"size:100" json:"reference"`
+	Hash          string       `gorm:"size:64;uniqueIndex" json:"-"` // กันนำเข้ารายการเดิมซ้ำจากไฟล์ที่ช่วงเวลาทับกัน
+	Status        string       `gorm:"size:20;index" json:"status"`
+	TransactionID *uint        `gorm:"index" json:"transaction_id"` // รายการฝากที่จับคู่ได้
+	Transaction   *Transaction `gorm:"foreignKey:TransactionID" json:"transaction,omitempty"`
+	Candidates    int          `json:"candidates"` // จำนวนรายการฝากที่อาจตรงกันตอนนำเข้า
+	Note          string       `json:"note"`
+	ResolvedBy    *uint        `json:"resolved_by"`
+	ResolvedAt    *time.Time   `json:"resolved_at"`
+	CreatedAt     time.Time    `json:"created_at"`
+}
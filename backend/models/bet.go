@@ -23,19 +23,29 @@ type BetSlip struct {
 	AwayLogo string `json:"away_logo"`
 
 	// --- ข้อมูลการเดิมพัน ---
-	Pick   string  `json:"pick"`
-	Amount float64 `gorm:"column:amount" json:"total_stake"`
+	Pick   string `json:"pick"`
+	Amount Money  `gorm:"column:amount" json:"total_stake"`
 
 	Hdp         float64 `json:"hdp" gorm:"type:decimal(10,2);default:0"`
 	Price       int     `json:"price" gorm:"default:0"`
 	IsHomeUpper bool    `json:"is_home_upper" gorm:"default:true"`
 
 	Odds   float64 `json:"odds"`
-	Payout float64 `json:"payout" gorm:"default:0"`
+	Payout Money   `json:"payout" gorm:"default:0"`
 	Status string  `json:"status" gorm:"default:'pending'"`
 
+	SettledAt *time.Time `json:"settled_at"`
+
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
-// 🔥🔥🔥 เพิ่ม struct นี้ต่อท้ายในไฟล์ models.go 🔥🔥🔥
+// สถานะผลการแทง (ใช้ชุดเดียวกันทั้งบอลเต็ง บอลสเต็ป และรายคู่ในสเต็ป)
+const (
+	BetStatusPending  = "pending"
+	BetStatusWin      = "win"       // ชนะเต็ม
+	BetStatusWinHalf  = "win_half"  // ชนะบางส่วน (ได้ตาม % ค่าน้ำ)
+	BetStatusDraw     = "draw"      // เสมอ คืนทุน
+	BetStatusLoseHalf = "lose_half" // เสียบางส่วน (เสียตาม % ค่าน้ำ)
+	BetStatusLoss     = "loss"      // เสียเต็ม
+)
@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+// สถานะรอบคืนยอดเสีย
+const (
+	CashbackStatusDraft     = "draft"     // คำนวณแล้ว รอ Admin ตรวจ/อนุมัติ
+	CashbackStatusPaid      = "paid"      // อนุมัติและจ่ายเข้ากระเป๋าแล้ว
+	CashbackStatusCancelled = "cancelled" // ยกเลิก (คำนวณรอบเดิมใหม่ได้)
+)
+
+// CashbackTier: ขั้นคืนยอดเสียของแต่ละสกุล (ยอดเสียสุทธิถึง MinLoss ได้ Percent% ไม่เกิน MaxAmount)
+type CashbackTier struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Currency  string    `gorm:"size:3;index" json:"currency"`
+	MinLoss   Money     `json:"min_loss"`
+	Percent   float64   `json:"percent"`
+	MaxAmount Money     `json:"max_amount"` // เพดานต่อคนต่อรอบ (0 = ไม่จำกัด)
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CashbackBatch: รอบคืนยอดเสีย 1 รอบของ 1 สกุล (ยอดทุกช่องเป็นสกุล Currency)
+type CashbackBatch struct {
+	ID          uint           `gorm:"primaryKey" json:"id"`
+	Currency    string         `gorm:"size:3;index:idx_cashback_batch" json:"currency"`
+	PeriodStart time.Time      `gorm:"index:idx_cashback_batch" json:"period_start"`
+	PeriodEnd   time.Time      `json:"period_end"` // ไม่นับรวม
+	Status      string         `gorm:"size:20;index;default:'draft'" json:"status"`
+	Members     int            `json:"members"`
+	TotalLoss   Money          `json:"total_loss"`
+	TotalAmount Money          `json:"total_amount"`
+	CreatedBy   *uint          `json:"created_by"` // nil = สร้างจาก Cron
+	ApprovedBy  *uint          `json:"approved_by"`
+	ApprovedAt  *time.Time     `json:"approved_at"`
+	Note        string         `json:"note"`
+	Items       []CashbackItem `gorm:"foreignKey:BatchID" json:"items,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+}
+
+// CashbackItem: ยอดคืนของสมาชิก 1 คนในรอบ
+type CashbackItem struct {
+	ID            uint    `gorm:"primaryKey" json:"id"`
+	BatchID       uint    `gorm:"uniqueIndex:idx_cashback_item" json:"batch_id"`
+	UserID        uint    `gorm:"uniqueIndex:idx_cashback_item;index" json:"user_id"`
+	Stake         Money   `json:"stake"`    // ยอดแทงของบิลที่เคลียร์ในรอบ
+	Payout        Money   `json:"payout"`   // ยอดจ่ายคืนของบิลเหล่านั้น
+	NetLoss       Money   `json:"net_loss"` // Stake - Payout
+	TierID        uint    `json:"tier_id"`
+	Percent       float64 `json:"percent"`
+	Amount        Money   `json:"amount"`
+	TransactionID *uint   `json:"transaction_id"`
+
+	User *User `gorm:"foreignKey:UserID" json:"user,omitempty"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// CommissionRebate: ค่าคอม (Com) ที่จ่ายคืนจากยอดเทิร์นโอเวอร์ 1 รายการ ต่อผู้รับ ต่อสมาชิก ต่อรอบ
+// Level 0 = ค่าคอมของตัวสมาชิกเอง, Level 1 ขึ้นไป = ส่วนต่างค่าคอมของ Upline ตามสาย ParentID
+type CommissionRebate struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	UserID        uint      `gorm:"uniqueIndex:idx_commission_rebate" json:"user_id"`            // คนที่ได้รับค่าคอม
+	FromUserID    uint      `gorm:"uniqueIndex:idx_commission_rebate;index" json:"from_user_id"` // สมาชิกเจ้าของยอดเล่น
+	PeriodStart   time.Time `gorm:"uniqueIndex:idx_commission_rebate" json:"period_start"`
+	PeriodEnd     time.Time `json:"period_end"`
+	Level         int       `json:"level"`
+	Turnover      Money     `json:"turnover"` // ยอดเล่นที่นับค่าคอม (ไม่รวมเสมอ/ยกเลิก)
+	Rate          float64   `json:"rate"`     // % ที่ได้รับในชั้นนี้
+	Amount        Money     `json:"amount"`
+	TransactionID uint      `json:"transaction_id"`
+	AgentPeriodID *uint     `gorm:"index" json:"agent_period_id"` // รอบเคลียร์ยอด Agent ที่นับรายการนี้แล้ว
+	CreatedAt     time.Time `json:"created_at"`
+}
@@ -0,0 +1,64 @@
+package models
+
+import "time"
+
+// สกุลเงินของกระเป๋า (ISO 4217)
+const (
+	CurrencyTHB = "THB"
+	CurrencyMMK = "MMK"
+
+	// BaseCurrency: สกุลหลักที่ใช้รวมยอดในรายงาน (สกุลอื่นแปลงด้วย ExchangeRate)
+	BaseCurrency = CurrencyTHB
+)
+
+// SupportedCurrencies: สกุลเงินที่เปิดให้ใช้เป็นกระเป๋าได้
+var SupportedCurrencies = []string{CurrencyTHB, CurrencyMMK}
+
+// IsSupportedCurrency: เช็คว่ารหัสสกุลเงินรองรับหรือไม่
+func IsSupportedCurrency(code string) bool {
+	for _, c := range SupportedCurrencies {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// CurrencyLabel: ชื่อหน่วยเงินภาษาไทยสำหรับข้อความแจ้งเตือน
+func CurrencyLabel(code string) string {
+	switch code {
+	case CurrencyTHB:
+		return "บาท"
+	case CurrencyMMK:
+		return "จ๊าด"
+	}
+	return code
+}
+
+// CurrencyLimit: ขั้นต่ำ/เพดานของแต่ละสกุลเงิน (แทน MinBet/MaxBet/MaxPayout เดิมใน SystemSetting)
+type CurrencyLimit struct {
+	Currency             string    `gorm:"primaryKey;size:3" json:"currency"`
+	MinDeposit           Money     `json:"min_deposit"`
+	MinWithdraw          Money     `json:"min_withdraw"`
+	MinBet               Money     `json:"min_bet"`
+	MaxBet               Money     `json:"max_bet"`
+	MaxPayout            Money     `json:"max_payout"`
+	MaxReferralReward    Money     `json:"max_referral_reward"`    // เพดานรางวัลแนะนำเพื่อนต่อคน (0 = ไม่จำกัด)
+	WithdrawDualApproval Money     `json:"withdraw_dual_approval"` // ยอดถอนตั้งแต่นี้ต้องมีผู้อนุมัติ 2 คน (0 = คนเดียวพอ)
+	AdminDailyApproval   Money     `json:"admin_daily_approval"`   // ยอดถอนที่ Admin 1 คนอนุมัติได้ต่อวัน (0 = ไม่จำกัด)
+	MaxWithdrawDaily     Money     `json:"max_withdraw_daily"`     // ยอดถอนรวมต่อสมาชิกต่อวัน (0 = ไม่จำกัด)
+	MaxWithdrawCount     int       `json:"max_withdraw_count"`     // จำนวนครั้งที่ถอนได้ต่อสมาชิกต่อวัน (0 = ไม่จำกัด)
+	UpdatedAt            time.Time `json:"updated_at"`
+}
+
+// ExchangeRate: อัตราแลกเปลี่ยนที่ Admin บันทึก (เก็บทุกครั้งที่เปลี่ยน ไม่แก้ของเดิม)
+// Rate = มูลค่า 1 หน่วยของ Currency เป็นสกุลหลัก เช่น MMK 0.0165 = 1 จ๊าดเท่ากับ 0.0165 บาท
+type ExchangeRate struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Currency    string    `gorm:"size:3;index:idx_exchange_rate" json:"currency"`
+	Rate        float64   `gorm:"type:numeric(18,8)" json:"rate"`
+	EffectiveAt time.Time `gorm:"index:idx_exchange_rate" json:"effective_at"` // มีผลตั้งแต่เวลานี้จนกว่าจะมีเรทใหม่
+	Note        string    `json:"note"`
+	CreatedBy   *uint     `json:"created_by"`
+	CreatedAt   time.Time `json:"created_at"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// DepositIntent: คำขอฝากผ่าน QR PromptPay (คู่กับ Transaction ฝากสถานะ pending 1 รายการ)
+// ยอดโอนมีเศษสตางค์ไม่ซ้ำกับคำขออื่นของบัญชีเดียวกันในช่วงเวลาชำระ ใช้ระบุตัวผู้โอนตอนจับคู่ยอดเข้า
+type DepositIntent struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	TransactionID uint      `gorm:"uniqueIndex" json:"transaction_id"`
+	UserID        uint      `gorm:"index" json:"user_id"`
+	BankAccountID uint      `gorm:"index:idx_deposit_intent_slot" json:"bank_account_id"`
+	Amount        Money     `gorm:"index:idx_deposit_intent_slot" json:"amount"` // ยอดที่ต้องโอน (รวมเศษสตางค์)
+	BaseAmount    Money     `json:"base_amount"`                                 // ยอดที่สมาชิกขอ
+	PromptPayID   string    `gorm:"size:20" json:"promptpay_id"`                 // ปลายทางตอนสร้าง QR
+	Payload       string    `json:"payload"`                                     // ข้อความ EMVCo ใน QR
+	ExpiresAt     time.Time `gorm:"index" json:"expires_at"`
+	CreatedAt     time.Time `json:"created_at"`
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// JobConfig: ค่าตั้งของงาน Cron ที่แอดมินปรับได้ขณะระบบทำงาน (ไม่ต้อง Deploy ใหม่)
+type JobConfig struct {
+	Name      string    `gorm:"primaryKey;size:50" json:"name"`
+	Schedule  string    `json:"schedule"`  // Cron 5 ช่อง เช่น "*/5 * * * *"
+	Paused    bool      `json:"paused"`    // หยุดรันตามเวลา (ยังสั่งรันทันทีได้)
+	FeedPath  string    `json:"feed_path"` // path ของ feed บอล (เช่น "moung") สำหรับงานที่ดึง API
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// บัญชีในสมุดบัญชีคู่ (Ledger)
+// wallet / wallet_held / bonus = กระเป๋าของสมาชิก (ต้องมี UserID) ส่วนบัญชีอื่นเป็นบัญชีระบบ (UserID = nil)
+const (
+	LedgerAccountWallet          = "wallet"
+	LedgerAccountWalletHeld      = "wallet_held"      // ยอดพักรอถอนของสมาชิก (แจ้งถอน -> พัก, อนุมัติ -> จ่ายออก, ปฏิเสธ -> คืนกระเป๋า)
+	LedgerAccountBonus           = "bonus"            // กระเป๋าโบนัสของสมาชิก (ได้จากโปรโมชั่น ใช้แทงได้ ถอนไม่ได้)
+	LedgerAccountHouse           = "house"            // เจ้ามือ: รับยอดแทง / จ่ายรางวัล
+	LedgerAccountCash            = "cash"             // เงินสดเข้า-ออกผ่านธนาคาร
+	LedgerAccountWithdrawPayable = "withdraw_payable" // ยอดถอนที่ตัดเครดิตแล้ว รอโอนจริง (รายการแจ้งถอนก่อนมี wallet_held)
+	LedgerAccountCommission      = "commission"       // ค่าคอมที่จ่ายออก
+	LedgerAccountAdjustment      = "adjustment"       // ปรับยอด / ยอดยกมา / เครดิตที่ Admin ออกให้
+	LedgerAccountCreditClearing  = "credit_clearing"  // เคลียร์ยอดบัญชีเครดิตตอนปิดรอบ (เก็บเงิน/จ่ายเงินกับสมาชิกนอกระบบ)
+	LedgerAccountPromotion       = "promotion"        // ต้นทุนโบนัสโปรโมชั่น (ออกโบนัส / รับคืนโบนัสที่ถูกริบ)
+	LedgerAccountReferral        = "referral"         // รางวัลแนะนำเพื่อนที่จ่ายออก
+	LedgerAccountCashback        = "cashback"         // คืนยอดเสียที่จ่ายออก
+)
+
+// LedgerJournal: รายการบัญชี 1 ครั้ง (ยอดรวมของทุก Entry ในรายการต้องเป็น 0 เสมอ)
+type LedgerJournal struct {
+	ID        uint          `gorm:"primaryKey" json:"id"`
+	Type      string        `gorm:"size:30;index" json:"type"`                    // bet, payout, deposit, withdraw, transfer, commission, adjustment, opening
+	RefType   string        `gorm:"size:30;index:idx_ledger_ref" json:"ref_type"` // ตารางต้นทาง เช่น transaction, single, parlay
+	RefID     uint          `gorm:"index:idx_ledger_ref" json:"ref_id"`
+	Note      string        `json:"note"`
+	Currency  string        `gorm:"size:3;index;default:THB" json:"currency"` // สกุลเงินของทุก Entry ในรายการ (ตามกระเป๋าสมาชิก)
+	CreatedBy *uint         `json:"created_by"`
+	Entries   []LedgerEntry `gorm:"foreignKey:JournalID" json:"entries,omitempty"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+// LedgerEntry: การเคลื่อนไหวของบัญชี 1 บรรทัด (Amount บวก = เพิ่มยอดบัญชี, ลบ = ลดยอดบัญชี)
+// BalanceBefore/After มีเฉพาะบรรทัดของกระเป๋าสมาชิก (wallet / wallet_held / bonus)
+type LedgerEntry struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	JournalID     uint      `gorm:"index" json:"journal_id"`
+	Account       string    `gorm:"size:30;index:idx_ledger_account" json:"account"`
+	UserID        *uint     `gorm:"index:idx_ledger_account" json:"user_id"`
+	Amount        Money     `json:"amount"`
+	BalanceBefore *Money    `json:"balance_before"`
+	BalanceAfter  *Money    `json:"balance_after"`
+	CreatedAt     time.Time `gorm:"index" json:"created_at"`
+}
@@ -0,0 +1,9 @@
+package models
+
+import "time"
+
+// SchemaMigration: ขั้นตอนแก้ข้อมูลเดิมที่รันไปแล้ว (database.runMigrations รันแต่ละ Version ครั้งเดียว)
+type SchemaMigration struct {
+	Version   string    `gorm:"primaryKey;size:100" json:"version"`
+	AppliedAt time.Time `json:"applied_at"`
+}
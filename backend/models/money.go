@@ -0,0 +1,145 @@
+package models
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"fmt"
+	"math/big"
+	"strconv"
+)
+
+// Money: จำนวนเงินแบบ Fixed-point เก็บเป็นจำนวนเต็มหน่วยสตางค์ (1.00 = 100)
+// DB เก็บเป็น numeric(15,2), JSON ส่งเป็นตัวเลขทศนิยม 2 ตำแหน่ง (รับได้ทั้ง number และ string)
+// บวก/ลบ ใช้ + - ได้ตรงๆ ส่วนคูณ/หาร (ค่าน้ำ, ชนะครึ่ง/เสียครึ่ง, ค่าคอม, หุ้น) ต้องผ่าน Mul/Percent/MulRat
+// ซึ่งคิดแบบเศษส่วนแม่นยำ แล้วปัดครั้งเดียวตอนท้าย: ครึ่งสตางค์ปัดออกจากศูนย์ (0.005 -> 0.01, -0.005 -> -0.01)
+type Money int64
+
+// NewMoney: แปลง float64 เป็น Money โดยอ่านค่าแบบทศนิยมที่สั้นที่สุด (1.005 -> 1.01 ไม่ใช่ 1.00)
+func NewMoney(v float64) Money {
+	m, _ := ParseMoney(strconv.FormatFloat(v, 'f', -1, 64))
+	return m
+}
+
+// ParseMoney: แปลงข้อความ เช่น "1500", "99.5", "-0.25" เป็น Money
+func ParseMoney(s string) (Money, error) {
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return 0, fmt.Errorf("invalid money amount %q", s)
+	}
+	return roundRat(r.Mul(r, big.NewRat(100, 1))), nil
+}
+
+// roundRat: ปัดเศษส่วน (หน่วยสตางค์) เป็นจำนวนเต็ม ครึ่งหนึ่งปัดออกจากศูนย์
+func roundRat(r *big.Rat) Money {
+	num, den := r.Num(), r.Denom()
+	q, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	rem.Abs(rem).Lsh(rem, 1)
+	if rem.Cmp(den) >= 0 {
+		if num.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return Money(q.Int64())
+}
+
+// DecimalRat: แปลงตัวคูณ float64 เป็นเศษส่วนตามค่าทศนิยมที่เห็น (0.2 = 1/5 ไม่ใช่ค่าไบนารีที่ใกล้เคียง)
+func DecimalRat(f float64) *big.Rat {
+	r, _ := new(big.Rat).SetString(strconv.FormatFloat(f, 'f', -1, 64))
+	return r
+}
+
+// MulRat: คูณด้วยเศษส่วนแม่นยำ แล้วปัดเป็นสตางค์
+func (m Money) MulRat(r *big.Rat) Money {
+	x := new(big.Rat).SetInt64(int64(m))
+	return roundRat(x.Mul(x, r))
+}
+
+// Mul: คูณด้วยตัวคูณ เช่น ราคาน้ำ 1.85
+func (m Money) Mul(factor float64) Money {
+	return m.MulRat(DecimalRat(factor))
+}
+
+// Percent: pct% ของยอดเงิน เช่น ค่าคอม 0.5% หรือหุ้น 80%
+func (m Money) Percent(pct float64) Money {
+	r := DecimalRat(pct)
+	return m.MulRat(r.Quo(r, big.NewRat(100, 1)))
+}
+
+func (m Money) Abs() Money {
+	if m < 0 {
+		return -m
+	}
+	return m
+}
+
+// Float64: ใช้แสดงผล/คำนวณอัตราส่วนเท่านั้น ห้ามใช้คิดเงินต่อ
+func (m Money) Float64() float64 {
+	return float64(m) / 100
+}
+
+func (m Money) String() string {
+	sign := ""
+	v := int64(m)
+	if v < 0 {
+		sign = "-"
+		v = -v
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, v/100, v%100)
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.Trim(bytes.TrimSpace(data), `"`)
+	if len(data) == 0 || string(data) == "null" {
+		*m = 0
+		return nil
+	}
+	v, err := ParseMoney(string(data))
+	if err != nil {
+		return err
+	}
+	*m = v
+	return nil
+}
+
+// Value: บันทึกลง DB เป็นข้อความทศนิยม (Postgres แปลงเป็น numeric เอง ไม่ผ่าน float)
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+func (m *Money) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*m = 0
+	case []byte:
+		return m.scanString(string(v))
+	case string:
+		return m.scanString(v)
+	case float64:
+		*m = NewMoney(v)
+	case int64:
+		*m = Money(v * 100)
+	default:
+		return fmt.Errorf("cannot scan %T into Money", src)
+	}
+	return nil
+}
+
+func (m *Money) scanString(s string) error {
+	v, err := ParseMoney(s)
+	if err != nil {
+		return err
+	}
+	*m = v
+	return nil
+}
+
+// GormDataType: ให้ AutoMigrate สร้าง/แปลงคอลัมน์เป็น numeric(15,2)
+func (Money) GormDataType() string {
+	return "numeric(15,2)"
+}
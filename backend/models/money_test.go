@@ -0,0 +1,163 @@
+package models
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Money
+		wantErr bool
+	}{
+		{"1500", 150000, false},
+		{"99.5", 9950, false},
+		{"0.01", 1, false},
+		{"-0.25", -25, false},
+		{"0", 0, false},
+		{"0.005", 1, false},   // ครึ่งสตางค์ปัดขึ้น
+		{"-0.005", -1, false}, // ติดลบปัดออกจากศูนย์
+		{"0.0049", 0, false},
+		{"-0.0049", 0, false},
+		{"1.015", 102, false},
+		{"-1.015", -102, false},
+		{"2.675", 268, false}, // คิดผ่าน float64 (2.675*100) จะได้ 267
+		{"", 0, true},
+		{"1,000", 0, true},
+		{"abc", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseMoney(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseMoney(%q) = %d, %v, want %d, wantErr %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestNewMoney(t *testing.T) {
+	tests := []struct {
+		in   float64
+		want Money
+	}{
+		{1.005, 101},
+		{-1.005, -101},
+		{0.1 + 0.2, 30},
+		{2.675, 268},
+		{-0.004, 0},
+		{1234.5, 123450},
+	}
+
+	for _, tt := range tests {
+		if got := NewMoney(tt.in); got != tt.want {
+			t.Errorf("NewMoney(%v) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyRounding(t *testing.T) {
+	tests := []struct {
+		name string
+		got  Money
+		want Money
+	}{
+		{"odds multiplier", Money(100000).Mul(1.85), 185000},
+		{"half satang rounds up", Money(1).Mul(0.5), 1},
+		{"negative half satang rounds down", Money(-1).Mul(0.5), -1},
+		{"win half of odd stake", Money(333).Percent(50), 167},
+		{"lose half of odd stake", Money(-333).Percent(50), -167},
+		{"commission", Money(10000).Percent(0.5), 50},
+		{"commission below half satang", Money(99).Percent(0.5), 0},
+		{"commission at half satang", Money(100).Percent(0.5), 1},
+		{"share", Money(-12345).Percent(80), -9876},
+		{"third rounds down", Money(100).MulRat(big.NewRat(1, 3)), 33},
+		{"two thirds rounds up", Money(200).MulRat(big.NewRat(1, 3)), 67},
+		{"negative two thirds", Money(-200).MulRat(big.NewRat(1, 3)), -67},
+		{"decimal factor is exact", Money(1000).Mul(0.2), 200},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("got %d, want %d", tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := []struct {
+		in   Money
+		want string
+	}{
+		{0, "0.00"},
+		{5, "0.05"},
+		{-5, "-0.05"},
+		{-150, "-1.50"},
+		{123456, "1234.56"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.in.String(); got != tt.want {
+			t.Errorf("Money(%d).String() = %q, want %q", int64(tt.in), got, tt.want)
+		}
+	}
+}
+
+func TestMoneyScan(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     interface{}
+		want    Money
+		wantErr bool
+	}{
+		{"nil", nil, 0, false},
+		{"numeric bytes", []byte("1234.56"), 123456, false},
+		{"negative string", "-7.50", -750, false},
+		{"extra precision", "0.125", 13, false},
+		{"negative half satang", "-0.125", -13, false},
+		{"float", 0.1, 10, false},
+		{"int", int64(15), 1500, false},
+		{"bad string", "12,00", 0, true},
+		{"unsupported type", true, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var m Money
+			err := m.Scan(tt.src)
+			if (err != nil) != tt.wantErr || m != tt.want {
+				t.Errorf("Scan(%v) = %d, %v, want %d, wantErr %v", tt.src, m, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Money
+	}{
+		{`12.5`, 1250},
+		{`"12.5"`, 1250},
+		{`-0.005`, -1},
+		{`null`, 0},
+		{`""`, 0},
+	}
+
+	for _, tt := range tests {
+		var m Money
+		if err := json.Unmarshal([]byte(tt.in), &m); err != nil || m != tt.want {
+			t.Errorf("Unmarshal(%s) = %d, %v, want %d", tt.in, m, err, tt.want)
+		}
+	}
+
+	out, err := json.Marshal(struct {
+		Amount Money `json:"amount"`
+	}{-1050})
+	if err != nil || string(out) != `{"amount":-10.50}` {
+		t.Errorf("Marshal() = %s, %v", out, err)
+	}
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// Notification: แจ้งเตือนในระบบของผู้ใช้ 1 คน (เช่น Agent ได้รับแจ้งเมื่อสมาชิกในสายได้รับเงินถอน)
+type Notification struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"index" json:"user_id"`
+	Type      string     `gorm:"size:30" json:"type"` // withdraw_paid, withdraw_failed
+	Title     string     `json:"title"`
+	Message   string     `json:"message"`
+	RefType   string     `gorm:"size:30" json:"ref_type"`
+	RefID     uint       `json:"ref_id"`
+	ReadAt    *time.Time `json:"read_at"`
+	CreatedAt time.Time  `gorm:"index" json:"created_at"`
+}
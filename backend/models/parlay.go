@@ -5,13 +5,14 @@ import "time"
 type ParlayTicket struct {
 	ID          uint         `gorm:"primaryKey" json:"id"`
 	UserID      uint         `json:"user_id"`
-	Amount      float64      `json:"amount"`     // เงินต้น
+	Amount      Money        `json:"amount"`     // เงินต้น
 	TotalOdds   float64      `json:"total_odds"` // ราคาน้ำรวม (จะคำนวณเมื่อจบหมด)
-	Status      string       `json:"status"`     // pending, win, loss, draw
-	Payout      float64      `json:"payout"`     // ยอดจ่ายจริง
+	Status      string       `json:"status"`     // pending, win, lose_half, draw, loss
+	Payout      Money        `json:"payout"`     // ยอดจ่ายจริง
 	Price       int          `json:"price"`      // ค่าน้ำพม่า เช่น -80, 55
 	IsHomeUpper bool         `json:"is_home_upper" gorm:"default:true"`
 	Items       []ParlayItem `gorm:"foreignKey:TicketID" json:"items"`
+	SettledAt   *time.Time   `json:"settled_at"`
 	CreatedAt   time.Time
 }
 
@@ -21,9 +22,12 @@ type ParlayItem struct {
 	MatchID     string  `json:"match_id"`
 	HomeTeam    string  `json:"home_team"`
 	AwayTeam    string  `json:"away_team"`
-	Hdp         float64 `json:"hdp"`
-	Pick        string  `json:"pick"`
-	Price       int     `json:"price"`
+	Hdp         float64 `json:"hdp"`   // แต้มต่อ (ไลน์) ที่รับตอนแทง
+	Pick        string  `json:"pick"`  // home, away, over, under
+	Price       int     `json:"price"` // ค่าน้ำพม่าที่รับตอนแทง เช่น -80, 55
 	IsHomeUpper bool    `json:"is_home_upper" gorm:"default:true"`
-	Status      string  `json:"status"`
+	Status      string  `json:"status"`     // ใช้สถานะชุดเดียวกับ BetStatus*
+	Multiplier  float64 `json:"multiplier"` // ตัวคูณของคู่นี้หลังคิดผล
+	ScoreHome   *int    `json:"score_home"`
+	ScoreAway   *int    `json:"score_away"`
 }
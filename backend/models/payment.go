@@ -0,0 +1,47 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// สถานะคำสั่งชำระเงินผ่านผู้ให้บริการ (Payment Gateway)
+const (
+	PaymentPending   = "pending"
+	PaymentSucceeded = "succeeded"
+	PaymentFailed    = "failed"
+	PaymentMismatch  = "mismatch" // ยอดที่ผู้ให้บริการแจ้งไม่ตรงกับรายการ (รายการยังรอ Admin ตรวจ)
+)
+
+// PaymentOrder: คำสั่งฝาก/จ่ายเงินที่ส่งให้ผู้ให้บริการ 1 รายการ (Transaction ถอนสั่งจ่ายใหม่ได้ถ้าครั้งก่อนไม่สำเร็จ)
+type PaymentOrder struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	TransactionID uint      `gorm:"index" json:"transaction_id"`
+	Provider      string    `gorm:"size:30" json:"provider"`
+	Kind          string    `gorm:"size:10" json:"kind"`                // deposit, payout
+	ProviderRef   string    `gorm:"size:100;index" json:"provider_ref"` // เลขอ้างอิงฝั่งผู้ให้บริการ (ว่างจนกว่าจะสร้างสำเร็จ)
+	Amount        Money     `json:"amount"`
+	Currency      string    `gorm:"size:3" json:"currency"`
+	Status        string    `gorm:"size:20;index" json:"status"`
+	PayURL        string    `json:"pay_url"`      // หน้าชำระเงินของผู้ให้บริการ (ฝาก)
+	Reason        string    `json:"reason"`       // สาเหตุที่ไม่สำเร็จ / ยอดไม่ตรง
+	RequestedBy   *uint     `json:"requested_by"` // Admin ที่สั่งจ่าย (payout)
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// MerchantRef: เลขอ้างอิงฝั่งเราที่ส่งให้ผู้ให้บริการ และได้กลับมาใน Webhook
+func (o *PaymentOrder) MerchantRef() string {
+	return fmt.Sprintf("PO%d", o.ID)
+}
+
+// PaymentWebhookEvent: Webhook ที่รับแล้ว (Provider + EventID ไม่ซ้ำ กันยิงซ้ำ/ส่งซ้ำ)
+type PaymentWebhookEvent struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	Provider   string    `gorm:"size:30;uniqueIndex:idx_payment_event" json:"provider"`
+	EventID    string    `gorm:"size:100;uniqueIndex:idx_payment_event" json:"event_id"`
+	OrderID    uint      `gorm:"index" json:"order_id"`
+	Status     string    `gorm:"size:20" json:"status"`
+	Payload    string    `json:"payload"`
+	ReceivedAt time.Time `json:"received_at"`
+}
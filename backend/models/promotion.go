@@ -0,0 +1,77 @@
+package models
+
+import "time"
+
+// ประเภทโปรโมชั่น
+const (
+	PromoTypeFirstDeposit = "first_deposit" // ฝากครั้งแรก
+	PromoTypeReload       = "reload"        // ฝากครั้งถัดไป
+	PromoTypeFreeCredit   = "free_credit"   // เครดิตฟรี (รับด้วยโค้ด ไม่ต้องฝาก)
+)
+
+// วิธีคิดยอดโบนัส
+const (
+	BonusAmountFixed   = "fixed"   // ได้ Fixed เท่ากันทุกคน
+	BonusAmountPercent = "percent" // Percent% ของยอดฝาก (ไม่เกิน MaxBonus)
+)
+
+// สถานะโบนัสที่สมาชิกได้รับ
+const (
+	BonusStatusActive    = "active"    // กำลังทำเทิร์น (ถอนไม่ได้จนกว่าครบ)
+	BonusStatusCompleted = "completed" // ทำเทิร์นครบ ยอดโบนัสที่เหลือย้ายเข้ากระเป๋าแล้ว
+	BonusStatusForfeited = "forfeited" // ถูกยกเลิก (สมาชิกขอยกเลิก / Admin ยกเลิก) ยอดโบนัสที่เหลือถูกริบ
+	BonusStatusExpired   = "expired"   // หมดอายุก่อนทำเทิร์นครบ ยอดโบนัสที่เหลือถูกริบ
+)
+
+// Promotion: โปรโมชั่นที่ Admin ตั้ง (ยอดเงินทุกช่องเป็นสกุล Currency และรับได้เฉพาะกระเป๋าสกุลนั้น)
+// ยอดเทิร์นที่ต้องทำ = (โบนัส + ยอดฝากถ้า WagerIncludesDeposit) x TurnoverMultiple
+type Promotion struct {
+	ID                   uint       `gorm:"primaryKey" json:"id"`
+	Name                 string     `json:"name"`
+	Description          string     `json:"description"`
+	Code                 *string    `gorm:"size:30;uniqueIndex" json:"code"` // nil = เลือกจากรายการได้เลย ไม่ต้องใช้โค้ด
+	Type                 string     `gorm:"size:20;index" json:"type"`       // first_deposit, reload, free_credit
+	AmountType           string     `gorm:"size:10" json:"amount_type"`      // fixed, percent
+	Fixed                Money      `json:"fixed"`
+	Percent              float64    `json:"percent"`
+	MaxBonus             Money      `json:"max_bonus"`   // เพดานโบนัสต่อครั้ง (0 = ไม่จำกัด)
+	MinDeposit           Money      `json:"min_deposit"` // ยอดฝากขั้นต่ำที่รับโปรได้
+	Currency             string     `gorm:"size:3;default:THB" json:"currency"`
+	TurnoverMultiple     float64    `json:"turnover_multiple"`      // จำนวนเท่าของเทิร์น (0 = ไม่ต้องทำเทิร์น)
+	WagerIncludesDeposit bool       `json:"wager_includes_deposit"` // นับยอดฝากรวมในเทิร์นด้วย
+	ValidDays            int        `json:"valid_days"`             // ต้องทำเทิร์นให้ครบภายในกี่วัน (0 = ไม่หมดอายุ)
+	MaxClaimsPerUser     int        `json:"max_claims_per_user"`    // 0 = ไม่จำกัด (ฝากครั้งแรกรับได้ครั้งเดียวเสมอ)
+	StartAt              *time.Time `json:"start_at"`
+	EndAt                *time.Time `json:"end_at"`
+	Active               bool       `gorm:"default:true" json:"active"`
+	CreatedBy            *uint      `json:"created_by"`
+	CreatedAt            time.Time  `json:"created_at"`
+	UpdatedAt            time.Time  `json:"updated_at"`
+}
+
+// BonusGrant: โบนัสที่สมาชิกได้รับ 1 ครั้ง (มี active ได้ครั้งละ 1 รายการต่อคน)
+// ยอดอยู่ในกระเป๋าโบนัส (users.bonus_credit) ใช้แทงได้แต่ถอนไม่ได้ ทำเทิร์นครบแล้วย้ายเข้ากระเป๋าหลัก
+type BonusGrant struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	PromotionID   uint       `gorm:"index" json:"promotion_id"`
+	UserID        uint       `gorm:"index" json:"user_id"`
+	TransactionID *uint      `gorm:"index" json:"transaction_id"` // รายการฝากที่ได้โบนัส (เครดิตฟรี = nil)
+	Amount        Money      `json:"amount"`
+	Wagering      Money      `json:"wagering"` // ยอดเทิร์นที่ต้องทำ
+	Wagered       Money      `json:"wagered"`  // ยอดเทิร์นที่ทำแล้ว (นับจากบิลที่เคลียร์แล้ว)
+	Released      Money      `json:"released"` // ยอดโบนัสที่ย้ายเข้ากระเป๋าหลักตอนทำเทิร์นครบ
+	Forfeited     Money      `json:"forfeited"`
+	Status        string     `gorm:"size:20;index;default:'active'" json:"status"` // active, completed, forfeited, expired
+	ForfeitReason string     `json:"forfeit_reason"`
+	ExpiresAt     *time.Time `json:"expires_at"`
+	ClosedAt      *time.Time `json:"closed_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// Remaining: ยอดเทิร์นที่ยังต้องทำ
+func (g *BonusGrant) Remaining() Money {
+	if g.Wagered >= g.Wagering {
+		return 0
+	}
+	return g.Wagering - g.Wagered
+}
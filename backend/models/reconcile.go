@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// สถานะของรายงานยอดไม่ตรง
+const (
+	DriftStatusOpen      = "open"
+	DriftStatusCorrected = "corrected"
+	DriftStatusDismissed = "dismissed"
+)
+
+// BalanceDrift: ผลกระทบยอดของสมาชิก 1 คนที่ยอดไม่ตรงกัน (1 คนมีรายการ open ได้ครั้งละ 1 รายการ รันซ้ำจะอัปเดตรายการเดิม)
+// Cached = users.credit, Ledger = ผลรวม Entry ของ wallet, History = ผลรวม Transaction ที่ผูกกับ Ledger
+type BalanceDrift struct {
+	ID               uint         `gorm:"primaryKey" json:"id"`
+	UserID           uint         `gorm:"index" json:"user_id"`
+	Cached           Money        `json:"cached"`
+	Ledger           Money        `json:"ledger"`
+	History          Money        `json:"history"`
+	Issues           []DriftIssue `gorm:"serializer:json;type:text" json:"issues"`
+	Status           string       `gorm:"size:20;index;default:'open'" json:"status"` // open, corrected, dismissed
+	CorrectionAmount Money        `json:"correction_amount"`                          // ยอดปรับที่ Admin อนุมัติ (0 = แค่ซิงก์ cache/เติมประวัติ)
+	ResolutionNote   string       `json:"resolution_note"`
+	ApprovedBy       *uint        `json:"approved_by"`
+	ResolvedAt       *time.Time   `json:"resolved_at"`
+	CheckedAt        time.Time    `json:"checked_at"`
+	CreatedAt        time.Time    `json:"created_at"`
+}
+
+// DriftIssue: รายการต้นเหตุที่ทำให้ยอดไม่ตรง
+// Kind: cache_drift, held_drift, bonus_drift, chain_break, missing_transaction, amount_mismatch, orphan_transaction
+type DriftIssue struct {
+	Kind          string `json:"kind"`
+	JournalID     uint   `json:"journal_id,omitempty"`
+	EntryID       uint   `json:"entry_id,omitempty"`
+	TransactionID uint   `json:"transaction_id,omitempty"`
+	Detail        string `json:"detail"`
+}
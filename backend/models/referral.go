@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// ReferralCode: รหัสแนะนำของ Master/Agent (และสมาชิกถ้าเปิด SystemSetting.MemberReferral)
+// ลิงก์สมัคร = SystemSetting.ReferralBaseURL + "?ref=" + Code มีได้หลายรหัสต่อคนเพื่อแยกช่องทาง
+type ReferralCode struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"index" json:"user_id"`
+	Code      string    `gorm:"size:20;uniqueIndex" json:"code"`
+	Label     string    `json:"label"` // ชื่อช่องทาง เช่น Facebook, กลุ่ม Line
+	Active    bool      `gorm:"default:true" json:"active"`
+	Clicks    int64     `gorm:"default:0" json:"clicks"` // จำนวนครั้งที่เปิดลิงก์
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ReferralAttribution: สมาชิกที่สมัครผ่านรหัสแนะนำ (1 คนมีได้ 1 รายการ)
+// รางวัลแนะนำจ่ายครั้งเดียวตอนยอดฝากแรกของสมาชิกใหม่ได้รับอนุมัติ
+type ReferralAttribution struct {
+	ID                  uint       `gorm:"primaryKey" json:"id"`
+	UserID              uint       `gorm:"uniqueIndex" json:"user_id"` // สมาชิกใหม่
+	ReferrerID          uint       `gorm:"index" json:"referrer_id"`   // เจ้าของรหัส (ผู้ได้รางวัล)
+	CodeID              uint       `gorm:"index" json:"code_id"`
+	Code                string     `gorm:"size:20" json:"code"`
+	ParentID            *uint      `json:"parent_id"` // ต้นสายที่ผูกให้ตอนสมัคร
+	IP                  string     `gorm:"size:45" json:"ip"`
+	FirstDeposit        Money      `json:"first_deposit"`
+	FirstDepositAt      *time.Time `json:"first_deposit_at"`
+	Reward              Money      `json:"reward"`
+	RewardTransactionID *uint      `json:"reward_transaction_id"`
+	CreatedAt           time.Time  `json:"created_at"`
+
+	User *User `gorm:"foreignKey:UserID" json:"user,omitempty"`
+}
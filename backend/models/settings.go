@@ -6,12 +6,29 @@ type SystemSetting struct {
 	ID               uint      `gorm:"primaryKey" json:"id"`
 	SiteName         string    `json:"site_name"`
 	MaintenanceMode  bool      `json:"maintenance_mode"`
-	MinBet           float64   `json:"min_bet"`
-	MaxBet           float64   `json:"max_bet"`
-	MaxPayout        float64   `json:"max_payout"`
 	LineID           string    `json:"line_id"`
 	TelegramLink     string    `json:"telegram_link"`
 	MetaDescription  string    `json:"meta_description"`
 	AnnouncementText string    `json:"announcement_text"`
+	CommissionCycle  string    `json:"commission_cycle" gorm:"default:'daily'"` // daily, weekly
 	UpdatedAt        time.Time `json:"updated_at"`
+
+	// แนะนำเพื่อน (เพดานรางวัลต่อคนอยู่ใน CurrencyLimit.MaxReferralReward)
+	ReferralBaseURL       string  `json:"referral_base_url"`       // หน้าเว็บสมัครสมาชิก เช่น https://example.com/register
+	MemberReferral        bool    `json:"member_referral"`         // เปิดให้สมาชิกทั่วไปมีรหัสแนะนำ (Master/Agent มีได้เสมอ)
+	ReferralRewardPercent float64 `json:"referral_reward_percent"` // รางวัล % ของยอดฝากแรกของคนที่แนะนำ (0 = ไม่จ่าย)
+
+	// คืนยอดเสีย (ขั้น % / เพดานอยู่ใน cashback_tiers แยกตามสกุล)
+	CashbackEnabled bool   `json:"cashback_enabled"`                       // Cron สร้างรอบคืนยอดเสียรอ Admin อนุมัติ
+	CashbackCycle   string `json:"cashback_cycle" gorm:"default:'weekly'"` // daily, weekly
+
+	// บัญชีรับเงิน: round_robin, least_used, group (วงเงินรายวันตั้งที่แต่ละบัญชี)
+	BankRotation string `json:"bank_rotation" gorm:"default:'round_robin'"`
+
+	// กติกาการถอน (เพดานต่อวันแยกตามสกุลอยู่ใน CurrencyLimit)
+	WithdrawTurnoverPercent   float64 `json:"withdraw_turnover_percent" gorm:"default:100"`   // ต้องมียอดเล่นหลังฝากล่าสุดกี่ % ของยอดฝากนั้น (0 = ไม่บังคับ)
+	WithdrawBankCooldownHours int     `json:"withdraw_bank_cooldown_hours" gorm:"default:24"` // ตั้ง/เปลี่ยนบัญชีรับเงินแล้วต้องรอกี่ชั่วโมงจึงถอนได้
+
+	// ขั้นต่ำ/เพดานแยกตามสกุลเงิน (เก็บในตาราง currency_limits)
+	CurrencyLimits []CurrencyLimit `gorm:"-" json:"currency_limits"`
 }
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// ShareAllocation: ส่วนแบ่งได้เสีย (ถือสู้) ของบิล 1 ใบ ต่อ 1 ชั้นในสายงาน
+// ชั้นบนสุด (HolderID = nil) คือบริษัท ซึ่งรับส่วนที่เหลือหลังหักหุ้นของทุกชั้น
+type ShareAllocation struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	TicketType    string    `gorm:"uniqueIndex:idx_share_allocation;size:10" json:"ticket_type"` // single, parlay
+	TicketID      uint      `gorm:"uniqueIndex:idx_share_allocation" json:"ticket_id"`
+	Level         int       `gorm:"uniqueIndex:idx_share_allocation" json:"level"` // 1 = Upline ตรงของสมาชิก
+	MemberID      uint      `gorm:"index" json:"member_id"`                        // สมาชิกเจ้าของบิล
+	HolderID      *uint     `gorm:"index" json:"holder_id"`                        // คนถือหุ้นชั้นนี้ (nil = บริษัท)
+	HolderRole    string    `json:"holder_role"`
+	SharePct      float64   `json:"share_pct"`       // % ที่ชั้นนี้ถือจริง
+	Stake         Money     `json:"stake"`           // ยอดแทงของบิล
+	MemberWinLoss Money     `json:"member_win_loss"` // ได้เสียฝั่งสมาชิก (payout - stake)
+	Amount        Money     `json:"amount"`          // ได้เสียของชั้นนี้ (ฝั่งเจ้ามือ)
+	SettledAt     time.Time `gorm:"index" json:"settled_at"`
+	AgentPeriodID *uint     `gorm:"index" json:"agent_period_id"` // รอบเคลียร์ยอด Agent ที่นับบิลนี้แล้ว (nil = ยังไม่ปิดรอบ)
+	CreatedAt     time.Time `json:"created_at"`
+}
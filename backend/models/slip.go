@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// ผลการเทียบสลิปกับสลิปที่เคยอัปโหลด
+const (
+	SlipMatchExact = "exact" // ไฟล์เดียวกันทุกไบต์
+	SlipMatchNear  = "near"  // ภาพเกือบเหมือน (บีบอัดใหม่/ย่อ/ครอปเล็กน้อย)
+)
+
+// SlipFingerprint: ลายนิ้วมือสลิปฝากเงิน 1 ใบ (1 รายการฝากมีได้ 1 ใบ)
+// เทียบกับสลิปของทุกคน ถ้าตรงกับรายการเดิมจะติดธงไว้ และ Admin ต้องใส่เหตุผลก่อนอนุมัติ
+type SlipFingerprint struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	TransactionID  uint       `gorm:"uniqueIndex" json:"transaction_id"`
+	UserID         uint       `gorm:"index" json:"user_id"`
+	SHA256         string     `gorm:"column:sha256;size:64;index" json:"sha256"`
+	PHash          *int64     `gorm:"column:phash" json:"phash"`    // dHash 64 บิต (nil = อ่านรูปไม่ได้)
+	DuplicateOfID  *uint      `gorm:"index" json:"duplicate_of_id"` // รายการเดิมที่ใช้สลิปนี้ไปแล้ว
+	Match          string     `gorm:"size:10" json:"match"`         // exact, near (ว่าง = ไม่ซ้ำ)
+	Distance       int        `json:"distance"`                     // จำนวนบิตที่ต่างกัน (exact = 0)
+	OverrideReason string     `json:"override_reason"`              // เหตุผลที่ Admin ยืนยันอนุมัติทั้งที่ซ้ำ
+	OverrideBy     *uint      `json:"override_by"`
+	OverrideAt     *time.Time `json:"override_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+
+	DuplicateOf *Transaction `gorm:"foreignKey:DuplicateOfID" json:"duplicate_of,omitempty"`
+}
+
+// Flagged: สลิปนี้ตรงกับรายการเดิมและยังไม่มี Admin ยืนยัน
+func (s *SlipFingerprint) Flagged() bool {
+	return s.DuplicateOfID != nil && s.OverrideAt == nil
+}
+
+// SlipAccessLog: ประวัติการเปิดดูสลิปของคนที่ไม่ใช่เจ้าของ (Admin / Agent ต้นสาย)
+type SlipAccessLog struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	TransactionID uint      `gorm:"index" json:"transaction_id"`
+	OwnerID       uint      `gorm:"index" json:"owner_id"`
+	ViewerID      uint      `gorm:"index" json:"viewer_id"`
+	Role          string    `gorm:"size:20" json:"role"`
+	Variant       string    `gorm:"size:10" json:"variant"` // full, thumb
+	Via           string    `gorm:"size:10" json:"via"`     // session (Login), signed (URL ลงลายเซ็น)
+	IP            string    `gorm:"size:45" json:"ip"`
+	CreatedAt     time.Time `gorm:"index" json:"created_at"`
+}
@@ -1,7 +1,10 @@
 package models
 
 import (
+	"fmt"
 	"time"
+
+	"gorm.io/gorm"
 )
 
 type Transaction struct {
@@ -9,16 +12,47 @@ type Transaction struct {
 	UserID        uint      `json:"user_id"`
 	User          User      `gorm:"foreignKey:UserID;references:ID" json:"user"`
 	AdminID       *uint     `json:"admin_id"`
-	Amount        float64   `json:"amount"`
-	Type          string    `json:"type"`                            // deposit, withdraw, adjustment
-	Status        string    `gorm:"default:'pending'" json:"status"` // pending, approved, rejected
+	Amount        Money     `json:"amount"`
+	Type          string    `json:"type"`                            // deposit, withdraw, withdraw_refund, bet, payout, commission, transfer_in, transfer_out, adjustment, credit_clear, bonus_release, referral, cashback
+	Status        string    `gorm:"default:'pending'" json:"status"` // pending, approved, rejected (ถอน: pending -> approved -> paid / failed)
 	BankName      string    `json:"bank_name"`
 	BankAccount   string    `json:"account_number"`
 	AccountName   string    `json:"account_name"`
-	BalanceBefore float64   `json:"balance_before"`
-	BalanceAfter  float64   `json:"balance_after"`
-	SlipURL       string    `json:"slip_url"`
+	BankAccountID *uint     `gorm:"index" json:"bank_account_id"` // บัญชีรับเงินของเว็บที่ให้สมาชิกโอนเข้า (ฝาก)
+	BalanceBefore Money     `json:"balance_before"`
+	BalanceAfter  Money     `json:"balance_after"`
+	SlipPath      string    `gorm:"column:slip_url" json:"-"`       // ไฟล์สลิปใน Storage (/uploads/<key>) ไม่เปิดให้เข้าตรง (ถอน = หลักฐานการโอนของ Admin)
+	SlipThumbPath string    `gorm:"column:slip_thumb_url" json:"-"` // รูปย่อของสลิป (ไฟล์ที่อัปโหลดหลังมี Storage)
+	JournalID     *uint     `gorm:"index" json:"journal_id"`        // รายการใน Ledger ที่ทำให้เครดิตเปลี่ยน (nil = ยังไม่มีเงินเคลื่อนไหว)
+	PromotionID   *uint     `json:"promotion_id"`                   // โปรที่สมาชิกเลือกตอนแจ้งฝาก (ได้โบนัสตอน Admin อนุมัติ)
+	BonusAmount   Money     `json:"bonus_amount"`                   // ส่วนที่ตัดจากกระเป๋าโบนัส (บิลแทง) ไม่นับรวมใน Amount
 	Note          string    `json:"note"`
 	CreatedAt     time.Time `json:"created_at"`
 	UpdatedAt     time.Time `json:"updated_at"`
+
+	// อ่านสลิปผ่าน API ที่ต้อง Login และมีสิทธิ์ (เจ้าของ / Admin / Agent ต้นสาย) เติมให้ตอนโหลดจาก DB
+	SlipURL      string `gorm:"-" json:"slip_url"`
+	SlipThumbURL string `gorm:"-" json:"slip_thumb_url"`
+
+	Slip  *SlipFingerprint `gorm:"foreignKey:TransactionID" json:"slip,omitempty"`  // ลายนิ้วมือสลิป (เฉพาะรายการฝาก)
+	Steps []WithdrawalStep `gorm:"foreignKey:TransactionID" json:"steps,omitempty"` // ขั้นตอนที่ Admin ทำกับรายการถอน
+}
+
+func (t *Transaction) AfterFind(tx *gorm.DB) error {
+	t.setSlipURLs()
+	return nil
+}
+
+func (t *Transaction) AfterCreate(tx *gorm.DB) error {
+	t.setSlipURLs()
+	return nil
+}
+
+func (t *Transaction) setSlipURLs() {
+	if t.SlipPath != "" {
+		t.SlipURL = fmt.Sprintf("/api/v3/slips/%d", t.ID)
+	}
+	if t.SlipThumbPath != "" {
+		t.SlipThumbURL = fmt.Sprintf("/api/v3/slips/%d?size=thumb", t.ID)
+	}
 }
@@ -6,18 +6,34 @@ import (
 	"gorm.io/gorm"
 )
 
+// โหมดบัญชีเครดิต
+const (
+	AccountModeCash   = "cash"   // Prepaid: เครดิตหมด = แทงไม่ได้
+	AccountModeCredit = "credit" // Post-paid: แทงได้ถึงวงเงิน ยอดติดลบได้ เคลียร์ตอนปิดรอบ
+)
+
 type User struct {
-	ID          uint    `gorm:"primaryKey" json:"id"`
-	Username    string  `gorm:"unique;not null" json:"username"`
-	Password    string  `gorm:"not null" json:"-"`
-	Role        string  `gorm:"default:user" json:"role"` // admin, user
-	Phone       string  `json:"phone"`                    // เอา unique ออกเพื่อให้ค่าว่างซ้ำกันได้
-	FirstName   string  `json:"first_name"`
-	LastName    string  `json:"last_name"`
-	FullName    string  `json:"fullName"` // สำหรับแสดงผลชื่อเต็ม
-	BankName    string  `json:"bank_name"`
-	BankAccount string  `json:"bank_account"` // เอา unique ออก
-	Credit      float64 `gorm:"default:0" json:"credit"`
+	ID          uint   `gorm:"primaryKey" json:"id"`
+	Username    string `gorm:"unique;not null" json:"username"`
+	Password    string `gorm:"not null" json:"-"`
+	Role        string `gorm:"default:user" json:"role"` // admin, user
+	Phone       string `json:"phone"`                    // เอา unique ออกเพื่อให้ค่าว่างซ้ำกันได้
+	FirstName   string `json:"first_name"`
+	LastName    string `json:"last_name"`
+	FullName    string `json:"fullName"` // สำหรับแสดงผลชื่อเต็ม
+	BankName    string `json:"bank_name"`
+	BankAccount string `json:"bank_account"`                             // เอา unique ออก
+	Credit      Money  `gorm:"default:0;<-:create" json:"credit"`        // ยอดใช้ได้ (available)
+	HeldCredit  Money  `gorm:"default:0;<-:create" json:"held_credit"`   // ยอดพักรอถอน (ยังเป็นของสมาชิก แต่ใช้แทง/ถอนซ้ำไม่ได้)
+	BonusCredit Money  `gorm:"default:0;<-:create" json:"bonus_credit"`  // กระเป๋าโบนัส (ใช้แทงได้ ถอนไม่ได้จนกว่าทำเทิร์นครบ)
+	AccountMode string `gorm:"size:10;default:cash" json:"account_mode"` // cash = เติมเงินก่อนเล่น, credit = เล่นตามวงเงินเครดิต
+	CreditLimit Money  `gorm:"default:0" json:"credit_limit"`            // วงเงินเครดิต (ใช้เมื่อ AccountMode = credit)
+	Currency    string `gorm:"size:3;default:THB" json:"currency"`       // สกุลเงินของกระเป๋า (ยอดทุกช่องของ User เป็นสกุลนี้)
+	BankGroup   string `gorm:"size:30" json:"bank_group"`                // กลุ่มบัญชีรับเงิน (กลยุทธ์ group) ว่าง = บัญชีกลาง
+
+	// บัญชีรับเงินถอน (BankName/BankAccount): ถอนได้เฉพาะบัญชีนี้ ต้องยืนยันโดย Admin และพ้นช่วงรอหลังเปลี่ยน
+	BankVerifiedAt *time.Time `json:"bank_verified_at"` // nil = ยังไม่ยืนยัน (เปลี่ยนบัญชีแล้วต้องยืนยันใหม่)
+	BankChangedAt  *time.Time `json:"bank_changed_at"`  // ตั้ง/เปลี่ยนบัญชีล่าสุด (นับช่วงรอก่อนถอน)
 
 	// --- ส่วนที่แก้ไข ---
 	ParentID *uint `json:"parent_id"`
@@ -31,3 +47,27 @@ type User struct {
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 }
+
+// AvailableCredit: ยอดที่ใช้แทงได้ (บัญชีเครดิตรวมวงเงินที่เหลือด้วย)
+func (u *User) AvailableCredit() Money {
+	if u.AccountMode == AccountModeCredit {
+		return u.Credit + u.CreditLimit
+	}
+	return u.Credit
+}
+
+// PlayableCredit: ยอดที่ใช้แทงได้รวมกระเป๋าโบนัส (บัญชีเครดิตไม่ใช้โบนัส)
+func (u *User) PlayableCredit() Money {
+	if u.AccountMode == AccountModeCredit {
+		return u.AvailableCredit()
+	}
+	return u.Credit + u.BonusCredit
+}
+
+// Outstanding: ยอดค้างชำระของบัญชีเครดิต (ยอดติดลบ)
+func (u *User) Outstanding() Money {
+	if u.Credit < 0 {
+		return -u.Credit
+	}
+	return 0
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// ขั้นตอนของรายการถอน (Transaction.Status: pending -> approved -> paid / failed, pending -> rejected)
+const (
+	WithdrawStepApprove = "approve" // อนุมัติ (ยอดถึงเกณฑ์ต้องมีผู้อนุมัติ 2 คน)
+	WithdrawStepPaid    = "paid"    // โอนเงินแล้ว (มีเลขอ้างอิงธนาคาร / หลักฐานการโอน)
+	WithdrawStepFailed  = "failed"  // โอนไม่สำเร็จ คืนยอดพักเข้ากระเป๋า
+)
+
+// WithdrawalStep: ประวัติการดำเนินการกับรายการถอน 1 ครั้ง (ใครทำอะไร เมื่อไหร่)
+// ใช้นับผู้อนุมัติของรายการ และยอดที่ Admin แต่ละคนอนุมัติไปแล้วในวันนั้น
+type WithdrawalStep struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	TransactionID uint      `gorm:"index" json:"transaction_id"`
+	AdminID       uint      `gorm:"index:idx_withdrawal_step_admin" json:"admin_id"`
+	Admin         *User     `gorm:"foreignKey:AdminID" json:"admin,omitempty"`
+	Action        string    `gorm:"size:20" json:"action"`
+	Amount        Money     `json:"amount"`
+	Currency      string    `gorm:"size:3" json:"currency"`         // สกุลของกระเป๋าสมาชิก (เพดานอนุมัติรายวันแยกตามสกุล)
+	BankReference string    `gorm:"size:100" json:"bank_reference"` // เลขอ้างอิงการโอน (paid)
+	Reason        string    `json:"reason"`                         // สาเหตุที่โอนไม่สำเร็จ (failed)
+	CreatedAt     time.Time `gorm:"index:idx_withdrawal_step_admin" json:"created_at"`
+}
@@ -1,13 +1,35 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// กลยุทธ์เลือกบัญชีรับเงินให้สมาชิก (SystemSetting.BankRotation)
+const (
+	BankRotationRoundRobin = "round_robin" // วนบัญชีที่ถูกแจกล่าสุดนานที่สุดก่อน
+	BankRotationLeastUsed  = "least_used"  // บัญชีที่มียอดฝากวันนี้น้อยที่สุด
+	BankRotationGroup      = "group"       // ตามกลุ่มสมาชิก (User.BankGroup = BankAccount.Group) แล้ววนในกลุ่ม
+)
 
 type BankAccount struct {
 	gorm.Model
-	BankName      string `json:"bank_name"`      // เช่น กสิกรไทย, ไทยพาณิชย์
-	AccountName   string `json:"account_name"`   // ชื่อเจ้าของบัญชี
-	AccountNumber string `json:"account_number"` // เลขบัญชี
-	IsActive      bool   `json:"is_active" gorm:"default:true"`
+	BankName         string     `json:"bank_name"`                                    // เช่น กสิกรไทย, ไทยพาณิชย์
+	AccountName      string     `json:"account_name"`                                 // ชื่อเจ้าของบัญชี
+	AccountNumber    string     `json:"account_number"`                               // เลขบัญชี
+	PromptPayID      string     `json:"promptpay_id"`                                 // เบอร์มือถือ / เลขบัตรประชาชน / e-Wallet ID ที่ผูกบัญชีนี้ (ว่าง = ไม่รับ QR)
+	IsActive         bool       `json:"is_active" gorm:"default:true"`                // ปิด = ไม่แจกให้สมาชิกแล้ว (เช่น เตรียมปลดก่อนธนาคารอายัด)
+	Group            string     `gorm:"column:bank_group;size:30;index" json:"group"` // ใช้กับกลยุทธ์ group (ว่าง = บัญชีกลาง ใช้ได้ทุกกลุ่ม)
+	DailyAmountLimit Money      `json:"daily_amount_limit"`                           // ยอดฝากรวมต่อวัน (0 = ไม่จำกัด)
+	DailyCountLimit  int        `json:"daily_count_limit"`                            // จำนวนรายการฝากต่อวัน (0 = ไม่จำกัด)
+	LastAssignedAt   *time.Time `json:"last_assigned_at"`                             // ครั้งล่าสุดที่แจกให้สมาชิก (ใช้วน round_robin)
+	Note             string     `json:"note"`
 }
 
-// models/transaction.go
+// UserBankAssignment: บัญชีรับเงินที่แจกให้สมาชิกแต่ละคน (ใช้ต่อจนบัญชีถูกปิดหรือเต็มวงเงินวันนั้น)
+type UserBankAssignment struct {
+	UserID        uint      `gorm:"primaryKey" json:"user_id"`
+	BankAccountID uint      `gorm:"index" json:"bank_account_id"`
+	AssignedAt    time.Time `json:"assigned_at"`
+}
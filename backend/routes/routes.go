@@ -8,9 +8,6 @@ import (
 )
 
 func SetupRoutes(app *fiber.App) {
-	// 1. Static Files
-	app.Static("/uploads", "./uploads")
-
 	// API Group V3
 	api := app.Group("/api/v3")
 
@@ -18,14 +15,31 @@ func SetupRoutes(app *fiber.App) {
 	api.Post("/login", handlers.Login)
 	api.Post("/register", handlers.Register) // อันนี้คือสมัครสมาชิกหน้าเว็บ (ลูกค้าสมัครเอง)
 	api.Get("/settings", handlers.GetSettings)
-	api.Get("/config/bank", handlers.GetAdminBank)
-	api.Post("/transaction/withdraw-request", handlers.RequestWithdraw)
+	api.Get("/config/bank", middleware.OptionalAuth(), handlers.GetAdminBank) // Login แล้วได้บัญชีที่แจกให้
+	// เส้นทางเดิม ใช้ตัวเดียวกับ /user/withdraw
+	api.Post("/transaction/withdraw-request", middleware.AuthMiddleware(), handlers.CreateWithdraw)
+	api.Get("/ref/:code", handlers.OpenReferralLink)     // เปิดลิงก์แนะนำ (นับคลิก)
+	api.Get("/slip-files/:id", handlers.ServeSignedSlip) // สลิปจาก URL ลงลายเซ็น (ตรวจลายเซ็นแทน Token)
+
+	// Payment Gateway (ผู้ให้บริการยิงเข้ามาเอง ตรวจลายเซ็น HMAC แทน Token)
+	api.Post("/payments/mock/:ref/complete", handlers.CompleteMockPayment) // จำลองการชำระของ mock (ปิด mock = 404)
+	api.Post("/payments/:provider/webhook", handlers.PaymentWebhook)       // ผู้ให้บริการแจ้งผล (event ซ้ำ = ไม่ทำอะไร)
 
 	// --- 🔵 2. Root Protected Routes ---
 	authOnly := api.Group("/", middleware.AuthMiddleware())
 	{
 		authOnly.Get("/me", handlers.GetMe)
+		authOnly.Get("/slips/:id", handlers.ViewSlip)       // เจ้าของ / Admin / Agent ต้นสาย
+		authOnly.Get("/slips/:id/url", handlers.GetSlipURL) // URL ลงลายเซ็นอายุสั้นสำหรับ <img>
 		authOnly.Get("/match/:path", handlers.GetMatches)
+		authOnly.Get("/notifications", handlers.GetMyNotifications)
+		authOnly.Post("/notifications/read", handlers.ReadMyNotifications)
+
+		// แนะนำเพื่อน (Master/Agent และสมาชิกถ้าเปิดใน Settings)
+		authOnly.Get("/referral", handlers.GetMyReferrals)
+		authOnly.Get("/referral/members", handlers.GetMyReferredMembers)
+		authOnly.Post("/referral/codes", handlers.CreateMyReferralCode)
+		authOnly.Patch("/referral/codes/:id", handlers.UpdateMyReferralCode)
 	}
 
 	// --- 🔵 3. Member Routes ---
@@ -34,9 +48,21 @@ func SetupRoutes(app *fiber.App) {
 		member.Get("/balance", handlers.GetBalance)
 		member.Get("/profile", handlers.GetProfile)
 		member.Post("/deposit", handlers.CreateDeposit)
+		member.Post("/deposit/promptpay", handlers.CreatePromptPayDeposit) // ฝากด้วย QR ยอดมีเศษสตางค์เฉพาะตัว
+		member.Get("/deposit/promptpay/:id/qr", handlers.GetPromptPayQR)
+		member.Post("/deposit/gateway", handlers.CreateGatewayDeposit) // ฝากผ่านผู้ให้บริการชำระเงิน (ได้ pay_url)
 		member.Post("/withdraw", handlers.CreateWithdraw)
+		member.Put("/bank", handlers.UpdateMyBank) // บัญชีรับเงินถอน (ถอนเข้าได้เฉพาะบัญชีนี้)
 		member.Get("/bet-history", handlers.GetBetHistory)
 		member.Post("/bet", handlers.PlaceBet)
+		member.Get("/statement", handlers.GetMyStatement)
+
+		// Promotions (เลือกโปรตอนแจ้งฝากด้วย promotion_id / promo_code)
+		member.Get("/promotions", handlers.GetAvailablePromotions)
+		member.Post("/promotions/claim", handlers.ClaimPromotion)
+		member.Get("/bonus", handlers.GetMyBonus)
+		member.Post("/bonus/:id/forfeit", handlers.ForfeitMyBonus)
+		member.Get("/cashback", services.GetMyCashback)
 	}
 
 	// --- 🔴 4. Admin Routes ---
@@ -56,29 +82,138 @@ func SetupRoutes(app *fiber.App) {
 
 		// Financial & Transactions
 		admin.Get("/finance/summary", handlers.GetFinanceSummary)
+		admin.Get("/ledger", services.GetLedgerEntries)
 		admin.Get("/transactions/pending", handlers.GetPendingTransactions)
 		admin.Get("/transactions/history", handlers.GetTransactionHistory)
 		admin.Post("/transactions/approve/:id", handlers.ApproveTransaction)
 		admin.Post("/transactions/reject/:id", handlers.RejectTransaction)
 		admin.Get("/transactions", handlers.GetLatestTransactions)
 		admin.Get("/users/:id/transactions", handlers.GetUserTransactions)
+		admin.Get("/users/:id/statement", handlers.GetMemberStatement)
 
 		admin.Get("/betslips", handlers.GetAdminBetSlips)
 		admin.Delete("/betslips/:id", handlers.DeleteBetSlip)
 		admin.Post("/transactions/approve-only/:id", handlers.ApproveDepositSlipOnly)
 
+		// Slips (ประวัติการเปิดดูสลิป)
+		admin.Get("/slip-access", handlers.GetSlipAccessLogs)
+
+		// Withdrawals (อนุมัติที่ /transactions/approve/:id -> บันทึกโอนแล้ว / โอนไม่สำเร็จ)
+		admin.Get("/withdrawals", handlers.GetWithdrawals)
+		admin.Post("/withdrawals/:id/paid", handlers.PayWithdrawal)
+		admin.Post("/withdrawals/:id/failed", handlers.FailWithdrawal)
+		admin.Post("/withdrawals/:id/payout", handlers.PayoutWithdrawal) // สั่งจ่ายผ่านผู้ให้บริการแทนโอนเอง
+		admin.Get("/payments", handlers.GetPaymentOrders)
+		admin.Post("/payments/:id/sync", handlers.SyncPaymentOrder)
+
 		// System Configuration
 		admin.Put("/config/bank", handlers.UpdateAdminBank)
 		admin.Put("/settings", handlers.UpdateSettings)
 
+		// Receiving Bank Accounts (หลายบัญชี / หมุนเวียน / วงเงินรายวัน)
+		admin.Get("/bank-accounts", handlers.GetBankAccounts)
+		admin.Post("/bank-accounts", handlers.CreateBankAccount)
+		admin.Get("/bank-accounts/report", handlers.GetBankAccountReport)
+		admin.Put("/bank-accounts/:id", handlers.UpdateBankAccount)
+		admin.Delete("/bank-accounts/:id", handlers.DeleteBankAccount)
+		admin.Put("/users/:id/bank-group", handlers.SetUserBankGroup)
+
+		// Bank Statement (นำเข้า CSV + คิวกระทบยอดฝาก)
+		admin.Post("/statements/import", handlers.ImportBankStatement)
+		admin.Get("/statements/imports", handlers.GetStatementImports)
+		admin.Get("/statements/lines", handlers.GetStatementLines)
+		admin.Get("/statements/lines/:id/candidates", handlers.GetStatementLineCandidates)
+		admin.Post("/statements/lines/:id/match", handlers.MatchStatementLine)
+		admin.Post("/statements/lines/:id/ignore", handlers.IgnoreStatementLine)
+		admin.Get("/statement-mappings", handlers.GetStatementMappings)
+		admin.Put("/statement-mappings/:bank", handlers.UpdateStatementMapping)
+
+		// Currencies (ขั้นต่ำ/เพดานรายสกุล / อัตราแลกเปลี่ยน)
+		admin.Get("/currencies", handlers.GetCurrencies)
+		admin.Put("/currencies/:code/limits", handlers.UpdateCurrencyLimit)
+		admin.Get("/exchange-rates", handlers.GetExchangeRates)
+		admin.Post("/exchange-rates", handlers.CreateExchangeRate)
+
+		// Promotions (โบนัส / เทิร์นโอเวอร์)
+		admin.Get("/promotions", handlers.GetPromotions)
+		admin.Post("/promotions", handlers.CreatePromotion)
+		admin.Put("/promotions/:id", handlers.UpdatePromotion)
+		admin.Get("/promotions/:id/grants", handlers.GetPromotionGrants)
+		admin.Post("/bonus-grants/:id/forfeit", handlers.ForfeitBonusGrant)
+
+		// Referrals (ที่มาของสมาชิก / ยอดแนะนำ)
+		admin.Get("/referrals", handlers.GetReferralAttributions)
+		admin.Get("/users/:id/referrals", handlers.GetUserReferralStats)
+
 		// Game & Settlement
 		admin.Get("/bets", handlers.GetAllBets)
 		admin.Post("/settle", services.ManualSettlement)
+		admin.Get("/settle/preview", services.PreviewSettlement)
+
+		// Commission (ค่าคอมคืนยอดเล่น)
+		admin.Post("/commission/run", services.ManualCommission)
+		admin.Get("/commission/rebates", services.GetCommissionRebates)
+
+		// Cashback (คืนยอดเสีย: ตั้งขั้น / ดูก่อนสร้างรอบ / อนุมัติจ่าย / รายงาน)
+		admin.Get("/cashback/tiers", services.GetCashbackTiers)
+		admin.Put("/cashback/tiers/:currency", services.UpdateCashbackTiers)
+		admin.Get("/cashback/preview", services.PreviewCashback)
+		admin.Get("/cashback/batches", services.GetCashbackBatches)
+		admin.Post("/cashback/batches", services.CreateCashbackBatch)
+		admin.Get("/cashback/batches/:id", services.GetCashbackBatch)
+		admin.Post("/cashback/batches/:id/approve", services.ApproveCashback)
+		admin.Post("/cashback/batches/:id/cancel", services.CancelCashback)
+		admin.Get("/cashback/report", services.GetCashbackReport)
+
+		// Cron Jobs (ดูสถานะ / ตั้งเวลา / หยุด / สั่งรันทันที)
+		admin.Get("/jobs", services.GetJobs)
+		admin.Patch("/jobs/:name", services.UpdateJob)
+		admin.Post("/jobs/:name/run", services.RunJobNow)
+
+		// Agent Settlement Periods (ปิดรอบ / ใบแจ้งยอด)
+		admin.Get("/agent-periods", handlers.GetAgentPeriods)
+		admin.Post("/agent-periods/close", handlers.CloseAgentPeriod)
+		admin.Get("/invoices", handlers.GetAgentInvoices)
+
+		// Reconciliation (กระทบยอดเครดิต / อนุมัติแก้ยอด)
+		admin.Get("/reconcile/drifts", services.GetBalanceDrifts)
+		admin.Get("/reconcile/users/:id", services.GetUserReconciliation)
+		admin.Post("/reconcile/drifts/:id/correct", services.CorrectBalanceDrift)
+		admin.Post("/reconcile/drifts/:id/dismiss", services.DismissBalanceDrift)
 
 		// User Actions
 		admin.Patch("/users/:id/password", handlers.ChangeUserPassword)
+		admin.Patch("/users/:id/currency", handlers.UpdateUserCurrency)
+		admin.Put("/users/:id/bank", handlers.UpdateUserBank)
+		admin.Post("/users/:id/bank/verify", handlers.VerifyUserBank)
 		admin.Post("/users/:id/toggle-lock", handlers.ToggleUserLock)
 		admin.Get("/users/:id/bets", handlers.GetUserBetsAdmin)
 		admin.Get("/matches-summary", handlers.GetMatchesSummary)
+
+		// Share (หุ้นถือสู้)
+		admin.Get("/share/pnl", handlers.GetSharePnLSummary)
+	}
+
+	// --- 🟠 5. Agent Routes ---
+	// Group นี้อนุญาต Agent, Master และ Admin
+	agent := api.Group("/agent", middleware.AuthMiddleware(), middleware.RequireAgentRole())
+	{
+		// Share (หุ้นถือสู้ของตัวเอง)
+		agent.Get("/share/pnl", handlers.GetMySharePnL)
+		agent.Get("/share/tickets", handlers.GetMyShareTickets)
+		agent.Get("/share/positions", handlers.GetMyOpenPositions)
+
+		// Statement ของสมาชิกในสายงาน
+		agent.Get("/members/:id/statement", handlers.GetMemberStatement)
+
+		// บัญชีเครดิต (วงเงิน / ยอดค้างชำระ)
+		agent.Patch("/members/:id/credit-line", handlers.UpdateCreditLine)
+		agent.Get("/credit-lines", handlers.GetCreditLines)
+
+		// เคลียร์ยอดรายสัปดาห์ (ยอดรอบปัจจุบัน / ใบแจ้งยอด / ยืนยันการชำระ)
+		agent.Get("/settlement/summary", handlers.GetSettlementSummary)
+		agent.Get("/invoices", handlers.GetMyInvoices)
+		agent.Get("/invoices/:id", handlers.GetInvoice)
+		agent.Post("/invoices/:id/payments", handlers.RecordInvoicePayment)
 	}
 }
@@ -0,0 +1,292 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/PawornpratKongdaeng/soccer/database"
+	"github.com/PawornpratKongdaeng/soccer/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ==========================================
+// รอบเคลียร์ยอด Agent (Weekly Agent Settlement)
+// ==========================================
+// รอบละ 1 สัปดาห์ จันทร์ 00:00 - จันทร์ถัดไป 00:00 (เวลาไทย)
+// ปิดรอบ = ผูก ShareAllocation (ตาม settled_at) และ CommissionRebate (ตาม period_end) ที่ยังไม่มีรอบเข้ากับรอบนี้
+// แล้วออกใบแจ้งยอดให้ Agent/Master ทุกคนที่มียอด บิลที่เคลียร์ช้าจะไปอยู่รอบถัดไป ไม่ถูกนับซ้ำ
+//
+// ยอดที่ Agent ต้องส่งต้นสาย = ได้เสียส่วนของชั้นที่อยู่เหนือตัวเอง (UplineWinLoss)
+//   - ค่าคอมที่ระบบจ่ายให้สมาชิกในสายงานไปแล้ว (สมาชิกจึงเคลียร์กับ Agent น้อยลงเท่านั้น)
+// ค่าคอมของ Agent เองจ่ายเข้ากระเป๋าผ่าน Ledger แล้ว จึงแสดงไว้อ้างอิงเท่านั้น
+
+var (
+	ErrPeriodNotEnded     = errors.New("period has not ended")
+	ErrPeriodClosed       = errors.New("period already closed")
+	ErrPeriodOutOfOrder   = errors.New("a later period is already closed")
+	ErrInvoiceNotAllowed  = errors.New("not allowed to record payment on this invoice")
+	ErrInvoiceOverpayment = errors.New("payment exceeds remaining amount")
+)
+
+// AgentPeriodBounds: รอบ (จันทร์-อาทิตย์) ที่เวลา t อยู่
+func AgentPeriodBounds(t time.Time) (time.Time, time.Time) {
+	t = t.In(bangkokTZ)
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, bangkokTZ)
+	offset := (int(day.Weekday()) + 6) % 7 // จันทร์ = 0
+	start := day.AddDate(0, 0, -offset)
+	return start, start.AddDate(0, 0, 7)
+}
+
+// periodScope: กรองรายการของรอบ periodID (nil = รายการที่ยังไม่ปิดรอบ)
+func periodScope(column string, periodID *uint) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if periodID == nil {
+			return db.Where(column + " IS NULL")
+		}
+		return db.Where(column+" = ?", *periodID)
+	}
+}
+
+// agentInvoices: คิดยอดใบแจ้งยอดของ Agent จากรายการของรอบ periodID (ไม่บันทึก)
+// agentIDs = nil คือ Agent/Master ทุกคน
+func agentInvoices(db *gorm.DB, periodID *uint, agentIDs []uint) ([]models.AgentInvoice, error) {
+	type allocRow struct {
+		AgentID       uint
+		Tickets       int64
+		Turnover      models.Money
+		MemberWinLoss models.Money
+		ShareWinLoss  models.Money
+		UplineWinLoss models.Money
+	}
+	var allocRows []allocRow
+	err := db.Table("share_allocations a").
+		Select(`a.holder_id AS agent_id, COUNT(*) AS tickets,
+			COALESCE(SUM(a.stake), 0) AS turnover,
+			COALESCE(SUM(a.member_win_loss), 0) AS member_win_loss,
+			COALESCE(SUM(a.amount), 0) AS share_win_loss,
+			COALESCE(SUM((SELECT COALESCE(SUM(u.amount), 0) FROM share_allocations u
+				WHERE u.ticket_type = a.ticket_type AND u.ticket_id = a.ticket_id AND u.level > a.level)), 0) AS upline_win_loss`).
+		Where("a.holder_id IS NOT NULL").
+		Scopes(periodScope("a.agent_period_id", periodID)).
+		Group("a.holder_id").
+		Scan(&allocRows).Error
+	if err != nil {
+		return nil, err
+	}
+	allocs := make(map[uint]allocRow, len(allocRows))
+	for _, r := range allocRows {
+		allocs[r.AgentID] = r
+	}
+
+	// ค่าคอมของสมาชิกเอง (Level 0) และของ Agent (Level 1 ขึ้นไป) แยกตามผู้รับ
+	memberCom, err := sumByUser(db.Model(&models.CommissionRebate{}).
+		Select("user_id, COALESCE(SUM(amount), 0) AS total").
+		Where("level = 0").Scopes(periodScope("agent_period_id", periodID)).Group("user_id"))
+	if err != nil {
+		return nil, err
+	}
+	agentCom, err := sumByUser(db.Model(&models.CommissionRebate{}).
+		Select("user_id, COALESCE(SUM(amount), 0) AS total").
+		Where("level > 0").Scopes(periodScope("agent_period_id", periodID)).Group("user_id"))
+	if err != nil {
+		return nil, err
+	}
+
+	var agents []models.User
+	query := db.Select("id", "parent_id", "currency").Where("role IN ?", []string{"master", "agent"}).Order("id")
+	if agentIDs != nil {
+		query = query.Where("id IN ?", agentIDs)
+	}
+	if err := query.Find(&agents).Error; err != nil {
+		return nil, err
+	}
+
+	invoices := make([]models.AgentInvoice, 0, len(agents))
+	for _, agent := range agents {
+		var downlineCom models.Money
+		if len(memberCom) > 0 {
+			ids, err := DownlineIDs(db, agent.ID)
+			if err != nil {
+				return nil, err
+			}
+			for _, id := range ids {
+				downlineCom += memberCom[id]
+			}
+		}
+
+		a := allocs[agent.ID]
+		if a.Tickets == 0 && downlineCom == 0 && agentCom[agent.ID] == 0 {
+			continue
+		}
+
+		invoices = append(invoices, models.AgentInvoice{
+			AgentID:          agent.ID,
+			UplineID:         agent.ParentID,
+			Currency:         agent.Currency,
+			Tickets:          a.Tickets,
+			Turnover:         a.Turnover,
+			MemberWinLoss:    a.MemberWinLoss,
+			ShareWinLoss:     a.ShareWinLoss,
+			UplineWinLoss:    a.UplineWinLoss,
+			MemberCommission: downlineCom,
+			AgentCommission:  agentCom[agent.ID],
+			Amount:           a.UplineWinLoss - downlineCom,
+			Status:           models.InvoiceStatusUnpaid,
+		})
+	}
+	return invoices, nil
+}
+
+// PreviewAgentInvoice: ยอดที่ยังไม่ปิดรอบของ Agent 1 คน (nil = ยังไม่มียอด)
+func PreviewAgentInvoice(db *gorm.DB, agentID uint) (*models.AgentInvoice, error) {
+	invoices, err := agentInvoices(db, nil, []uint{agentID})
+	if err != nil || len(invoices) == 0 {
+		return nil, err
+	}
+	return &invoices[0], nil
+}
+
+// CloseAgentPeriod: ปิดรอบที่เริ่ม start (ปัดเป็นวันจันทร์) แล้วออกใบแจ้งยอด
+func CloseAgentPeriod(start time.Time, closedBy *uint) (*models.AgentPeriod, error) {
+	start, end := AgentPeriodBounds(start)
+	if end.After(time.Now()) {
+		return nil, ErrPeriodNotEnded
+	}
+
+	period := models.AgentPeriod{Start: start, End: end, ClosedBy: closedBy}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		// กันปิดรอบพร้อมกัน 2 ที่
+		if err := tx.Exec("LOCK TABLE agent_periods IN SHARE ROW EXCLUSIVE MODE").Error; err != nil {
+			return err
+		}
+
+		var latest models.AgentPeriod
+		err := tx.Order("start desc").First(&latest).Error
+		if err == nil {
+			if latest.Start.Equal(start) {
+				return ErrPeriodClosed
+			}
+			if latest.Start.After(start) {
+				return ErrPeriodOutOfOrder
+			}
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		if err := tx.Create(&period).Error; err != nil {
+			return err
+		}
+
+		// ผูกรายการที่ยังไม่มีรอบ (รวมรายการตกค้างจากรอบก่อน)
+		if err := tx.Model(&models.ShareAllocation{}).
+			Where("agent_period_id IS NULL AND settled_at < ?", end).
+			Update("agent_period_id", period.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.CommissionRebate{}).
+			Where("agent_period_id IS NULL AND period_end <= ?", end).
+			Update("agent_period_id", period.ID).Error; err != nil {
+			return err
+		}
+
+		invoices, err := agentInvoices(tx, &period.ID, nil)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		for i := range invoices {
+			invoices[i].PeriodID = period.ID
+			if invoices[i].Amount == 0 {
+				invoices[i].Status = models.InvoiceStatusSettled
+				invoices[i].SettledAt = &now
+			}
+		}
+		if len(invoices) > 0 {
+			if err := tx.Create(&invoices).Error; err != nil {
+				return err
+			}
+		}
+
+		period.Invoices = len(invoices)
+		return tx.Model(&period).Update("invoices", period.Invoices).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &period, nil
+}
+
+// RunAgentPeriodClose: ปิดรอบของสัปดาห์ที่แล้ว (งาน "agent-period" ใน Job Registry)
+func RunAgentPeriodClose() error {
+	start, _ := AgentPeriodBounds(time.Now())
+	period, err := CloseAgentPeriod(start.AddDate(0, 0, -7), nil)
+	if errors.Is(err, ErrPeriodClosed) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	log.Printf("✅ [AgentPeriod] Closed %s with %d invoices", period.Start.Format("2006-01-02"), period.Invoices)
+	return nil
+}
+
+// RecordInvoicePayment: บันทึกการชำระใบแจ้งยอด โดยฝั่งที่เป็นผู้รับเงินเป็นคนยืนยัน
+// (Agent ต้องจ่าย -> ต้นสาย/Admin ยืนยัน, ต้นสายต้องจ่าย -> Agent/Admin ยืนยัน)
+func RecordInvoicePayment(invoiceID uint, amount models.Money, note string, recorder models.User) (*models.AgentInvoice, error) {
+	if amount <= 0 {
+		return nil, ErrInvoiceOverpayment
+	}
+
+	var invoice models.AgentInvoice
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&invoice, invoiceID).Error; err != nil {
+			return err
+		}
+
+		if recorder.Role != "admin" {
+			receiver := invoice.AgentID
+			if invoice.Amount > 0 {
+				if invoice.UplineID == nil {
+					return ErrInvoiceNotAllowed // บริษัทเป็นผู้รับ ต้องให้ Admin ยืนยัน
+				}
+				receiver = *invoice.UplineID
+			}
+			if receiver != recorder.ID {
+				return ErrInvoiceNotAllowed
+			}
+		}
+
+		if invoice.Paid+amount > invoice.Amount.Abs() {
+			return fmt.Errorf("%w: remaining %s", ErrInvoiceOverpayment, invoice.Amount.Abs()-invoice.Paid)
+		}
+
+		if err := tx.Create(&models.AgentInvoicePayment{
+			InvoiceID:  invoice.ID,
+			Amount:     amount,
+			Note:       note,
+			RecordedBy: recorder.ID,
+		}).Error; err != nil {
+			return err
+		}
+
+		invoice.Paid += amount
+		invoice.Status = models.InvoiceStatusPartial
+		if invoice.Paid == invoice.Amount.Abs() {
+			now := time.Now()
+			invoice.Status = models.InvoiceStatusSettled
+			invoice.SettledAt = &now
+		}
+		return tx.Model(&invoice).Updates(map[string]interface{}{
+			"paid":       invoice.Paid,
+			"status":     invoice.Status,
+			"settled_at": invoice.SettledAt,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &invoice, nil
+}
@@ -0,0 +1,260 @@
+package services
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/PawornpratKongdaeng/soccer/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ==========================================
+// บัญชีรับเงิน (หลายบัญชี + หมุนเวียน + วงเงินรายวัน)
+// ==========================================
+// สมาชิกแต่ละคนได้บัญชีรับเงิน 1 บัญชี (UserBankAssignment) ใช้ต่อไปจนบัญชีนั้นถูกปิด
+// เต็มวงเงินรายวัน หรือไม่ตรงกลุ่มแล้ว จึงเลือกบัญชีใหม่ตาม SystemSetting.BankRotation
+// ยอดใช้ต่อวัน = รายการฝาก pending + approved ที่ผูกกับบัญชี (Transaction.BankAccountID) ตั้งแต่ 00:00 เวลาไทย
+
+var ErrNoBankAccount = errors.New("no receiving bank account available")
+
+// BankUsage: ยอดฝากของบัญชีรับเงินในช่วงเวลาหนึ่ง
+type BankUsage struct {
+	BankAccountID uint         `json:"bank_account_id"`
+	Count         int64        `json:"count"`
+	Amount        models.Money `json:"amount"`
+}
+
+// BankUsageToday: ยอดฝากวันนี้ (pending + approved) แยกตามบัญชี
+func BankUsageToday(db *gorm.DB, now time.Time) (map[uint]BankUsage, error) {
+	local := now.In(bangkokTZ)
+	start := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, bangkokTZ)
+
+	var rows []BankUsage
+	if err := db.Model(&models.Transaction{}).
+		Select("bank_account_id, COUNT(*) AS count, COALESCE(SUM(amount), 0) AS amount").
+		Where("type = ? AND status IN ? AND bank_account_id IS NOT NULL AND created_at >= ?", "deposit", []string{"pending", "approved"}, start).
+		Group("bank_account_id").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	usage := make(map[uint]BankUsage, len(rows))
+	for _, r := range rows {
+		usage[r.BankAccountID] = r
+	}
+	return usage, nil
+}
+
+// bankHasRoom: ยังไม่เต็มวงเงิน/จำนวนรายการของวันนี้
+func bankHasRoom(bank *models.BankAccount, usage BankUsage) bool {
+	if bank.DailyAmountLimit > 0 && usage.Amount >= bank.DailyAmountLimit {
+		return false
+	}
+	if bank.DailyCountLimit > 0 && usage.Count >= int64(bank.DailyCountLimit) {
+		return false
+	}
+	return true
+}
+
+// AssignBankAccount: บัญชีรับเงินของสมาชิก (ใช้บัญชีเดิมถ้ายังใช้ได้ ไม่งั้นเลือกใหม่ตามกลยุทธ์)
+func AssignBankAccount(db *gorm.DB, user *models.User) (*models.BankAccount, error) {
+	var settings models.SystemSetting
+	db.First(&settings, 1)
+	strategy := settings.BankRotation
+	if strategy != models.BankRotationLeastUsed && strategy != models.BankRotationGroup {
+		strategy = models.BankRotationRoundRobin
+	}
+
+	var chosen *models.BankAccount
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var banks []models.BankAccount
+		if err := tx.Where("is_active = ?", true).Order("id").Find(&banks).Error; err != nil {
+			return err
+		}
+		usage, err := BankUsageToday(tx, time.Now())
+		if err != nil {
+			return err
+		}
+
+		var candidates []*models.BankAccount
+		for i := range banks {
+			if bankHasRoom(&banks[i], usage[banks[i].ID]) {
+				candidates = append(candidates, &banks[i])
+			}
+		}
+		if strategy == models.BankRotationGroup {
+			candidates = bankGroupCandidates(candidates, user.BankGroup)
+		}
+
+		var current models.UserBankAssignment
+		if err := tx.Where("user_id = ?", user.ID).Limit(1).Find(&current).Error; err != nil {
+			return err
+		}
+		for _, b := range candidates {
+			if b.ID == current.BankAccountID {
+				chosen = b
+				return nil
+			}
+		}
+		if len(candidates) == 0 {
+			return ErrNoBankAccount
+		}
+
+		if strategy == models.BankRotationLeastUsed {
+			sort.SliceStable(candidates, func(i, j int) bool {
+				ui, uj := usage[candidates[i].ID], usage[candidates[j].ID]
+				if ui.Amount != uj.Amount {
+					return ui.Amount < uj.Amount
+				}
+				return ui.Count < uj.Count
+			})
+		} else {
+			sort.SliceStable(candidates, func(i, j int) bool {
+				ai, aj := candidates[i].LastAssignedAt, candidates[j].LastAssignedAt
+				if ai == nil || aj == nil {
+					return ai == nil && aj != nil
+				}
+				return ai.Before(*aj)
+			})
+		}
+		chosen = candidates[0]
+
+		now := time.Now()
+		chosen.LastAssignedAt = &now
+		if err := tx.Model(chosen).Update("last_assigned_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"bank_account_id", "assigned_at"}),
+		}).Create(&models.UserBankAssignment{UserID: user.ID, BankAccountID: chosen.ID, AssignedAt: now}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return chosen, nil
+}
+
+// bankGroupCandidates: บัญชีของกลุ่มสมาชิก ถ้ากลุ่มนี้ไม่มีบัญชีที่ใช้ได้ใช้บัญชีกลาง (Group ว่าง)
+func bankGroupCandidates(banks []*models.BankAccount, group string) []*models.BankAccount {
+	var own, shared []*models.BankAccount
+	for _, b := range banks {
+		switch {
+		case group != "" && b.Group == group:
+			own = append(own, b)
+		case b.Group == "":
+			shared = append(shared, b)
+		}
+	}
+	if len(own) > 0 {
+		return own
+	}
+	return shared
+}
+
+// AssignedBankAccountID: บัญชีที่แจกให้สมาชิกไว้ (ใช้ผูกกับรายการแจ้งฝาก) nil = ยังไม่เคยได้
+func AssignedBankAccountID(db *gorm.DB, userID uint) *uint {
+	var a models.UserBankAssignment
+	if err := db.Where("user_id = ?", userID).Limit(1).Find(&a).Error; err != nil || a.BankAccountID == 0 {
+		return nil
+	}
+	return &a.BankAccountID
+}
+
+// BankAccountReportRow: ยอดฝากของบัญชีรับเงิน 1 บัญชีในช่วงที่เลือก
+type BankAccountReportRow struct {
+	BankAccountID    uint         `json:"bank_account_id"`
+	BankName         string       `json:"bank_name"`
+	AccountNumber    string       `json:"account_number"`
+	AccountName      string       `json:"account_name"`
+	IsActive         bool         `json:"is_active"`
+	Deposits         int64        `json:"deposits"`        // รายการที่อนุมัติแล้ว
+	Amount           models.Money `json:"amount"`          // ยอดอนุมัติรวม
+	Pending          int64        `json:"pending"`         // รายการที่ยังรอ
+	PendingAmount    models.Money `json:"pending_amount"`  // ยอดที่ยังรอ
+	Depositors       int64        `json:"depositors"`      // จำนวนสมาชิกที่โอนเข้า
+	LastDepositAt    *time.Time   `json:"last_deposit_at"` // รายการล่าสุดในช่วง
+	TodayCount       int64        `json:"today_count"`
+	TodayAmount      models.Money `json:"today_amount"`
+	DailyAmountLimit models.Money `json:"daily_amount_limit"`
+	DailyCountLimit  int          `json:"daily_count_limit"`
+	AssignedUsers    int64        `json:"assigned_users"` // สมาชิกที่ถือบัญชีนี้อยู่
+}
+
+// BankAccountReport: ยอดฝากแยกตามบัญชีรับเงินในช่วง [from, to) ไว้ดูว่าบัญชีไหนยอดหนาควรพักก่อนโดนอายัด
+func BankAccountReport(db *gorm.DB, from, to time.Time) ([]BankAccountReportRow, error) {
+	var banks []models.BankAccount
+	if err := db.Order("id").Find(&banks).Error; err != nil {
+		return nil, err
+	}
+
+	type sumRow struct {
+		BankAccountID uint
+		Deposits      int64
+		Amount        models.Money
+		Pending       int64
+		PendingAmount models.Money
+		Depositors    int64
+		LastDepositAt *time.Time
+	}
+	var sums []sumRow
+	if err := db.Model(&models.Transaction{}).
+		Select(`bank_account_id,
+			COUNT(*) FILTER (WHERE status = 'approved') AS deposits,
+			COALESCE(SUM(amount) FILTER (WHERE status = 'approved'), 0) AS amount,
+			COUNT(*) FILTER (WHERE status = 'pending') AS pending,
+			COALESCE(SUM(amount) FILTER (WHERE status = 'pending'), 0) AS pending_amount,
+			COUNT(DISTINCT user_id) FILTER (WHERE status = 'approved') AS depositors,
+			MAX(created_at) AS last_deposit_at`).
+		Where("type = ? AND bank_account_id IS NOT NULL AND created_at >= ? AND created_at < ?", "deposit", from, to).
+		Group("bank_account_id").Scan(&sums).Error; err != nil {
+		return nil, err
+	}
+	byBank := make(map[uint]sumRow, len(sums))
+	for _, s := range sums {
+		byBank[s.BankAccountID] = s
+	}
+
+	type assignedRow struct {
+		BankAccountID uint
+		Users         int64
+	}
+	var assigned []assignedRow
+	if err := db.Model(&models.UserBankAssignment{}).Select("bank_account_id, COUNT(*) AS users").
+		Group("bank_account_id").Scan(&assigned).Error; err != nil {
+		return nil, err
+	}
+	users := make(map[uint]int64, len(assigned))
+	for _, a := range assigned {
+		users[a.BankAccountID] = a.Users
+	}
+
+	today, err := BankUsageToday(db, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]BankAccountReportRow, 0, len(banks))
+	for _, b := range banks {
+		s := byBank[b.ID]
+		rows = append(rows, BankAccountReportRow{
+			BankAccountID:    b.ID,
+			BankName:         b.BankName,
+			AccountNumber:    b.AccountNumber,
+			AccountName:      b.AccountName,
+			IsActive:         b.IsActive,
+			Deposits:         s.Deposits,
+			Amount:           s.Amount,
+			Pending:          s.Pending,
+			PendingAmount:    s.PendingAmount,
+			Depositors:       s.Depositors,
+			LastDepositAt:    s.LastDepositAt,
+			TodayCount:       today[b.ID].Count,
+			TodayAmount:      today[b.ID].Amount,
+			DailyAmountLimit: b.DailyAmountLimit,
+			DailyCountLimit:  b.DailyCountLimit,
+			AssignedUsers:    users[b.ID],
+		})
+	}
+	return rows, nil
+}
@@ -0,0 +1,540 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/PawornpratKongdaeng/soccer/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ==========================================
+// นำเข้า Statement ธนาคาร + จับคู่ยอดฝากอัตโนมัติ
+// ==========================================
+// Admin อัปโหลด CSV ที่ export จาก Internet Banking ของบัญชีรับเงิน -> อ่านเฉพาะรายการเงินเข้า
+// -> หารายการฝาก pending ที่ยอดตรง บัญชีเดียวกัน และแจ้งฝากในช่วงเวลาใกล้กัน
+// แน่ใจ (มีคำขอ QR ที่ยอดตรงสตางค์ หรือมีรายการฝากที่เข้าเงื่อนไขรายการเดียวและไม่ถูกรายการเงินเข้าอื่นในไฟล์แย่ง) = อนุมัติเลย
+// ที่เหลือเข้าคิว review / unmatched ให้ Admin จับคู่เองหรือกดข้าม
+
+const (
+	maxStatementBytes    = 5 << 20
+	statementMatchBefore = time.Hour     // แจ้งฝากก่อนโอนได้ไม่เกินนี้ (เช่น สร้างรายการก่อนแล้วค่อยโอน)
+	statementMatchAfter  = 6 * time.Hour // แจ้งฝากหลังโอนได้ไม่เกินนี้
+	statementReviewRange = 24 * time.Hour
+)
+
+var (
+	ErrStatementBank      = errors.New("unknown statement format")
+	ErrStatementEmpty     = errors.New("no statement rows could be read")
+	ErrStatementTooLarge  = errors.New("statement file too large")
+	ErrStatementLineState = errors.New("statement line is already resolved")
+	ErrStatementMatch     = errors.New("transaction cannot be matched to this statement line")
+)
+
+// defaultStatementMappings: หัวคอลัมน์ของไฟล์ CSV จาก Internet Banking แต่ละธนาคาร (แก้ได้ที่ /admin/statement-mappings/:bank)
+var defaultStatementMappings = map[string]models.BankStatementMapping{
+	"KBANK": {Name: "กสิกรไทย", DateColumn: "วันที่", TimeColumn: "เวลา", DateLayout: "02/01/2006 15:04",
+		CreditColumn: "ฝากเงิน", DescriptionColumn: "รายละเอียด", ReferenceColumn: "ช่องทาง"},
+	"SCB": {Name: "ไทยพาณิชย์", DateColumn: "Date", TimeColumn: "Time", DateLayout: "02/01/2006 15:04",
+		CreditColumn: "Deposit", DescriptionColumn: "Description", ReferenceColumn: "Channel"},
+	"BBL": {Name: "กรุงเทพ", DateColumn: "Date", DateLayout: "02/01/2006 15:04",
+		CreditColumn: "Deposit", DescriptionColumn: "Description", ReferenceColumn: "Channel"},
+	"KTB": {Name: "กรุงไทย", DateColumn: "วันที่ทำรายการ", DateLayout: "02/01/2006 15:04:05",
+		CreditColumn: "จำนวนเงินฝาก", DescriptionColumn: "รายละเอียด", ReferenceColumn: "เลขที่อ้างอิง"},
+	"BAY": {Name: "กรุงศรีอยุธยา", DateColumn: "Date", TimeColumn: "Time", DateLayout: "02/01/2006 15:04:05",
+		CreditColumn: "Credit", DescriptionColumn: "Description", ReferenceColumn: "Reference No."},
+	"TTB": {Name: "ทีทีบี", DateColumn: "Transaction Date", DateLayout: "02/01/2006 15:04",
+		CreditColumn: "Credit Amount", DescriptionColumn: "Description", ReferenceColumn: "Reference"},
+}
+
+// StatementMappingFor: รูปแบบไฟล์ของธนาคาร (ตั้งเองใน DB ก่อน ไม่มีใช้ค่าเริ่มต้น)
+func StatementMappingFor(db *gorm.DB, bank string) (models.BankStatementMapping, error) {
+	bank = strings.ToUpper(strings.TrimSpace(bank))
+	var m models.BankStatementMapping
+	if err := db.Where("bank = ?", bank).Limit(1).Find(&m).Error; err != nil {
+		return m, err
+	}
+	if m.Bank != "" {
+		return m, nil
+	}
+	def, ok := defaultStatementMappings[bank]
+	if !ok {
+		return m, fmt.Errorf("%w: %s", ErrStatementBank, bank)
+	}
+	def.Bank = bank
+	return def, nil
+}
+
+// StatementMappings: รูปแบบไฟล์ของทุกธนาคาร (ค่าเริ่มต้น + ที่ Admin เพิ่มเอง)
+func StatementMappings(db *gorm.DB) ([]models.BankStatementMapping, error) {
+	var saved []models.BankStatementMapping
+	if err := db.Find(&saved).Error; err != nil {
+		return nil, err
+	}
+	byBank := make(map[string]models.BankStatementMapping, len(defaultStatementMappings)+len(saved))
+	for bank, m := range defaultStatementMappings {
+		m.Bank = bank
+		byBank[bank] = m
+	}
+	for _, m := range saved {
+		byBank[m.Bank] = m
+	}
+	out := make([]models.BankStatementMapping, 0, len(byBank))
+	for _, m := range byBank {
+		out = append(out, m)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Bank < out[j].Bank })
+	return out, nil
+}
+
+// StatementCredit: รายการเงินเข้า 1 บรรทัดที่อ่านได้จากไฟล์
+type StatementCredit struct {
+	TxnAt       time.Time
+	Amount      models.Money
+	Description string
+	Reference   string
+}
+
+// ParseStatementCSV: อ่านไฟล์ CSV ตาม mapping คืนรายการเงินเข้าและจำนวนแถวข้อมูลที่อ่านได้
+// ข้ามบรรทัดหัวไฟล์/สรุปท้ายไฟล์ที่อ่านวันที่ไม่ได้ รองรับ UTF-8 (มี BOM ได้) และ TIS-620/Windows-874
+func ParseStatementCSV(data []byte, m models.BankStatementMapping) ([]StatementCredit, int, error) {
+	data = bytes.TrimPrefix(data, []byte("\xEF\xBB\xBF"))
+	if !utf8.Valid(data) {
+		data = decodeTIS620(data)
+	}
+
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	if firstLine, _, _ := bytes.Cut(data, []byte("\n")); bytes.Count(firstLine, []byte("\t")) > bytes.Count(firstLine, []byte(",")) {
+		r.Comma = '\t'
+	}
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	cols := statementColumns{}
+	if !strings.HasPrefix(m.DateColumn, "#") {
+		// ใช้ชื่อหัวคอลัมน์: หาบรรทัดหัวตารางก่อน (บรรทัดบนๆ มักเป็นชื่อบัญชี/ช่วงวันที่)
+		for i, rec := range records {
+			if c, ok := headerColumns(rec, m); ok {
+				cols, records = c, records[i+1:]
+				break
+			}
+			if i == len(records)-1 {
+				return nil, 0, fmt.Errorf("%w: header %q not found", ErrStatementEmpty, m.DateColumn)
+			}
+		}
+	} else {
+		cols = statementColumns{
+			date: columnIndex(m.DateColumn), time: columnIndex(m.TimeColumn), credit: columnIndex(m.CreditColumn),
+			desc: columnIndex(m.DescriptionColumn), ref: columnIndex(m.ReferenceColumn),
+		}
+	}
+
+	var credits []StatementCredit
+	rows := 0
+	for _, rec := range records {
+		at, ok := parseStatementTime(field(rec, cols.date), field(rec, cols.time), m.DateLayout)
+		if !ok {
+			continue
+		}
+		rows++
+		amount, ok := parseStatementAmount(field(rec, cols.credit))
+		if !ok || amount <= 0 {
+			continue
+		}
+		credits = append(credits, StatementCredit{
+			TxnAt:       at,
+			Amount:      amount,
+			Description: field(rec, cols.desc),
+			Reference:   field(rec, cols.ref),
+		})
+	}
+	if rows == 0 {
+		return nil, 0, ErrStatementEmpty
+	}
+	return credits, rows, nil
+}
+
+// statementColumns: ตำแหน่งคอลัมน์ (เริ่มที่ 0, -1 = ไม่มี)
+type statementColumns struct {
+	date, time, credit, desc, ref int
+}
+
+func headerColumns(rec []string, m models.BankStatementMapping) (statementColumns, bool) {
+	find := func(name string) int {
+		if name == "" {
+			return -1
+		}
+		for i, h := range rec {
+			if strings.EqualFold(strings.TrimSpace(h), strings.TrimSpace(name)) {
+				return i
+			}
+		}
+		return -1
+	}
+	cols := statementColumns{
+		date: find(m.DateColumn), time: find(m.TimeColumn), credit: find(m.CreditColumn),
+		desc: find(m.DescriptionColumn), ref: find(m.ReferenceColumn),
+	}
+	return cols, cols.date >= 0 && cols.credit >= 0
+}
+
+// columnIndex: "#3" -> 2
+func columnIndex(spec string) int {
+	n, err := strconv.Atoi(strings.TrimPrefix(strings.TrimSpace(spec), "#"))
+	if err != nil || n < 1 {
+		return -1
+	}
+	return n - 1
+}
+
+func field(rec []string, i int) string {
+	if i < 0 || i >= len(rec) {
+		return ""
+	}
+	return strings.TrimSpace(rec[i])
+}
+
+// buddhistYearPattern: ปี พ.ศ. 4 หลัก (2400-2699)
+var buddhistYearPattern = regexp.MustCompile(`\b2[4-6]\d\d\b`)
+
+// parseStatementTime: วันที่ (+ เวลาจากอีกคอลัมน์ถ้ามี) เป็นเวลาไทย ปี พ.ศ. แปลงเป็น ค.ศ.
+func parseStatementTime(date, clock, layout string) (time.Time, bool) {
+	if date == "" {
+		return time.Time{}, false
+	}
+	value := date
+	if clock != "" {
+		value = date + " " + clock
+	}
+	// แปลงปี พ.ศ. ก่อนอ่าน (29/02 ของปีอธิกสุรทินอ่านด้วยปี พ.ศ. ตรงๆ ไม่ได้)
+	value = buddhistYearPattern.ReplaceAllStringFunc(value, func(y string) string {
+		n, _ := strconv.Atoi(y)
+		return strconv.Itoa(n - 543)
+	})
+	layouts := []string{layout}
+	// เวลามีหรือไม่มีวินาทีก็อ่านได้
+	if strings.Contains(layout, "15:04:05") {
+		layouts = append(layouts, strings.Replace(layout, "15:04:05", "15:04", 1))
+	} else if strings.Contains(layout, "15:04") {
+		layouts = append(layouts, strings.Replace(layout, "15:04", "15:04:05", 1))
+	}
+	for _, l := range layouts {
+		t, err := time.ParseInLocation(l, value, bangkokTZ)
+		if err != nil {
+			continue
+		}
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+// parseStatementAmount: "1,500.00" / "+1500" / "฿1,500" -> Money (ว่างหรือ "-" = ไม่มีรายการ)
+func parseStatementAmount(s string) (models.Money, bool) {
+	s = strings.NewReplacer(",", "", " ", "", "฿", "", "THB", "", "+", "").Replace(s)
+	if s == "" || s == "-" {
+		return 0, false
+	}
+	m, err := models.ParseMoney(s)
+	return m, err == nil
+}
+
+// decodeTIS620: ไฟล์จากโปรแกรมธนาคารรุ่นเก่า (TIS-620/Windows-874) -> UTF-8
+// ตัวอักษรไทย 0xA1-0xFB ตรงกับ U+0E01-U+0E5B
+func decodeTIS620(data []byte) []byte {
+	var buf bytes.Buffer
+	buf.Grow(len(data) * 2)
+	for _, b := range data {
+		if b >= 0xA1 && b <= 0xFB {
+			buf.WriteRune(rune(b) + 0x0E01 - 0xA1)
+		} else {
+			buf.WriteByte(b)
+		}
+	}
+	return buf.Bytes()
+}
+
+// ImportStatement: บันทึกรายการเงินเข้าจากไฟล์ (ข้ามรายการที่เคยนำเข้าแล้ว) แล้วจับคู่กับรายการฝาก
+func ImportStatement(db *gorm.DB, bankAccountID uint, bank, fileName string, data []byte, adminID uint) (*models.BankStatementImport, error) {
+	if len(data) > maxStatementBytes {
+		return nil, ErrStatementTooLarge
+	}
+	mapping, err := StatementMappingFor(db, bank)
+	if err != nil {
+		return nil, err
+	}
+	var account models.BankAccount
+	if err := db.First(&account, bankAccountID).Error; err != nil {
+		return nil, err
+	}
+	credits, rows, err := ParseStatementCSV(data, mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	imp := models.BankStatementImport{
+		BankAccountID: account.ID,
+		Bank:          mapping.Bank,
+		FileName:      fileName,
+		Rows:          rows,
+		CreatedBy:     adminID,
+	}
+	var lines []models.BankStatementLine
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&imp).Error; err != nil {
+			return err
+		}
+		seen := make(map[string]int)
+		for _, cr := range credits {
+			// รายการที่เหมือนกันทุกช่องในไฟล์เดียว (โอนยอดเท่ากันนาทีเดียวกัน) นับลำดับต่อท้ายไว้ จะได้ไม่ถูกมองว่าซ้ำ
+			key := fmt.Sprintf("%d|%d|%s|%s|%s", account.ID, cr.TxnAt.Unix(), cr.Amount, cr.Description, cr.Reference)
+			seen[key]++
+			sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%d", key, seen[key])))
+
+			line := models.BankStatementLine{
+				ImportID:      imp.ID,
+				BankAccountID: account.ID,
+				TxnAt:         cr.TxnAt,
+				Amount:        cr.Amount,
+				Description:   cr.Description,
+				Reference:     cr.Reference,
+				Hash:          hex.EncodeToString(sum[:]),
+				Status:        models.StatementLineUnmatched,
+			}
+			res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&line)
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				imp.Duplicates++
+				continue
+			}
+			lines = append(lines, line)
+		}
+		imp.Credits = len(lines)
+		return tx.Model(&imp).Updates(map[string]interface{}{"credits": imp.Credits, "duplicates": imp.Duplicates}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	approved, queued := matchStatementLines(db, lines, adminID)
+	imp.AutoApproved, imp.Queued = approved, queued
+	if err := db.Model(&imp).Updates(map[string]interface{}{"auto_approved": approved, "queued": queued}).Error; err != nil {
+		return nil, err
+	}
+	log.Printf("✅ [Statement] import #%d %s: %d credits, %d auto-approved, %d queued, %d duplicates",
+		imp.ID, imp.Bank, imp.Credits, approved, queued, imp.Duplicates)
+	return &imp, nil
+}
+
+// statementCandidate: รายการฝากที่อาจตรงกับรายการเงินเข้า
+type statementCandidate struct {
+	TransactionID uint
+	Confident     bool // มาจากคำขอ QR (ยอดตรงสตางค์) หรือผูกกับบัญชีรับเงินนี้ตรงๆ
+}
+
+// lineCandidates: รายการฝาก pending ที่ยอดตรง บัญชีเดียวกัน (หรือยังไม่ผูกบัญชี) และแจ้งฝากในช่วงเวลาที่รับได้
+func lineCandidates(db *gorm.DB, line *models.BankStatementLine) ([]statementCandidate, error) {
+	if intent, err := MatchDepositIntent(db, line.BankAccountID, line.Amount, line.TxnAt); err == nil {
+		return []statementCandidate{{TransactionID: intent.TransactionID, Confident: true}}, nil
+	} else if !errors.Is(err, ErrIntentNotFound) {
+		return nil, err
+	}
+
+	var txs []models.Transaction
+	err := db.Select("id", "bank_account_id").
+		Where("type = ? AND status = ? AND amount = ?", "deposit", "pending", line.Amount).
+		Where("bank_account_id = ? OR bank_account_id IS NULL", line.BankAccountID).
+		Where("created_at >= ? AND created_at <= ?", line.TxnAt.Add(-statementMatchBefore), line.TxnAt.Add(statementMatchAfter)).
+		Where("id NOT IN (SELECT transaction_id FROM bank_statement_lines WHERE transaction_id IS NOT NULL)").
+		Order("created_at").Limit(10).Find(&txs).Error
+	if err != nil {
+		return nil, err
+	}
+	out := make([]statementCandidate, 0, len(txs))
+	for _, t := range txs {
+		out = append(out, statementCandidate{
+			TransactionID: t.ID,
+			Confident:     t.BankAccountID != nil && *t.BankAccountID == line.BankAccountID,
+		})
+	}
+	return out, nil
+}
+
+// matchStatementLines: จับคู่รายการเงินเข้าที่เพิ่งนำเข้า คืนจำนวนที่อนุมัติอัตโนมัติ / ส่งเข้าคิว
+func matchStatementLines(db *gorm.DB, lines []models.BankStatementLine, adminID uint) (int, int) {
+	candidates := make([][]statementCandidate, len(lines))
+	claims := make(map[uint]int) // รายการฝาก -> จำนวนรายการเงินเข้าในไฟล์ที่อาจเป็นของมัน
+	for i := range lines {
+		c, err := lineCandidates(db, &lines[i])
+		if err != nil {
+			log.Printf("❌ [Statement] candidates for line #%d: %v", lines[i].ID, err)
+		}
+		candidates[i] = c
+		for _, cand := range c {
+			claims[cand.TransactionID]++
+		}
+	}
+
+	approved, queued := 0, 0
+	for i := range lines {
+		line := &lines[i]
+		c := candidates[i]
+		status, note := models.StatementLineUnmatched, ""
+		if len(c) > 0 {
+			status = models.StatementLineReview
+		}
+
+		if len(c) == 1 && c[0].Confident && claims[c[0].TransactionID] == 1 {
+			err := autoApproveLine(db, line, c[0].TransactionID, adminID)
+			if err == nil {
+				approved++
+				continue
+			}
+			note = "อนุมัติอัตโนมัติไม่ได้: " + err.Error()
+			log.Printf("⚠️ [Statement] line #%d -> deposit #%d: %v", line.ID, c[0].TransactionID, err)
+		} else if len(c) > 1 || (len(c) == 1 && claims[c[0].TransactionID] > 1) {
+			note = "พบรายการฝากที่อาจตรงกันมากกว่า 1 รายการ"
+		} else if len(c) == 1 {
+			note = "รายการฝากไม่ได้ระบุบัญชีรับเงิน"
+		}
+
+		queued++
+		if err := db.Model(line).Updates(map[string]interface{}{"status": status, "candidates": len(c), "note": note}).Error; err != nil {
+			log.Printf("❌ [Statement] update line #%d: %v", line.ID, err)
+		}
+	}
+	return approved, queued
+}
+
+// autoApproveLine: อนุมัติรายการฝากจากรายการเงินเข้า (สลิปซ้ำ = ไม่อนุมัติ ให้ Admin ดูเอง)
+func autoApproveLine(db *gorm.DB, line *models.BankStatementLine, transactionID, adminID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var t models.Transaction
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&t, transactionID).Error; err != nil {
+			return err
+		}
+		if t.Type != "deposit" || t.Status != "pending" || t.Amount != line.Amount {
+			return ErrStatementMatch
+		}
+		if err := CheckSlipApproval(tx, t.ID, "", adminID); err != nil {
+			return err
+		}
+		if _, err := ApproveDeposit(tx, &t, &adminID); err != nil {
+			return err
+		}
+		return tx.Model(line).Updates(map[string]interface{}{
+			"status":         models.StatementLineApproved,
+			"transaction_id": t.ID,
+			"candidates":     1,
+			"note":           "อนุมัติอัตโนมัติจาก Statement",
+		}).Error
+	})
+}
+
+// StatementCandidates: รายการฝากที่ Admin เลือกจับคู่กับรายการเงินเข้าได้ (ยอดตรง ช่วง ±24 ชม. ทุกบัญชี)
+// รวมรายการที่อนุมัติไปแล้วแต่ยังไม่ผูกกับ Statement (Admin อนุมัติเองจากสลิปก่อนนำเข้า)
+func StatementCandidates(db *gorm.DB, line *models.BankStatementLine) ([]models.Transaction, error) {
+	var txs []models.Transaction
+	err := db.Preload("User").
+		Where("type = ? AND status IN ? AND amount = ?", "deposit", []string{"pending", "approved"}, line.Amount).
+		Where("created_at >= ? AND created_at <= ?", line.TxnAt.Add(-statementReviewRange), line.TxnAt.Add(statementReviewRange)).
+		Where("id NOT IN (SELECT transaction_id FROM bank_statement_lines WHERE transaction_id IS NOT NULL)").
+		Order("status desc, created_at").Limit(50).Find(&txs).Error
+	return txs, err
+}
+
+// ResolveStatementLine: Admin จับคู่รายการเงินเข้ากับรายการฝากเอง (pending = อนุมัติให้ด้วย, approved = ผูกอย่างเดียว)
+func ResolveStatementLine(db *gorm.DB, lineID, transactionID, adminID uint, overrideReason string) (*models.BonusGrant, error) {
+	var grant *models.BonusGrant
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var line models.BankStatementLine
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&line, lineID).Error; err != nil {
+			return err
+		}
+		if line.Status != models.StatementLineReview && line.Status != models.StatementLineUnmatched {
+			return ErrStatementLineState
+		}
+
+		var t models.Transaction
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&t, transactionID).Error; err != nil {
+			return err
+		}
+		var linked int64
+		if err := tx.Model(&models.BankStatementLine{}).Where("transaction_id = ?", t.ID).Count(&linked).Error; err != nil {
+			return err
+		}
+		if t.Type != "deposit" || t.Amount != line.Amount || linked > 0 || (t.Status != "pending" && t.Status != "approved") {
+			return ErrStatementMatch
+		}
+
+		if t.Status == "pending" {
+			if err := CheckSlipApproval(tx, t.ID, strings.TrimSpace(overrideReason), adminID); err != nil {
+				return err
+			}
+			g, err := ApproveDeposit(tx, &t, &adminID)
+			if err != nil {
+				return err
+			}
+			grant = g
+		}
+
+		now := time.Now()
+		return tx.Model(&line).Updates(map[string]interface{}{
+			"status":         models.StatementLineResolved,
+			"transaction_id": t.ID,
+			"resolved_by":    adminID,
+			"resolved_at":    now,
+		}).Error
+	})
+	return grant, err
+}
+
+// IgnoreStatementLine: รายการเงินเข้าที่ไม่ใช่ยอดฝากของสมาชิก (เช่น เงินโอนระหว่างบัญชีของเว็บ)
+func IgnoreStatementLine(db *gorm.DB, lineID, adminID uint, note string) error {
+	now := time.Now()
+	res := db.Model(&models.BankStatementLine{}).
+		Where("id = ? AND status IN ?", lineID, []string{models.StatementLineReview, models.StatementLineUnmatched}).
+		Updates(map[string]interface{}{
+			"status":      models.StatementLineIgnored,
+			"note":        strings.TrimSpace(note),
+			"resolved_by": adminID,
+			"resolved_at": now,
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrStatementLineState
+	}
+	return nil
+}
+
+// SaveStatementMapping: บันทึกรูปแบบไฟล์ของธนาคาร (เพิ่มธนาคารใหม่ได้)
+func SaveStatementMapping(db *gorm.DB, m models.BankStatementMapping) (*models.BankStatementMapping, error) {
+	m.Bank = strings.ToUpper(strings.TrimSpace(m.Bank))
+	if m.Bank == "" || m.DateColumn == "" || m.CreditColumn == "" || m.DateLayout == "" {
+		return nil, fmt.Errorf("%w: bank, date_column, credit_column and date_layout are required", ErrStatementBank)
+	}
+	m.UpdatedAt = time.Now()
+	if err := db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&m).Error; err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
@@ -0,0 +1,323 @@
+package services
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PawornpratKongdaeng/soccer/models"
+	"gorm.io/gorm"
+)
+
+func TestColumnIndex(t *testing.T) {
+	tests := []struct {
+		spec string
+		want int
+	}{
+		{"#1", 0},
+		{"#3", 2},
+		{" #12 ", 11},
+		{"4", 3},
+		{"#0", -1},
+		{"#-2", -1},
+		{"", -1},
+		{"วันที่", -1},
+	}
+
+	for _, tt := range tests {
+		if got := columnIndex(tt.spec); got != tt.want {
+			t.Errorf("columnIndex(%q) = %d, want %d", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestParseStatementTime(t *testing.T) {
+	tests := []struct {
+		name        string
+		date, clock string
+		layout      string
+		want        time.Time
+		ok          bool
+	}{
+		{"buddhist year", "15/03/2567 09:30", "", "02/01/2006 15:04", time.Date(2024, 3, 15, 9, 30, 0, 0, bangkokTZ), true},
+		{"christian year", "15/03/2024 09:30", "", "02/01/2006 15:04", time.Date(2024, 3, 15, 9, 30, 0, 0, bangkokTZ), true},
+		{"separate time column", "01/12/2566", "23:05", "02/01/2006 15:04", time.Date(2023, 12, 1, 23, 5, 0, 0, bangkokTZ), true},
+		{"seconds when layout has none", "01/12/2566", "23:05:09", "02/01/2006 15:04", time.Date(2023, 12, 1, 23, 5, 9, 0, bangkokTZ), true},
+		{"no seconds when layout has them", "01/12/2566 23:05", "", "02/01/2006 15:04:05", time.Date(2023, 12, 1, 23, 5, 0, 0, bangkokTZ), true},
+		{"buddhist leap day", "29/02/2567 00:00", "", "02/01/2006 15:04", time.Date(2024, 2, 29, 0, 0, 0, 0, bangkokTZ), true},
+		{"empty date", "", "10:00", "02/01/2006 15:04", time.Time{}, false},
+		{"summary row", "รวม", "", "02/01/2006 15:04", time.Time{}, false},
+		{"wrong order", "2024-03-15 09:30", "", "02/01/2006 15:04", time.Time{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseStatementTime(tt.date, tt.clock, tt.layout)
+			if ok != tt.ok || !got.Equal(tt.want) {
+				t.Errorf("parseStatementTime(%q, %q) = %v, %v, want %v, %v", tt.date, tt.clock, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestParseStatementAmount(t *testing.T) {
+	tests := []struct {
+		in   string
+		want models.Money
+		ok   bool
+	}{
+		{"1,500.00", models.NewMoney(1500), true},
+		{"1500", models.NewMoney(1500), true},
+		{"+300.50", models.NewMoney(300.50), true},
+		{"฿2,000", models.NewMoney(2000), true},
+		{"THB 1,000.25", models.NewMoney(1000.25), true},
+		{" 12,345,678.90 ", models.NewMoney(12345678.90), true},
+		{"-250.00", models.NewMoney(-250), true},
+		{"-", 0, false},
+		{"", 0, false},
+		{"abc", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseStatementAmount(tt.in)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("parseStatementAmount(%q) = %s, %v, want %s, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// encodeTIS620: UTF-8 -> TIS-620 (ตัวอักษรไทย U+0E01-U+0E5B = 0xA1-0xFB)
+func encodeTIS620(s string) []byte {
+	var out []byte
+	for _, r := range s {
+		if r >= 0x0E01 && r <= 0x0E5B {
+			out = append(out, byte(r-0x0E01+0xA1))
+		} else {
+			out = append(out, string(r)...)
+		}
+	}
+	return out
+}
+
+func TestParseStatementCSV(t *testing.T) {
+	kbank := defaultStatementMappings["KBANK"]
+	ktb := defaultStatementMappings["KTB"]
+	byIndex := models.BankStatementMapping{DateColumn: "#1", TimeColumn: "#2", DateLayout: "02/01/2006 15:04",
+		CreditColumn: "#4", DescriptionColumn: "#5"}
+
+	kbankFile := "บัญชี: 123-4-56789-0\n" +
+		"วันที่,เวลา,รายละเอียด,ถอนเงิน,ฝากเงิน,ยอดคงเหลือ,ช่องทาง\n" +
+		"15/03/2567,09:30,รับโอนเงิน,,\"1,500.00\",\"11,500.00\",K PLUS\n" +
+		"15/03/2567,10:00,โอนเงิน,200.00,,\"11,300.00\",K PLUS\n" +
+		"15/03/2567,10:15,รับโอนเงิน,,\"+300.50\",\"11,600.50\",PromptPay\n" +
+		"รวม,,,200.00,\"1,800.50\",,\n"
+
+	tests := []struct {
+		name     string
+		data     []byte
+		mapping  models.BankStatementMapping
+		wantRows int
+		want     []StatementCredit
+		wantErr  error
+	}{
+		{
+			name: "header after preamble skips debits and totals", data: []byte(kbankFile), mapping: kbank, wantRows: 3,
+			want: []StatementCredit{
+				{TxnAt: time.Date(2024, 3, 15, 9, 30, 0, 0, bangkokTZ), Amount: models.NewMoney(1500), Description: "รับโอนเงิน", Reference: "K PLUS"},
+				{TxnAt: time.Date(2024, 3, 15, 10, 15, 0, 0, bangkokTZ), Amount: models.NewMoney(300.50), Description: "รับโอนเงิน", Reference: "PromptPay"},
+			},
+		},
+		{
+			name: "utf-8 bom", data: append([]byte("\xEF\xBB\xBF"), kbankFile...), mapping: kbank, wantRows: 3,
+			want: []StatementCredit{
+				{TxnAt: time.Date(2024, 3, 15, 9, 30, 0, 0, bangkokTZ), Amount: models.NewMoney(1500), Description: "รับโอนเงิน", Reference: "K PLUS"},
+				{TxnAt: time.Date(2024, 3, 15, 10, 15, 0, 0, bangkokTZ), Amount: models.NewMoney(300.50), Description: "รับโอนเงิน", Reference: "PromptPay"},
+			},
+		},
+		{
+			name: "tis-620 tab separated with date and time in one column", mapping: ktb, wantRows: 2,
+			data: encodeTIS620("วันที่ทำรายการ\tรายละเอียด\tจำนวนเงินฝาก\tเลขที่อ้างอิง\n" +
+				"01/12/2566 23:05:09\tรับโอน\t2,000.00\tREF1\n" +
+				"02/12/2566 08:00\tค่าธรรมเนียม\t-\tREF2\n"),
+			want: []StatementCredit{
+				{TxnAt: time.Date(2023, 12, 1, 23, 5, 9, 0, bangkokTZ), Amount: models.NewMoney(2000), Description: "รับโอน", Reference: "REF1"},
+			},
+		},
+		{
+			name: "columns by position", mapping: byIndex, wantRows: 2,
+			data: []byte("05/01/2024,07:45,x,\"1,000\",ATM\n06/01/2024,08:00,x,0,ATM\n"),
+			want: []StatementCredit{
+				{TxnAt: time.Date(2024, 1, 5, 7, 45, 0, 0, bangkokTZ), Amount: models.NewMoney(1000), Description: "ATM"},
+			},
+		},
+		{
+			name: "header not found", mapping: kbank, wantErr: ErrStatementEmpty,
+			data: []byte("Date,Time,Deposit\n15/03/2024,09:30,100\n"),
+		},
+		{
+			name: "no readable rows", mapping: byIndex, wantErr: ErrStatementEmpty,
+			data: []byte("foo,bar\nbaz,qux\n"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, rows, err := ParseStatementCSV(tt.data, tt.mapping)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ParseStatementCSV() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseStatementCSV() error = %v", err)
+			}
+			if rows != tt.wantRows {
+				t.Errorf("rows = %d, want %d", rows, tt.wantRows)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("credits = %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				if !got[i].TxnAt.Equal(tt.want[i].TxnAt) || got[i].Amount != tt.want[i].Amount ||
+					got[i].Description != tt.want[i].Description || got[i].Reference != tt.want[i].Reference {
+					t.Errorf("credit[%d] = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// statementDeposit: รายการฝาก pending ที่แจ้งไว้ ณ เวลา at (account nil = ไม่ได้ระบุบัญชีรับเงิน)
+func statementDeposit(t *testing.T, db *gorm.DB, user models.User, amount models.Money, account *uint, at time.Time) models.Transaction {
+	t.Helper()
+	d := models.Transaction{UserID: user.ID, Amount: amount, Type: "deposit", Status: "pending", BankAccountID: account, CreatedAt: at}
+	if err := db.Create(&d).Error; err != nil {
+		t.Fatalf("create deposit: %v", err)
+	}
+	return d
+}
+
+func TestImportStatementMatching(t *testing.T) {
+	db := openTestDB(t)
+	paidAt := time.Now().In(bangkokTZ).Truncate(time.Minute).Add(-time.Hour)
+	user := createTestUser(t, db, "stmt_member", 0)
+
+	tests := []struct {
+		name string
+		// setup: รายการฝากที่มีอยู่ก่อนนำเข้า คืนรายการที่ควรถูกอนุมัติ (0 = ไม่มี)
+		setup      func(account uint) uint
+		credits    []models.Money
+		wantStatus []string
+		approved   int
+	}{
+		{
+			name: "unique deposit on this account is approved",
+			setup: func(account uint) uint {
+				return statementDeposit(t, db, user, models.NewMoney(1500), &account, paidAt.Add(5*time.Minute)).ID
+			},
+			credits:    []models.Money{models.NewMoney(1500)},
+			wantStatus: []string{models.StatementLineApproved},
+			approved:   1,
+		},
+		{
+			name: "two deposits with the same amount go to review",
+			setup: func(account uint) uint {
+				statementDeposit(t, db, user, models.NewMoney(2000), &account, paidAt.Add(time.Minute))
+				statementDeposit(t, db, user, models.NewMoney(2000), &account, paidAt.Add(2*time.Minute))
+				return 0
+			},
+			credits:    []models.Money{models.NewMoney(2000)},
+			wantStatus: []string{models.StatementLineReview},
+		},
+		{
+			name: "two credits claiming one deposit go to review",
+			setup: func(account uint) uint {
+				statementDeposit(t, db, user, models.NewMoney(2500), &account, paidAt.Add(time.Minute))
+				return 0
+			},
+			credits:    []models.Money{models.NewMoney(2500), models.NewMoney(2500)},
+			wantStatus: []string{models.StatementLineReview, models.StatementLineReview},
+		},
+		{
+			name: "deposit without receiving account goes to review",
+			setup: func(account uint) uint {
+				statementDeposit(t, db, user, models.NewMoney(3000), nil, paidAt.Add(time.Minute))
+				return 0
+			},
+			credits:    []models.Money{models.NewMoney(3000)},
+			wantStatus: []string{models.StatementLineReview},
+		},
+		{
+			name: "deposit outside the time window is not a candidate",
+			setup: func(account uint) uint {
+				statementDeposit(t, db, user, models.NewMoney(3500), &account, paidAt.Add(-statementMatchBefore-time.Minute))
+				return 0
+			},
+			credits:    []models.Money{models.NewMoney(3500)},
+			wantStatus: []string{models.StatementLineUnmatched},
+		},
+		{
+			name:       "no deposit at all",
+			setup:      func(account uint) uint { return 0 },
+			credits:    []models.Money{models.NewMoney(777.77)},
+			wantStatus: []string{models.StatementLineUnmatched},
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			account := models.BankAccount{BankName: "KBANK", AccountName: "test", AccountNumber: "000" + strconv.Itoa(i), IsActive: true}
+			if err := db.Create(&account).Error; err != nil {
+				t.Fatalf("create bank account: %v", err)
+			}
+			wantApproved := tt.setup(account.ID)
+
+			var csv strings.Builder
+			csv.WriteString("วันที่,เวลา,รายละเอียด,ถอนเงิน,ฝากเงิน,ยอดคงเหลือ,ช่องทาง\n")
+			for j, amount := range tt.credits {
+				at := paidAt.Add(time.Duration(j) * time.Minute)
+				csv.WriteString(at.Format("02/01/") + strconv.Itoa(at.Year()+543) + "," + at.Format("15:04") +
+					",รับโอนเงิน,,\"" + amount.String() + "\",,K PLUS\n")
+			}
+
+			imp, err := ImportStatement(db, account.ID, "kbank", "statement.csv", []byte(csv.String()), 1)
+			if err != nil {
+				t.Fatalf("ImportStatement() error = %v", err)
+			}
+			if imp.Credits != len(tt.credits) || imp.AutoApproved != tt.approved || imp.Queued != len(tt.credits)-tt.approved {
+				t.Errorf("import = credits %d approved %d queued %d", imp.Credits, imp.AutoApproved, imp.Queued)
+			}
+
+			var lines []models.BankStatementLine
+			db.Where("import_id = ?", imp.ID).Order("txn_at, id").Find(&lines)
+			if len(lines) != len(tt.wantStatus) {
+				t.Fatalf("lines = %d, want %d", len(lines), len(tt.wantStatus))
+			}
+			for j, line := range lines {
+				if line.Status != tt.wantStatus[j] {
+					t.Errorf("line[%d] status = %q (%s), want %q", j, line.Status, line.Note, tt.wantStatus[j])
+				}
+			}
+			if wantApproved != 0 {
+				if lines[0].TransactionID == nil || *lines[0].TransactionID != wantApproved {
+					t.Errorf("line matched to %v, want deposit #%d", lines[0].TransactionID, wantApproved)
+				}
+				if d := loadTransaction(t, db, wantApproved); d.Status != "approved" {
+					t.Errorf("deposit status = %q, want approved", d.Status)
+				}
+			}
+
+			// นำเข้าไฟล์เดิมซ้ำ = ข้ามทุกรายการ ไม่จับคู่ซ้ำ
+			again, err := ImportStatement(db, account.ID, "kbank", "statement.csv", []byte(csv.String()), 1)
+			if err != nil {
+				t.Fatalf("re-import error = %v", err)
+			}
+			if again.Credits != 0 || again.Duplicates != len(tt.credits) {
+				t.Errorf("re-import = credits %d duplicates %d, want 0 / %d", again.Credits, again.Duplicates, len(tt.credits))
+			}
+		})
+	}
+}
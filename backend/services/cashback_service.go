@@ -0,0 +1,553 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/PawornpratKongdaeng/soccer/database"
+	"github.com/PawornpratKongdaeng/soccer/models"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ==========================================
+// คืนยอดเสีย (Cashback)
+// ==========================================
+// ยอดเสียสุทธิ = ยอดแทง - ยอดจ่ายคืน ของบิลเต็ง/สเต็ปที่เคลียร์ในรอบ (settled_at อยู่ใน [start, end))
+// ขั้นที่ได้ = ขั้นที่ MinLoss สูงสุดที่ไม่เกินยอดเสีย (ตามสกุลกระเป๋า) แล้วตัดที่ MaxAmount ของขั้น
+// Cron สร้างรอบเป็น draft แยกตามสกุล Admin ตรวจรายชื่อแล้วอนุมัติ = จ่ายเข้ากระเป๋าทั้งรอบใน DB Transaction เดียว
+
+var (
+	ErrCashbackBatchExists = errors.New("cashback batch already exists for this period")
+	ErrCashbackNotDraft    = errors.New("cashback batch is not a draft")
+	ErrInvalidCashbackTier = errors.New("invalid cashback tier")
+)
+
+// CashbackTiers: ขั้นคืนยอดเสียของสกุล เรียงจาก MinLoss น้อยไปมาก
+func CashbackTiers(db *gorm.DB, currency string) ([]models.CashbackTier, error) {
+	var tiers []models.CashbackTier
+	err := db.Where("currency = ?", currency).Order("min_loss").Find(&tiers).Error
+	return tiers, err
+}
+
+// SaveCashbackTiers: แทนที่ขั้นทั้งหมดของสกุล (ส่งรายการว่าง = ปิดคืนยอดเสียของสกุลนั้น)
+func SaveCashbackTiers(db *gorm.DB, currency string, tiers []models.CashbackTier) ([]models.CashbackTier, error) {
+	if !models.IsSupportedCurrency(currency) {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedCurrency, currency)
+	}
+	seen := make(map[models.Money]bool, len(tiers))
+	for i := range tiers {
+		t := &tiers[i]
+		if t.MinLoss <= 0 || t.Percent <= 0 || t.Percent > 100 || t.MaxAmount < 0 {
+			return nil, fmt.Errorf("%w: min loss and percent (0-100] are required", ErrInvalidCashbackTier)
+		}
+		if seen[t.MinLoss] {
+			return nil, fmt.Errorf("%w: duplicate min loss %s", ErrInvalidCashbackTier, t.MinLoss)
+		}
+		seen[t.MinLoss] = true
+		t.ID = 0
+		t.Currency = currency
+	}
+	sort.Slice(tiers, func(i, j int) bool { return tiers[i].MinLoss < tiers[j].MinLoss })
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("currency = ?", currency).Delete(&models.CashbackTier{}).Error; err != nil {
+			return err
+		}
+		if len(tiers) == 0 {
+			return nil
+		}
+		return tx.Create(&tiers).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tiers, nil
+}
+
+// cashbackTierFor: ขั้นที่ยอดเสียนี้ได้ (nil = ไม่ถึงขั้นต่ำสุด) tiers ต้องเรียงตาม MinLoss
+func cashbackTierFor(tiers []models.CashbackTier, loss models.Money) *models.CashbackTier {
+	var match *models.CashbackTier
+	for i := range tiers {
+		if loss >= tiers[i].MinLoss {
+			match = &tiers[i]
+		}
+	}
+	return match
+}
+
+// cashbackLosses: ยอดแทง/ยอดจ่ายคืนรวมของสมาชิกแต่ละคนจากบิลที่เคลียร์ในรอบ
+func cashbackLosses(db *gorm.DB, start, end time.Time) (map[uint][2]models.Money, error) {
+	type lossRow struct {
+		UserID uint
+		Stake  models.Money
+		Payout models.Money
+	}
+
+	settled := []string{models.BetStatusWin, models.BetStatusWinHalf, models.BetStatusDraw, models.BetStatusLoseHalf, models.BetStatusLoss}
+	totals := make(map[uint][2]models.Money)
+	for _, model := range []interface{}{&models.BetSlip{}, &models.ParlayTicket{}} {
+		var rows []lossRow
+		err := db.Model(model).
+			Select("user_id, COALESCE(SUM(amount), 0) AS stake, COALESCE(SUM(payout), 0) AS payout").
+			Where("status IN ? AND settled_at >= ? AND settled_at < ?", settled, start, end).
+			Group("user_id").
+			Scan(&rows).Error
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range rows {
+			t := totals[r.UserID]
+			totals[r.UserID] = [2]models.Money{t[0] + r.Stake, t[1] + r.Payout}
+		}
+	}
+	return totals, nil
+}
+
+// ComputeCashback: คำนวณรอบ [start, end) แยกตามสกุล (ยังไม่บันทึก ใช้ทั้ง Preview และสร้างรอบ)
+// รวมเฉพาะสมาชิก (role user) ที่สถานะ active และยอดคืนมากกว่า 0
+func ComputeCashback(db *gorm.DB, start, end time.Time) ([]models.CashbackBatch, error) {
+	losses, err := cashbackLosses(db, start, end)
+	if err != nil {
+		return nil, err
+	}
+	if len(losses) == 0 {
+		return []models.CashbackBatch{}, nil
+	}
+
+	userIDs := make([]uint, 0, len(losses))
+	for id := range losses {
+		userIDs = append(userIDs, id)
+	}
+	var users []models.User
+	if err := db.Select("id", "username", "role", "status", "currency").
+		Where("id IN ? AND role = ? AND status = ?", userIDs, "user", "active").
+		Order("id").Find(&users).Error; err != nil {
+		return nil, err
+	}
+
+	tiersByCurrency := make(map[string][]models.CashbackTier)
+	batches := make(map[string]*models.CashbackBatch)
+	var order []string
+	for i := range users {
+		u := users[i]
+		t := losses[u.ID]
+		loss := t[0] - t[1]
+		if loss <= 0 {
+			continue
+		}
+
+		tiers, ok := tiersByCurrency[u.Currency]
+		if !ok {
+			if tiers, err = CashbackTiers(db, u.Currency); err != nil {
+				return nil, err
+			}
+			tiersByCurrency[u.Currency] = tiers
+		}
+		tier := cashbackTierFor(tiers, loss)
+		if tier == nil {
+			continue
+		}
+		amount := loss.Percent(tier.Percent)
+		if tier.MaxAmount > 0 && amount > tier.MaxAmount {
+			amount = tier.MaxAmount
+		}
+		if amount <= 0 {
+			continue
+		}
+
+		batch, ok := batches[u.Currency]
+		if !ok {
+			batch = &models.CashbackBatch{Currency: u.Currency, PeriodStart: start, PeriodEnd: end, Status: models.CashbackStatusDraft}
+			batches[u.Currency] = batch
+			order = append(order, u.Currency)
+		}
+		batch.Items = append(batch.Items, models.CashbackItem{
+			UserID:  u.ID,
+			Stake:   t[0],
+			Payout:  t[1],
+			NetLoss: loss,
+			TierID:  tier.ID,
+			Percent: tier.Percent,
+			Amount:  amount,
+			User:    &users[i],
+		})
+		batch.Members++
+		batch.TotalLoss += loss
+		batch.TotalAmount += amount
+	}
+
+	sort.Strings(order)
+	result := make([]models.CashbackBatch, 0, len(order))
+	for _, currency := range order {
+		result = append(result, *batches[currency])
+	}
+	return result, nil
+}
+
+// CreateCashbackBatches: คำนวณแล้วบันทึกเป็นรอบ draft (สกุลที่มีรอบ draft/paid ของช่วงนี้อยู่แล้วจะถูกข้าม)
+func CreateCashbackBatches(start, end time.Time, createdBy *uint) ([]models.CashbackBatch, error) {
+	computed, err := ComputeCashback(database.DB, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	created := make([]models.CashbackBatch, 0, len(computed))
+	for _, batch := range computed {
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			var count int64
+			if err := tx.Model(&models.CashbackBatch{}).
+				Where("currency = ? AND period_start = ? AND status <> ?", batch.Currency, start, models.CashbackStatusCancelled).
+				Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return ErrCashbackBatchExists
+			}
+
+			batch.CreatedBy = createdBy
+			for i := range batch.Items {
+				batch.Items[i].User = nil
+			}
+			return tx.Create(&batch).Error
+		})
+		if errors.Is(err, ErrCashbackBatchExists) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		created = append(created, batch)
+	}
+	return created, nil
+}
+
+// ApproveCashbackBatch: อนุมัติรอบ draft แล้วจ่ายเข้ากระเป๋าทุกคน (ทั้งรอบสำเร็จหรือไม่จ่ายเลย)
+func ApproveCashbackBatch(batchID uint, adminID uint) (*models.CashbackBatch, error) {
+	var batch models.CashbackBatch
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Items").First(&batch, batchID).Error; err != nil {
+			return err
+		}
+		if batch.Status != models.CashbackStatusDraft {
+			return ErrCashbackNotDraft
+		}
+
+		period := fmt.Sprintf("%s - %s", batch.PeriodStart.In(bangkokTZ).Format("2006-01-02"),
+			batch.PeriodEnd.In(bangkokTZ).AddDate(0, 0, -1).Format("2006-01-02"))
+		for i := range batch.Items {
+			item := &batch.Items[i]
+			note := fmt.Sprintf("คืนยอดเสีย %s (เสีย %s, %.2f%%)", period, item.NetLoss, item.Percent)
+
+			journal, err := PostWallet(tx, item.UserID, item.Amount, models.LedgerAccountCashback, Journal{
+				Type:      "cashback",
+				RefType:   "cashback_batch",
+				RefID:     batch.ID,
+				Note:      note,
+				CreatedBy: &adminID,
+			})
+			if err != nil {
+				return fmt.Errorf("user %d: %w", item.UserID, err)
+			}
+			cashbackTx, err := RecordTransaction(tx, journal, item.UserID, models.Transaction{
+				AdminID: &adminID,
+				Type:    "cashback",
+				Note:    note,
+			})
+			if err != nil {
+				return err
+			}
+			item.TransactionID = &cashbackTx.ID
+			if err := tx.Model(item).Update("transaction_id", cashbackTx.ID).Error; err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		batch.Status = models.CashbackStatusPaid
+		batch.ApprovedBy = &adminID
+		batch.ApprovedAt = &now
+		return tx.Omit("Items").Save(&batch).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+// CancelCashbackBatch: ยกเลิกรอบ draft (คำนวณรอบเดิมใหม่ได้)
+func CancelCashbackBatch(batchID uint, note string) error {
+	result := database.DB.Model(&models.CashbackBatch{}).
+		Where("id = ? AND status = ?", batchID, models.CashbackStatusDraft).
+		Updates(map[string]interface{}{"status": models.CashbackStatusCancelled, "note": note})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrCashbackNotDraft
+	}
+	return nil
+}
+
+// RunScheduledCashback: งาน "cashback" ใน Job Registry สร้างรอบ draft ของรอบที่เพิ่งปิด
+// รอบรายสัปดาห์สร้างเฉพาะวันจันทร์ (ช่วงเวลาเดียวกับค่าคอม)
+func RunScheduledCashback() error {
+	var settings models.SystemSetting
+	database.DB.First(&settings, 1)
+	if !settings.CashbackEnabled {
+		return nil
+	}
+
+	cycle := settings.CashbackCycle
+	if cycle != "daily" {
+		cycle = "weekly"
+	}
+	now := time.Now()
+	if cycle == "weekly" && now.In(bangkokTZ).Weekday() != time.Monday {
+		return nil
+	}
+
+	start, end := CommissionPeriod(cycle, now)
+	batches, err := CreateCashbackBatches(start, end, nil)
+	if err != nil {
+		return err
+	}
+	for _, b := range batches {
+		log.Printf("✅ [Cashback] %s %s: draft #%d, %d members (%s)", cycle, start.Format("2006-01-02"), b.ID, b.Members, b.TotalAmount)
+	}
+	return nil
+}
+
+// ==========================================
+// Admin API
+// ==========================================
+
+// cashbackRange: ช่วงวันที่จาก Query/Body (start, end แบบ 2006-01-02 ไม่นับ end) ว่าง = รอบล่าสุดตาม Settings
+func cashbackRange(startStr, endStr string) (time.Time, time.Time, error) {
+	if startStr == "" && endStr == "" {
+		var settings models.SystemSetting
+		database.DB.First(&settings, 1)
+		cycle := settings.CashbackCycle
+		if cycle != "daily" {
+			cycle = "weekly"
+		}
+		start, end := CommissionPeriod(cycle, time.Now())
+		return start, end, nil
+	}
+	start, errStart := time.ParseInLocation("2006-01-02", startStr, bangkokTZ)
+	end, errEnd := time.ParseInLocation("2006-01-02", endStr, bangkokTZ)
+	if errStart != nil || errEnd != nil || !end.After(start) {
+		return time.Time{}, time.Time{}, errors.New("invalid period")
+	}
+	return start, end, nil
+}
+
+// GET /admin/cashback/preview?start=2024-01-01&end=2024-01-08
+// ดูยอดคืนก่อนสร้างรอบ (ไม่บันทึก)
+func PreviewCashback(c *fiber.Ctx) error {
+	start, end, err := cashbackRange(c.Query("start"), c.Query("end"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "ช่วงวันที่ไม่ถูกต้อง"})
+	}
+	batches, err := ComputeCashback(database.DB, start, end)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "คำนวณยอดคืนไม่สำเร็จ"})
+	}
+	return c.JSON(fiber.Map{"start": start, "end": end, "batches": batches})
+}
+
+// POST /admin/cashback/batches {"start": "2024-01-01", "end": "2024-01-08"}
+// สร้างรอบ draft เอง (ว่าง = รอบล่าสุดตาม Settings)
+func CreateCashbackBatch(c *fiber.Ctx) error {
+	var req struct {
+		Start string `json:"start"`
+		End   string `json:"end"`
+	}
+	c.BodyParser(&req)
+
+	start, end, err := cashbackRange(req.Start, req.End)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "ช่วงวันที่ไม่ถูกต้อง"})
+	}
+	adminID, _ := c.Locals("user_id").(uint)
+	batches, err := CreateCashbackBatches(start, end, &adminID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "สร้างรอบคืนยอดเสียไม่สำเร็จ"})
+	}
+	if len(batches) == 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "ไม่มีสมาชิกที่ได้คืนยอดเสีย หรือสร้างรอบนี้ไปแล้ว"})
+	}
+	return c.JSON(fiber.Map{"message": "สร้างรอบคืนยอดเสียเรียบร้อย รอตรวจสอบและอนุมัติ", "batches": batches})
+}
+
+// GET /admin/cashback/batches?status=&currency=
+func GetCashbackBatches(c *fiber.Ctx) error {
+	query := database.DB.Model(&models.CashbackBatch{}).Order("id desc")
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if currency := c.Query("currency"); currency != "" {
+		query = query.Where("currency = ?", currency)
+	}
+
+	var batches []models.CashbackBatch
+	if err := query.Limit(200).Find(&batches).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "ดึงข้อมูลไม่สำเร็จ"})
+	}
+	return c.JSON(batches)
+}
+
+// GET /admin/cashback/batches/:id (รายชื่อสมาชิกในรอบ + สรุปตามขั้น)
+func GetCashbackBatch(c *fiber.Ctx) error {
+	var batch models.CashbackBatch
+	err := database.DB.Preload("Items", func(db *gorm.DB) *gorm.DB {
+		return db.Order("amount desc")
+	}).Preload("Items.User", func(db *gorm.DB) *gorm.DB {
+		return db.Unscoped().Select("id", "username", "parent_id")
+	}).First(&batch, c.Params("id")).Error
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "ไม่พบรอบคืนยอดเสีย"})
+	}
+
+	type tierSummary struct {
+		Percent float64      `json:"percent"`
+		Members int          `json:"members"`
+		Loss    models.Money `json:"loss"`
+		Amount  models.Money `json:"amount"`
+	}
+	byTier := make(map[float64]*tierSummary)
+	tiers := make([]*tierSummary, 0)
+	for _, item := range batch.Items {
+		s, ok := byTier[item.Percent]
+		if !ok {
+			s = &tierSummary{Percent: item.Percent}
+			byTier[item.Percent] = s
+			tiers = append(tiers, s)
+		}
+		s.Members++
+		s.Loss += item.NetLoss
+		s.Amount += item.Amount
+	}
+	sort.Slice(tiers, func(i, j int) bool { return tiers[i].Percent < tiers[j].Percent })
+
+	return c.JSON(fiber.Map{"batch": batch, "tiers": tiers})
+}
+
+// POST /admin/cashback/batches/:id/approve
+func ApproveCashback(c *fiber.Ctx) error {
+	batchID, err := c.ParamsInt("id")
+	if err != nil || batchID <= 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "รหัสรอบไม่ถูกต้อง"})
+	}
+
+	adminID, _ := c.Locals("user_id").(uint)
+	batch, err := ApproveCashbackBatch(uint(batchID), adminID)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(404).JSON(fiber.Map{"error": "ไม่พบรอบคืนยอดเสีย"})
+	case errors.Is(err, ErrCashbackNotDraft):
+		return c.Status(400).JSON(fiber.Map{"error": "รอบนี้ถูกดำเนินการไปแล้ว"})
+	case err != nil:
+		log.Printf("❌ [Cashback] Approve batch %d Error: %v", batchID, err)
+		return c.Status(500).JSON(fiber.Map{"error": "จ่ายคืนยอดเสียไม่สำเร็จ (" + err.Error() + ")"})
+	}
+	return c.JSON(fiber.Map{"message": "จ่ายคืนยอดเสียเรียบร้อย", "batch": batch})
+}
+
+// POST /admin/cashback/batches/:id/cancel {"note": "..."}
+func CancelCashback(c *fiber.Ctx) error {
+	batchID, err := c.ParamsInt("id")
+	if err != nil || batchID <= 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "รหัสรอบไม่ถูกต้อง"})
+	}
+	var req struct {
+		Note string `json:"note"`
+	}
+	c.BodyParser(&req)
+
+	if err := CancelCashbackBatch(uint(batchID), req.Note); err != nil {
+		if errors.Is(err, ErrCashbackNotDraft) {
+			return c.Status(400).JSON(fiber.Map{"error": "ยกเลิกได้เฉพาะรอบที่ยังไม่อนุมัติ"})
+		}
+		return c.Status(500).JSON(fiber.Map{"error": "บันทึกไม่สำเร็จ"})
+	}
+	return c.JSON(fiber.Map{"message": "ยกเลิกรอบคืนยอดเสียเรียบร้อย"})
+}
+
+// GET /admin/cashback/report?start=2024-01-01&end=2024-02-01
+// ยอดคืนยอดเสียที่จ่ายแล้ว แยกตามสกุล (นับตามวันเริ่มรอบ)
+func GetCashbackReport(c *fiber.Ctx) error {
+	type reportRow struct {
+		Currency    string       `json:"currency"`
+		Batches     int          `json:"batches"`
+		Members     int          `json:"members"`
+		TotalLoss   models.Money `json:"total_loss"`
+		TotalAmount models.Money `json:"total_amount"`
+	}
+
+	query := database.DB.Model(&models.CashbackBatch{}).
+		Select("currency, COUNT(*) AS batches, COALESCE(SUM(members), 0) AS members, "+
+			"COALESCE(SUM(total_loss), 0) AS total_loss, COALESCE(SUM(total_amount), 0) AS total_amount").
+		Where("status = ?", models.CashbackStatusPaid).
+		Group("currency").Order("currency")
+	if start := c.Query("start"); start != "" {
+		query = query.Where("period_start >= ?", start)
+	}
+	if end := c.Query("end"); end != "" {
+		query = query.Where("period_start < ?", end)
+	}
+
+	var rows []reportRow
+	if err := query.Scan(&rows).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "ดึงข้อมูลไม่สำเร็จ"})
+	}
+	return c.JSON(rows)
+}
+
+// GET /admin/cashback/tiers
+func GetCashbackTiers(c *fiber.Ctx) error {
+	var tiers []models.CashbackTier
+	if err := database.DB.Order("currency, min_loss").Find(&tiers).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "ดึงข้อมูลไม่สำเร็จ"})
+	}
+	return c.JSON(tiers)
+}
+
+// PUT /admin/cashback/tiers/:currency [{"min_loss": 1000, "percent": 5, "max_amount": 5000}, ...]
+// แทนที่ขั้นทั้งหมดของสกุล
+func UpdateCashbackTiers(c *fiber.Ctx) error {
+	var tiers []models.CashbackTier
+	if err := c.BodyParser(&tiers); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "ข้อมูลไม่ถูกต้อง"})
+	}
+
+	saved, err := SaveCashbackTiers(database.DB, c.Params("currency"), tiers)
+	switch {
+	case errors.Is(err, ErrUnsupportedCurrency):
+		return c.Status(400).JSON(fiber.Map{"error": "ไม่รองรับสกุลเงินนี้"})
+	case errors.Is(err, ErrInvalidCashbackTier):
+		return c.Status(400).JSON(fiber.Map{"error": "ขั้นคืนยอดเสียไม่ถูกต้อง (" + err.Error() + ")"})
+	case err != nil:
+		return c.Status(500).JSON(fiber.Map{"error": "บันทึกไม่สำเร็จ"})
+	}
+	return c.JSON(fiber.Map{"message": "บันทึกขั้นคืนยอดเสียเรียบร้อย", "tiers": saved})
+}
+
+// GET /user/cashback
+// [USER] ประวัติคืนยอดเสียที่ได้รับ
+func GetMyCashback(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(uint)
+
+	var items []models.CashbackItem
+	if err := database.DB.
+		Joins("JOIN cashback_batches ON cashback_batches.id = cashback_items.batch_id").
+		Where("cashback_items.user_id = ? AND cashback_batches.status = ?", userID, models.CashbackStatusPaid).
+		Order("cashback_items.id desc").Limit(100).Find(&items).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "ดึงข้อมูลไม่สำเร็จ"})
+	}
+	return c.JSON(items)
+}
@@ -0,0 +1,136 @@
+package services
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/PawornpratKongdaeng/soccer/models"
+)
+
+func TestCashbackTierFor(t *testing.T) {
+	tiers := []models.CashbackTier{
+		{ID: 1, MinLoss: models.NewMoney(100), Percent: 5},
+		{ID: 2, MinLoss: models.NewMoney(1000), Percent: 10},
+		{ID: 3, MinLoss: models.NewMoney(5000), Percent: 15},
+	}
+
+	tests := []struct {
+		name   string
+		tiers  []models.CashbackTier
+		loss   models.Money
+		wantID uint // 0 = ไม่ถึงขั้นต่ำสุด
+	}{
+		{"no tiers", nil, models.NewMoney(10000), 0},
+		{"below lowest tier", tiers, models.NewMoney(99.99), 0},
+		{"exactly lowest tier", tiers, models.NewMoney(100), 1},
+		{"just below next tier", tiers, models.NewMoney(999.99), 1},
+		{"exactly middle tier", tiers, models.NewMoney(1000), 2},
+		{"above highest tier", tiers, models.NewMoney(1000000), 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := cashbackTierFor(tt.tiers, tt.loss)
+			var gotID uint
+			if got != nil {
+				gotID = got.ID
+			}
+			if gotID != tt.wantID {
+				t.Errorf("cashbackTierFor(%s) = tier %d, want %d", tt.loss, gotID, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestComputeCashbackCaps(t *testing.T) {
+	db := openTestDB(t)
+	if _, err := SaveCashbackTiers(db, models.CurrencyTHB, []models.CashbackTier{
+		{MinLoss: models.NewMoney(1000), Percent: 10, MaxAmount: models.NewMoney(300)},
+		{MinLoss: models.NewMoney(100), Percent: 5, MaxAmount: models.NewMoney(20)},
+		{MinLoss: models.NewMoney(5000), Percent: 15}, // ไม่มีเพดาน
+	}); err != nil {
+		t.Fatalf("SaveCashbackTiers() error = %v", err)
+	}
+	start := time.Date(2026, 3, 2, 0, 0, 0, 0, bangkokTZ)
+	end := start.AddDate(0, 0, 7)
+	in := start.Add(time.Hour)
+
+	tests := []struct {
+		name       string
+		stake      models.Money
+		payout     models.Money
+		role       string
+		wantAmount models.Money // 0 = ไม่อยู่ในรอบ
+	}{
+		{"below lowest tier", models.NewMoney(99.99), 0, "user", 0},
+		{"lowest tier under cap", models.NewMoney(300), 0, "user", models.NewMoney(15)},
+		{"lowest tier capped", models.NewMoney(999), 0, "user", models.NewMoney(20)},
+		{"middle tier under cap", models.NewMoney(2500), models.NewMoney(500), "user", models.NewMoney(200)},
+		{"middle tier capped", models.NewMoney(4999.99), 0, "user", models.NewMoney(300)},
+		{"top tier without cap", models.NewMoney(10000), 0, "user", models.NewMoney(1500)},
+		{"net winner", models.NewMoney(1000), models.NewMoney(1500), "user", 0},
+		{"agents are excluded", models.NewMoney(10000), 0, "agent", 0},
+	}
+
+	users := make([]models.User, len(tests))
+	for i, tt := range tests {
+		users[i] = createTestUser(t, db, "cashback_"+strconv.Itoa(i), 0)
+		updateUser(t, db, users[i].ID, map[string]interface{}{"role": tt.role})
+		status := models.BetStatusLoss
+		if tt.payout > 0 {
+			status = models.BetStatusWin
+		}
+		settledSlip(t, db, users[i].ID, tt.stake, tt.payout, status, in)
+	}
+
+	batches, err := ComputeCashback(db, start, end)
+	if err != nil {
+		t.Fatalf("ComputeCashback() error = %v", err)
+	}
+	got := make(map[uint]models.Money)
+	for _, b := range batches {
+		for _, item := range b.Items {
+			got[item.UserID] = item.Amount
+		}
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got[users[i].ID] != tt.wantAmount {
+				t.Errorf("cashback = %s, want %s", got[users[i].ID], tt.wantAmount)
+			}
+		})
+	}
+}
+
+func TestApproveCashbackBatch(t *testing.T) {
+	db := openTestDB(t)
+	if _, err := SaveCashbackTiers(db, models.CurrencyTHB, []models.CashbackTier{{MinLoss: models.NewMoney(100), Percent: 10}}); err != nil {
+		t.Fatal(err)
+	}
+	admin := createTestUser(t, db, "cashback_admin", 0)
+	user := createTestUser(t, db, "cashback_paid", 0)
+	start := time.Date(2026, 3, 2, 0, 0, 0, 0, bangkokTZ)
+	end := start.AddDate(0, 0, 7)
+	settledSlip(t, db, user.ID, models.NewMoney(500), 0, models.BetStatusLoss, start.Add(time.Hour))
+
+	created, err := CreateCashbackBatches(start, end, nil)
+	if err != nil || len(created) != 1 {
+		t.Fatalf("CreateCashbackBatches() = %d batches, %v", len(created), err)
+	}
+	if again, err := CreateCashbackBatches(start, end, nil); err != nil || len(again) != 0 {
+		t.Errorf("CreateCashbackBatches() again = %d batches, %v, want 0", len(again), err)
+	}
+
+	if _, err := ApproveCashbackBatch(created[0].ID, admin.ID); err != nil {
+		t.Fatalf("ApproveCashbackBatch() error = %v", err)
+	}
+	if u := reloadUser(t, db, user.ID); u.Credit != models.NewMoney(50) {
+		t.Errorf("credit = %s, want 50.00", u.Credit)
+	}
+	if _, err := ApproveCashbackBatch(created[0].ID, admin.ID); !errors.Is(err, ErrCashbackNotDraft) {
+		t.Errorf("ApproveCashbackBatch() again = %v, want %v", err, ErrCashbackNotDraft)
+	}
+}
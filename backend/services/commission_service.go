@@ -0,0 +1,295 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/PawornpratKongdaeng/soccer/database"
+	"github.com/PawornpratKongdaeng/soccer/models"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// จำนวนชั้นสูงสุดที่จะไล่ขึ้นไปตามสาย ParentID (กันลูปกรณีข้อมูลผิด)
+const maxUplineDepth = 10
+
+var bangkokTZ = time.FixedZone("Asia/Bangkok", 7*60*60)
+
+var ErrCommissionOverlap = errors.New("commission period overlaps a paid period")
+
+// CommissionPeriod: หารอบค่าคอมล่าสุดที่ปิดไปแล้ว (daily = เมื่อวาน, weekly = จันทร์-อาทิตย์ที่แล้ว)
+func CommissionPeriod(cycle string, now time.Time) (time.Time, time.Time) {
+	local := now.In(bangkokTZ)
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, bangkokTZ)
+
+	if cycle == "weekly" {
+		// ย้อนไปหาวันจันทร์ของสัปดาห์นี้ แล้วถอยไปอีก 7 วัน
+		offset := (int(today.Weekday()) + 6) % 7
+		thisMonday := today.AddDate(0, 0, -offset)
+		return thisMonday.AddDate(0, 0, -7), thisMonday
+	}
+	return today.AddDate(0, 0, -1), today
+}
+
+// commissionTurnover: รวมยอดเล่นที่นับค่าคอมของแต่ละสมาชิก จากบิลที่เคลียร์แล้วในช่วงเวลา
+// ไม่นับบิลเสมอ/ยกเลิก และบิลชนะครึ่ง/เสียครึ่งนับยอดเล่นครึ่งเดียว (รวมใน DB แบบ numeric แล้วปัดสตางค์ครั้งเดียว)
+func commissionTurnover(db *gorm.DB, start, end time.Time) (map[uint]models.Money, error) {
+	type turnoverRow struct {
+		UserID   uint
+		Turnover models.Money
+	}
+
+	fullStatuses := []string{models.BetStatusWin, models.BetStatusLoss}
+	halfStatuses := []string{models.BetStatusWinHalf, models.BetStatusLoseHalf}
+	validStatuses := append(append([]string{}, fullStatuses...), halfStatuses...)
+
+	turnover := make(map[uint]models.Money)
+	for _, model := range []interface{}{&models.BetSlip{}, &models.ParlayTicket{}} {
+		var rows []turnoverRow
+		err := db.Model(model).
+			Select("user_id, COALESCE(SUM(CASE WHEN status IN ? THEN amount * 0.5 ELSE amount END), 0) AS turnover", halfStatuses).
+			Where("status IN ? AND settled_at >= ? AND settled_at < ?", validStatuses, start, end).
+			Group("user_id").
+			Scan(&rows).Error
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range rows {
+			turnover[r.UserID] += r.Turnover
+		}
+	}
+	return turnover, nil
+}
+
+// commissionShare: ส่วนแบ่งค่าคอมของแต่ละชั้น
+type commissionShare struct {
+	UserID uint
+	Level  int
+	Rate   float64
+}
+
+// commissionChain: ไล่สาย ParentID ของสมาชิก แล้วคิด % ที่แต่ละชั้นได้รับ
+// สมาชิกได้ Com ของตัวเอง ส่วน Upline ได้ส่วนต่างระหว่าง Com ของตัวเองกับชั้นที่อยู่ต่ำกว่า
+func commissionChain(db *gorm.DB, member models.User) []commissionShare {
+	var shares []commissionShare
+	if member.Com > 0 {
+		shares = append(shares, commissionShare{UserID: member.ID, Level: 0, Rate: member.Com})
+	}
+
+	childRate := member.Com
+	parentID := member.ParentID
+	for level := 1; parentID != nil && level <= maxUplineDepth; level++ {
+		var parent models.User
+		if err := db.First(&parent, *parentID).Error; err != nil {
+			break
+		}
+		// บริษัท (admin) ไม่รับค่าคอม
+		if parent.Role == "admin" {
+			break
+		}
+		if override := parent.Com - childRate; override > 0 {
+			shares = append(shares, commissionShare{UserID: parent.ID, Level: level, Rate: override})
+		}
+		if parent.Com > childRate {
+			childRate = parent.Com
+		}
+		parentID = parent.ParentID
+	}
+	return shares
+}
+
+// commissionOverlap: ยอดเล่นของสมาชิกในช่วง [start, end) ถูกจ่ายค่าคอมไปแล้วในรอบอื่นที่ทับกัน
+// (รอบเดียวกันเป๊ะไม่นับ ให้รันซ้ำต่อจากที่ค้างได้) from 0 = ทุกสมาชิก
+func commissionOverlap(db *gorm.DB, fromUserID uint, start, end time.Time) (bool, error) {
+	query := db.Model(&models.CommissionRebate{}).
+		Where("period_start < ? AND period_end > ?", end, start).
+		Where("NOT (period_start = ? AND period_end = ?)", start, end)
+	if fromUserID != 0 {
+		query = query.Where("from_user_id = ?", fromUserID)
+	}
+	var n int64
+	if err := query.Count(&n).Error; err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// RunCommission: คำนวณและจ่ายค่าคอมของรอบ [start, end)
+// รันซ้ำได้ รายการที่จ่ายไปแล้ว (ผู้รับ + สมาชิก + รอบ) จะถูกข้าม
+// สมาชิกที่มีรอบอื่นทับช่วงนี้ที่จ่ายไปแล้ว (รันเองซ้อนช่วง / เปลี่ยนรอบ daily <-> weekly) ข้ามทั้งคน กันจ่ายซ้ำบิลเดิม
+func RunCommission(start, end time.Time) (int, models.Money, error) {
+	turnover, err := commissionTurnover(database.DB, start, end)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	paidCount := 0
+	var paidTotal models.Money
+
+	for memberID, memberTurnover := range turnover {
+		if memberTurnover <= 0 {
+			continue
+		}
+
+		var member models.User
+		if err := database.DB.First(&member, memberID).Error; err != nil {
+			continue
+		}
+
+		for _, share := range commissionChain(database.DB, member) {
+			amount := memberTurnover.Percent(share.Rate)
+			if amount <= 0 {
+				continue
+			}
+
+			paid := false
+			err := database.DB.Transaction(func(tx *gorm.DB) error {
+				// ล็อกสมาชิกไว้ กันสองรอบที่ทับกันจ่ายพร้อมกัน
+				if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&models.User{}, member.ID).Error; err != nil {
+					return err
+				}
+				if overlap, err := commissionOverlap(tx, member.ID, start, end); err != nil {
+					return err
+				} else if overlap {
+					return ErrCommissionOverlap
+				}
+
+				rebate := models.CommissionRebate{
+					UserID:      share.UserID,
+					FromUserID:  member.ID,
+					PeriodStart: start,
+					PeriodEnd:   end,
+					Level:       share.Level,
+					Turnover:    memberTurnover,
+					Rate:        share.Rate,
+					Amount:      amount,
+				}
+
+				// จ่ายไปแล้วในรอบนี้ -> ข้าม
+				result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&rebate)
+				if result.Error != nil {
+					return result.Error
+				}
+				if result.RowsAffected == 0 {
+					return nil
+				}
+
+				note := fmt.Sprintf("ค่าคอม %s - %s จาก %s (ชั้น %d, %.2f%%)",
+					start.Format("2006-01-02"), end.AddDate(0, 0, -1).Format("2006-01-02"), member.Username, share.Level, share.Rate)
+
+				journal, err := PostWallet(tx, share.UserID, amount, models.LedgerAccountCommission, Journal{
+					Type:    "commission",
+					RefType: "commission_rebate",
+					RefID:   rebate.ID,
+					Note:    note,
+				})
+				if err != nil {
+					return err
+				}
+
+				commissionTx, err := RecordTransaction(tx, journal, share.UserID, models.Transaction{Type: "commission", Note: note})
+				if err != nil {
+					return err
+				}
+
+				paid = true
+				return tx.Model(&rebate).Update("transaction_id", commissionTx.ID).Error
+			})
+
+			if errors.Is(err, ErrCommissionOverlap) {
+				log.Printf("⚠️ [Commission] Member %d skipped: %s - %s overlaps a paid period", member.ID, start.Format("2006-01-02"), end.Format("2006-01-02"))
+				break
+			}
+			if err != nil {
+				log.Printf("❌ [Commission] Member %d -> User %d Error: %v", member.ID, share.UserID, err)
+				continue
+			}
+			if paid {
+				paidCount++
+				paidTotal += amount
+			}
+		}
+	}
+
+	return paidCount, paidTotal, nil
+}
+
+// RunScheduledCommission: เรียกจาก Cron ทุกวัน แล้วเช็ครอบจาก SystemSetting (daily / weekly)
+func RunScheduledCommission() error {
+	var settings models.SystemSetting
+	database.DB.First(&settings, 1)
+
+	cycle := settings.CommissionCycle
+	if cycle != "weekly" {
+		cycle = "daily"
+	}
+
+	now := time.Now()
+	// รอบรายสัปดาห์ จ่ายเฉพาะวันจันทร์
+	if cycle == "weekly" && now.In(bangkokTZ).Weekday() != time.Monday {
+		return nil
+	}
+
+	start, end := CommissionPeriod(cycle, now)
+	count, total, err := RunCommission(start, end)
+	if err != nil {
+		return err
+	}
+	log.Printf("✅ [Commission] %s %s: paid %d rebates (%s)", cycle, start.Format("2006-01-02"), count, total)
+	return nil
+}
+
+// ManualCommission: (Admin) สั่งคำนวณค่าคอมของช่วงวันที่เอง
+// POST /admin/commission/run {"start": "2024-01-01", "end": "2024-01-08"} (end ไม่นับรวม)
+func ManualCommission(c *fiber.Ctx) error {
+	var req struct {
+		Start string `json:"start"`
+		End   string `json:"end"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "ข้อมูลไม่ถูกต้อง"})
+	}
+
+	start, errStart := time.ParseInLocation("2006-01-02", req.Start, bangkokTZ)
+	end, errEnd := time.ParseInLocation("2006-01-02", req.End, bangkokTZ)
+	if errStart != nil || errEnd != nil || !end.After(start) {
+		return c.Status(400).JSON(fiber.Map{"error": "ช่วงวันที่ไม่ถูกต้อง"})
+	}
+	// ช่วงที่ทับรอบที่จ่ายไปแล้ว (ยกเว้นรอบเดิมเป๊ะ ที่รันซ้ำต่อได้) = จ่ายซ้ำบิลเดิม
+	if overlap, err := commissionOverlap(database.DB, 0, start, end); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "ตรวจสอบรอบค่าคอมไม่สำเร็จ"})
+	} else if overlap {
+		return c.Status(409).JSON(fiber.Map{"error": "ช่วงวันที่นี้ทับกับรอบค่าคอมที่จ่ายไปแล้ว"})
+	}
+
+	count, total, err := RunCommission(start, end)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "คำนวณค่าคอมไม่สำเร็จ"})
+	}
+
+	return c.JSON(fiber.Map{"message": "จ่ายค่าคอมเรียบร้อย", "count": count, "total": total})
+}
+
+// GetCommissionRebates: (Admin) ดูรายการค่าคอมที่จ่ายแล้ว กรองตาม user_id / รอบได้
+func GetCommissionRebates(c *fiber.Ctx) error {
+	query := database.DB.Model(&models.CommissionRebate{}).Order("id desc")
+
+	if userID := c.Query("user_id"); userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+	if start := c.Query("start"); start != "" {
+		query = query.Where("period_start >= ?", start)
+	}
+	if end := c.Query("end"); end != "" {
+		query = query.Where("period_end <= ?", end)
+	}
+
+	var rebates []models.CommissionRebate
+	if err := query.Limit(500).Find(&rebates).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "ดึงข้อมูลไม่สำเร็จ"})
+	}
+	return c.JSON(rebates)
+}
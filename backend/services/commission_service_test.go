@@ -0,0 +1,140 @@
+package services
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/PawornpratKongdaeng/soccer/models"
+	"gorm.io/gorm"
+)
+
+// uplineChain: สมาชิก 1 คนใต้ Agent ต่อกันขึ้นไป len(coms) ชั้น (coms[0] = Upline ตรง) ชั้นบนสุดอยู่ใต้ Admin
+func uplineChain(t *testing.T, db *gorm.DB, prefix string, memberCom float64, coms []float64) (models.User, []models.User) {
+	t.Helper()
+	admin := createTestUser(t, db, prefix+"_admin", 0)
+	admin = updateUser(t, db, admin.ID, map[string]interface{}{"role": "admin", "com": 5})
+
+	uplines := make([]models.User, len(coms))
+	parentID := admin.ID
+	for i := len(coms) - 1; i >= 0; i-- {
+		u := createTestUser(t, db, prefix+"_agent_"+strconv.Itoa(i), 0)
+		uplines[i] = updateUser(t, db, u.ID, map[string]interface{}{"role": "agent", "com": coms[i], "parent_id": parentID})
+		parentID = u.ID
+	}
+	member := createTestUser(t, db, prefix+"_member", 0)
+	member = updateUser(t, db, member.ID, map[string]interface{}{"com": memberCom, "parent_id": parentID})
+	return member, uplines
+}
+
+func TestCommissionChain(t *testing.T) {
+	db := openTestDB(t)
+
+	t.Run("stops at max upline depth", func(t *testing.T) {
+		coms := make([]float64, maxUplineDepth+2)
+		for i := range coms {
+			coms[i] = 0.5 + 0.25*float64(i+1)
+		}
+		member, uplines := uplineChain(t, db, "com_deep", 0.5, coms)
+
+		shares := commissionChain(db, member)
+		if len(shares) != maxUplineDepth+1 {
+			t.Fatalf("shares = %d, want %d (member + %d levels)", len(shares), maxUplineDepth+1, maxUplineDepth)
+		}
+		if shares[0] != (commissionShare{UserID: member.ID, Level: 0, Rate: 0.5}) {
+			t.Errorf("level 0 = %+v", shares[0])
+		}
+		for level := 1; level <= maxUplineDepth; level++ {
+			want := commissionShare{UserID: uplines[level-1].ID, Level: level, Rate: 0.25}
+			if shares[level] != want {
+				t.Errorf("level %d = %+v, want %+v", level, shares[level], want)
+			}
+		}
+	})
+
+	t.Run("lower upline gets nothing and admin is skipped", func(t *testing.T) {
+		// Upline ตรง 1.0, ชั้นถัดไป 0.75 (ต่ำกว่า ไม่ได้ส่วนต่าง), ชั้นบนสุด 1.5 ได้ส่วนต่างจาก 1.0
+		member, uplines := uplineChain(t, db, "com_gap", 0, []float64{1.0, 0.75, 1.5})
+
+		shares := commissionChain(db, member)
+		want := []commissionShare{
+			{UserID: uplines[0].ID, Level: 1, Rate: 1.0},
+			{UserID: uplines[2].ID, Level: 3, Rate: 0.5},
+		}
+		if len(shares) != len(want) {
+			t.Fatalf("shares = %+v, want %+v", shares, want)
+		}
+		for i := range want {
+			if shares[i] != want[i] {
+				t.Errorf("share %d = %+v, want %+v", i, shares[i], want[i])
+			}
+		}
+	})
+}
+
+func TestCommissionTurnover(t *testing.T) {
+	db := openTestDB(t)
+	start := time.Date(2026, 3, 2, 0, 0, 0, 0, bangkokTZ)
+	end := start.AddDate(0, 0, 7)
+	in := start.Add(36 * time.Hour)
+
+	user := createTestUser(t, db, "com_turnover", 0)
+	other := createTestUser(t, db, "com_turnover_other", 0)
+
+	settledSlip(t, db, user.ID, models.NewMoney(100), models.NewMoney(200), models.BetStatusWin, in)
+	settledSlip(t, db, user.ID, models.NewMoney(50), 0, models.BetStatusLoss, in)
+	settledSlip(t, db, user.ID, models.NewMoney(33.33), models.NewMoney(59.99), models.BetStatusWinHalf, in)
+	settledSlip(t, db, user.ID, models.NewMoney(20), models.NewMoney(10), models.BetStatusLoseHalf, in)
+	settledSlip(t, db, user.ID, models.NewMoney(500), models.NewMoney(500), models.BetStatusDraw, in)
+	settledSlip(t, db, user.ID, models.NewMoney(70), 0, models.BetStatusLoss, end)                   // รอบถัดไป
+	settledSlip(t, db, user.ID, models.NewMoney(80), 0, models.BetStatusLoss, start.Add(-time.Hour)) // รอบก่อน
+	settledSlip(t, db, other.ID, models.NewMoney(10), 0, models.BetStatusLoss, start)
+
+	settledAt := in
+	ticket := models.ParlayTicket{UserID: user.ID, Amount: models.NewMoney(40), Status: models.BetStatusLoss, SettledAt: &settledAt}
+	if err := db.Create(&ticket).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := commissionTurnover(db, start, end)
+	if err != nil {
+		t.Fatalf("commissionTurnover() error = %v", err)
+	}
+	// 100 + 50 + 33.33/2 + 20/2 + สเต็ป 40 (ครึ่งสตางค์ 16.665 รวมก่อนปัดครั้งเดียว)
+	if want := models.NewMoney(216.67); got[user.ID] != want {
+		t.Errorf("turnover = %s, want %s", got[user.ID], want)
+	}
+	if want := models.NewMoney(10); got[other.ID] != want {
+		t.Errorf("turnover at period start = %s, want %s", got[other.ID], want)
+	}
+}
+
+func TestRunCommissionOverlap(t *testing.T) {
+	db := openTestDB(t)
+	member, uplines := uplineChain(t, db, "com_run", 0.5, []float64{1.0})
+	start := time.Date(2026, 3, 2, 0, 0, 0, 0, bangkokTZ)
+	end := start.AddDate(0, 0, 1)
+	settledSlip(t, db, member.ID, models.NewMoney(1000), 0, models.BetStatusLoss, start.Add(time.Hour))
+
+	count, total, err := RunCommission(start, end)
+	if err != nil || count != 2 || total != models.NewMoney(10) {
+		t.Fatalf("RunCommission() = %d, %s, %v, want 2, 10.00", count, total, err)
+	}
+	if u := reloadUser(t, db, member.ID); u.Credit != models.NewMoney(5) {
+		t.Errorf("member credit = %s, want 5.00", u.Credit)
+	}
+	if u := reloadUser(t, db, uplines[0].ID); u.Credit != models.NewMoney(5) {
+		t.Errorf("upline credit = %s, want 5.00", u.Credit)
+	}
+
+	if count, _, err := RunCommission(start, end); err != nil || count != 0 {
+		t.Errorf("rerun same period = %d, %v, want 0", count, err)
+	}
+	// รอบรายสัปดาห์ที่ครอบวันที่จ่ายไปแล้ว ต้องข้ามสมาชิกคนนี้ทั้งคน
+	if count, _, err := RunCommission(start, start.AddDate(0, 0, 7)); err != nil || count != 0 {
+		t.Errorf("overlapping period = %d, %v, want 0", count, err)
+	}
+	if u := reloadUser(t, db, member.ID); u.Credit != models.NewMoney(5) {
+		t.Errorf("member credit after reruns = %s, want 5.00", u.Credit)
+	}
+}
@@ -0,0 +1,187 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/PawornpratKongdaeng/soccer/database"
+	"github.com/PawornpratKongdaeng/soccer/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ==========================================
+// บัญชีเครดิต (Credit-line / Post-paid)
+// ==========================================
+// สมาชิกโหมด credit แทงได้จนยอดติดลบถึง -CreditLimit (ดู Journal.UseCreditLine)
+// วงเงินตั้งโดยต้นสาย (Parent) และผลรวมวงเงินที่แจกให้ลูกสายตรงต้องไม่เกินวงเงินของตัวเอง (Admin ไม่จำกัด)
+// ตอนปิดรอบ ClearCreditLines จะเคลียร์ยอดทุกบัญชีเครดิตกลับเป็น 0 (ติดลบ = เก็บเงินสมาชิก, บวก = จ่ายให้สมาชิก)
+
+var (
+	ErrInvalidAccountMode    = errors.New("invalid account mode")
+	ErrNotDirectDownline     = errors.New("member is not a direct downline")
+	ErrCreditLimitExceeded   = errors.New("credit limit exceeds upline limit")
+	ErrLimitBelowOutstanding = errors.New("credit limit below outstanding balance")
+)
+
+// SetCreditLine: ตั้งโหมดบัญชี/วงเงินให้ memberID โดย setter (ต้องเป็น Parent โดยตรง หรือ Admin)
+func SetCreditLine(db *gorm.DB, setter models.User, memberID uint, mode string, limit models.Money) (*models.User, error) {
+	if mode != models.AccountModeCash && mode != models.AccountModeCredit {
+		return nil, ErrInvalidAccountMode
+	}
+	if limit < 0 || mode == models.AccountModeCash {
+		limit = 0
+	}
+
+	var member models.User
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&member, memberID).Error; err != nil {
+			return err
+		}
+		if setter.Role != "admin" && (member.ParentID == nil || *member.ParentID != setter.ID) {
+			return ErrNotDirectDownline
+		}
+
+		// ยอดค้างต้องอยู่ในวงเงินใหม่เสมอ (เปลี่ยนเป็น cash ได้เมื่อไม่มียอดค้าง)
+		if member.Outstanding() > limit {
+			return ErrLimitBelowOutstanding
+		}
+
+		if setter.Role != "admin" {
+			var allocated models.Money
+			if err := tx.Model(&models.User{}).
+				Where("parent_id = ? AND id <> ? AND account_mode = ?", setter.ID, member.ID, models.AccountModeCredit).
+				Select("COALESCE(SUM(credit_limit), 0)").Scan(&allocated).Error; err != nil {
+				return err
+			}
+			if allocated+limit > setter.CreditLimit {
+				return fmt.Errorf("%w: available %s", ErrCreditLimitExceeded, setter.CreditLimit-allocated)
+			}
+		}
+
+		member.AccountMode = mode
+		member.CreditLimit = limit
+		return tx.Model(&member).Updates(map[string]interface{}{
+			"account_mode": mode,
+			"credit_limit": limit,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+// CreditLineRow: ยอดของบัญชีเครดิต 1 บัญชี (ใช้ในรายงาน Agent)
+type CreditLineRow struct {
+	UserID      uint         `json:"user_id"`
+	Username    string       `json:"username"`
+	Role        string       `json:"role"`
+	ParentID    *uint        `json:"parent_id"`
+	CreditLimit models.Money `json:"credit_limit"`
+	Balance     models.Money `json:"balance"`
+	Outstanding models.Money `json:"outstanding"`
+	Available   models.Money `json:"available"`
+}
+
+// CreditLines: บัญชีเครดิตในสายงานของ rootID (rootID = 0 คือทุกบัญชี)
+func CreditLines(db *gorm.DB, rootID uint) ([]CreditLineRow, error) {
+	query := db.Model(&models.User{}).Where("account_mode = ?", models.AccountModeCredit).Order("username")
+	if rootID != 0 {
+		ids, err := DownlineIDs(db, rootID)
+		if err != nil {
+			return nil, err
+		}
+		if len(ids) == 0 {
+			return []CreditLineRow{}, nil
+		}
+		query = query.Where("id IN ?", ids)
+	}
+
+	var users []models.User
+	if err := query.Find(&users).Error; err != nil {
+		return nil, err
+	}
+
+	rows := make([]CreditLineRow, 0, len(users))
+	for _, u := range users {
+		rows = append(rows, CreditLineRow{
+			UserID:      u.ID,
+			Username:    u.Username,
+			Role:        u.Role,
+			ParentID:    u.ParentID,
+			CreditLimit: u.CreditLimit,
+			Balance:     u.Credit,
+			Outstanding: u.Outstanding(),
+			Available:   u.AvailableCredit(),
+		})
+	}
+	return rows, nil
+}
+
+// ClearCreditLines: ปิดรอบบัญชีเครดิต เคลียร์ยอด ณ periodEnd ของทุกบัญชีโหมด credit (งาน "credit-clear" ใน Job Registry)
+// รายการที่เข้ากระเป๋าหลัง periodEnd (บิลเคลียร์ระหว่างรอรัน) ไม่ถูกเคลียร์ ยกไปอยู่ในรอบถัดไป
+func ClearCreditLines(periodEnd time.Time) (int, error) {
+	var users []models.User
+	if err := database.DB.Select("id").
+		Where("account_mode = ?", models.AccountModeCredit).
+		Find(&users).Error; err != nil {
+		return 0, err
+	}
+
+	note := fmt.Sprintf("เคลียร์ยอดเครดิตรอบถึง %s", periodEnd.In(bangkokTZ).Format("2006-01-02"))
+	cleared, failed := 0, 0
+	for _, u := range users {
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			// อ่านยอดล่าสุดหลังล็อก (อาจมีบิลเคลียร์แทรกระหว่างรอ)
+			var user models.User
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "credit").First(&user, u.ID).Error; err != nil {
+				return err
+			}
+			// ความเคลื่อนไหวหลังสิ้นรอบ (ไม่นับการเคลียร์เอง รันซ้ำแล้วยอด ณ สิ้นรอบเป็น 0 ไม่เคลียร์ซ้ำ)
+			var since models.Money
+			if err := tx.Model(&models.LedgerEntry{}).Select("COALESCE(SUM(ledger_entries.amount), 0)").
+				Joins("JOIN ledger_journals ON ledger_journals.id = ledger_entries.journal_id").
+				Where("ledger_entries.account = ? AND ledger_entries.user_id = ? AND ledger_entries.created_at >= ?", models.LedgerAccountWallet, user.ID, periodEnd).
+				Where("ledger_journals.type <> ?", "credit_clear").
+				Scan(&since).Error; err != nil {
+				return err
+			}
+			balance := user.Credit - since // ยอด ณ สิ้นรอบ
+			if balance == 0 {
+				return nil
+			}
+
+			journal, err := PostWallet(tx, user.ID, -balance, models.LedgerAccountCreditClearing, Journal{
+				Type: "credit_clear",
+				Note: note,
+			})
+			if err != nil {
+				return err
+			}
+			_, err = RecordTransaction(tx, journal, user.ID, models.Transaction{Type: "credit_clear", Note: note})
+			return err
+		})
+		if err != nil {
+			log.Printf("❌ [CreditLine] Clear user %d Error: %v", u.ID, err)
+			failed++
+			continue
+		}
+		cleared++
+	}
+
+	if failed > 0 {
+		return cleared, fmt.Errorf("%d credit lines failed to clear", failed)
+	}
+	return cleared, nil
+}
+
+// RunCreditClear: งานตั้งเวลา ปิดรอบสัปดาห์ที่แล้ว (ถึงจันทร์ 00:00 เวลาไทย ขอบเดียวกับใบแจ้งยอด Agent)
+func RunCreditClear() error {
+	periodEnd, _ := AgentPeriodBounds(time.Now())
+	count, err := ClearCreditLines(periodEnd)
+	log.Printf("✅ [CreditLine] Cleared %d accounts", count)
+	return err
+}
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/PawornpratKongdaeng/soccer/models"
+)
+
+func TestSetCreditLine(t *testing.T) {
+	db := openTestDB(t)
+	admin := createTestUser(t, db, "credit_admin", 0)
+	admin = updateUser(t, db, admin.ID, map[string]interface{}{"role": "admin"})
+	agent := createTestUser(t, db, "credit_agent", 0)
+	agent = updateUser(t, db, agent.ID, map[string]interface{}{"role": "agent", "account_mode": models.AccountModeCredit, "credit_limit": models.NewMoney(1000), "parent_id": admin.ID})
+	sibling := createTestUser(t, db, "credit_sibling", 0)
+	updateUser(t, db, sibling.ID, map[string]interface{}{"parent_id": agent.ID, "account_mode": models.AccountModeCredit, "credit_limit": models.NewMoney(600)})
+	member := createTestUser(t, db, "credit_member", 0)
+	member = updateUser(t, db, member.ID, map[string]interface{}{"parent_id": agent.ID})
+	stranger := createTestUser(t, db, "credit_stranger", 0)
+
+	// สมาชิกที่มียอดค้าง 150 (แทงเกินยอดด้วยวงเงินเดิม)
+	owing := createTestUser(t, db, "credit_owing", 0)
+	updateUser(t, db, owing.ID, map[string]interface{}{"parent_id": agent.ID, "account_mode": models.AccountModeCredit, "credit_limit": models.NewMoney(200)})
+	if _, err := PostJournal(db, Journal{Type: "bet", UseCreditLine: true, Postings: []Posting{
+		WalletPosting(owing.ID, models.NewMoney(-150)), SystemPosting(models.LedgerAccountHouse, models.NewMoney(150)),
+	}}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		setter    models.User
+		memberID  uint
+		mode      string
+		limit     models.Money
+		wantErr   error
+		wantLimit models.Money
+	}{
+		{"invalid mode", agent, member.ID, "prepaid", 0, ErrInvalidAccountMode, 0},
+		{"not a direct downline", agent, stranger.ID, models.AccountModeCredit, models.NewMoney(100), ErrNotDirectDownline, 0},
+		// วงเงินของ agent 1000 แจกไปแล้ว 600 + 200 เหลือ 200
+		{"within upline limit", agent, member.ID, models.AccountModeCredit, models.NewMoney(200), nil, models.NewMoney(200)},
+		{"over upline limit", agent, member.ID, models.AccountModeCredit, models.NewMoney(200.01), ErrCreditLimitExceeded, models.NewMoney(200)},
+		{"below outstanding", agent, owing.ID, models.AccountModeCredit, models.NewMoney(149.99), ErrLimitBelowOutstanding, models.NewMoney(200)},
+		{"cash with outstanding", agent, owing.ID, models.AccountModeCash, 0, ErrLimitBelowOutstanding, models.NewMoney(200)},
+		{"admin is not capped", admin, agent.ID, models.AccountModeCredit, models.NewMoney(1000000), nil, models.NewMoney(1000000)},
+		{"cash clears limit", agent, member.ID, models.AccountModeCash, models.NewMoney(500), nil, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := SetCreditLine(db, tt.setter, tt.memberID, tt.mode, tt.limit)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("SetCreditLine() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.memberID == stranger.ID {
+				return
+			}
+			if u := reloadUser(t, db, tt.memberID); u.CreditLimit != tt.wantLimit {
+				t.Errorf("credit limit = %s, want %s", u.CreditLimit, tt.wantLimit)
+			}
+		})
+	}
+}
+
+func TestClearCreditLines(t *testing.T) {
+	db := openTestDB(t)
+	user := createTestUser(t, db, "credit_clear", 0)
+	updateUser(t, db, user.ID, map[string]interface{}{"account_mode": models.AccountModeCredit, "credit_limit": models.NewMoney(500)})
+	if _, err := PostJournal(db, Journal{Type: "bet", UseCreditLine: true, Postings: []Posting{
+		WalletPosting(user.ID, models.NewMoney(-80)), SystemPosting(models.LedgerAccountHouse, models.NewMoney(80)),
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+
+	steps := []struct {
+		name       string
+		periodEnd  time.Time
+		wantCredit models.Money
+		wantClears int64
+	}{
+		{"activity after period end carries over", now.Add(-time.Hour), models.NewMoney(-80), 0},
+		{"period end after activity clears to zero", now.Add(time.Hour), 0, 1},
+		{"rerun does not clear twice", now.Add(time.Hour), 0, 1},
+	}
+
+	for _, s := range steps {
+		t.Run(s.name, func(t *testing.T) {
+			if _, err := ClearCreditLines(s.periodEnd); err != nil {
+				t.Fatalf("ClearCreditLines() error = %v", err)
+			}
+			if u := reloadUser(t, db, user.ID); u.Credit != s.wantCredit {
+				t.Errorf("credit = %s, want %s", u.Credit, s.wantCredit)
+			}
+			var clears int64
+			db.Model(&models.Transaction{}).Where("user_id = ? AND type = ?", user.ID, "credit_clear").Count(&clears)
+			if clears != s.wantClears {
+				t.Errorf("credit_clear transactions = %d, want %d", clears, s.wantClears)
+			}
+		})
+	}
+}
@@ -1,27 +1,324 @@
 package services
 
 import (
+	"errors"
+	"fmt"
 	"log"
-	// ปรับตาม path ของคุณ
+	"sync"
+	"time"
+
+	"github.com/PawornpratKongdaeng/soccer/database"
+	"github.com/PawornpratKongdaeng/soccer/models"
+	"github.com/gofiber/fiber/v2"
 	"github.com/robfig/cron/v3"
 )
 
-func InitCron() {
-	// สร้าง Cron instance ใหม่
-	c := cron.New()
+// JobFunc: งานที่ลงทะเบียนไว้ รับค่าตั้งล่าสุดของงาน (เช่น feed path) และคืน error เพื่อเก็บเป็น last error
+type JobFunc func(cfg models.JobConfig) error
+
+// Job: งาน 1 ตัวใน Registry พร้อมสถานะการรันล่าสุด
+type Job struct {
+	Name        string
+	Description string
+	Run         JobFunc
+
+	config    models.JobConfig
+	entryID   cron.EntryID
+	running   bool
+	lastRun   *time.Time
+	lastError string
+	lastTook  time.Duration
+}
+
+// JobStatus: ข้อมูลงานที่ส่งให้หน้า Admin
+type JobStatus struct {
+	Name         string     `json:"name"`
+	Description  string     `json:"description"`
+	Schedule     string     `json:"schedule"`
+	FeedPath     string     `json:"feed_path"`
+	Paused       bool       `json:"paused"`
+	Running      bool       `json:"running"`
+	LastRun      *time.Time `json:"last_run"`
+	LastDuration string     `json:"last_duration"`
+	LastError    string     `json:"last_error"`
+	NextRun      *time.Time `json:"next_run"`
+}
+
+// JobRegistry: รวมงาน Cron ทั้งหมดไว้ที่เดียว ตั้งเวลา/หยุด/สั่งรันได้ตอนระบบทำงาน
+type JobRegistry struct {
+	mu    sync.Mutex
+	cron  *cron.Cron
+	jobs  map[string]*Job
+	order []string
+}
+
+var (
+	ErrJobNotFound = errors.New("job not found")
+	ErrJobRunning  = errors.New("job is already running")
+)
+
+// Jobs: Registry กลางของระบบ (main.go เป็นคนลงทะเบียนงานและสั่ง Start)
+var Jobs = NewJobRegistry()
+
+func NewJobRegistry() *JobRegistry {
+	return &JobRegistry{
+		cron: cron.New(cron.WithChain(cron.Recover(cron.DefaultLogger))),
+		jobs: make(map[string]*Job),
+	}
+}
+
+// Register: ลงทะเบียนงาน พร้อมค่าเริ่มต้น (ถ้าใน DB มีค่าตั้งอยู่แล้ว จะใช้ค่าใน DB ตอน Start)
+func (r *JobRegistry) Register(name, description, schedule, feedPath string, run JobFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.jobs[name] = &Job{
+		Name:        name,
+		Description: description,
+		Run:         run,
+		config:      models.JobConfig{Name: name, Schedule: schedule, FeedPath: feedPath},
+	}
+	r.order = append(r.order, name)
+}
+
+// Start: โหลดค่าตั้งจาก DB (สร้างค่าเริ่มต้นถ้ายังไม่มี) แล้วเริ่มตั้งเวลาทุกงาน
+func (r *JobRegistry) Start() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, name := range r.order {
+		job := r.jobs[name]
+
+		var cfg models.JobConfig
+		if err := database.DB.Where("name = ?", name).Attrs(job.config).FirstOrCreate(&cfg).Error; err != nil {
+			return fmt.Errorf("load job %s: %w", name, err)
+		}
+		job.config = cfg
+
+		if err := r.schedule(job); err != nil {
+			return fmt.Errorf("schedule job %s: %w", name, err)
+		}
+	}
+
+	r.cron.Start()
+	return nil
+}
+
+// schedule: ลบเวลาเดิมแล้วตั้งใหม่ตาม config (ต้องถือ r.mu อยู่)
+func (r *JobRegistry) schedule(job *Job) error {
+	if job.entryID != 0 {
+		r.cron.Remove(job.entryID)
+		job.entryID = 0
+	}
+	if job.config.Paused {
+		return nil
+	}
 
-	// 🕒 ตั้งค่าให้รัน AutoSettlement ทุกๆ 5 นาที
-	// Format: "*/5 * * * *" (นาที ชั่วโมง วัน เดือน วันในสัปดาห์)
-	_, err := c.AddFunc("*/5 * * * *", func() {
-		log.Println("⏰ [Cron] Starting AutoSettlement task...")
-		AutoSettlement()
+	name := job.Name
+	id, err := r.cron.AddFunc(job.config.Schedule, func() {
+		log.Printf("⏰ [Cron] Task: %s running...", name)
+		r.execute(name)
 	})
+	if err != nil {
+		return err
+	}
+	job.entryID = id
+	return nil
+}
+
+// claim: จองสถานะกำลังรันของงาน (ต้องถือ r.mu อยู่) false = รอบก่อนยังไม่จบ
+func (r *JobRegistry) claim(job *Job) (models.JobConfig, bool) {
+	if job.running {
+		return models.JobConfig{}, false
+	}
+	job.running = true
+	return job.config, true
+}
+
+// execute: รันงาน 1 รอบตามเวลา Cron (ข้ามถ้ารอบก่อนยังไม่จบ)
+func (r *JobRegistry) execute(name string) {
+	r.mu.Lock()
+	job, ok := r.jobs[name]
+	var cfg models.JobConfig
+	if ok {
+		cfg, ok = r.claim(job)
+	}
+	r.mu.Unlock()
+	if ok {
+		r.run(job, cfg)
+	}
+}
+
+// run: รันงานที่จองไว้แล้ว (claim) แล้วเก็บเวลา/ผลลัพธ์
+func (r *JobRegistry) run(job *Job, cfg models.JobConfig) {
+	started := time.Now()
+	err := func() (err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				err = fmt.Errorf("panic: %v", p)
+			}
+		}()
+		return job.Run(cfg)
+	}()
 
+	r.mu.Lock()
+	job.running = false
+	job.lastRun = &started
+	job.lastTook = time.Since(started)
+	job.lastError = ""
 	if err != nil {
-		log.Fatalf("❌ [Cron] Error scheduling task: %v", err)
+		job.lastError = err.Error()
 	}
+	took := job.lastTook
+	r.mu.Unlock()
+
+	if err != nil {
+		log.Printf("❌ [Cron] %s Error: %v", job.Name, err)
+	} else {
+		log.Printf("✅ [Cron] %s Completed (%s)", job.Name, took.Round(time.Millisecond))
+	}
+}
+
+// Trigger: สั่งรันงานทันที (Background) ไม่ว่างานจะถูก Pause อยู่หรือไม่
+// จองสถานะกำลังรันก่อนปล่อย lock ตอบ nil = งานเริ่มรันแน่นอน
+func (r *JobRegistry) Trigger(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 
-	// เริ่มรัน Cron ใน Background
-	c.Start()
-	log.Println("🚀 [Cron] Scheduler started (running every 5 minutes)")
+	job, ok := r.jobs[name]
+	if !ok {
+		return ErrJobNotFound
+	}
+	cfg, ok := r.claim(job)
+	if !ok {
+		return ErrJobRunning
+	}
+	go r.run(job, cfg)
+	return nil
+}
+
+// JobUpdate: ค่าที่แอดมินส่งมาแก้ (nil = ไม่แก้)
+type JobUpdate struct {
+	Schedule *string `json:"schedule"`
+	Paused   *bool   `json:"paused"`
+	FeedPath *string `json:"feed_path"`
+}
+
+// Update: แก้เวลา/หยุด/feed path ของงาน บันทึกลง DB แล้วตั้งเวลาใหม่ทันที
+func (r *JobRegistry) Update(name string, req JobUpdate) (JobStatus, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	job, ok := r.jobs[name]
+	if !ok {
+		return JobStatus{}, ErrJobNotFound
+	}
+
+	cfg := job.config
+	if req.Schedule != nil {
+		if _, err := cron.ParseStandard(*req.Schedule); err != nil {
+			return JobStatus{}, fmt.Errorf("invalid schedule: %w", err)
+		}
+		cfg.Schedule = *req.Schedule
+	}
+	if req.Paused != nil {
+		cfg.Paused = *req.Paused
+	}
+	if req.FeedPath != nil {
+		cfg.FeedPath = *req.FeedPath
+	}
+
+	if err := database.DB.Save(&cfg).Error; err != nil {
+		return JobStatus{}, err
+	}
+	job.config = cfg
+
+	if err := r.schedule(job); err != nil {
+		return JobStatus{}, err
+	}
+	return r.status(job), nil
+}
+
+// FeedPath: feed path ปัจจุบันของงาน (ใช้ตอนเรียก API นอกรอบ Cron เช่น Preview)
+func (r *JobRegistry) FeedPath(name string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if job, ok := r.jobs[name]; ok && job.config.FeedPath != "" {
+		return job.config.FeedPath
+	}
+	return "moung"
+}
+
+// status: สร้าง JobStatus (ต้องถือ r.mu อยู่)
+func (r *JobRegistry) status(job *Job) JobStatus {
+	st := JobStatus{
+		Name:        job.Name,
+		Description: job.Description,
+		Schedule:    job.config.Schedule,
+		FeedPath:    job.config.FeedPath,
+		Paused:      job.config.Paused,
+		Running:     job.running,
+		LastRun:     job.lastRun,
+		LastError:   job.lastError,
+	}
+	if job.lastRun != nil {
+		st.LastDuration = job.lastTook.Round(time.Millisecond).String()
+	}
+	if job.entryID != 0 {
+		if next := r.cron.Entry(job.entryID).Next; !next.IsZero() {
+			st.NextRun = &next
+		}
+	}
+	return st
+}
+
+// Statuses: สถานะของทุกงานเรียงตามลำดับที่ลงทะเบียน
+func (r *JobRegistry) Statuses() []JobStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	list := make([]JobStatus, 0, len(r.order))
+	for _, name := range r.order {
+		list = append(list, r.status(r.jobs[name]))
+	}
+	return list
+}
+
+// ==========================================
+// Admin API
+// ==========================================
+
+// GET /admin/jobs
+func GetJobs(c *fiber.Ctx) error {
+	return c.JSON(Jobs.Statuses())
+}
+
+// PATCH /admin/jobs/:name {"schedule": "*/2 * * * *", "paused": false, "feed_path": "moung"}
+func UpdateJob(c *fiber.Ctx) error {
+	var req JobUpdate
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "ข้อมูลไม่ถูกต้อง"})
+	}
+
+	st, err := Jobs.Update(c.Params("name"), req)
+	if errors.Is(err, ErrJobNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": "ไม่พบงานนี้"})
+	}
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"message": "อัปเดตงานเรียบร้อย", "job": st})
+}
+
+// POST /admin/jobs/:name/run
+func RunJobNow(c *fiber.Ctx) error {
+	err := Jobs.Trigger(c.Params("name"))
+	if errors.Is(err, ErrJobNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": "ไม่พบงานนี้"})
+	}
+	if errors.Is(err, ErrJobRunning) {
+		return c.Status(429).JSON(fiber.Map{"message": "งานนี้กำลังทำงานอยู่..."})
+	}
+	return c.JSON(fiber.Map{"message": "สั่งรันงานแล้ว"})
 }
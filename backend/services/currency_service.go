@@ -0,0 +1,279 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/PawornpratKongdaeng/soccer/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ==========================================
+// สกุลเงิน (Multi-currency Wallets)
+// ==========================================
+// กระเป๋าของสมาชิกมีสกุลเดียว (users.currency) และสายงานเดียวกันต้องใช้สกุลเดียวกัน (ลูกสายได้สกุลของต้นสาย)
+// รายการบัญชี 1 รายการห้ามมีกระเป๋าต่างสกุล (PostJournal เช็คให้) ยอดของบัญชีระบบจึงแยกสกุลได้จาก ledger_journals.currency
+// ขั้นต่ำ/เพดานแยกตามสกุลใน currency_limits (ยังไม่ตั้ง = ใช้ defaultCurrencyLimits)
+// รายงานที่รวมหลายสกุลแปลงเป็นสกุลหลัก (models.BaseCurrency) ด้วยเรทที่มีผล ณ เวลาของรายการ (RateBook)
+
+var (
+	ErrUnsupportedCurrency  = errors.New("unsupported currency")
+	ErrCurrencyMismatch     = errors.New("wallet currencies do not match")
+	ErrCurrencyInUse        = errors.New("wallet currency cannot be changed while in use")
+	ErrInvalidCurrencyLimit = errors.New("invalid currency limit")
+	ErrInvalidExchangeRate  = errors.New("invalid exchange rate")
+	ErrNoExchangeRate       = errors.New("no exchange rate")
+)
+
+// defaultCurrencyLimits: ค่าเริ่มต้นก่อน Admin ตั้งเอง (THB ตามค่าเดิมของระบบ)
+var defaultCurrencyLimits = map[string]models.CurrencyLimit{
+	models.CurrencyTHB: {
+		MinDeposit:           models.NewMoney(100),
+		MinWithdraw:          models.NewMoney(100),
+		MinBet:               models.NewMoney(50),
+		MaxBet:               models.NewMoney(50000),
+		MaxPayout:            models.NewMoney(200000),
+		MaxReferralReward:    models.NewMoney(500),
+		WithdrawDualApproval: models.NewMoney(50000),
+		MaxWithdrawDaily:     models.NewMoney(200000),
+		MaxWithdrawCount:     5,
+	},
+	models.CurrencyMMK: {
+		MinDeposit:           models.NewMoney(5000),
+		MinWithdraw:          models.NewMoney(5000),
+		MinBet:               models.NewMoney(1000),
+		MaxBet:               models.NewMoney(1500000),
+		MaxPayout:            models.NewMoney(6000000),
+		MaxReferralReward:    models.NewMoney(25000),
+		WithdrawDualApproval: models.NewMoney(3000000),
+		MaxWithdrawDaily:     models.NewMoney(12000000),
+		MaxWithdrawCount:     5,
+	},
+}
+
+// CurrencyLimitFor: ขั้นต่ำ/เพดานของสกุล currency
+func CurrencyLimitFor(db *gorm.DB, currency string) (models.CurrencyLimit, error) {
+	if !models.IsSupportedCurrency(currency) {
+		return models.CurrencyLimit{}, fmt.Errorf("%w: %s", ErrUnsupportedCurrency, currency)
+	}
+	var limit models.CurrencyLimit
+	err := db.Where("currency = ?", currency).First(&limit).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		limit = defaultCurrencyLimits[currency]
+		limit.Currency = currency
+		return limit, nil
+	}
+	return limit, err
+}
+
+// CurrencyLimits: ขั้นต่ำ/เพดานของทุกสกุลที่รองรับ
+func CurrencyLimits(db *gorm.DB) ([]models.CurrencyLimit, error) {
+	limits := make([]models.CurrencyLimit, 0, len(models.SupportedCurrencies))
+	for _, code := range models.SupportedCurrencies {
+		limit, err := CurrencyLimitFor(db, code)
+		if err != nil {
+			return nil, err
+		}
+		limits = append(limits, limit)
+	}
+	return limits, nil
+}
+
+// SaveCurrencyLimit: บันทึกขั้นต่ำ/เพดานของสกุล (ไม่มีแถว = สร้างใหม่)
+func SaveCurrencyLimit(db *gorm.DB, limit models.CurrencyLimit) (*models.CurrencyLimit, error) {
+	if !models.IsSupportedCurrency(limit.Currency) {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedCurrency, limit.Currency)
+	}
+	if limit.MinDeposit < 0 || limit.MinWithdraw < 0 || limit.MinBet < 0 || limit.MaxBet <= 0 || limit.MaxPayout <= 0 ||
+		limit.MaxReferralReward < 0 || limit.WithdrawDualApproval < 0 || limit.AdminDailyApproval < 0 ||
+		limit.MaxWithdrawDaily < 0 || limit.MaxWithdrawCount < 0 || limit.MinBet > limit.MaxBet {
+		return nil, ErrInvalidCurrencyLimit
+	}
+	limit.UpdatedAt = time.Now()
+	if err := db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&limit).Error; err != nil {
+		return nil, err
+	}
+	return &limit, nil
+}
+
+// SetExchangeRate: บันทึกเรทใหม่ของ currency (เรทเดิมยังอยู่ ใช้กับรายการก่อน effectiveAt)
+func SetExchangeRate(db *gorm.DB, currency string, rate float64, effectiveAt time.Time, note string, createdBy *uint) (*models.ExchangeRate, error) {
+	if !models.IsSupportedCurrency(currency) || currency == models.BaseCurrency {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedCurrency, currency)
+	}
+	if rate <= 0 {
+		return nil, ErrInvalidExchangeRate
+	}
+	if effectiveAt.IsZero() {
+		effectiveAt = time.Now()
+	}
+	r := models.ExchangeRate{
+		Currency:    currency,
+		Rate:        rate,
+		EffectiveAt: effectiveAt,
+		Note:        note,
+		CreatedBy:   createdBy,
+	}
+	if err := db.Create(&r).Error; err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// RateBook: เรททั้งหมดที่โหลดไว้สำหรับแปลงยอดในรายงาน (โหลดครั้งเดียว ใช้ได้ทั้งรายงาน)
+type RateBook struct {
+	rates map[string][]models.ExchangeRate // เรียงตาม EffectiveAt จากเก่าไปใหม่
+}
+
+// LoadRateBook: โหลดประวัติเรทของทุกสกุล
+func LoadRateBook(db *gorm.DB) (*RateBook, error) {
+	var rates []models.ExchangeRate
+	if err := db.Where("currency <> ?", models.BaseCurrency).
+		Order("currency, effective_at, id").Find(&rates).Error; err != nil {
+		return nil, err
+	}
+	book := &RateBook{rates: make(map[string][]models.ExchangeRate)}
+	for _, r := range rates {
+		book.rates[r.Currency] = append(book.rates[r.Currency], r)
+	}
+	return book, nil
+}
+
+// RateAt: เรทของ currency ที่มีผล ณ เวลา at (สกุลหลัก = 1)
+func (b *RateBook) RateAt(currency string, at time.Time) (float64, error) {
+	if currency == models.BaseCurrency || currency == "" {
+		return 1, nil
+	}
+	history := b.rates[currency]
+	i := sort.Search(len(history), func(i int) bool { return history[i].EffectiveAt.After(at) })
+	if i == 0 {
+		return 0, fmt.Errorf("%w: %s at %s", ErrNoExchangeRate, currency, at.Format(time.RFC3339))
+	}
+	return history[i-1].Rate, nil
+}
+
+// ToBase: แปลงยอดสกุล currency เป็นสกุลหลักด้วยเรท ณ เวลา at
+func (b *RateBook) ToBase(amount models.Money, currency string, at time.Time) (models.Money, error) {
+	rate, err := b.RateAt(currency, at)
+	if err != nil {
+		return 0, err
+	}
+	return amount.Mul(rate), nil
+}
+
+// Latest: เรทล่าสุดของทุกสกุล (ใช้แสดงหน้าตั้งค่า)
+func (b *RateBook) Latest() map[string]models.ExchangeRate {
+	latest := make(map[string]models.ExchangeRate, len(b.rates))
+	now := time.Now()
+	for code, history := range b.rates {
+		i := sort.Search(len(history), func(i int) bool { return history[i].EffectiveAt.After(now) })
+		if i > 0 {
+			latest[code] = history[i-1]
+		}
+	}
+	return latest
+}
+
+// NewMemberCurrency: สกุลของสมาชิกใหม่ที่ creator สร้าง
+// Admin เลือกได้ (ไม่ระบุ = สกุลหลัก) ส่วน Master/Agent ลูกสายต้องใช้สกุลเดียวกับตัวเอง
+func NewMemberCurrency(creator models.User, requested string) (string, error) {
+	if creator.Role != "admin" {
+		if requested != "" && requested != creator.Currency {
+			return "", ErrCurrencyMismatch
+		}
+		requested = creator.Currency
+	}
+	if requested == "" {
+		requested = models.BaseCurrency
+	}
+	if !models.IsSupportedCurrency(requested) {
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedCurrency, requested)
+	}
+	return requested, nil
+}
+
+// SetUserCurrency: เปลี่ยนสกุลกระเป๋าของ userID พร้อมลูกสายทุกชั้น (สายงานต้องสกุลเดียวกัน)
+// ไม่มีการแปลงยอด ทุกกระเป๋าในสายต้องไม่มียอดเงิน โบนัส วงเงิน บิลค้าง หรือแจ้งฝากที่รออนุมัติ (ถ้ามีต้องถอน/ดึงคืนให้หมดก่อน)
+func SetUserCurrency(db *gorm.DB, userID uint, currency string) (*models.User, error) {
+	if !models.IsSupportedCurrency(currency) {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedCurrency, currency)
+	}
+
+	var user models.User
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&user, userID).Error; err != nil {
+			return err
+		}
+		if user.Currency == currency {
+			return nil
+		}
+		if user.ParentID != nil {
+			var parent models.User
+			if err := tx.Select("id", "role", "currency").First(&parent, *user.ParentID).Error; err != nil {
+				return err
+			}
+			if parent.Role != "admin" && parent.Currency != currency {
+				return ErrCurrencyMismatch
+			}
+		}
+
+		ids, err := DownlineIDs(tx, userID)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, userID)
+
+		// ล็อกกระเป๋าทั้งสายเรียงตาม ID แบบเดียวกับ PostJournal
+		var wallets []models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "credit", "held_credit", "bonus_credit", "credit_limit").Where("id IN ?", ids).Order("id").Find(&wallets).Error; err != nil {
+			return err
+		}
+		for _, w := range wallets {
+			if w.Credit != 0 || w.HeldCredit != 0 || w.BonusCredit != 0 || w.CreditLimit != 0 {
+				return fmt.Errorf("%w: wallet of user %d is not empty", ErrCurrencyInUse, w.ID)
+			}
+		}
+
+		var pending int64
+		if err := tx.Model(&models.BetSlip{}).Where("user_id IN ? AND status = ?", ids, "pending").Count(&pending).Error; err != nil {
+			return err
+		}
+		if pending == 0 {
+			if err := tx.Model(&models.ParlayTicket{}).Where("user_id IN ? AND status = ?", ids, "pending").Count(&pending).Error; err != nil {
+				return err
+			}
+		}
+		if pending > 0 {
+			return fmt.Errorf("%w: pending bets", ErrCurrencyInUse)
+		}
+		// ยอดฝากที่แจ้งไว้เป็นสกุลเดิม อนุมัติหลังเปลี่ยนสกุลจะกลายเป็นตัวเลขเดียวกันในสกุลใหม่
+		if err := tx.Model(&models.Transaction{}).Where("user_id IN ? AND type = ? AND status = ?", ids, "deposit", "pending").Count(&pending).Error; err != nil {
+			return err
+		}
+		if pending > 0 {
+			return fmt.Errorf("%w: pending deposits", ErrCurrencyInUse)
+		}
+
+		user.Currency = currency
+		return tx.Model(&models.User{}).Where("id IN ?", ids).Update("currency", currency).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// BangkokDaySQL: วันที่ตามเวลาไทยของคอลัมน์ col (ใช้ GROUP BY รายงานที่จะแปลงสกุลด้วย DayToBase)
+func BangkokDaySQL(col string) string {
+	return "DATE(" + col + " AT TIME ZONE 'Asia/Bangkok')"
+}
+
+// DayToBase: แปลงยอดรวมของวัน day เป็นสกุลหลัก ด้วยเรทที่มีผลตอนสิ้นวัน (เวลาไทย)
+func (b *RateBook) DayToBase(amount models.Money, currency string, day time.Time) (models.Money, error) {
+	end := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, bangkokTZ).AddDate(0, 0, 1)
+	return b.ToBase(amount, currency, end.Add(-time.Nanosecond))
+}
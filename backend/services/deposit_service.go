@@ -0,0 +1,37 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/PawornpratKongdaeng/soccer/models"
+	"gorm.io/gorm"
+)
+
+// ApproveDeposit: อนุมัติรายการฝาก pending (เติมเครดิต + โบนัสโปรที่เลือก + รางวัลแนะนำเพื่อน)
+// ผู้เรียกต้องล็อกแถว Transaction และตรวจสลิปซ้ำ (CheckSlipApproval) ก่อน ใช้ทั้งหน้า Admin และการจับคู่ Statement
+func ApproveDeposit(tx *gorm.DB, t *models.Transaction, adminID *uint) (*models.BonusGrant, error) {
+	if err := PostDeposit(tx, t, adminID); err != nil {
+		return nil, err
+	}
+	// โปรที่สมาชิกเลือกตอนแจ้งฝาก: ไม่ตรงเงื่อนไขแล้วก็อนุมัติยอดฝากตามปกติ (ไม่ได้โบนัส)
+	grant, err := GrantDepositBonus(tx, t, adminID)
+	switch {
+	case errors.Is(err, ErrPromotionNotEligible), errors.Is(err, ErrBonusActive), errors.Is(err, ErrPromotionNotFound):
+		log.Printf("⚠️ [Promotion] deposit #%d no bonus: %v", t.ID, err)
+		t.Note = strings.TrimSpace(t.Note + " ไม่ได้รับโบนัส: " + err.Error())
+	case err != nil:
+		return nil, err
+	}
+	// สมัครผ่านรหัสแนะนำ: ยอดฝากแรกจ่ายรางวัลให้ผู้แนะนำ
+	if _, err := RewardReferral(tx, t, adminID); err != nil {
+		return nil, err
+	}
+
+	t.Status = "approved"
+	if err := tx.Model(t).Updates(map[string]interface{}{"status": t.Status, "note": t.Note}).Error; err != nil {
+		return nil, err
+	}
+	return grant, nil
+}
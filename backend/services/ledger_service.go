@@ -0,0 +1,466 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/PawornpratKongdaeng/soccer/database"
+	"github.com/PawornpratKongdaeng/soccer/models"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ==========================================
+// สมุดบัญชีคู่ (Double-entry Ledger)
+// ==========================================
+// ทุกการเปลี่ยนแปลงเครดิตต้องผ่าน PostJournal เท่านั้น (ห้าม UPDATE users.credit เอง)
+// users.credit เป็นแค่ยอดสรุปของบัญชี wallet ซึ่งรวมใหม่จาก ledger_entries ได้เสมอ
+// (models.User.Credit ตั้งเป็น <-:create ไว้ ทำให้ Save/Updates ของ GORM เขียนทับยอดไม่ได้)
+// ทุกรายการลงบัญชีอย่างน้อย 2 ฝั่ง และยอดรวมต้องเป็น 0 (เงินเข้ากระเป๋าใคร ต้องออกจากบัญชีอื่น)
+
+var (
+	ErrInsufficientCredit = errors.New("insufficient credit")
+	ErrUnbalancedJournal  = errors.New("journal is not balanced")
+	ErrInsufficientHeld   = errors.New("insufficient held balance")
+	ErrInsufficientBonus  = errors.New("insufficient bonus balance")
+)
+
+// Posting: 1 บรรทัดที่จะลงบัญชี (Amount บวก = เพิ่มยอดบัญชี)
+type Posting struct {
+	Account string
+	UserID  *uint
+	Amount  models.Money
+}
+
+// Journal: รายการที่จะลงบัญชี 1 ครั้ง
+// UseCreditLine = ให้กระเป๋าของบัญชีเครดิตติดลบได้ถึงวงเงิน (ใช้กับการแทงเท่านั้น ถอน/โอนต้องมียอดจริง)
+type Journal struct {
+	Type          string
+	RefType       string
+	RefID         uint
+	Note          string
+	CreatedBy     *uint
+	UseCreditLine bool
+	Postings      []Posting
+}
+
+// WalletPosting: บรรทัดของกระเป๋าเครดิตสมาชิก
+func WalletPosting(userID uint, amount models.Money) Posting {
+	return Posting{Account: models.LedgerAccountWallet, UserID: &userID, Amount: amount}
+}
+
+// HeldPosting: บรรทัดของยอดพักรอถอนของสมาชิก
+func HeldPosting(userID uint, amount models.Money) Posting {
+	return Posting{Account: models.LedgerAccountWalletHeld, UserID: &userID, Amount: amount}
+}
+
+// BonusPosting: บรรทัดของกระเป๋าโบนัสของสมาชิก
+func BonusPosting(userID uint, amount models.Money) Posting {
+	return Posting{Account: models.LedgerAccountBonus, UserID: &userID, Amount: amount}
+}
+
+// isUserAccount: บัญชีที่เป็นของสมาชิก (ต้องมี UserID และมียอดสรุปใน users)
+func isUserAccount(account string) bool {
+	return account == models.LedgerAccountWallet || account == models.LedgerAccountWalletHeld || account == models.LedgerAccountBonus
+}
+
+// SystemPosting: บรรทัดของบัญชีระบบ (house, cash, commission, ...)
+func SystemPosting(account string, amount models.Money) Posting {
+	return Posting{Account: account, Amount: amount}
+}
+
+// PostJournal: ลงบัญชี 1 รายการ (ต้องเรียกภายใน DB Transaction)
+// ล็อกกระเป๋าของทุกคนในรายการ เช็คยอดไม่ให้ติดลบ บันทึก Entry พร้อมยอดก่อน/หลัง แล้วอัปเดต users.credit / held_credit / bonus_credit
+func PostJournal(tx *gorm.DB, j Journal) (*models.LedgerJournal, error) {
+	// 1. ตรวจว่ารายการสมดุล
+	var total models.Money
+	var userIDs []uint
+	seen := make(map[uint]bool)
+	for _, p := range j.Postings {
+		if p.Amount == 0 {
+			return nil, fmt.Errorf("ledger: zero amount on %s", p.Account)
+		}
+		if isUserAccount(p.Account) {
+			if p.UserID == nil {
+				return nil, fmt.Errorf("ledger: %s posting without user", p.Account)
+			}
+			if !seen[*p.UserID] {
+				seen[*p.UserID] = true
+				userIDs = append(userIDs, *p.UserID)
+			}
+		}
+		total += p.Amount
+	}
+	if len(j.Postings) < 2 || total != 0 {
+		return nil, ErrUnbalancedJournal
+	}
+
+	// 2. ล็อกกระเป๋าเรียงตาม ID (กัน Deadlock เวลาโอนสลับกัน)
+	sort.Slice(userIDs, func(a, b int) bool { return userIDs[a] < userIDs[b] })
+	balances := make(map[uint]models.Money, len(userIDs))
+	held := make(map[uint]models.Money, len(userIDs))
+	bonus := make(map[uint]models.Money, len(userIDs))
+	floors := make(map[uint]models.Money, len(userIDs)) // ยอดต่ำสุดที่ยอมให้ติดลบได้
+	currency := models.BaseCurrency                     // รายการที่มีแต่บัญชีระบบถือเป็นสกุลหลัก
+	if len(userIDs) > 0 {
+		var users []models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "credit", "held_credit", "bonus_credit", "account_mode", "credit_limit", "currency").Where("id IN ?", userIDs).Order("id").Find(&users).Error; err != nil {
+			return nil, err
+		}
+		if len(users) != len(userIDs) {
+			return nil, fmt.Errorf("ledger: wallet owner not found")
+		}
+		currency = users[0].Currency
+		for _, u := range users {
+			// กระเป๋าต่างสกุลอยู่ในรายการเดียวกันไม่ได้ (ยอดรวมเป็น 0 จะไม่มีความหมาย)
+			if u.Currency != currency {
+				return nil, ErrCurrencyMismatch
+			}
+			balances[u.ID] = u.Credit
+			held[u.ID] = u.HeldCredit
+			bonus[u.ID] = u.BonusCredit
+			if j.UseCreditLine && u.AccountMode == models.AccountModeCredit {
+				floors[u.ID] = -u.CreditLimit
+			}
+		}
+	}
+
+	// 3. สร้างรายการและ Entry
+	journal := models.LedgerJournal{
+		Type:      j.Type,
+		RefType:   j.RefType,
+		RefID:     j.RefID,
+		Note:      j.Note,
+		Currency:  currency,
+		CreatedBy: j.CreatedBy,
+	}
+	if err := tx.Create(&journal).Error; err != nil {
+		return nil, err
+	}
+
+	entries := make([]models.LedgerEntry, 0, len(j.Postings))
+	for _, p := range j.Postings {
+		entry := models.LedgerEntry{
+			JournalID: journal.ID,
+			Account:   p.Account,
+			UserID:    p.UserID,
+			Amount:    p.Amount,
+		}
+		switch p.Account {
+		case models.LedgerAccountWallet:
+			before := balances[*p.UserID]
+			after := before + entry.Amount
+			if entry.Amount < 0 && after < floors[*p.UserID] {
+				return nil, ErrInsufficientCredit
+			}
+			entry.BalanceBefore = &before
+			entry.BalanceAfter = &after
+			balances[*p.UserID] = after
+		case models.LedgerAccountWalletHeld:
+			before := held[*p.UserID]
+			after := before + entry.Amount
+			if after < 0 {
+				return nil, ErrInsufficientHeld
+			}
+			entry.BalanceBefore = &before
+			entry.BalanceAfter = &after
+			held[*p.UserID] = after
+		case models.LedgerAccountBonus:
+			before := bonus[*p.UserID]
+			after := before + entry.Amount
+			if after < 0 {
+				return nil, ErrInsufficientBonus
+			}
+			entry.BalanceBefore = &before
+			entry.BalanceAfter = &after
+			bonus[*p.UserID] = after
+		}
+		entries = append(entries, entry)
+	}
+	if err := tx.Create(&entries).Error; err != nil {
+		return nil, err
+	}
+
+	// 4. อัปเดตยอดสรุปใน users
+	for _, id := range userIDs {
+		if err := tx.Exec("UPDATE users SET credit = ?, held_credit = ?, bonus_credit = ? WHERE id = ?", balances[id], held[id], bonus[id], id).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	journal.Entries = entries
+	return &journal, nil
+}
+
+// PostWallet: ลงบัญชีระหว่างกระเป๋าสมาชิก 1 ใบกับบัญชีระบบ 1 บัญชี (amount บวก = เพิ่มเครดิตให้สมาชิก)
+func PostWallet(tx *gorm.DB, userID uint, amount models.Money, counterAccount string, j Journal) (*models.LedgerJournal, error) {
+	j.Postings = []Posting{
+		WalletPosting(userID, amount),
+		SystemPosting(counterAccount, -amount),
+	}
+	return PostJournal(tx, j)
+}
+
+// PostTransfer: โอนเครดิตระหว่างกระเป๋าสมาชิก 2 ใบ
+func PostTransfer(tx *gorm.DB, fromUserID, toUserID uint, amount models.Money, j Journal) (*models.LedgerJournal, error) {
+	j.Postings = []Posting{
+		WalletPosting(fromUserID, -amount),
+		WalletPosting(toUserID, amount),
+	}
+	return PostJournal(tx, j)
+}
+
+// WalletChange: ยอดก่อน/หลังของกระเป๋า userID ในรายการนี้ (ใช้เติม BalanceBefore/After ของ Transaction)
+func WalletChange(journal *models.LedgerJournal, userID uint) (before, after models.Money) {
+	found := false
+	for _, e := range journal.Entries {
+		if e.Account != models.LedgerAccountWallet || e.UserID == nil || *e.UserID != userID {
+			continue
+		}
+		if !found {
+			before = *e.BalanceBefore
+			found = true
+		}
+		after = *e.BalanceAfter
+	}
+	return before, after
+}
+
+// hasWalletEntry: รายการนี้มีบรรทัดกระเป๋าหลักของ userID หรือไม่
+func hasWalletEntry(journal *models.LedgerJournal, userID uint) bool {
+	for _, e := range journal.Entries {
+		if e.Account == models.LedgerAccountWallet && e.UserID != nil && *e.UserID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// RecordTransaction: สร้าง Transaction (ประวัติที่สมาชิกเห็น) ของกระเป๋า userID จากรายการใน Ledger
+// เติม Amount, ยอดก่อน/หลัง และ JournalID ให้เอง ส่วน Type/Status/Note/AdminID มาจาก t
+// ทุกรายการที่ทำให้เครดิตเปลี่ยนต้องมี Transaction คู่กันเสมอ (ใช้ตรวจในงานกระทบยอด)
+// ถ้ากระเป๋าหลักไม่เปลี่ยนในรายการนี้ (เช่น แทงด้วยโบนัสล้วน) Amount = 0 และใช้ยอดก่อน/หลังที่ส่งมาใน t
+func RecordTransaction(tx *gorm.DB, journal *models.LedgerJournal, userID uint, t models.Transaction) (*models.Transaction, error) {
+	t.UserID = userID
+	t.Amount = 0
+	if hasWalletEntry(journal, userID) {
+		before, after := WalletChange(journal, userID)
+		t.Amount = (after - before).Abs()
+		t.BalanceBefore = before
+		t.BalanceAfter = after
+	}
+	t.JournalID = &journal.ID
+	if t.Status == "" {
+		t.Status = "success"
+	}
+	if err := tx.Create(&t).Error; err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// RecordTransferSide: บันทึก Transaction ฝั่งผู้โอน/ผู้รับอีกฝั่งของการโอนเครดิต (transfer_in / transfer_out ตามทิศของยอด)
+func RecordTransferSide(tx *gorm.DB, journal *models.LedgerJournal, userID uint, adminID *uint, note string) error {
+	before, after := WalletChange(journal, userID)
+	txType := "transfer_in"
+	if after < before {
+		txType = "transfer_out"
+	}
+	_, err := RecordTransaction(tx, journal, userID, models.Transaction{AdminID: adminID, Type: txType, Note: note})
+	return err
+}
+
+// ==========================================
+// รายการฝาก/ถอน (ผูกกับ models.Transaction)
+// ==========================================
+// ทุกฟังก์ชันเติม BalanceBefore/After และ JournalID ของ Transaction จากยอดใน Ledger แล้วบันทึกลง DB ให้ด้วย
+
+func postTransaction(tx *gorm.DB, t *models.Transaction, journalType string, createdBy *uint, postings ...Posting) error {
+	journal, err := PostJournal(tx, Journal{
+		Type:      journalType,
+		RefType:   "transaction",
+		RefID:     t.ID,
+		Note:      t.Note,
+		CreatedBy: createdBy,
+		Postings:  postings,
+	})
+	if err != nil {
+		return err
+	}
+
+	t.BalanceBefore, t.BalanceAfter = WalletChange(journal, t.UserID)
+	t.JournalID = &journal.ID
+	return tx.Model(t).Updates(map[string]interface{}{
+		"balance_before": t.BalanceBefore,
+		"balance_after":  t.BalanceAfter,
+		"journal_id":     journal.ID,
+	}).Error
+}
+
+// PostDeposit: อนุมัติฝาก เงินสดเข้า -> กระเป๋าสมาชิก
+func PostDeposit(tx *gorm.DB, t *models.Transaction, adminID *uint) error {
+	return postTransaction(tx, t, "deposit", adminID,
+		WalletPosting(t.UserID, t.Amount),
+		SystemPosting(models.LedgerAccountCash, -t.Amount),
+	)
+}
+
+// PostWithdrawRequest: แจ้งถอน ย้ายยอดจากยอดใช้ได้ไปพักไว้ (wallet -> wallet_held)
+func PostWithdrawRequest(tx *gorm.DB, t *models.Transaction) error {
+	return postTransaction(tx, t, "withdraw", &t.UserID,
+		WalletPosting(t.UserID, -t.Amount),
+		HeldPosting(t.UserID, t.Amount),
+	)
+}
+
+// withdrawHoldPosting: บรรทัดฝั่งยอดพักของรายการถอน t
+// รายการที่แจ้งถอนก่อนมี wallet_held พักยอดไว้ใน withdraw_payable จึงต้องตัดจากบัญชีเดิม
+func withdrawHoldPosting(tx *gorm.DB, t *models.Transaction, amount models.Money) (Posting, error) {
+	if t.JournalID != nil {
+		var legacy int64
+		if err := tx.Model(&models.LedgerEntry{}).
+			Where("journal_id = ? AND account = ?", *t.JournalID, models.LedgerAccountWithdrawPayable).
+			Count(&legacy).Error; err != nil {
+			return Posting{}, err
+		}
+		if legacy > 0 {
+			return SystemPosting(models.LedgerAccountWithdrawPayable, amount), nil
+		}
+	}
+	return HeldPosting(t.UserID, amount), nil
+}
+
+// PostWithdrawRefund: ปฏิเสธการถอน ปล่อยยอดพักกลับเข้ายอดใช้ได้ (สร้าง Transaction withdraw_refund แยกจากรายการถอนเดิม)
+func PostWithdrawRefund(tx *gorm.DB, t *models.Transaction, adminID *uint) error {
+	hold, err := withdrawHoldPosting(tx, t, -t.Amount)
+	if err != nil {
+		return err
+	}
+
+	journal, err := PostJournal(tx, Journal{
+		Type:      "withdraw_refund",
+		RefType:   "transaction",
+		RefID:     t.ID,
+		CreatedBy: adminID,
+		Postings:  []Posting{hold, WalletPosting(t.UserID, t.Amount)},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = RecordTransaction(tx, journal, t.UserID, models.Transaction{
+		AdminID: adminID,
+		Type:    "withdraw_refund",
+		Note:    fmt.Sprintf("คืนเงินรายการถอน #%d", t.ID),
+	})
+	return err
+}
+
+// PostWithdrawPaid: อนุมัติถอน (โอนเงินจริงแล้ว) ตัดยอดพักออกเป็นเงินสดจ่าย ไม่กระทบยอดใช้ได้
+func PostWithdrawPaid(tx *gorm.DB, t *models.Transaction, adminID *uint) error {
+	hold, err := withdrawHoldPosting(tx, t, -t.Amount)
+	if err != nil {
+		return err
+	}
+
+	_, err = PostJournal(tx, Journal{
+		Type:      "withdraw_paid",
+		RefType:   "transaction",
+		RefID:     t.ID,
+		CreatedBy: adminID,
+		Postings:  []Posting{hold, SystemPosting(models.LedgerAccountCash, t.Amount)},
+	})
+	return err
+}
+
+// WalletBalance: ยอดกระเป๋าที่รวมจาก ledger_entries (ใช้เทียบกับ users.credit)
+func WalletBalance(db *gorm.DB, userID uint) (models.Money, error) {
+	var balance models.Money
+	err := db.Model(&models.LedgerEntry{}).
+		Where("account = ? AND user_id = ?", models.LedgerAccountWallet, userID).
+		Select("COALESCE(SUM(amount), 0)").Scan(&balance).Error
+	return balance, err
+}
+
+// PostOpeningBalances: ย้ายยอดเครดิตเดิม (ก่อนมี Ledger) เข้าบัญชีเป็นยอดยกมา
+// เฉพาะสมาชิกที่มีเครดิตแต่ยังไม่มี Entry ของ wallet เลย รันซ้ำได้
+func PostOpeningBalances() error {
+	var users []models.User
+	err := database.DB.Select("id", "credit").
+		Where("credit <> 0").
+		Where("NOT EXISTS (SELECT 1 FROM ledger_entries e WHERE e.account = ? AND e.user_id = users.id)", models.LedgerAccountWallet).
+		Find(&users).Error
+	if err != nil {
+		return err
+	}
+
+	for _, u := range users {
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			// ล้างยอดเดิมก่อน แล้วลงบัญชียอดยกมาเต็มจำนวน ให้ users.credit ตรงกับผลรวม Entry
+			if err := tx.Exec("UPDATE users SET credit = 0 WHERE id = ?", u.ID).Error; err != nil {
+				return err
+			}
+			journal, err := PostWallet(tx, u.ID, u.Credit, models.LedgerAccountAdjustment, Journal{
+				Type: "opening",
+				Note: "ยอดยกมาก่อนเปิดใช้ Ledger",
+			})
+			if err != nil {
+				return err
+			}
+			_, err = RecordTransaction(tx, journal, u.ID, models.Transaction{Type: "adjustment", Note: journal.Note})
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("opening balance user %d: %w", u.ID, err)
+		}
+	}
+
+	if len(users) > 0 {
+		log.Printf("✅ [Ledger] Posted opening balances for %d users", len(users))
+	}
+	return nil
+}
+
+// ==========================================
+// Admin API
+// ==========================================
+
+// GET /admin/ledger?user_id=1&account=wallet&type=bet
+// ดูรายการเคลื่อนไหวในสมุดบัญชี (ล่าสุดก่อน)
+func GetLedgerEntries(c *fiber.Ctx) error {
+	type EntryRow struct {
+		models.LedgerEntry
+		Type    string `json:"type"`
+		RefType string `json:"ref_type"`
+		RefID   uint   `json:"ref_id"`
+		Note    string `json:"note"`
+	}
+
+	query := database.DB.Table("ledger_entries").
+		Select("ledger_entries.*, ledger_journals.type, ledger_journals.ref_type, ledger_journals.ref_id, ledger_journals.note").
+		Joins("INNER JOIN ledger_journals ON ledger_journals.id = ledger_entries.journal_id").
+		Order("ledger_entries.id desc")
+
+	if userID := c.Query("user_id"); userID != "" {
+		query = query.Where("ledger_entries.user_id = ?", userID)
+	}
+	if account := c.Query("account"); account != "" {
+		query = query.Where("ledger_entries.account = ?", account)
+	}
+	if journalType := c.Query("type"); journalType != "" {
+		query = query.Where("ledger_journals.type = ?", journalType)
+	}
+
+	var rows []EntryRow
+	if err := query.Limit(500).Scan(&rows).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "ดึงข้อมูลไม่สำเร็จ"})
+	}
+	if rows == nil {
+		rows = []EntryRow{}
+	}
+	return c.JSON(rows)
+}
@@ -0,0 +1,206 @@
+package services
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PawornpratKongdaeng/soccer/models"
+	"gorm.io/gorm"
+)
+
+func TestPostJournal(t *testing.T) {
+	db := openTestDB(t)
+	user := createTestUser(t, db, "ledger_post", models.NewMoney(100))
+	id := user.ID
+
+	tests := []struct {
+		name     string
+		postings []Posting
+		wantErr  error
+	}{
+		{"single posting", []Posting{WalletPosting(id, 100)}, ErrUnbalancedJournal},
+		{"does not sum to zero", []Posting{WalletPosting(id, 100), SystemPosting(models.LedgerAccountCash, -99)}, ErrUnbalancedJournal},
+		{"wallet below zero", []Posting{WalletPosting(id, models.NewMoney(-100.01)), SystemPosting(models.LedgerAccountHouse, models.NewMoney(100.01))}, ErrInsufficientCredit},
+		{"held below zero", []Posting{HeldPosting(id, -1), WalletPosting(id, 1)}, ErrInsufficientHeld},
+		{"bonus below zero", []Posting{BonusPosting(id, -1), SystemPosting(models.LedgerAccountHouse, 1)}, ErrInsufficientBonus},
+		{"balanced", []Posting{WalletPosting(id, models.NewMoney(-40)), HeldPosting(id, models.NewMoney(40))}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := reloadUser(t, db, id)
+			journal, err := PostJournal(db, Journal{Type: "adjustment", Postings: tt.postings})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("PostJournal() error = %v, want %v", err, tt.wantErr)
+			}
+			after := reloadUser(t, db, id)
+			if tt.wantErr != nil {
+				if after.Credit != before.Credit || after.HeldCredit != before.HeldCredit || after.BonusCredit != before.BonusCredit {
+					t.Errorf("balances changed on error: %s/%s/%s", after.Credit, after.HeldCredit, after.BonusCredit)
+				}
+				return
+			}
+
+			var sum models.Money
+			for _, e := range journal.Entries {
+				sum += e.Amount
+				if e.UserID != nil && *e.BalanceBefore+e.Amount != *e.BalanceAfter {
+					t.Errorf("entry %s: %s + %s != %s", e.Account, *e.BalanceBefore, e.Amount, *e.BalanceAfter)
+				}
+			}
+			if sum != 0 {
+				t.Errorf("entries sum = %s, want 0", sum)
+			}
+			if ledger, _ := WalletBalance(db, id); ledger != after.Credit {
+				t.Errorf("users.credit = %s, ledger = %s", after.Credit, ledger)
+			}
+		})
+	}
+
+	if _, err := PostJournal(db, Journal{Type: "adjustment", Postings: []Posting{WalletPosting(id, 0), SystemPosting(models.LedgerAccountCash, 0)}}); err == nil {
+		t.Error("PostJournal() accepted zero amount")
+	}
+	if _, err := PostJournal(db, Journal{Type: "adjustment", Postings: []Posting{{Account: models.LedgerAccountWallet, Amount: 1}, SystemPosting(models.LedgerAccountCash, -1)}}); err == nil {
+		t.Error("PostJournal() accepted wallet posting without user")
+	}
+}
+
+func TestPostJournalCreditLine(t *testing.T) {
+	db := openTestDB(t)
+	limit := models.NewMoney(100)
+
+	tests := []struct {
+		name          string
+		mode          string
+		useCreditLine bool
+		stakes        []models.Money
+		wantErr       error
+		wantCredit    models.Money
+	}{
+		{"credit bets down to the floor", models.AccountModeCredit, true, []models.Money{models.NewMoney(60), models.NewMoney(40)}, nil, -limit},
+		{"credit bet past the floor", models.AccountModeCredit, true, []models.Money{models.NewMoney(60), models.NewMoney(40.01)}, ErrInsufficientCredit, models.NewMoney(-60)},
+		{"credit line not used for transfers", models.AccountModeCredit, false, []models.Money{models.NewMoney(0.01)}, ErrInsufficientCredit, 0},
+		{"cash account cannot go negative", models.AccountModeCash, true, []models.Money{models.NewMoney(0.01)}, ErrInsufficientCredit, 0},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := createTestUser(t, db, "ledger_floor_"+strconv.Itoa(i), 0)
+			updateUser(t, db, user.ID, map[string]interface{}{"account_mode": tt.mode, "credit_limit": limit})
+
+			var err error
+			for _, stake := range tt.stakes {
+				_, err = PostJournal(db, Journal{
+					Type:          "bet",
+					UseCreditLine: tt.useCreditLine,
+					Postings:      []Posting{WalletPosting(user.ID, -stake), SystemPosting(models.LedgerAccountHouse, stake)},
+				})
+				if err != nil {
+					break
+				}
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("PostJournal() error = %v, want %v", err, tt.wantErr)
+			}
+			if u := reloadUser(t, db, user.ID); u.Credit != tt.wantCredit {
+				t.Errorf("credit = %s, want %s", u.Credit, tt.wantCredit)
+			}
+		})
+	}
+}
+
+func TestWithdrawHeldFlow(t *testing.T) {
+	db := openTestDB(t)
+	user := createTestUser(t, db, "ledger_held", models.NewMoney(1000))
+
+	paid := models.Transaction{UserID: user.ID, Amount: models.NewMoney(300), Type: "withdraw", Status: "pending"}
+	refunded := models.Transaction{UserID: user.ID, Amount: models.NewMoney(200), Type: "withdraw", Status: "pending"}
+	for _, w := range []*models.Transaction{&paid, &refunded} {
+		if err := db.Create(w).Error; err != nil {
+			t.Fatal(err)
+		}
+		if err := PostWithdrawRequest(db, w); err != nil {
+			t.Fatalf("PostWithdrawRequest() error = %v", err)
+		}
+	}
+
+	steps := []struct {
+		name       string
+		post       func() error
+		wantErr    error
+		wantCredit models.Money
+		wantHeld   models.Money
+	}{
+		{"requests hold both amounts", func() error { return nil }, nil, models.NewMoney(500), models.NewMoney(500)},
+		{"paid releases held only", func() error { return PostWithdrawPaid(db, &paid, nil) }, nil, models.NewMoney(500), models.NewMoney(200)},
+		{"refund returns held to wallet", func() error { return PostWithdrawRefund(db, &refunded, nil) }, nil, models.NewMoney(700), 0},
+		{"second payout has nothing held", func() error { return PostWithdrawPaid(db, &paid, nil) }, ErrInsufficientHeld, models.NewMoney(700), 0},
+	}
+
+	for _, s := range steps {
+		t.Run(s.name, func(t *testing.T) {
+			if err := s.post(); !errors.Is(err, s.wantErr) {
+				t.Fatalf("error = %v, want %v", err, s.wantErr)
+			}
+			u := reloadUser(t, db, user.ID)
+			if u.Credit != s.wantCredit || u.HeldCredit != s.wantHeld {
+				t.Errorf("credit/held = %s/%s, want %s/%s", u.Credit, u.HeldCredit, s.wantCredit, s.wantHeld)
+			}
+		})
+	}
+
+	var refund models.Transaction
+	if err := db.Where("user_id = ? AND type = ?", user.ID, "withdraw_refund").First(&refund).Error; err != nil {
+		t.Fatalf("withdraw_refund transaction: %v", err)
+	}
+	if refund.Amount != refunded.Amount || refund.BalanceAfter != models.NewMoney(700) {
+		t.Errorf("refund = %s (after %s), want %s (after 700.00)", refund.Amount, refund.BalanceAfter, refunded.Amount)
+	}
+}
+
+// TestPostTransferLockOrder: โอนสวนทางกันพร้อมกันต้องไม่ Deadlock (ล็อกกระเป๋าเรียงตาม ID เสมอ)
+// ใช้ DB จริงนอก Transaction ของเทสต์ เพราะต้องมีหลาย Connection แย่งล็อกกัน แล้วลบข้อมูลทิ้งตอนจบ
+func TestPostTransferLockOrder(t *testing.T) {
+	openTestDB(t)
+	db := testDB
+	suffix := strconv.FormatInt(time.Now().UnixNano(), 36)
+	a := createTestUser(t, db, "lock_a_"+suffix, models.NewMoney(1000))
+	b := createTestUser(t, db, "lock_b_"+suffix, models.NewMoney(1000))
+	t.Cleanup(func() {
+		journals := db.Model(&models.LedgerEntry{}).Select("journal_id").Where("user_id IN ?", []uint{a.ID, b.ID})
+		db.Where("id IN (?)", journals).Delete(&models.LedgerJournal{})
+		db.Where("user_id IN ?", []uint{a.ID, b.ID}).Delete(&models.LedgerEntry{})
+		db.Unscoped().Delete(&models.User{}, []uint{a.ID, b.ID})
+	})
+
+	const rounds = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*rounds)
+	for i := 0; i < rounds; i++ {
+		for _, pair := range [][2]uint{{a.ID, b.ID}, {b.ID, a.ID}} {
+			wg.Add(1)
+			go func(from, to uint) {
+				defer wg.Done()
+				errs <- db.Transaction(func(tx *gorm.DB) error {
+					_, err := PostTransfer(tx, from, to, models.NewMoney(1), Journal{Type: "transfer"})
+					return err
+				})
+			}(pair[0], pair[1])
+		}
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("PostTransfer() error = %v", err)
+		}
+	}
+	gotA, gotB := reloadUser(t, db, a.ID), reloadUser(t, db, b.ID)
+	if gotA.Credit != models.NewMoney(1000) || gotB.Credit != models.NewMoney(1000) {
+		t.Errorf("credits = %s/%s, want 1000.00/1000.00", gotA.Credit, gotB.Credit)
+	}
+}
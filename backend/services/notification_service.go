@@ -0,0 +1,53 @@
+package services
+
+import (
+	"time"
+
+	"github.com/PawornpratKongdaeng/soccer/models"
+	"gorm.io/gorm"
+)
+
+// ==========================================
+// แจ้งเตือนในระบบ (Notification)
+// ==========================================
+
+// Notify: สร้างแจ้งเตือนถึงผู้ใช้ 1 คน (เรียกใน Transaction เดียวกับเหตุการณ์ จะได้ไม่แจ้งเรื่องที่ Rollback ไป)
+func Notify(tx *gorm.DB, userID uint, typ, title, message, refType string, refID uint) error {
+	return tx.Create(&models.Notification{
+		UserID:  userID,
+		Type:    typ,
+		Title:   title,
+		Message: message,
+		RefType: refType,
+		RefID:   refID,
+	}).Error
+}
+
+// NotifyUplines: แจ้ง Agent/Master ทุกชั้นเหนือสมาชิก (ไล่ ParentID จนถึง Admin)
+func NotifyUplines(tx *gorm.DB, member models.User, typ, title, message, refType string, refID uint) error {
+	parentID := member.ParentID
+	for level := 1; parentID != nil && level <= maxUplineDepth; level++ {
+		var parent models.User
+		if err := tx.First(&parent, *parentID).Error; err != nil {
+			return nil
+		}
+		if parent.Role == "admin" {
+			return nil
+		}
+		if err := Notify(tx, parent.ID, typ, title, message, refType, refID); err != nil {
+			return err
+		}
+		parentID = parent.ParentID
+	}
+	return nil
+}
+
+// MarkNotificationsRead: อ่านแล้ว (ids ว่าง = ทั้งหมดของผู้ใช้)
+func MarkNotificationsRead(db *gorm.DB, userID uint, ids []uint, now time.Time) (int64, error) {
+	query := db.Model(&models.Notification{}).Where("user_id = ? AND read_at IS NULL", userID)
+	if len(ids) > 0 {
+		query = query.Where("id IN ?", ids)
+	}
+	res := query.Update("read_at", now)
+	return res.RowsAffected, res.Error
+}
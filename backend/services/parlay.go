@@ -0,0 +1,159 @@
+package services
+
+import (
+	"math/big"
+
+	"github.com/PawornpratKongdaeng/soccer/models"
+)
+
+// ==========================================
+// ราคาพม่า (Burmese Pricing) สำหรับบอลเต็งและบอลสเต็ป
+// ==========================================
+// แต้มต่อเป็นจำนวนลูก (hdp) และค่าน้ำเป็น % (price เช่น 80, -60)
+// - ทีมต่อชนะเกินแต้มต่อ  = ทีมต่อชนะเต็ม
+// - ทีมต่อชนะน้อยกว่าแต้มต่อ = ทีมต่อเสียเต็ม
+// - ชนะเท่าแต้มต่อพอดี: น้ำบวก ทีมต่อได้ตาม % / น้ำลบ ทีมต่อเสียตาม %
+// สูง/ต่ำ ใช้กติกาเดียวกัน โดยให้ "over" เป็นฝั่งต่อ และเทียบกับจำนวนประตูรวม
+
+// SettleLeg: คิดผลรายคู่ คืนค่าสถานะตาม BetStatus*
+func SettleLeg(homeScore, awayScore int, hdp float64, price int, isHomeUpper bool, pick string) string {
+	var diff float64
+	var isUserPickUpper bool
+
+	switch pick {
+	case "over", "under":
+		diff = float64(homeScore + awayScore)
+		isUserPickUpper = pick == "over"
+	default:
+		diff = float64(homeScore - awayScore)
+		if !isHomeUpper {
+			diff = float64(awayScore - homeScore)
+		}
+		isUserPickUpper = (pick == "home" && isHomeUpper) || (pick == "away" && !isHomeUpper)
+	}
+
+	var upperResult string
+	switch {
+	case diff > hdp:
+		upperResult = models.BetStatusWin
+	case diff < hdp:
+		upperResult = models.BetStatusLoss
+	case price > 0:
+		upperResult = models.BetStatusWinHalf
+	case price < 0:
+		upperResult = models.BetStatusLoseHalf
+	default:
+		upperResult = models.BetStatusDraw
+	}
+
+	if isUserPickUpper {
+		return upperResult
+	}
+
+	// ฝั่งรอง: ผลกลับด้านกับฝั่งต่อ
+	switch upperResult {
+	case models.BetStatusWin:
+		return models.BetStatusLoss
+	case models.BetStatusLoss:
+		return models.BetStatusWin
+	case models.BetStatusWinHalf:
+		return models.BetStatusLoseHalf
+	case models.BetStatusLoseHalf:
+		return models.BetStatusWinHalf
+	default:
+		return models.BetStatusDraw
+	}
+}
+
+// legMultiplierRat: ตัวคูณของคู่ในสเต็ปแบบเศษส่วนแม่นยำ (ชนะเต็มคือ x2 ตามราคาพม่า)
+func legMultiplierRat(status string, price int) *big.Rat {
+	abs := int64(price)
+	if abs < 0 {
+		abs = -abs
+	}
+	pct := big.NewRat(abs, 100)
+
+	switch status {
+	case models.BetStatusWin:
+		return big.NewRat(2, 1)
+	case models.BetStatusWinHalf:
+		return pct.Add(big.NewRat(1, 1), pct)
+	case models.BetStatusDraw:
+		return big.NewRat(1, 1)
+	case models.BetStatusLoseHalf:
+		return pct.Sub(big.NewRat(1, 1), pct)
+	default:
+		return new(big.Rat)
+	}
+}
+
+// LegMultiplier: ตัวคูณของคู่ในสเต็ป (ใช้เก็บ/แสดงผล ส่วนการคิดเงินใช้เศษส่วนใน EvaluateParlay)
+func LegMultiplier(status string, price int) float64 {
+	f, _ := legMultiplierRat(status, price).Float64()
+	return f
+}
+
+// SettleSingle: คิดผลบอลเต็ง (กติกาและตัวคูณเดียวกับคู่ในสเต็ป) คืนสถานะและยอดจ่ายคืน (รวมทุน)
+func SettleSingle(amount models.Money, homeScore, awayScore int, hdp float64, price int, isHomeUpper bool, pick string) (string, models.Money) {
+	status := SettleLeg(homeScore, awayScore, hdp, price, isHomeUpper, pick)
+	return status, amount.MulRat(legMultiplierRat(status, price))
+}
+
+// MaxPayout: ยอดจ่ายสูงสุดของบิล (ทุกคู่ชนะเต็ม) เต็ง = ทุน x2, สเต็ป n คู่ = ทุน x2^n
+// คิดจากทุนที่แทงฝั่ง Server ไม่เชื่อยอดที่หน้าเว็บส่งมา ใช้เทียบเพดานยอดจ่ายและเก็บเป็น Payout ตอนรับบิล
+func MaxPayout(amount models.Money, legs int) models.Money {
+	multiplier := big.NewRat(1, 1)
+	for i := 0; i < legs; i++ {
+		multiplier.Mul(multiplier, legMultiplierRat(models.BetStatusWin, 0))
+	}
+	return amount.MulRat(multiplier)
+}
+
+// ParlayOutcome: ผลรวมของบิลสเต็ป
+type ParlayOutcome struct {
+	Status     string       `json:"status"`
+	Multiplier float64      `json:"multiplier"`
+	Payout     models.Money `json:"payout"`
+	IsSettled  bool         `json:"is_settled"` // false = ยังมีคู่ที่รอผล
+}
+
+// EvaluateParlay: คิดผลบิลสเต็ปจากสถานะรายคู่
+// ถ้ามีคู่ใดเสียเต็ม บิลจบทันที ไม่ต้องรอคู่ที่เหลือ
+// ตัวคูณรวมคิดเป็นเศษส่วน แล้วปัดยอดจ่ายเป็นสตางค์ครั้งเดียวตอนท้าย
+func EvaluateParlay(amount models.Money, items []models.ParlayItem) ParlayOutcome {
+	multiplier := big.NewRat(1, 1)
+	allFinished := true
+
+	for _, item := range items {
+		if item.Status == "" || item.Status == models.BetStatusPending {
+			allFinished = false
+			continue
+		}
+
+		legMult := legMultiplierRat(item.Status, item.Price)
+		if legMult.Sign() == 0 {
+			return ParlayOutcome{Status: models.BetStatusLoss, Multiplier: 0, Payout: 0, IsSettled: true}
+		}
+		multiplier.Mul(multiplier, legMult)
+	}
+
+	multiplierFloat, _ := multiplier.Float64()
+	if !allFinished {
+		return ParlayOutcome{Status: models.BetStatusPending, Multiplier: multiplierFloat}
+	}
+
+	status := models.BetStatusDraw
+	switch multiplier.Cmp(big.NewRat(1, 1)) {
+	case 1:
+		status = models.BetStatusWin
+	case -1:
+		status = models.BetStatusLoseHalf
+	}
+
+	return ParlayOutcome{
+		Status:     status,
+		Multiplier: multiplierFloat,
+		Payout:     amount.MulRat(multiplier),
+		IsSettled:  true,
+	}
+}
@@ -0,0 +1,197 @@
+package services
+
+import (
+	"math"
+	"testing"
+
+	"github.com/PawornpratKongdaeng/soccer/models"
+)
+
+func TestSettleLeg(t *testing.T) {
+	tests := []struct {
+		name        string
+		home, away  int
+		hdp         float64
+		price       int
+		isHomeUpper bool
+		pick        string
+		want        string
+	}{
+		{"upper wins by more than line", 3, 1, 1, 80, true, "home", models.BetStatusWin},
+		{"upper wins by less than line", 1, 1, 1, 80, true, "home", models.BetStatusLoss},
+		{"exact line positive price upper", 2, 1, 1, 80, true, "home", models.BetStatusWinHalf},
+		{"exact line positive price lower", 2, 1, 1, 80, true, "away", models.BetStatusLoseHalf},
+		{"exact line negative price upper", 2, 1, 1, -60, true, "home", models.BetStatusLoseHalf},
+		{"exact line negative price lower", 2, 1, 1, -60, true, "away", models.BetStatusWinHalf},
+		{"away upper wins", 0, 2, 1, 50, false, "away", models.BetStatusWin},
+		{"away upper backed home", 0, 2, 1, 50, false, "home", models.BetStatusLoss},
+		{"exact line zero price", 1, 0, 1, 0, true, "home", models.BetStatusDraw},
+		{"over beats goal line", 2, 2, 3, 70, true, "over", models.BetStatusWin},
+		{"under at goal line negative price", 2, 1, 3, -40, true, "under", models.BetStatusWinHalf},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SettleLeg(tt.home, tt.away, tt.hdp, tt.price, tt.isHomeUpper, tt.pick)
+			if got != tt.want {
+				t.Errorf("SettleLeg() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLegMultiplier(t *testing.T) {
+	tests := []struct {
+		status string
+		price  int
+		want   float64
+	}{
+		{models.BetStatusWin, 80, 2},
+		{models.BetStatusWinHalf, 80, 1.8},
+		{models.BetStatusWinHalf, -60, 1.6},
+		{models.BetStatusDraw, 80, 1},
+		{models.BetStatusLoseHalf, 80, 0.2},
+		{models.BetStatusLoseHalf, -60, 0.4},
+		{models.BetStatusLoss, 80, 0},
+	}
+
+	for _, tt := range tests {
+		if got := LegMultiplier(tt.status, tt.price); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("LegMultiplier(%q, %d) = %v, want %v", tt.status, tt.price, got, tt.want)
+		}
+	}
+}
+
+func TestSettleSingle(t *testing.T) {
+	tests := []struct {
+		name        string
+		home, away  int
+		hdp         float64
+		price       int
+		isHomeUpper bool
+		pick        string
+		amount      models.Money
+		wantStatus  string
+		wantPayout  models.Money
+	}{
+		{"upper wins by more than line", 3, 1, 1, 80, true, "home", models.NewMoney(100), models.BetStatusWin, models.NewMoney(200)},
+		{"lower side loses", 3, 1, 1, 80, true, "away", models.NewMoney(100), models.BetStatusLoss, 0},
+		{"exact line positive price upper", 2, 1, 1, 80, true, "home", models.NewMoney(100), models.BetStatusWinHalf, models.NewMoney(180)},
+		{"exact line positive price lower", 2, 1, 1, 80, true, "away", models.NewMoney(100), models.BetStatusLoseHalf, models.NewMoney(20)},
+		{"exact line negative price upper", 2, 1, 1, -60, true, "home", models.NewMoney(100), models.BetStatusLoseHalf, models.NewMoney(40)},
+		{"exact line negative price lower", 2, 1, 1, -60, true, "away", models.NewMoney(100), models.BetStatusWinHalf, models.NewMoney(160)},
+		{"away upper wins", 0, 2, 1, 50, false, "away", models.NewMoney(100), models.BetStatusWin, models.NewMoney(200)},
+		{"away upper backed home", 0, 2, 1, 50, false, "home", models.NewMoney(100), models.BetStatusLoss, 0},
+		{"exact line zero price refunds", 1, 0, 1, 0, true, "home", models.NewMoney(100), models.BetStatusDraw, models.NewMoney(100)},
+		{"over beats goal line", 2, 1, 2, 70, true, "over", models.NewMoney(100), models.BetStatusWin, models.NewMoney(200)},
+		{"under loses to goal line", 2, 1, 2, 70, true, "under", models.NewMoney(100), models.BetStatusLoss, 0},
+		{"under at goal line negative price", 2, 1, 3, -40, true, "under", models.NewMoney(100), models.BetStatusWinHalf, models.NewMoney(140)},
+		{"payout rounds to satang", 2, 1, 1, 33, true, "home", models.NewMoney(33.33), models.BetStatusWinHalf, models.NewMoney(44.33)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, payout := SettleSingle(tt.amount, tt.home, tt.away, tt.hdp, tt.price, tt.isHomeUpper, tt.pick)
+			if status != tt.wantStatus || payout != tt.wantPayout {
+				t.Errorf("SettleSingle() = %q, %s, want %q, %s", status, payout, tt.wantStatus, tt.wantPayout)
+			}
+		})
+	}
+}
+
+func TestMaxPayout(t *testing.T) {
+	tests := []struct {
+		name   string
+		amount models.Money
+		legs   int
+		want   models.Money
+	}{
+		{"single", models.NewMoney(100), 1, models.NewMoney(200)},
+		{"two legs", models.NewMoney(100), 2, models.NewMoney(400)},
+		{"ten legs", models.NewMoney(10), 10, models.NewMoney(10240)},
+		{"odd satang", models.NewMoney(0.01), 3, models.NewMoney(0.08)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MaxPayout(tt.amount, tt.legs); got != tt.want {
+				t.Errorf("MaxPayout(%s, %d) = %s, want %s", tt.amount, tt.legs, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateParlay(t *testing.T) {
+	leg := func(status string, price int) models.ParlayItem {
+		return models.ParlayItem{Status: status, Price: price}
+	}
+
+	tests := []struct {
+		name        string
+		items       []models.ParlayItem
+		wantStatus  string
+		wantPayout  models.Money
+		wantSettled bool
+	}{
+		{
+			name:        "all legs win",
+			items:       []models.ParlayItem{leg(models.BetStatusWin, 80), leg(models.BetStatusWin, -50)},
+			wantStatus:  models.BetStatusWin,
+			wantPayout:  models.NewMoney(400),
+			wantSettled: true,
+		},
+		{
+			name:        "win and partial win",
+			items:       []models.ParlayItem{leg(models.BetStatusWin, 80), leg(models.BetStatusWinHalf, 80)},
+			wantStatus:  models.BetStatusWin,
+			wantPayout:  models.NewMoney(360),
+			wantSettled: true,
+		},
+		{
+			name:        "loss ends ticket while other legs pending",
+			items:       []models.ParlayItem{leg(models.BetStatusLoss, 80), leg(models.BetStatusPending, 80)},
+			wantStatus:  models.BetStatusLoss,
+			wantPayout:  0,
+			wantSettled: true,
+		},
+		{
+			name:        "pending leg keeps ticket open",
+			items:       []models.ParlayItem{leg(models.BetStatusWin, 80), leg(models.BetStatusPending, 80)},
+			wantStatus:  models.BetStatusPending,
+			wantSettled: false,
+		},
+		{
+			name:        "all draws refund stake",
+			items:       []models.ParlayItem{leg(models.BetStatusDraw, 80), leg(models.BetStatusDraw, -20)},
+			wantStatus:  models.BetStatusDraw,
+			wantPayout:  models.NewMoney(100),
+			wantSettled: true,
+		},
+		{
+			name:        "partial loss returns part of stake",
+			items:       []models.ParlayItem{leg(models.BetStatusLoseHalf, -60), leg(models.BetStatusDraw, 80)},
+			wantStatus:  models.BetStatusLoseHalf,
+			wantPayout:  models.NewMoney(40),
+			wantSettled: true,
+		},
+		{
+			name:        "multiplier rounds to satang once",
+			items:       []models.ParlayItem{leg(models.BetStatusWinHalf, 33), leg(models.BetStatusWinHalf, -33), leg(models.BetStatusWinHalf, 33)},
+			wantStatus:  models.BetStatusWin,
+			wantPayout:  models.NewMoney(235.26),
+			wantSettled: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := EvaluateParlay(models.NewMoney(100), tt.items)
+			if got.Status != tt.wantStatus || got.IsSettled != tt.wantSettled {
+				t.Fatalf("EvaluateParlay() = %+v, want status %q settled %v", got, tt.wantStatus, tt.wantSettled)
+			}
+			if tt.wantSettled && got.Payout != tt.wantPayout {
+				t.Errorf("EvaluateParlay() payout = %v, want %v", got.Payout, tt.wantPayout)
+			}
+		})
+	}
+}
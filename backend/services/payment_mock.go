@@ -0,0 +1,126 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/PawornpratKongdaeng/soccer/models"
+	"gorm.io/gorm"
+)
+
+// ==========================================
+// ผู้ให้บริการจำลอง (mock) สำหรับทดสอบในเครื่อง
+// ==========================================
+// เก็บคำสั่งไว้ในหน่วยความจำ (หายเมื่อรีสตาร์ท) คำสั่งค้าง pending จนเรียก Complete
+// Complete ส่ง Webhook ลงลายเซ็นจริงผ่าน ProcessWebhook เหมือนผู้ให้บริการจริงยิงเข้ามา
+// ถอนเข้าบัญชีที่ไม่มีเลขบัญชี = ปฏิเสธทันที (ทดสอบกรณีผู้ให้บริการไม่รับคำสั่ง)
+
+// MockPayments: ผู้ให้บริการจำลอง (nil = ไม่ได้เปิด PAYMENT_MOCK_ENABLED)
+var MockPayments *MockProvider
+
+type mockPayment struct {
+	merchantRef string
+	amount      models.Money
+	status      string
+	reason      string
+}
+
+type MockProvider struct {
+	secret   []byte
+	mu       sync.Mutex
+	seq      int
+	payments map[string]*mockPayment
+}
+
+func NewMockProvider(secret []byte) *MockProvider {
+	return &MockProvider{secret: secret, payments: map[string]*mockPayment{}}
+}
+
+func (m *MockProvider) Name() string { return "mock" }
+
+func (m *MockProvider) create(prefix string, order *models.PaymentOrder) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seq++
+	ref := fmt.Sprintf("MOCK-%s%06d", prefix, m.seq)
+	m.payments[ref] = &mockPayment{merchantRef: order.MerchantRef(), amount: order.Amount, status: models.PaymentPending}
+	return ref
+}
+
+func (m *MockProvider) CreateDeposit(order *models.PaymentOrder, user *models.User) (*PaymentResult, error) {
+	ref := m.create("D", order)
+	return &PaymentResult{
+		MerchantRef: order.MerchantRef(),
+		ProviderRef: ref,
+		Status:      models.PaymentPending,
+		PayURL:      "/api/v3/payments/mock/" + ref + "/complete", // POST {"status": "succeeded"} เพื่อจำลองการชำระ
+	}, nil
+}
+
+func (m *MockProvider) CreatePayout(order *models.PaymentOrder, t *models.Transaction) (*PaymentResult, error) {
+	if t.BankAccount == "" {
+		return nil, fmt.Errorf("mock: missing destination account")
+	}
+	return &PaymentResult{MerchantRef: order.MerchantRef(), ProviderRef: m.create("P", order), Status: models.PaymentPending}, nil
+}
+
+func (m *MockProvider) QueryStatus(order *models.PaymentOrder) (*PaymentResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p, ok := m.payments[order.ProviderRef]
+	if !ok {
+		return nil, fmt.Errorf("mock: unknown reference %q", order.ProviderRef)
+	}
+	return &PaymentResult{MerchantRef: p.merchantRef, ProviderRef: order.ProviderRef, Status: p.status, Amount: p.amount, Reason: p.reason}, nil
+}
+
+func (m *MockProvider) ParseWebhook(header func(string) string, body []byte, now time.Time) (*PaymentResult, error) {
+	if err := VerifyWebhookSignature(m.secret, header, body, now); err != nil {
+		return nil, err
+	}
+	var res PaymentResult
+	if err := json.Unmarshal(body, &res); err != nil {
+		return nil, fmt.Errorf("mock: decode webhook: %w", err)
+	}
+	return &res, nil
+}
+
+// Complete: จำลองผู้ให้บริการทำรายการเสร็จแล้วยิง Webhook (amount 0 = ยอดตามคำสั่ง ใส่ยอดอื่นเพื่อทดสอบยอดไม่ตรง)
+func (m *MockProvider) Complete(db *gorm.DB, ref, status, reason string, amount models.Money) (*models.PaymentOrder, bool, error) {
+	if status != models.PaymentSucceeded && status != models.PaymentFailed {
+		return nil, false, fmt.Errorf("mock: invalid status %q", status)
+	}
+	m.mu.Lock()
+	p, ok := m.payments[ref]
+	if ok {
+		p.status, p.reason = status, reason
+		if amount == 0 {
+			amount = p.amount
+		}
+	}
+	m.mu.Unlock()
+	if !ok {
+		return nil, false, ErrPaymentOrderNotFound
+	}
+
+	body, err := json.Marshal(PaymentResult{
+		EventID:     ref + ":" + status,
+		MerchantRef: p.merchantRef,
+		ProviderRef: ref,
+		Status:      status,
+		Amount:      amount,
+		Reason:      reason,
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	now := time.Now()
+	headers := map[string]string{
+		PaymentTimestampHeader: strconv.FormatInt(now.Unix(), 10),
+		PaymentSignatureHeader: SignWebhook(m.secret, now.Unix(), body),
+	}
+	return ProcessWebhook(db, m, func(name string) string { return headers[name] }, body, now)
+}
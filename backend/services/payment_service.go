@@ -0,0 +1,442 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PawornpratKongdaeng/soccer/database"
+	"github.com/PawornpratKongdaeng/soccer/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ==========================================
+// ฝาก/ถอนผ่านผู้ให้บริการชำระเงิน (Payment Gateway)
+// ==========================================
+// ผู้ให้บริการแต่ละเจ้า implement PaymentProvider แล้วลงทะเบียนใน InitPayments
+// ฝาก: สมาชิกขอยอด -> Transaction ฝาก pending + PaymentOrder -> ผู้ให้บริการคืนหน้าชำระเงิน (PayURL)
+// ถอน: Admin อนุมัติครบแล้ว (approved) สั่งจ่ายผ่านผู้ให้บริการแทนการโอนเอง
+// ผลลัพธ์เข้ามาทาง Webhook (ลงลายเซ็น HMAC) หรือ job payment-sync ถามสถานะเอง
+// ทั้งสองทางจบที่ ApplyPaymentResult ซึ่งเปลี่ยนสถานะได้ครั้งเดียวต่อคำสั่ง (ซ้ำ = ไม่ทำอะไร)
+//
+// ตั้งผ่าน ENV:
+//   PAYMENT_MOCK_ENABLED     = true เปิดผู้ให้บริการจำลอง "mock" (ทดสอบทั้ง flow ในเครื่อง)
+//   PAYMENT_MOCK_SECRET      = กุญแจลงลายเซ็น Webhook ของ mock (ไม่ตั้ง = สุ่มใหม่ทุกครั้งที่เปิดเซิร์ฟเวอร์)
+//   PAYMENT_DEFAULT_PROVIDER = ผู้ให้บริการที่ใช้เมื่อไม่ระบุ (ไม่ตั้งและมีเจ้าเดียว = ใช้เจ้านั้น)
+
+const (
+	PaymentSignatureHeader = "X-Payment-Signature" // hex(HMAC-SHA256(secret, timestamp + "." + body))
+	PaymentTimestampHeader = "X-Payment-Timestamp" // unix วินาที
+	webhookTolerance       = 5 * time.Minute       // Webhook ที่ลงเวลาห่างจากตอนนี้เกินนี้ = ถูกยิงซ้ำ/ค้างทาง ไม่รับ
+	paymentSyncAfter       = 2 * time.Minute       // คำสั่งที่รอนานเกินนี้ให้ job ถามสถานะเอง (เผื่อ Webhook หาย)
+)
+
+var (
+	ErrPaymentProviderUnknown = errors.New("payment provider not available")
+	ErrPaymentProvider        = errors.New("payment provider request failed")
+	ErrWebhookSignature       = errors.New("invalid webhook signature")
+	ErrWebhookExpired         = errors.New("webhook timestamp outside tolerance")
+	ErrPaymentOrderNotFound   = errors.New("payment order not found")
+	ErrPayoutInFlight         = errors.New("payout is in progress at the payment provider")
+)
+
+// PaymentResult: สถานะคำสั่งจากผู้ให้บริการ (ตอนสร้าง / ถามสถานะ / Webhook)
+type PaymentResult struct {
+	EventID     string       `json:"event_id"`     // Webhook เท่านั้น (กันส่งซ้ำ)
+	MerchantRef string       `json:"merchant_ref"` // PaymentOrder.MerchantRef()
+	ProviderRef string       `json:"provider_ref"`
+	Status      string       `json:"status"` // models.PaymentPending / PaymentSucceeded / PaymentFailed
+	Amount      models.Money `json:"amount"` // ยอดที่ผู้ให้บริการรับ/จ่ายจริง (0 = ไม่แจ้ง)
+	PayURL      string       `json:"pay_url,omitempty"`
+	Reason      string       `json:"reason,omitempty"`
+}
+
+// PaymentProvider: ผู้ให้บริการชำระเงิน 1 เจ้า
+type PaymentProvider interface {
+	Name() string
+	CreateDeposit(order *models.PaymentOrder, user *models.User) (*PaymentResult, error)
+	CreatePayout(order *models.PaymentOrder, t *models.Transaction) (*PaymentResult, error)
+	QueryStatus(order *models.PaymentOrder) (*PaymentResult, error)
+	// ParseWebhook: ตรวจลายเซ็น/เวลา แล้วแปลง body เป็นผลลัพธ์ (header = ค่า header ตามชื่อ)
+	ParseWebhook(header func(string) string, body []byte, now time.Time) (*PaymentResult, error)
+}
+
+var (
+	paymentProviders       = map[string]PaymentProvider{}
+	defaultPaymentProvider string
+)
+
+// RegisterPaymentProvider: เพิ่มผู้ให้บริการ (เรียกตอนเปิดเซิร์ฟเวอร์เท่านั้น)
+func RegisterPaymentProvider(p PaymentProvider) {
+	paymentProviders[p.Name()] = p
+}
+
+// PaymentProviderFor: ผู้ให้บริการตามชื่อ ("" = ค่าเริ่มต้น)
+func PaymentProviderFor(name string) (PaymentProvider, error) {
+	if name = strings.ToLower(strings.TrimSpace(name)); name == "" {
+		name = defaultPaymentProvider
+	}
+	p, ok := paymentProviders[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrPaymentProviderUnknown, name)
+	}
+	return p, nil
+}
+
+// PaymentProviderNames: ผู้ให้บริการที่เปิดใช้ (เรียงตามชื่อ)
+func PaymentProviderNames() []string {
+	names := make([]string, 0, len(paymentProviders))
+	for name := range paymentProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// InitPayments: ลงทะเบียนผู้ให้บริการตาม ENV
+func InitPayments() {
+	if enabled, _ := strconv.ParseBool(os.Getenv("PAYMENT_MOCK_ENABLED")); enabled {
+		secret := []byte(os.Getenv("PAYMENT_MOCK_SECRET"))
+		if len(secret) == 0 {
+			secret = make([]byte, 32)
+			if _, err := rand.Read(secret); err != nil {
+				log.Fatalf("❌ [Payment] generate mock secret: %v", err)
+			}
+		}
+		MockPayments = NewMockProvider(secret)
+		RegisterPaymentProvider(MockPayments)
+	}
+
+	defaultPaymentProvider = strings.ToLower(os.Getenv("PAYMENT_DEFAULT_PROVIDER"))
+	if names := PaymentProviderNames(); defaultPaymentProvider == "" && len(names) == 1 {
+		defaultPaymentProvider = names[0]
+	}
+	if len(paymentProviders) > 0 {
+		log.Printf("✅ [Payment] providers: %s (default %q)", strings.Join(PaymentProviderNames(), ", "), defaultPaymentProvider)
+	}
+}
+
+// SignWebhook: ลายเซ็น Webhook ตามรูปแบบกลาง (ผู้ให้บริการที่ใช้รูปแบบอื่นตรวจเองใน ParseWebhook)
+func SignWebhook(secret []byte, timestamp int64, body []byte) string {
+	return hex.EncodeToString(hmacSHA256(secret, strconv.FormatInt(timestamp, 10)+"."+string(body)))
+}
+
+// VerifyWebhookSignature: ตรวจลายเซ็น + เวลาที่ลงไว้ต้องไม่ห่างจากตอนนี้เกิน webhookTolerance
+func VerifyWebhookSignature(secret []byte, header func(string) string, body []byte, now time.Time) error {
+	ts, err := strconv.ParseInt(header(PaymentTimestampHeader), 10, 64)
+	if err != nil {
+		return ErrWebhookSignature
+	}
+	sig, err := hex.DecodeString(header(PaymentSignatureHeader))
+	if err != nil {
+		return ErrWebhookSignature
+	}
+	if !hmac.Equal(sig, hmacSHA256(secret, strconv.FormatInt(ts, 10)+"."+string(body))) {
+		return ErrWebhookSignature
+	}
+	if d := now.Sub(time.Unix(ts, 0)); d > webhookTolerance || d < -webhookTolerance {
+		return ErrWebhookExpired
+	}
+	return nil
+}
+
+// ProcessWebhook: รับ Webhook 1 ครั้ง (duplicate = เคยรับ event นี้แล้ว ไม่ทำอะไรซ้ำ)
+// error ที่ไม่ใช่ลายเซ็นผิด = ยังไม่บันทึก event ผู้ให้บริการส่งซ้ำมาใหม่ได้
+func ProcessWebhook(db *gorm.DB, p PaymentProvider, header func(string) string, body []byte, now time.Time) (order *models.PaymentOrder, duplicate bool, err error) {
+	res, err := p.ParseWebhook(header, body, now)
+	if err != nil {
+		return nil, false, err
+	}
+	if res.EventID == "" {
+		res.EventID = res.ProviderRef + ":" + res.Status
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		found, err := paymentOrderByRef(tx, p.Name(), res)
+		if err != nil {
+			return err
+		}
+		order = found
+
+		ins := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.PaymentWebhookEvent{
+			Provider:   p.Name(),
+			EventID:    res.EventID,
+			OrderID:    order.ID,
+			Status:     res.Status,
+			Payload:    string(body),
+			ReceivedAt: now,
+		})
+		if ins.Error != nil {
+			return ins.Error
+		}
+		if ins.RowsAffected == 0 {
+			duplicate = true
+			return nil
+		}
+		return ApplyPaymentResult(tx, order, res, now)
+	})
+	return order, duplicate, err
+}
+
+// paymentOrderByRef: คำสั่งของผู้ให้บริการนี้จากเลขอ้างอิงฝั่งเรา (ไม่มีใช้เลขฝั่งผู้ให้บริการ)
+func paymentOrderByRef(tx *gorm.DB, provider string, res *PaymentResult) (*models.PaymentOrder, error) {
+	query := tx.Where("provider = ?", provider)
+	id, err := strconv.ParseUint(strings.TrimPrefix(res.MerchantRef, "PO"), 10, 64)
+	switch {
+	case err == nil && strings.HasPrefix(res.MerchantRef, "PO"):
+		query = query.Where("id = ?", id)
+	case res.ProviderRef != "":
+		query = query.Where("provider_ref = ?", res.ProviderRef)
+	default:
+		return nil, ErrPaymentOrderNotFound
+	}
+	var order models.PaymentOrder
+	if err := query.First(&order).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPaymentOrderNotFound
+		}
+		return nil, err
+	}
+	return &order, nil
+}
+
+// ApplyPaymentResult: เปลี่ยนสถานะคำสั่ง + Transaction ตามผลจากผู้ให้บริการ
+// ทำได้ครั้งเดียวตอนคำสั่งยัง pending (ล็อกแถวก่อน) ผลซ้ำ/มาช้าหลังจบแล้ว = ไม่ทำอะไร
+// ยอดไม่ตรง หรือ Transaction ถูก Admin ดำเนินการไปก่อน = mismatch ให้ Admin ตรวจเอง
+func ApplyPaymentResult(tx *gorm.DB, order *models.PaymentOrder, res *PaymentResult, now time.Time) error {
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(order, order.ID).Error; err != nil {
+		return err
+	}
+	if order.Status != models.PaymentPending {
+		return nil
+	}
+	updates := map[string]interface{}{}
+	if res.ProviderRef != "" && order.ProviderRef == "" {
+		order.ProviderRef = res.ProviderRef
+		updates["provider_ref"] = res.ProviderRef
+	}
+	if res.PayURL != "" && order.PayURL == "" {
+		order.PayURL = res.PayURL
+		updates["pay_url"] = res.PayURL
+	}
+	if res.Status != models.PaymentSucceeded && res.Status != models.PaymentFailed {
+		if len(updates) == 0 {
+			return nil
+		}
+		return tx.Model(order).Updates(updates).Error
+	}
+
+	var t models.Transaction
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&t, order.TransactionID).Error; err != nil {
+		return err
+	}
+	status, reason := res.Status, strings.TrimSpace(res.Reason)
+	if status == models.PaymentFailed && reason == "" {
+		reason = "ผู้ให้บริการแจ้งว่าไม่สำเร็จ"
+	}
+	wantState := "pending"
+	if order.Kind == "payout" {
+		wantState = "approved"
+	}
+
+	switch {
+	case status == models.PaymentSucceeded && res.Amount != 0 && res.Amount != order.Amount:
+		status, reason = models.PaymentMismatch, fmt.Sprintf("ยอดจากผู้ให้บริการ %s ไม่ตรงกับรายการ %s", res.Amount, order.Amount)
+	case t.Status != wantState:
+		if status == models.PaymentSucceeded {
+			status, reason = models.PaymentMismatch, fmt.Sprintf("ผู้ให้บริการแจ้งสำเร็จแต่รายการเป็น %s แล้ว", t.Status)
+		}
+	case order.Kind == "deposit" && status == models.PaymentSucceeded:
+		if _, err := ApproveDeposit(tx, &t, nil); err != nil {
+			return err
+		}
+	case order.Kind == "deposit":
+		t.Status = "rejected"
+		t.Note = strings.TrimSpace(t.Note + " ชำระไม่สำเร็จ: " + reason)
+		if err := tx.Model(&t).Updates(map[string]interface{}{"status": t.Status, "note": t.Note}).Error; err != nil {
+			return err
+		}
+	case status == models.PaymentSucceeded:
+		if err := MarkWithdrawalPaid(tx, &t, payoutAdmin(order), order.Provider+":"+order.ProviderRef, nil, now); err != nil {
+			return err
+		}
+	default:
+		if err := FailWithdrawal(tx, &t, payoutAdmin(order), order.Provider+": "+reason, now); err != nil {
+			return err
+		}
+	}
+	if status == models.PaymentMismatch {
+		log.Printf("⚠️ [Payment] order #%d (%s) mismatch: %s", order.ID, order.Provider, reason)
+	}
+
+	order.Status, order.Reason = status, reason
+	updates["status"], updates["reason"] = status, reason
+	return tx.Model(order).Updates(updates).Error
+}
+
+func payoutAdmin(order *models.PaymentOrder) uint {
+	if order.RequestedBy == nil {
+		return 0
+	}
+	return *order.RequestedBy
+}
+
+// CreateGatewayDeposit: สร้างรายการฝาก pending + คำสั่งฝากกับผู้ให้บริการ (ได้ PayURL ให้สมาชิกไปชำระ)
+// เรียกผู้ให้บริการนอก DB Transaction เรียกไม่สำเร็จ = ปฏิเสธรายการฝากทันที
+func CreateGatewayDeposit(db *gorm.DB, p PaymentProvider, user *models.User, amount models.Money, promotionID *uint) (*models.PaymentOrder, *models.Transaction, error) {
+	t := models.Transaction{
+		UserID:      user.ID,
+		Amount:      amount,
+		Type:        "deposit",
+		Status:      "pending",
+		PromotionID: promotionID,
+		Note:        "ฝากผ่าน " + p.Name(),
+	}
+	order := models.PaymentOrder{
+		Provider: p.Name(),
+		Kind:     "deposit",
+		Amount:   amount,
+		Currency: withdrawalCurrency(*user),
+		Status:   models.PaymentPending,
+	}
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&t).Error; err != nil {
+			return err
+		}
+		order.TransactionID = t.ID
+		return tx.Create(&order).Error
+	}); err != nil {
+		return nil, nil, err
+	}
+
+	res, err := p.CreateDeposit(&order, user)
+	if err != nil {
+		log.Printf("❌ [Payment] %s create deposit #%d: %v", p.Name(), order.ID, err)
+		res = &PaymentResult{Status: models.PaymentFailed, Reason: err.Error()}
+	}
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		return ApplyPaymentResult(tx, &order, res, time.Now())
+	}); err != nil {
+		return nil, nil, err
+	}
+	db.First(&t, t.ID)
+	if order.Status == models.PaymentFailed {
+		return &order, &t, fmt.Errorf("%w: %s", ErrPaymentProvider, order.Reason)
+	}
+	return &order, &t, nil
+}
+
+// CreateGatewayPayout: สั่งจ่ายรายการถอนที่อนุมัติครบแล้วผ่านผู้ให้บริการ
+// ระหว่างรอผล Admin บันทึกโอนเอง/โอนไม่สำเร็จไม่ได้ (CheckNoPendingPayout)
+// ผู้ให้บริการปฏิเสธคำสั่ง = รายการถอนยังอนุมัติอยู่ สั่งจ่ายใหม่หรือโอนเองได้
+func CreateGatewayPayout(db *gorm.DB, p PaymentProvider, transactionID uint, adminID uint) (*models.PaymentOrder, error) {
+	var order models.PaymentOrder
+	var t models.Transaction
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&t, transactionID).Error; err != nil {
+			return err
+		}
+		if t.Type != "withdraw" || t.Status != "approved" {
+			return ErrWithdrawalState
+		}
+		if err := CheckNoPendingPayout(tx, t.ID); err != nil {
+			return err
+		}
+		var member models.User
+		if err := tx.First(&member, t.UserID).Error; err != nil {
+			return err
+		}
+		order = models.PaymentOrder{
+			TransactionID: t.ID,
+			Provider:      p.Name(),
+			Kind:          "payout",
+			Amount:        t.Amount,
+			Currency:      withdrawalCurrency(member),
+			Status:        models.PaymentPending,
+			RequestedBy:   &adminID,
+		}
+		return tx.Create(&order).Error
+	}); err != nil {
+		return nil, err
+	}
+
+	res, err := p.CreatePayout(&order, &t)
+	if err != nil {
+		log.Printf("❌ [Payment] %s create payout #%d: %v", p.Name(), order.ID, err)
+		// ยังไม่ได้ส่งเงิน ปิดคำสั่งนี้อย่างเดียว ไม่คืนยอดพัก (FailWithdrawal) ให้ Admin เลือกทางต่อ
+		order.Status, order.Reason = models.PaymentFailed, err.Error()
+		db.Model(&order).Updates(map[string]interface{}{"status": order.Status, "reason": order.Reason})
+		return &order, fmt.Errorf("%w: %v", ErrPaymentProvider, err)
+	}
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		return ApplyPaymentResult(tx, &order, res, time.Now())
+	}); err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// CheckNoPendingPayout: รายการถอนนี้ไม่มีคำสั่งจ่ายที่รอผลจากผู้ให้บริการอยู่
+func CheckNoPendingPayout(tx *gorm.DB, transactionID uint) error {
+	var n int64
+	if err := tx.Model(&models.PaymentOrder{}).
+		Where("transaction_id = ? AND kind = ? AND status = ?", transactionID, "payout", models.PaymentPending).
+		Count(&n).Error; err != nil {
+		return err
+	}
+	if n > 0 {
+		return ErrPayoutInFlight
+	}
+	return nil
+}
+
+// SyncPayment: ถามสถานะคำสั่งจากผู้ให้บริการแล้วอัปเดต (ใช้แทน Webhook ที่หาย)
+func SyncPayment(db *gorm.DB, order *models.PaymentOrder, now time.Time) error {
+	p, err := PaymentProviderFor(order.Provider)
+	if err != nil {
+		return err
+	}
+	if order.ProviderRef == "" {
+		return nil // ผู้ให้บริการยังไม่ได้รับคำสั่ง ไม่มีอะไรให้ถาม
+	}
+	res, err := p.QueryStatus(order)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrPaymentProvider, err)
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		return ApplyPaymentResult(tx, order, res, now)
+	})
+}
+
+// RunPaymentSync: job ถามสถานะคำสั่งที่รอนานเกิน paymentSyncAfter
+func RunPaymentSync() error {
+	now := time.Now()
+	var orders []models.PaymentOrder
+	if err := database.DB.Where("status = ? AND created_at < ?", models.PaymentPending, now.Add(-paymentSyncAfter)).
+		Order("id").Limit(200).Find(&orders).Error; err != nil {
+		return err
+	}
+	synced := 0
+	for i := range orders {
+		if err := SyncPayment(database.DB, &orders[i], now); err != nil {
+			log.Printf("⚠️ [Payment] sync order #%d (%s): %v", orders[i].ID, orders[i].Provider, err)
+			continue
+		}
+		if orders[i].Status != models.PaymentPending {
+			synced++
+		}
+	}
+	if synced > 0 {
+		log.Printf("✅ [Payment] Synced %d orders", synced)
+	}
+	return nil
+}
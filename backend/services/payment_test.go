@@ -0,0 +1,341 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/PawornpratKongdaeng/soccer/models"
+	"gorm.io/gorm"
+)
+
+// signedHeader: header ของ Webhook ที่ลงลายเซ็นด้วย secret ณ เวลา ts
+func signedHeader(secret []byte, ts int64, body []byte) func(string) string {
+	headers := map[string]string{
+		PaymentTimestampHeader: strconv.FormatInt(ts, 10),
+		PaymentSignatureHeader: SignWebhook(secret, ts, body),
+	}
+	return func(name string) string { return headers[name] }
+}
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	secret := []byte("webhook-secret")
+	body := []byte(`{"event_id":"E1","merchant_ref":"PO1","status":"succeeded"}`)
+	now := time.Unix(1_700_000_000, 0)
+	signedAt := func(d time.Duration) func(string) string {
+		return signedHeader(secret, now.Add(d).Unix(), body)
+	}
+
+	tests := []struct {
+		name   string
+		secret []byte
+		header func(string) string
+		body   []byte
+		want   error
+	}{
+		{"valid", secret, signedAt(0), body, nil},
+		{"valid at edge of window", secret, signedAt(-webhookTolerance), body, nil},
+		{"valid slightly ahead", secret, signedAt(time.Minute), body, nil},
+		{"tampered body", secret, signedAt(0), []byte(`{"event_id":"E1","merchant_ref":"PO1","status":"failed"}`), ErrWebhookSignature},
+		{"wrong secret", []byte("other-secret"), signedAt(0), body, ErrWebhookSignature},
+		{"missing headers", secret, func(string) string { return "" }, body, ErrWebhookSignature},
+		{"signature not hex", secret, func(name string) string {
+			if name == PaymentSignatureHeader {
+				return "not-hex"
+			}
+			return signedAt(0)(name)
+		}, body, ErrWebhookSignature},
+		{"timestamp changed after signing", secret, func(name string) string {
+			if name == PaymentTimestampHeader {
+				return strconv.FormatInt(now.Unix()+1, 10)
+			}
+			return signedAt(0)(name)
+		}, body, ErrWebhookSignature},
+		{"too old (replayed)", secret, signedAt(-webhookTolerance - time.Second), body, ErrWebhookExpired},
+		{"too far in the future", secret, signedAt(webhookTolerance + time.Second), body, ErrWebhookExpired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyWebhookSignature(tt.secret, tt.header, tt.body, now)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("VerifyWebhookSignature() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestMockParseWebhook(t *testing.T) {
+	secret := []byte("mock-secret")
+	m := NewMockProvider(secret)
+	now := time.Now()
+	body, _ := json.Marshal(PaymentResult{EventID: "E1", MerchantRef: "PO7", ProviderRef: "MOCK-D000001", Status: models.PaymentSucceeded, Amount: models.NewMoney(500)})
+
+	res, err := m.ParseWebhook(signedHeader(secret, now.Unix(), body), body, now)
+	if err != nil {
+		t.Fatalf("ParseWebhook() error = %v", err)
+	}
+	if res.EventID != "E1" || res.MerchantRef != "PO7" || res.Status != models.PaymentSucceeded || res.Amount != models.NewMoney(500) {
+		t.Errorf("ParseWebhook() = %+v", res)
+	}
+
+	if _, err := m.ParseWebhook(signedHeader([]byte("other"), now.Unix(), body), body, now); !errors.Is(err, ErrWebhookSignature) {
+		t.Errorf("ParseWebhook() with foreign signature = %v, want %v", err, ErrWebhookSignature)
+	}
+	stale := now.Add(-time.Hour)
+	if _, err := m.ParseWebhook(signedHeader(secret, stale.Unix(), body), body, now); !errors.Is(err, ErrWebhookExpired) {
+		t.Errorf("ParseWebhook() with stale timestamp = %v, want %v", err, ErrWebhookExpired)
+	}
+}
+
+// mockDeposit: สมาชิกใหม่ + รายการฝากผ่าน mock ที่รอชำระ
+func mockDeposit(t *testing.T, db *gorm.DB, m *MockProvider, username string, amount models.Money) (models.User, *models.PaymentOrder) {
+	t.Helper()
+	user := createTestUser(t, db, username, 0)
+	order, _, err := CreateGatewayDeposit(db, m, &user, amount, nil)
+	if err != nil {
+		t.Fatalf("CreateGatewayDeposit() error = %v", err)
+	}
+	if order.Status != models.PaymentPending || order.ProviderRef == "" {
+		t.Fatalf("new order = %+v, want pending with provider ref", order)
+	}
+	return user, order
+}
+
+func loadTransaction(t *testing.T, db *gorm.DB, id uint) models.Transaction {
+	t.Helper()
+	var tx models.Transaction
+	if err := db.First(&tx, id).Error; err != nil {
+		t.Fatalf("load transaction %d: %v", id, err)
+	}
+	return tx
+}
+
+func TestWebhookDeposit(t *testing.T) {
+	db := openTestDB(t)
+	amount := models.NewMoney(500)
+
+	tests := []struct {
+		name       string
+		status     string
+		amount     models.Money
+		wantOrder  string
+		wantTx     string
+		wantCredit models.Money
+	}{
+		{"succeeded credits wallet", models.PaymentSucceeded, 0, models.PaymentSucceeded, "approved", amount},
+		{"failed rejects deposit", models.PaymentFailed, 0, models.PaymentFailed, "rejected", 0},
+		{"amount differs is mismatch", models.PaymentSucceeded, models.NewMoney(499), models.PaymentMismatch, "pending", 0},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMockProvider([]byte("mock-secret"))
+			user, order := mockDeposit(t, db, m, "pay_dep_"+strconv.Itoa(i), amount)
+
+			got, duplicate, err := m.Complete(db, order.ProviderRef, tt.status, "", tt.amount)
+			if err != nil || duplicate {
+				t.Fatalf("Complete() = duplicate %v, error %v", duplicate, err)
+			}
+			if got.Status != tt.wantOrder {
+				t.Errorf("order status = %q, want %q", got.Status, tt.wantOrder)
+			}
+			if tx := loadTransaction(t, db, order.TransactionID); tx.Status != tt.wantTx {
+				t.Errorf("transaction status = %q, want %q", tx.Status, tt.wantTx)
+			}
+			if u := reloadUser(t, db, user.ID); u.Credit != tt.wantCredit {
+				t.Errorf("credit = %s, want %s", u.Credit, tt.wantCredit)
+			}
+		})
+	}
+}
+
+func TestWebhookReplayAndIdempotency(t *testing.T) {
+	db := openTestDB(t)
+	secret := []byte("mock-secret")
+	m := NewMockProvider(secret)
+	amount := models.NewMoney(300)
+	user, order := mockDeposit(t, db, m, "pay_replay", amount)
+
+	body, _ := json.Marshal(PaymentResult{EventID: "evt-1", MerchantRef: order.MerchantRef(), ProviderRef: order.ProviderRef, Status: models.PaymentSucceeded, Amount: amount})
+	now := time.Now()
+	header := signedHeader(secret, now.Unix(), body)
+
+	if _, duplicate, err := ProcessWebhook(db, m, header, body, now); err != nil || duplicate {
+		t.Fatalf("first delivery = duplicate %v, error %v", duplicate, err)
+	}
+
+	t.Run("same event delivered again", func(t *testing.T) {
+		_, duplicate, err := ProcessWebhook(db, m, header, body, now.Add(time.Minute))
+		if err != nil || !duplicate {
+			t.Errorf("redelivery = duplicate %v, error %v, want duplicate", duplicate, err)
+		}
+	})
+
+	t.Run("captured request replayed after window", func(t *testing.T) {
+		_, _, err := ProcessWebhook(db, m, header, body, now.Add(webhookTolerance+time.Minute))
+		if !errors.Is(err, ErrWebhookExpired) {
+			t.Errorf("replay = %v, want %v", err, ErrWebhookExpired)
+		}
+	})
+
+	t.Run("late failure after success changes nothing", func(t *testing.T) {
+		got, duplicate, err := m.Complete(db, order.ProviderRef, models.PaymentFailed, "late", 0)
+		if err != nil || duplicate {
+			t.Fatalf("Complete() = duplicate %v, error %v", duplicate, err)
+		}
+		if got.Status != models.PaymentSucceeded {
+			t.Errorf("order status = %q, want %q", got.Status, models.PaymentSucceeded)
+		}
+		if tx := loadTransaction(t, db, order.TransactionID); tx.Status != "approved" {
+			t.Errorf("transaction status = %q, want approved", tx.Status)
+		}
+	})
+
+	if u := reloadUser(t, db, user.ID); u.Credit != amount {
+		t.Errorf("credit = %s, want %s (credited once)", u.Credit, amount)
+	}
+	var events int64
+	db.Model(&models.PaymentWebhookEvent{}).Where("order_id = ?", order.ID).Count(&events)
+	if events != 2 {
+		t.Errorf("webhook events = %d, want 2", events)
+	}
+}
+
+func TestWebhookUnknownOrder(t *testing.T) {
+	db := openTestDB(t)
+	secret := []byte("mock-secret")
+	m := NewMockProvider(secret)
+	body, _ := json.Marshal(PaymentResult{EventID: "evt-x", MerchantRef: "PO999999999", Status: models.PaymentSucceeded})
+	now := time.Now()
+
+	if _, _, err := ProcessWebhook(db, m, signedHeader(secret, now.Unix(), body), body, now); !errors.Is(err, ErrPaymentOrderNotFound) {
+		t.Fatalf("ProcessWebhook() = %v, want %v", err, ErrPaymentOrderNotFound)
+	}
+	// ไม่บันทึก event ไว้ ผู้ให้บริการส่งซ้ำมาได้หลังคำสั่งถูกสร้าง
+	var events int64
+	db.Model(&models.PaymentWebhookEvent{}).Where("event_id = ?", "evt-x").Count(&events)
+	if events != 0 {
+		t.Errorf("webhook events = %d, want 0", events)
+	}
+}
+
+// approvedWithdrawal: รายการถอนที่อนุมัติครบแล้ว (ยอดพักไว้ใน wallet_held) พร้อมสั่งจ่าย
+func approvedWithdrawal(t *testing.T, db *gorm.DB, user models.User, amount models.Money) models.Transaction {
+	t.Helper()
+	w := models.Transaction{UserID: user.ID, Amount: amount, Type: "withdraw", Status: "pending", BankName: user.BankName, BankAccount: user.BankAccount}
+	if err := db.Create(&w).Error; err != nil {
+		t.Fatalf("create withdraw: %v", err)
+	}
+	if err := PostWithdrawRequest(db, &w); err != nil {
+		t.Fatalf("PostWithdrawRequest() error = %v", err)
+	}
+	if err := db.Model(&w).Update("status", "approved").Error; err != nil {
+		t.Fatalf("approve withdraw: %v", err)
+	}
+	return loadTransaction(t, db, w.ID)
+}
+
+func TestWebhookPayout(t *testing.T) {
+	db := openTestDB(t)
+	funded := models.NewMoney(1000)
+	amount := models.NewMoney(400)
+
+	tests := []struct {
+		name       string
+		status     string
+		wantTx     string
+		wantCredit models.Money
+		wantHeld   models.Money
+	}{
+		{"succeeded marks paid", models.PaymentSucceeded, "paid", funded - amount, 0},
+		{"failed refunds held credit", models.PaymentFailed, "failed", funded, 0},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMockProvider([]byte("mock-secret"))
+			user := createTestUser(t, db, "pay_out_"+strconv.Itoa(i), funded)
+			w := approvedWithdrawal(t, db, user, amount)
+
+			order, err := CreateGatewayPayout(db, m, w.ID, 1)
+			if err != nil {
+				t.Fatalf("CreateGatewayPayout() error = %v", err)
+			}
+			if _, err := CreateGatewayPayout(db, m, w.ID, 1); !errors.Is(err, ErrPayoutInFlight) {
+				t.Errorf("second payout = %v, want %v", err, ErrPayoutInFlight)
+			}
+
+			if _, _, err := m.Complete(db, order.ProviderRef, tt.status, "bank closed", 0); err != nil {
+				t.Fatalf("Complete() error = %v", err)
+			}
+			if got := loadTransaction(t, db, w.ID); got.Status != tt.wantTx {
+				t.Errorf("withdraw status = %q, want %q", got.Status, tt.wantTx)
+			}
+			u := reloadUser(t, db, user.ID)
+			if u.Credit != tt.wantCredit || u.HeldCredit != tt.wantHeld {
+				t.Errorf("credit/held = %s/%s, want %s/%s", u.Credit, u.HeldCredit, tt.wantCredit, tt.wantHeld)
+			}
+
+			// ผลซ้ำหลังจบแล้วไม่คืนยอด/ตัดยอดซ้ำ
+			if _, duplicate, err := m.Complete(db, order.ProviderRef, tt.status, "bank closed", 0); err != nil || !duplicate {
+				t.Errorf("repeated Complete() = duplicate %v, error %v, want duplicate", duplicate, err)
+			}
+			if again := reloadUser(t, db, user.ID); again.Credit != u.Credit || again.HeldCredit != u.HeldCredit {
+				t.Errorf("balance changed on repeat: %s/%s", again.Credit, again.HeldCredit)
+			}
+		})
+	}
+}
+
+func TestPayoutRejectedByProvider(t *testing.T) {
+	db := openTestDB(t)
+	m := NewMockProvider([]byte("mock-secret"))
+	user := createTestUser(t, db, "pay_out_noacct", models.NewMoney(1000))
+	w := approvedWithdrawal(t, db, user, models.NewMoney(200))
+	db.Model(&w).Update("bank_account", "")
+
+	order, err := CreateGatewayPayout(db, m, w.ID, 1)
+	if !errors.Is(err, ErrPaymentProvider) {
+		t.Fatalf("CreateGatewayPayout() = %v, want %v", err, ErrPaymentProvider)
+	}
+	if order.Status != models.PaymentFailed {
+		t.Errorf("order status = %q, want %q", order.Status, models.PaymentFailed)
+	}
+	// รายการถอนยังอนุมัติอยู่ และสั่งจ่ายใหม่ได้ (ไม่ติด ErrPayoutInFlight)
+	if got := loadTransaction(t, db, w.ID); got.Status != "approved" {
+		t.Errorf("withdraw status = %q, want approved", got.Status)
+	}
+	if err := CheckNoPendingPayout(db, w.ID); err != nil {
+		t.Errorf("CheckNoPendingPayout() = %v, want nil", err)
+	}
+}
+
+func TestSyncPayment(t *testing.T) {
+	db := openTestDB(t)
+	m := NewMockProvider([]byte("mock-secret"))
+	RegisterPaymentProvider(m)
+	t.Cleanup(func() { delete(paymentProviders, m.Name()) })
+
+	amount := models.NewMoney(250)
+	user, order := mockDeposit(t, db, m, "pay_sync", amount)
+
+	// ผู้ให้บริการรับเงินแล้วแต่ Webhook หาย
+	m.payments[order.ProviderRef].status = models.PaymentSucceeded
+
+	if err := SyncPayment(db, order, time.Now()); err != nil {
+		t.Fatalf("SyncPayment() error = %v", err)
+	}
+	if order.Status != models.PaymentSucceeded {
+		t.Errorf("order status = %q, want %q", order.Status, models.PaymentSucceeded)
+	}
+	// Webhook มาช้าหลัง sync แล้ว = ไม่ลงยอดซ้ำ
+	if _, _, err := m.Complete(db, order.ProviderRef, models.PaymentSucceeded, "", 0); err != nil {
+		t.Fatalf("late Complete() error = %v", err)
+	}
+	if u := reloadUser(t, db, user.ID); u.Credit != amount {
+		t.Errorf("credit = %s, want %s", u.Credit, amount)
+	}
+}
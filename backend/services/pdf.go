@@ -0,0 +1,106 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// ==========================================
+// PDF แบบข้อความล้วน (ไม่พึ่ง Library ภายนอก)
+// ==========================================
+// A4 แนวตั้ง ฟอนต์ Courier (ฟอนต์มาตรฐานของ PDF ไม่ต้องฝัง) ตัวอักษรกว้างเท่ากันจึงจัดคอลัมน์ด้วยช่องว่างได้
+// header จะพิมพ์ซ้ำทุกหน้า ส่วน body ตัดขึ้นหน้าใหม่อัตโนมัติ
+
+const (
+	pdfPageWidth  = 595
+	pdfPageHeight = 842
+	pdfMargin     = 40
+	pdfFontSize   = 8
+	pdfLeading    = 11
+)
+
+// pdfSafe: ตัดอักขระที่ Courier (WinAnsi) แสดงไม่ได้ออก แทนด้วย ?
+func pdfSafe(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= 0x20 && r < 0x7f {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+func pdfEscape(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `(`, `\(`, `)`, `\)`)
+	return r.Replace(pdfSafe(s))
+}
+
+// renderTextPDF: สร้างไฟล์ PDF จากบรรทัดข้อความ
+func renderTextPDF(header, body []string) []byte {
+	perPage := (pdfPageHeight-2*pdfMargin)/pdfLeading - len(header) - 2 // เว้นบรรทัดเลขหน้า
+	if perPage < 1 {
+		perPage = 1
+	}
+
+	var pages [][]string
+	for start := 0; start < len(body) || start == 0; start += perPage {
+		end := start + perPage
+		if end > len(body) {
+			end = len(body)
+		}
+		pages = append(pages, body[start:end])
+		if end == len(body) {
+			break
+		}
+	}
+
+	// Object: 1 = Catalog, 2 = Pages, 3 = Font, แล้วหน้าละ 2 Object (Page + Content)
+	var objects []string
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 4+i*2)
+	}
+	objects = append(objects,
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>",
+	)
+
+	for i, lines := range pages {
+		var content bytes.Buffer
+		fmt.Fprintf(&content, "BT /F1 %d Tf %d TL %d %d Td\n", pdfFontSize, pdfLeading, pdfMargin, pdfPageHeight-pdfMargin)
+		for _, l := range header {
+			fmt.Fprintf(&content, "(%s) Tj T*\n", pdfEscape(l))
+		}
+		for _, l := range lines {
+			fmt.Fprintf(&content, "(%s) Tj T*\n", pdfEscape(l))
+		}
+		fmt.Fprintf(&content, "T* (%s) Tj\nET", pdfEscape(fmt.Sprintf("Page %d / %d", i+1, len(pages))))
+
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>",
+				pdfPageWidth, pdfPageHeight, 5+i*2),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", content.Len(), content.String()),
+		)
+	}
+
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	return out.Bytes()
+}
@@ -0,0 +1,512 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/PawornpratKongdaeng/soccer/database"
+	"github.com/PawornpratKongdaeng/soccer/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ==========================================
+// โปรโมชั่น / โบนัส (Promotions & Wagering)
+// ==========================================
+// โบนัสเข้ากระเป๋าโบนัส (บัญชี bonus) ไม่ใช่กระเป๋าหลัก: ใช้แทงได้ (ตัดเงินจริงก่อน ขาดค่อยตัดโบนัส) แต่ถอนไม่ได้
+// ระหว่างมีโบนัส active สมาชิกถอนเงินไม่ได้จนกว่าจะทำเทิร์นครบ
+// เทิร์นนับจากบิลที่เคลียร์แล้วและแทงหลังได้โบนัส (ชนะ/เสียเต็มนับเต็ม, ชนะ/เสียครึ่งนับครึ่ง, เสมอไม่นับ) แบบเดียวกับค่าคอม
+// ครบเทิร์น = ย้ายยอดโบนัสที่เหลือเข้ากระเป๋าหลัก (bonus_release)
+// ริบโบนัส = ยอดโบนัสที่เหลือกลับบัญชี promotion เมื่อ หมดอายุ / สมาชิกขอยกเลิก (เพื่อถอนเงิน) / Admin ยกเลิก
+// รับได้ครั้งละ 1 โปร (กระเป๋าโบนัสจึงเป็นของโปรที่ active อยู่ทั้งหมด)
+
+var (
+	ErrInvalidPromotion     = errors.New("invalid promotion")
+	ErrPromotionNotFound    = errors.New("promotion not found")
+	ErrPromotionNotEligible = errors.New("not eligible for promotion")
+	ErrBonusActive          = errors.New("another bonus is still active")
+	ErrBonusClosed          = errors.New("bonus already closed")
+	ErrWageringIncomplete   = errors.New("wagering requirement not met")
+)
+
+// NormalizePromoCode: โค้ดโปรเก็บเป็นตัวพิมพ์ใหญ่ไม่มีช่องว่าง
+func NormalizePromoCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// ValidatePromotion: ตรวจค่าที่ Admin ตั้ง (และจัดรูปโค้ด)
+func ValidatePromotion(p *models.Promotion) error {
+	if p.Code != nil {
+		code := NormalizePromoCode(*p.Code)
+		p.Code = &code
+		if code == "" {
+			p.Code = nil
+		}
+	}
+	if p.Currency == "" {
+		p.Currency = models.BaseCurrency
+	}
+
+	switch {
+	case strings.TrimSpace(p.Name) == "":
+		return fmt.Errorf("%w: name is required", ErrInvalidPromotion)
+	case p.Type != models.PromoTypeFirstDeposit && p.Type != models.PromoTypeReload && p.Type != models.PromoTypeFreeCredit:
+		return fmt.Errorf("%w: unknown type %q", ErrInvalidPromotion, p.Type)
+	case p.AmountType != models.BonusAmountFixed && p.AmountType != models.BonusAmountPercent:
+		return fmt.Errorf("%w: unknown amount type %q", ErrInvalidPromotion, p.AmountType)
+	case p.AmountType == models.BonusAmountFixed && p.Fixed <= 0:
+		return fmt.Errorf("%w: fixed amount must be positive", ErrInvalidPromotion)
+	case p.AmountType == models.BonusAmountPercent && (p.Percent <= 0 || p.Percent > 1000):
+		return fmt.Errorf("%w: percent must be between 0 and 1000", ErrInvalidPromotion)
+	case p.Type == models.PromoTypeFreeCredit && p.AmountType != models.BonusAmountFixed:
+		return fmt.Errorf("%w: free credit must be a fixed amount", ErrInvalidPromotion)
+	case p.Type == models.PromoTypeFreeCredit && p.Code == nil:
+		return fmt.Errorf("%w: free credit requires a code", ErrInvalidPromotion)
+	case !models.IsSupportedCurrency(p.Currency):
+		return fmt.Errorf("%w: unsupported currency %s", ErrInvalidPromotion, p.Currency)
+	case p.MaxBonus < 0 || p.MinDeposit < 0 || p.TurnoverMultiple < 0 || p.ValidDays < 0 || p.MaxClaimsPerUser < 0:
+		return fmt.Errorf("%w: values must not be negative", ErrInvalidPromotion)
+	case p.StartAt != nil && p.EndAt != nil && !p.EndAt.After(*p.StartAt):
+		return fmt.Errorf("%w: end must be after start", ErrInvalidPromotion)
+	}
+	return nil
+}
+
+// ResolvePromotion: หาโปรจาก ID หรือโค้ด (ใช้ตอนแจ้งฝาก/รับเครดิตฟรี)
+func ResolvePromotion(db *gorm.DB, id uint, code string) (*models.Promotion, error) {
+	var p models.Promotion
+	query := db.Where("active = ?", true)
+	switch {
+	case code != "":
+		query = query.Where("code = ?", NormalizePromoCode(code))
+	case id != 0:
+		// โปรที่มีโค้ดต้องกรอกโค้ดเท่านั้น เลือกจาก ID ไม่ได้
+		query = query.Where("id = ? AND code IS NULL", id)
+	default:
+		return nil, ErrPromotionNotFound
+	}
+	if err := query.First(&p).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPromotionNotFound
+		}
+		return nil, err
+	}
+	return &p, nil
+}
+
+// AvailablePromotions: โปรที่สมาชิกเลือกได้ในตอนนี้ (ไม่รวมโปรที่ต้องใช้โค้ด)
+func AvailablePromotions(db *gorm.DB, currency string, now time.Time) ([]models.Promotion, error) {
+	var promos []models.Promotion
+	err := db.Where("active = ? AND code IS NULL AND currency = ?", true, currency).
+		Where("(start_at IS NULL OR start_at <= ?) AND (end_at IS NULL OR end_at > ?)", now, now).
+		Order("id").Find(&promos).Error
+	return promos, err
+}
+
+// bonusAmount: ยอดโบนัสจากยอดฝาก (ไม่เกิน MaxBonus)
+func bonusAmount(p *models.Promotion, deposit models.Money) models.Money {
+	amount := p.Fixed
+	if p.AmountType == models.BonusAmountPercent {
+		amount = deposit.Percent(p.Percent)
+	}
+	if p.MaxBonus > 0 && amount > p.MaxBonus {
+		amount = p.MaxBonus
+	}
+	return amount
+}
+
+// checkEligible: เงื่อนไขการรับโปร (deposit = nil คือรับด้วยโค้ดเครดิตฟรี)
+func checkEligible(tx *gorm.DB, p *models.Promotion, user *models.User, deposit *models.Transaction, now time.Time) error {
+	notEligible := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%w: %s", ErrPromotionNotEligible, fmt.Sprintf(format, args...))
+	}
+
+	if !p.Active || (p.StartAt != nil && now.Before(*p.StartAt)) || (p.EndAt != nil && !now.Before(*p.EndAt)) {
+		return notEligible("promotion is not available")
+	}
+	if user.Currency != p.Currency {
+		return notEligible("promotion is for %s wallets", p.Currency)
+	}
+	if user.AccountMode == models.AccountModeCredit {
+		return notEligible("credit accounts cannot receive bonuses")
+	}
+	if (p.Type == models.PromoTypeFreeCredit) != (deposit == nil) {
+		if deposit == nil {
+			return notEligible("promotion requires a deposit")
+		}
+		return notEligible("promotion cannot be used with a deposit")
+	}
+	if deposit != nil && deposit.Amount < p.MinDeposit {
+		return notEligible("minimum deposit %s", p.MinDeposit)
+	}
+
+	if p.Type == models.PromoTypeFirstDeposit {
+		var deposits int64
+		if err := tx.Model(&models.Transaction{}).
+			Where("user_id = ? AND type = ? AND status = ? AND id <> ?", user.ID, "deposit", "approved", deposit.ID).
+			Count(&deposits).Error; err != nil {
+			return err
+		}
+		if deposits > 0 {
+			return notEligible("not the first deposit")
+		}
+	}
+
+	var claims int64
+	if err := tx.Model(&models.BonusGrant{}).Where("promotion_id = ? AND user_id = ?", p.ID, user.ID).Count(&claims).Error; err != nil {
+		return err
+	}
+	if (p.Type == models.PromoTypeFirstDeposit && claims > 0) || (p.MaxClaimsPerUser > 0 && claims >= int64(p.MaxClaimsPerUser)) {
+		return notEligible("claim limit reached")
+	}
+
+	var active int64
+	if err := tx.Model(&models.BonusGrant{}).Where("user_id = ? AND status = ?", user.ID, models.BonusStatusActive).Count(&active).Error; err != nil {
+		return err
+	}
+	if active > 0 {
+		return ErrBonusActive
+	}
+	return nil
+}
+
+// grantBonus: ออกโบนัสเข้ากระเป๋าโบนัส (ไม่ต้องทำเทิร์น = ย้ายเข้ากระเป๋าหลักทันที)
+func grantBonus(tx *gorm.DB, p *models.Promotion, user *models.User, deposit *models.Transaction, createdBy *uint, now time.Time) (*models.BonusGrant, error) {
+	var depositAmount models.Money
+	grant := models.BonusGrant{PromotionID: p.ID, UserID: user.ID, Status: models.BonusStatusActive}
+	if deposit != nil {
+		depositAmount = deposit.Amount
+		grant.TransactionID = &deposit.ID
+	}
+
+	grant.Amount = bonusAmount(p, depositAmount)
+	if grant.Amount <= 0 {
+		return nil, fmt.Errorf("%w: bonus amount is zero", ErrPromotionNotEligible)
+	}
+	base := grant.Amount
+	if p.WagerIncludesDeposit {
+		base += depositAmount
+	}
+	grant.Wagering = base.Mul(p.TurnoverMultiple)
+	if p.ValidDays > 0 {
+		expires := now.AddDate(0, 0, p.ValidDays)
+		grant.ExpiresAt = &expires
+	}
+	if err := tx.Create(&grant).Error; err != nil {
+		return nil, err
+	}
+
+	if _, err := PostJournal(tx, Journal{
+		Type:      "bonus",
+		RefType:   "bonus_grant",
+		RefID:     grant.ID,
+		Note:      "โบนัส " + p.Name,
+		CreatedBy: createdBy,
+		Postings: []Posting{
+			BonusPosting(user.ID, grant.Amount),
+			SystemPosting(models.LedgerAccountPromotion, -grant.Amount),
+		},
+	}); err != nil {
+		return nil, err
+	}
+
+	if grant.Wagering == 0 {
+		if err := releaseBonus(tx, &grant, now); err != nil {
+			return nil, err
+		}
+	}
+	return &grant, nil
+}
+
+// lockBonusBalance: ล็อกกระเป๋าของสมาชิกแล้วอ่านยอดโบนัสล่าสุด
+func lockBonusBalance(tx *gorm.DB, userID uint) (models.Money, error) {
+	var user models.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "bonus_credit").First(&user, userID).Error; err != nil {
+		return 0, err
+	}
+	return user.BonusCredit, nil
+}
+
+// releaseBonus: ทำเทิร์นครบ ย้ายยอดโบนัสที่เหลือทั้งหมดเข้ากระเป๋าหลัก
+func releaseBonus(tx *gorm.DB, grant *models.BonusGrant, now time.Time) error {
+	amount, err := lockBonusBalance(tx, grant.UserID)
+	if err != nil {
+		return err
+	}
+	if amount > 0 {
+		journal, err := PostJournal(tx, Journal{
+			Type:    "bonus_release",
+			RefType: "bonus_grant",
+			RefID:   grant.ID,
+			Note:    fmt.Sprintf("ทำเทิร์นครบ โบนัส #%d เข้ากระเป๋าหลัก", grant.ID),
+			Postings: []Posting{
+				WalletPosting(grant.UserID, amount),
+				BonusPosting(grant.UserID, -amount),
+			},
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := RecordTransaction(tx, journal, grant.UserID, models.Transaction{Type: "bonus_release", Note: journal.Note}); err != nil {
+			return err
+		}
+	}
+
+	grant.Status = models.BonusStatusCompleted
+	grant.Released = amount
+	grant.ClosedAt = &now
+	return tx.Model(grant).Updates(map[string]interface{}{
+		"status":    grant.Status,
+		"wagered":   grant.Wagered,
+		"released":  grant.Released,
+		"closed_at": grant.ClosedAt,
+	}).Error
+}
+
+// forfeitBonus: ริบยอดโบนัสที่เหลือกลับบัญชี promotion (status = forfeited หรือ expired)
+func forfeitBonus(tx *gorm.DB, grant *models.BonusGrant, status, reason string, createdBy *uint, now time.Time) error {
+	amount, err := lockBonusBalance(tx, grant.UserID)
+	if err != nil {
+		return err
+	}
+	if amount > 0 {
+		if _, err := PostJournal(tx, Journal{
+			Type:      "bonus_forfeit",
+			RefType:   "bonus_grant",
+			RefID:     grant.ID,
+			Note:      reason,
+			CreatedBy: createdBy,
+			Postings: []Posting{
+				BonusPosting(grant.UserID, -amount),
+				SystemPosting(models.LedgerAccountPromotion, amount),
+			},
+		}); err != nil {
+			return err
+		}
+	}
+
+	grant.Status = status
+	grant.Forfeited = amount
+	grant.ForfeitReason = reason
+	grant.ClosedAt = &now
+	return tx.Model(grant).Updates(map[string]interface{}{
+		"status":         grant.Status,
+		"forfeited":      grant.Forfeited,
+		"forfeit_reason": grant.ForfeitReason,
+		"closed_at":      grant.ClosedAt,
+	}).Error
+}
+
+// GrantDepositBonus: ออกโบนัสของโปรที่สมาชิกเลือกไว้ตอนแจ้งฝาก (เรียกหลัง PostDeposit ใน DB Transaction เดียวกัน)
+// ไม่ได้เลือกโปร = nil, nil / ไม่ตรงเงื่อนไข = ErrPromotionNotEligible หรือ ErrBonusActive (ผู้เรียกเลือกได้ว่าจะอนุมัติฝากต่อหรือไม่)
+func GrantDepositBonus(tx *gorm.DB, t *models.Transaction, adminID *uint) (*models.BonusGrant, error) {
+	if t.PromotionID == nil {
+		return nil, nil
+	}
+
+	var p models.Promotion
+	if err := tx.First(&p, *t.PromotionID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPromotionNotFound
+		}
+		return nil, err
+	}
+
+	var user models.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, t.UserID).Error; err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if err := checkEligible(tx, &p, &user, t, now); err != nil {
+		return nil, err
+	}
+	return grantBonus(tx, &p, &user, t, adminID, now)
+}
+
+// ClaimPromotion: รับเครดิตฟรีด้วยโค้ด
+func ClaimPromotion(userID uint, code string) (*models.BonusGrant, error) {
+	var grant *models.BonusGrant
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		p, err := ResolvePromotion(tx, 0, code)
+		if err != nil {
+			return err
+		}
+
+		// ล็อกสมาชิกก่อนเช็คเงื่อนไข กันกดรับซ้ำพร้อมกัน
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, userID).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if err := checkEligible(tx, p, &user, nil, now); err != nil {
+			return err
+		}
+		grant, err = grantBonus(tx, p, &user, nil, nil, now)
+		return err
+	})
+	return grant, err
+}
+
+// ActiveBonus: โบนัสที่กำลังทำเทิร์นของสมาชิก (nil = ไม่มี)
+func ActiveBonus(db *gorm.DB, userID uint) (*models.BonusGrant, error) {
+	var grant models.BonusGrant
+	err := db.Where("user_id = ? AND status = ?", userID, models.BonusStatusActive).First(&grant).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &grant, nil
+}
+
+// CheckWithdrawable: ถอนได้เมื่อไม่มีโบนัสที่ยังทำเทิร์นไม่ครบ
+func CheckWithdrawable(db *gorm.DB, userID uint) error {
+	grant, err := ActiveBonus(db, userID)
+	if err != nil || grant == nil {
+		return err
+	}
+	return fmt.Errorf("%w: remaining %s", ErrWageringIncomplete, grant.Remaining())
+}
+
+// AddWagering: นับเทิร์นจากบิลที่เพิ่งเคลียร์ (เรียกใน DB Transaction เดียวกับการเคลียร์บิล)
+func AddWagering(tx *gorm.DB, line SettlementLine) error {
+	var turnover models.Money
+	switch line.Status {
+	case models.BetStatusWin, models.BetStatusLoss:
+		turnover = line.Amount
+	case models.BetStatusWinHalf, models.BetStatusLoseHalf:
+		turnover = line.Amount.Percent(50)
+	default:
+		return nil
+	}
+
+	var grant models.BonusGrant
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ? AND status = ?", line.UserID, models.BonusStatusActive).First(&grant).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if grant.ExpiresAt != nil && now.After(*grant.ExpiresAt) {
+		return nil // หมดอายุแล้ว รองาน bonus-expire ริบ
+	}
+
+	// นับเฉพาะบิลที่แทงหลังได้โบนัส
+	var model interface{} = &models.BetSlip{}
+	if line.TicketType == "parlay" {
+		model = &models.ParlayTicket{}
+	}
+	var placedAt []time.Time
+	if err := tx.Model(model).Where("id = ?", line.TicketID).Pluck("created_at", &placedAt).Error; err != nil {
+		return err
+	}
+	if len(placedAt) == 0 || placedAt[0].Before(grant.CreatedAt) {
+		return nil
+	}
+
+	grant.Wagered += turnover
+	if grant.Wagered >= grant.Wagering {
+		return releaseBonus(tx, &grant, now)
+	}
+	return tx.Model(&grant).Update("wagered", grant.Wagered).Error
+}
+
+// CancelBonus: ยกเลิกโบนัส active แล้วริบยอดโบนัสที่เหลือ
+// ownerID != 0 = สมาชิกขอยกเลิกเอง (ต้องเป็นเจ้าของ), ownerID = 0 = Admin ยกเลิก
+func CancelBonus(grantID, ownerID uint, reason string, cancelledBy *uint) (*models.BonusGrant, error) {
+	var grant models.BonusGrant
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&grant, grantID).Error; err != nil {
+			return err
+		}
+		if ownerID != 0 && grant.UserID != ownerID {
+			return gorm.ErrRecordNotFound
+		}
+		if grant.Status != models.BonusStatusActive {
+			return ErrBonusClosed
+		}
+		return forfeitBonus(tx, &grant, models.BonusStatusForfeited, reason, cancelledBy, time.Now())
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &grant, nil
+}
+
+// ExpireBonuses: ริบโบนัสที่หมดอายุก่อนทำเทิร์นครบ
+func ExpireBonuses(now time.Time) (int, error) {
+	var grants []models.BonusGrant
+	if err := database.DB.Select("id").
+		Where("status = ? AND expires_at IS NOT NULL AND expires_at <= ?", models.BonusStatusActive, now).
+		Find(&grants).Error; err != nil {
+		return 0, err
+	}
+
+	expired, failed := 0, 0
+	for _, g := range grants {
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			var grant models.BonusGrant
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&grant, g.ID).Error; err != nil {
+				return err
+			}
+			if grant.Status != models.BonusStatusActive {
+				return nil
+			}
+			return forfeitBonus(tx, &grant, models.BonusStatusExpired, "หมดอายุก่อนทำเทิร์นครบ", nil, now)
+		})
+		if err != nil {
+			log.Printf("❌ [Bonus] Expire grant %d Error: %v", g.ID, err)
+			failed++
+			continue
+		}
+		expired++
+	}
+
+	if failed > 0 {
+		return expired, fmt.Errorf("%d bonuses failed to expire", failed)
+	}
+	return expired, nil
+}
+
+// RunBonusExpiry: งาน "bonus-expire" ใน Job Registry
+func RunBonusExpiry() error {
+	count, err := ExpireBonuses(time.Now())
+	if count > 0 {
+		log.Printf("✅ [Bonus] Expired %d bonuses", count)
+	}
+	return err
+}
+
+// PromotionStats: สรุปยอดของโปร 1 รายการ (ใช้ในรายงาน Admin)
+type PromotionStats struct {
+	Grants    int64        `json:"grants"`
+	Active    int64        `json:"active"`
+	Granted   models.Money `json:"granted"`
+	Released  models.Money `json:"released"`
+	Forfeited models.Money `json:"forfeited"`
+	Wagered   models.Money `json:"wagered"`
+}
+
+// GetPromotionStats: ยอดรวมของโบนัสที่ออกจากโปร promotionID
+func GetPromotionStats(db *gorm.DB, promotionID uint) (PromotionStats, error) {
+	var stats PromotionStats
+	err := db.Model(&models.BonusGrant{}).
+		Select(`COUNT(*) AS grants,
+			COUNT(*) FILTER (WHERE status = 'active') AS active,
+			COALESCE(SUM(amount), 0) AS granted,
+			COALESCE(SUM(released), 0) AS released,
+			COALESCE(SUM(forfeited), 0) AS forfeited,
+			COALESCE(SUM(wagered), 0) AS wagered`).
+		Where("promotion_id = ?", promotionID).Scan(&stats).Error
+	return stats, err
+}
@@ -0,0 +1,247 @@
+package services
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/PawornpratKongdaeng/soccer/database"
+	"github.com/PawornpratKongdaeng/soccer/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ==========================================
+// ฝากผ่าน QR PromptPay (Deposit Intent)
+// ==========================================
+// สมาชิกขอยอดฝาก -> ระบบเติมเศษสตางค์ 0.01-0.99 ที่ยังไม่มีใครใช้กับบัญชีรับเงินนี้ในช่วงเวลาชำระ
+// -> สร้าง QR (EMVCo) ยอดตายตัว + Transaction ฝาก pending
+// ยอดเข้าบัญชี (จาก Statement / Admin) หาคำขอได้จาก บัญชี + ยอดตรงสตางค์ + เวลา (MatchDepositIntent)
+
+const (
+	DepositIntentTTL   = 15 * time.Minute // เวลาชำระ (เศษสตางค์ถูกจองไว้จนเลยเวลาชำระไปอีก 1 TTL)
+	depositIntentGrace = 24 * time.Hour   // เลยเวลาชำระนานเท่านี้แล้วยังไม่มียอดเข้า = ปฏิเสธอัตโนมัติ
+)
+
+var (
+	ErrPromptPayUnavailable = errors.New("no receiving account accepts promptpay")
+	ErrPromptPayCurrency    = errors.New("promptpay supports THB only")
+	ErrIntentSlotsFull      = errors.New("all satang suffixes are in use for this amount")
+	ErrIntentNotFound       = errors.New("no matching deposit intent")
+)
+
+// PromptPayPayload: ข้อความ EMVCo Merchant-Presented QR ของ PromptPay แบบระบุยอด
+// target: เบอร์มือถือ 10 หลัก, เลขบัตรประชาชน/ผู้เสียภาษี 13 หลัก หรือ e-Wallet 15 หลัก
+func PromptPayPayload(target string, amount models.Money) (string, error) {
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, target)
+
+	var account string
+	switch {
+	case len(digits) == 10 && digits[0] == '0': // มือถือ 0812345678 -> 0066812345678
+		account = emvField("01", "0066"+digits[1:])
+	case len(digits) == 11 && strings.HasPrefix(digits, "66"):
+		account = emvField("01", "00"+digits)
+	case len(digits) == 13:
+		account = emvField("02", digits)
+	case len(digits) == 15:
+		account = emvField("03", digits)
+	default:
+		return "", fmt.Errorf("invalid promptpay id %q", target)
+	}
+	if amount <= 0 {
+		return "", fmt.Errorf("invalid amount %s", amount)
+	}
+
+	payload := emvField("00", "01") + // Payload Format Indicator
+		emvField("01", "12") + // ใช้ครั้งเดียว (มียอด)
+		emvField("29", emvField("00", "A000000677010111")+account) +
+		emvField("53", "764") + // THB
+		emvField("54", amount.String()) +
+		emvField("58", "TH") +
+		"6304"
+	return payload + fmt.Sprintf("%04X", crc16CCITT([]byte(payload))), nil
+}
+
+func emvField(id, value string) string {
+	return fmt.Sprintf("%s%02d%s", id, len(value), value)
+}
+
+// crc16CCITT: CRC-16/CCITT-FALSE (poly 0x1021, init 0xFFFF) ตามที่ EMVCo กำหนด
+func crc16CCITT(data []byte) uint16 {
+	crc := uint16(0xFFFF)
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// PromptPayAccount: บัญชีรับเงินที่ใช้ออก QR (ระบุ id หรือ 0 = บัญชีแรกที่เปิดใช้และมี PromptPay)
+func PromptPayAccount(db *gorm.DB, id uint) (*models.BankAccount, error) {
+	query := db.Where("is_active = ? AND prompt_pay_id <> ''", true)
+	if id != 0 {
+		query = query.Where("id = ?", id)
+	}
+	var bank models.BankAccount
+	if err := query.Order("id").First(&bank).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPromptPayUnavailable
+		}
+		return nil, err
+	}
+	return &bank, nil
+}
+
+// CreateDepositIntent: จองเศษสตางค์ + สร้าง Transaction ฝาก pending + QR Payload
+// ยอดขอปัดลงเป็นบาทเต็มก่อนเติมเศษ (ขอ 500.50 -> โอน 500.xx)
+func CreateDepositIntent(db *gorm.DB, user *models.User, bankAccountID uint, amount models.Money, promotionID *uint) (*models.DepositIntent, *models.Transaction, error) {
+	if user.Currency != "" && user.Currency != models.CurrencyTHB {
+		return nil, nil, ErrPromptPayCurrency
+	}
+	base := amount - amount%100
+	// ไม่ระบุบัญชี = ใช้บัญชีที่แจกให้สมาชิก (ถ้าบัญชีนั้นรับ PromptPay)
+	if bankAccountID == 0 {
+		if bank, err := AssignBankAccount(db, user); err == nil && bank.PromptPayID != "" {
+			bankAccountID = bank.ID
+		}
+	}
+
+	var intent models.DepositIntent
+	var t models.Transaction
+	err := db.Transaction(func(tx *gorm.DB) error {
+		bank, err := PromptPayAccount(tx, bankAccountID)
+		if err != nil {
+			return err
+		}
+		// ล็อกบัญชีรับเงินไว้ กันสองคำขอพร้อมกันได้เศษสตางค์เดียวกัน
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&models.BankAccount{}, bank.ID).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		var used []models.Money
+		if err := tx.Model(&models.DepositIntent{}).
+			Joins("JOIN transactions ON transactions.id = deposit_intents.transaction_id").
+			Where("deposit_intents.bank_account_id = ? AND deposit_intents.amount > ? AND deposit_intents.amount < ?", bank.ID, base, base+100).
+			Where("deposit_intents.expires_at > ? AND transactions.status = ?", now.Add(-DepositIntentTTL), "pending"). // จองต่อจนพ้นช่วงที่ MatchDepositIntent ยังรับ
+			Pluck("deposit_intents.amount", &used).Error; err != nil {
+			return err
+		}
+		suffix, err := freeSatangSuffix(used, base)
+		if err != nil {
+			return err
+		}
+
+		payload, err := PromptPayPayload(bank.PromptPayID, base+suffix)
+		if err != nil {
+			return err
+		}
+		t = models.Transaction{
+			UserID:        user.ID,
+			Amount:        base + suffix,
+			Type:          "deposit",
+			Status:        "pending",
+			BankName:      bank.BankName,
+			BankAccount:   bank.AccountNumber,
+			AccountName:   bank.AccountName,
+			BankAccountID: &bank.ID,
+			PromotionID:   promotionID,
+			Note:          "ฝากผ่าน QR PromptPay",
+		}
+		if err := tx.Create(&t).Error; err != nil {
+			return err
+		}
+		intent = models.DepositIntent{
+			TransactionID: t.ID,
+			UserID:        user.ID,
+			BankAccountID: bank.ID,
+			Amount:        t.Amount,
+			BaseAmount:    base,
+			PromptPayID:   bank.PromptPayID,
+			Payload:       payload,
+			ExpiresAt:     now.Add(DepositIntentTTL),
+		}
+		return tx.Create(&intent).Error
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return &intent, &t, nil
+}
+
+// freeSatangSuffix: สุ่มเศษสตางค์ 1-99 ที่ยังไม่ถูกจอง (สุ่มจุดเริ่มแล้ววนหา)
+func freeSatangSuffix(used []models.Money, base models.Money) (models.Money, error) {
+	taken := make(map[models.Money]bool, len(used))
+	for _, m := range used {
+		taken[m-base] = true
+	}
+	n, err := rand.Int(rand.Reader, big.NewInt(99))
+	if err != nil {
+		return 0, err
+	}
+	start := models.Money(n.Int64())
+	for i := models.Money(0); i < 99; i++ {
+		suffix := (start+i)%99 + 1
+		if !taken[suffix] {
+			return suffix, nil
+		}
+	}
+	return 0, ErrIntentSlotsFull
+}
+
+// MatchDepositIntent: หาคำขอฝากจากยอดที่เข้าบัญชี (ตรงสตางค์ บัญชีเดียวกัน และโอนภายในเวลาชำระ)
+// ยอมรับยอดที่เข้าช้ากว่าเวลาชำระได้อีก DepositIntentTTL เผื่อธนาคารแจ้งช้า
+func MatchDepositIntent(db *gorm.DB, bankAccountID uint, amount models.Money, paidAt time.Time) (*models.DepositIntent, error) {
+	var intents []models.DepositIntent
+	err := db.Joins("JOIN transactions ON transactions.id = deposit_intents.transaction_id").
+		Where("deposit_intents.bank_account_id = ? AND deposit_intents.amount = ? AND transactions.status = ?", bankAccountID, amount, "pending").
+		Where("deposit_intents.created_at <= ? AND deposit_intents.expires_at >= ?", paidAt, paidAt.Add(-DepositIntentTTL)).
+		Order("deposit_intents.id desc").Limit(2).Find(&intents).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(intents) != 1 {
+		return nil, fmt.Errorf("%w: %d candidates", ErrIntentNotFound, len(intents))
+	}
+	return &intents[0], nil
+}
+
+// RunDepositIntentExpiry: ปฏิเสธคำขอฝาก QR ที่เลยเวลาชำระนานแล้วและยังไม่มียอดเข้า
+func RunDepositIntentExpiry() error {
+	count, err := ExpireDepositIntents(time.Now())
+	if count > 0 {
+		log.Printf("✅ [PromptPay] Expired %d deposit intents", count)
+	}
+	return err
+}
+
+func ExpireDepositIntents(now time.Time) (int64, error) {
+	var ids []uint
+	if err := database.DB.Model(&models.DepositIntent{}).
+		Joins("JOIN transactions ON transactions.id = deposit_intents.transaction_id").
+		Where("transactions.status = ? AND deposit_intents.expires_at < ?", "pending", now.Add(-depositIntentGrace)).
+		Pluck("deposit_intents.transaction_id", &ids).Error; err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	res := database.DB.Model(&models.Transaction{}).Where("id IN ? AND status = ?", ids, "pending").
+		Updates(map[string]interface{}{"status": "rejected", "note": "ฝากผ่าน QR PromptPay: หมดเวลาชำระ ไม่พบยอดเข้า"})
+	return res.RowsAffected, res.Error
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/PawornpratKongdaeng/soccer/models"
+)
+
+func TestCRC16CCITT(t *testing.T) {
+	tests := []struct {
+		in   string
+		want uint16
+	}{
+		{"", 0xFFFF},
+		{"123456789", 0x29B1}, // ค่าตรวจมาตรฐานของ CRC-16/CCITT-FALSE
+		// QR PromptPay แบบไม่ระบุยอดของ 0801234567 (ตัวอย่างจากไลบรารี promptpay-qr)
+		{"00020101021129370016A000000677010111011300668012345675802TH53037646304", 0x6197},
+	}
+
+	for _, tt := range tests {
+		if got := crc16CCITT([]byte(tt.in)); got != tt.want {
+			t.Errorf("crc16CCITT(%q) = %04X, want %04X", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestPromptPayPayload(t *testing.T) {
+	tests := []struct {
+		name    string
+		target  string
+		amount  models.Money
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "mobile number", target: "081-234-5678", amount: models.NewMoney(500.37),
+			// 00 02 01 | 01 02 12 | 29 37 [00 16 A000000677010111 | 01 13 0066812345678] | 53 03 764 | 54 06 500.37 | 58 02 TH | 63 04 CRC
+			want: "00020101021229370016A0000006770101110113006681234567853037645406500.375802TH63045DB2",
+		},
+		{
+			name: "mobile number with country code", target: "+66812345678", amount: models.NewMoney(500.37),
+			want: "00020101021229370016A0000006770101110113006681234567853037645406500.375802TH63045DB2",
+		},
+		{
+			name: "national id", target: "1-2345-67890-12-3", amount: models.NewMoney(1),
+			want: "00020101021229370016A00000067701011102131234567890123530376454041.005802TH6304E9B7",
+		},
+		{
+			name: "e-wallet", target: "123456789012345", amount: models.NewMoney(12345.67),
+			want: "00020101021229390016A00000067701011103151234567890123455303764540812345.675802TH63048CB8",
+		},
+		{name: "too short", target: "12345", amount: models.NewMoney(100), wantErr: true},
+		{name: "mobile without leading zero", target: "8123456789", amount: models.NewMoney(100), wantErr: true},
+		{name: "zero amount", target: "0812345678", amount: 0, wantErr: true},
+		{name: "negative amount", target: "0812345678", amount: models.NewMoney(-1), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := PromptPayPayload(tt.target, tt.amount)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("PromptPayPayload() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("PromptPayPayload() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,497 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/PawornpratKongdaeng/soccer/database"
+	"github.com/PawornpratKongdaeng/soccer/models"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ==========================================
+// กระทบยอดเครดิต (Balance Reconciliation)
+// ==========================================
+// เทียบยอด 3 แหล่งของสมาชิกแต่ละคน:
+//   cached  = users.credit (ยอดสรุป)
+//   ledger  = ผลรวม ledger_entries ของ wallet (ยอดจริง)
+//   history = ผลรวม Transaction ที่ผูกกับ Ledger (ประวัติที่สมาชิกเห็น)
+// ถ้าไม่ตรงกัน จะไล่ Entry/Transaction ของคนนั้นหาต้นเหตุ แล้วเก็บเป็น BalanceDrift ให้ Admin อนุมัติแก้
+
+var ErrDriftResolved = errors.New("drift already resolved")
+
+// transactionSignSQL: ยอดของ Transaction แบบมีเครื่องหมาย (บวก = เครดิตเพิ่ม)
+// adjustment และประเภทที่ไม่รู้จักใช้ทิศจากยอดก่อน/หลัง
+const transactionSignSQL = `CASE
+	WHEN type IN ('deposit', 'payout', 'commission', 'withdraw_refund', 'transfer_in', 'bonus_release', 'referral', 'cashback') THEN amount
+	WHEN type IN ('withdraw', 'bet', 'transfer_out') THEN -amount
+	ELSE balance_after - balance_before END`
+
+// transactionSign: เหมือน transactionSignSQL แต่คิดใน Go (ใช้ตอนไล่ทีละรายการ)
+func transactionSign(t models.Transaction) models.Money {
+	switch t.Type {
+	case "deposit", "payout", "commission", "withdraw_refund", "transfer_in", "bonus_release", "referral", "cashback":
+		return t.Amount
+	case "withdraw", "bet", "transfer_out":
+		return -t.Amount
+	}
+	return t.BalanceAfter - t.BalanceBefore
+}
+
+// transactionTypeForJournal: ประเภท Transaction ที่ใช้บันทึกย้อนหลังให้ Journal ที่ไม่มีประวัติ
+func transactionTypeForJournal(journalType string, delta models.Money) string {
+	switch journalType {
+	case "bet", "payout", "deposit", "withdraw", "withdraw_refund", "commission", "credit_clear", "bonus_release", "referral", "cashback":
+		return journalType
+	case "transfer":
+		if delta < 0 {
+			return "transfer_out"
+		}
+		return "transfer_in"
+	}
+	return "adjustment"
+}
+
+// ReconcileReport: ผลกระทบยอดของสมาชิก 1 คน
+type ReconcileReport struct {
+	UserID  uint                `json:"user_id"`
+	Cached  models.Money        `json:"cached"`
+	Ledger  models.Money        `json:"ledger"`
+	History models.Money        `json:"history"`
+	Issues  []models.DriftIssue `json:"issues"`
+}
+
+func (r *ReconcileReport) Balanced() bool {
+	return r.Cached == r.Ledger && r.History == r.Ledger && len(r.Issues) == 0
+}
+
+type userTotal struct {
+	UserID uint
+	Total  models.Money
+}
+
+func sumByUser(query *gorm.DB) (map[uint]models.Money, error) {
+	var rows []userTotal
+	if err := query.Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	totals := make(map[uint]models.Money, len(rows))
+	for _, r := range rows {
+		totals[r.UserID] = r.Total
+	}
+	return totals, nil
+}
+
+// ReconcileUser: ไล่ยอดของสมาชิก 1 คนแบบละเอียด
+func ReconcileUser(db *gorm.DB, userID uint) (*ReconcileReport, error) {
+	report := &ReconcileReport{UserID: userID, Issues: []models.DriftIssue{}}
+
+	var user models.User
+	if err := db.Unscoped().Select("id", "credit", "held_credit", "bonus_credit").First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	report.Cached = user.Credit
+
+	// 1. ไล่ Entry ของ wallet ตามลำดับ: ยอดก่อนต้องเท่ากับยอดหลังของบรรทัดก่อนหน้า
+	var entries []models.LedgerEntry
+	if err := db.Where("account = ? AND user_id = ?", models.LedgerAccountWallet, userID).
+		Order("id").Find(&entries).Error; err != nil {
+		return nil, err
+	}
+
+	var running models.Money
+	deltas := make(map[uint]models.Money)
+	var journalOrder []uint
+	for _, e := range entries {
+		if e.BalanceBefore == nil || e.BalanceAfter == nil {
+			report.Issues = append(report.Issues, models.DriftIssue{
+				Kind: "chain_break", JournalID: e.JournalID, EntryID: e.ID,
+				Detail: "Entry ไม่มียอดก่อน/หลัง",
+			})
+		} else if *e.BalanceBefore != running || *e.BalanceBefore+e.Amount != *e.BalanceAfter {
+			report.Issues = append(report.Issues, models.DriftIssue{
+				Kind: "chain_break", JournalID: e.JournalID, EntryID: e.ID,
+				Detail: fmt.Sprintf("ยอดสะสม %s แต่ Entry บันทึก %s + (%s) = %s",
+					running, *e.BalanceBefore, e.Amount, *e.BalanceAfter),
+			})
+		}
+		running += e.Amount
+		if _, ok := deltas[e.JournalID]; !ok {
+			journalOrder = append(journalOrder, e.JournalID)
+		}
+		deltas[e.JournalID] += e.Amount
+	}
+	report.Ledger = running
+
+	// 2. จับคู่ Journal กับ Transaction
+	var txs []models.Transaction
+	if err := db.Where("user_id = ? AND journal_id IS NOT NULL", userID).Order("id").Find(&txs).Error; err != nil {
+		return nil, err
+	}
+
+	recorded := make(map[uint]models.Money)
+	txIDs := make(map[uint][]uint)
+	for _, t := range txs {
+		signed := transactionSign(t)
+		report.History += signed
+		if _, ok := deltas[*t.JournalID]; !ok {
+			// แทงด้วยกระเป๋าโบนัสล้วน: มีประวัติแต่ไม่มี Entry ของ wallet (ยอด 0)
+			if signed == 0 {
+				continue
+			}
+			report.Issues = append(report.Issues, models.DriftIssue{
+				Kind: "orphan_transaction", JournalID: *t.JournalID, TransactionID: t.ID,
+				Detail: fmt.Sprintf("Transaction %s %s ไม่มี Entry ของกระเป๋านี้ใน Ledger", t.Type, signed),
+			})
+			continue
+		}
+		recorded[*t.JournalID] += signed
+		txIDs[*t.JournalID] = append(txIDs[*t.JournalID], t.ID)
+	}
+
+	for _, journalID := range journalOrder {
+		delta := deltas[journalID]
+		ids, ok := txIDs[journalID]
+		if !ok {
+			report.Issues = append(report.Issues, models.DriftIssue{
+				Kind: "missing_transaction", JournalID: journalID,
+				Detail: fmt.Sprintf("Ledger เปลี่ยนยอด %s แต่ไม่มี Transaction", delta),
+			})
+			continue
+		}
+		if recorded[journalID] != delta {
+			report.Issues = append(report.Issues, models.DriftIssue{
+				Kind: "amount_mismatch", JournalID: journalID, TransactionID: ids[0],
+				Detail: fmt.Sprintf("Ledger %s แต่ Transaction รวม %s", delta, recorded[journalID]),
+			})
+		}
+	}
+
+	// 3. ยอดสรุปใน users.credit / users.held_credit / users.bonus_credit
+	if report.Cached != report.Ledger {
+		report.Issues = append(report.Issues, models.DriftIssue{
+			Kind:   "cache_drift",
+			Detail: fmt.Sprintf("users.credit %s แต่ Ledger %s (ต่าง %s)", report.Cached, report.Ledger, report.Cached-report.Ledger),
+		})
+	}
+	heldLedger, err := accountBalance(db, models.LedgerAccountWalletHeld, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.HeldCredit != heldLedger {
+		report.Issues = append(report.Issues, models.DriftIssue{
+			Kind:   "held_drift",
+			Detail: fmt.Sprintf("users.held_credit %s แต่ Ledger %s", user.HeldCredit, heldLedger),
+		})
+	}
+	bonusLedger, err := accountBalance(db, models.LedgerAccountBonus, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.BonusCredit != bonusLedger {
+		report.Issues = append(report.Issues, models.DriftIssue{
+			Kind:   "bonus_drift",
+			Detail: fmt.Sprintf("users.bonus_credit %s แต่ Ledger %s", user.BonusCredit, bonusLedger),
+		})
+	}
+
+	return report, nil
+}
+
+// accountBalance: ยอดบัญชีย่อยของสมาชิก (wallet_held, bonus) ที่รวมจาก ledger_entries
+func accountBalance(db *gorm.DB, account string, userID uint) (models.Money, error) {
+	var balance models.Money
+	err := db.Model(&models.LedgerEntry{}).
+		Where("account = ? AND user_id = ?", account, userID).
+		Select("COALESCE(SUM(amount), 0)").Scan(&balance).Error
+	return balance, err
+}
+
+// RunReconciliation: กระทบยอดทุกคน (งาน "reconcile" ใน Job Registry)
+// รวมยอดแบบ Bulk ก่อน แล้วไล่ละเอียดเฉพาะคนที่ยอดไม่ตรง
+func RunReconciliation() error {
+	cached, err := sumByUser(database.DB.Unscoped().Model(&models.User{}).Select("id AS user_id, credit AS total"))
+	if err != nil {
+		return fmt.Errorf("load credits: %w", err)
+	}
+	ledger, err := sumByUser(database.DB.Model(&models.LedgerEntry{}).
+		Select("user_id, COALESCE(SUM(amount), 0) AS total").
+		Where("account = ?", models.LedgerAccountWallet).Group("user_id"))
+	if err != nil {
+		return fmt.Errorf("sum ledger: %w", err)
+	}
+	cachedHeld, err := sumByUser(database.DB.Unscoped().Model(&models.User{}).Select("id AS user_id, held_credit AS total"))
+	if err != nil {
+		return fmt.Errorf("load held credits: %w", err)
+	}
+	ledgerHeld, err := sumByUser(database.DB.Model(&models.LedgerEntry{}).
+		Select("user_id, COALESCE(SUM(amount), 0) AS total").
+		Where("account = ?", models.LedgerAccountWalletHeld).Group("user_id"))
+	if err != nil {
+		return fmt.Errorf("sum held: %w", err)
+	}
+	cachedBonus, err := sumByUser(database.DB.Unscoped().Model(&models.User{}).Select("id AS user_id, bonus_credit AS total"))
+	if err != nil {
+		return fmt.Errorf("load bonus credits: %w", err)
+	}
+	ledgerBonus, err := sumByUser(database.DB.Model(&models.LedgerEntry{}).
+		Select("user_id, COALESCE(SUM(amount), 0) AS total").
+		Where("account = ?", models.LedgerAccountBonus).Group("user_id"))
+	if err != nil {
+		return fmt.Errorf("sum bonus: %w", err)
+	}
+	history, err := sumByUser(database.DB.Model(&models.Transaction{}).
+		Select("user_id, COALESCE(SUM(" + transactionSignSQL + "), 0) AS total").
+		Where("journal_id IS NOT NULL").Group("user_id"))
+	if err != nil {
+		return fmt.Errorf("sum transactions: %w", err)
+	}
+
+	userIDs := make(map[uint]bool, len(cached))
+	for id := range cached {
+		userIDs[id] = true
+	}
+	for id := range ledger {
+		userIDs[id] = true
+	}
+	for id := range history {
+		userIDs[id] = true
+	}
+
+	checkedAt := time.Now()
+	drifted, failed := 0, 0
+	for id := range userIDs {
+		if cached[id] == ledger[id] && history[id] == ledger[id] && cachedHeld[id] == ledgerHeld[id] && cachedBonus[id] == ledgerBonus[id] {
+			continue
+		}
+
+		report, err := ReconcileUser(database.DB, id)
+		if err != nil {
+			log.Printf("❌ [Reconcile] User %d Error: %v", id, err)
+			failed++
+			continue
+		}
+		if err := saveDrift(database.DB, report, checkedAt); err != nil {
+			log.Printf("❌ [Reconcile] Save drift user %d Error: %v", id, err)
+			failed++
+			continue
+		}
+		drifted++
+	}
+
+	log.Printf("✅ [Reconcile] Checked %d users, %d drifted", len(userIDs), drifted)
+	if failed > 0 {
+		return fmt.Errorf("%d users failed to reconcile", failed)
+	}
+	return nil
+}
+
+// saveDrift: อัปเดตรายการ open เดิมของสมาชิก หรือสร้างใหม่ถ้ายังไม่มี
+func saveDrift(db *gorm.DB, report *ReconcileReport, checkedAt time.Time) error {
+	var drift models.BalanceDrift
+	err := db.Where("user_id = ? AND status = ?", report.UserID, models.DriftStatusOpen).First(&drift).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	drift.UserID = report.UserID
+	drift.Cached = report.Cached
+	drift.Ledger = report.Ledger
+	drift.History = report.History
+	drift.Issues = report.Issues
+	drift.Status = models.DriftStatusOpen
+	drift.CheckedAt = checkedAt
+	return db.Save(&drift).Error
+}
+
+// CorrectDrift: แก้ยอดตามที่ Admin อนุมัติ (ทำใน DB Transaction เดียว)
+//  1. บันทึก Transaction ย้อนหลังให้ Journal ที่ไม่มีประวัติ
+//  2. ซิงก์ users.credit / users.held_credit / users.bonus_credit ให้เท่ากับยอดใน Ledger
+//  3. ถ้า amount ไม่เป็น 0 ลงบัญชีปรับยอด (adjustment) พร้อม Transaction
+func CorrectDrift(driftID uint, amount models.Money, note string, adminID uint) (*models.BalanceDrift, error) {
+	var drift models.BalanceDrift
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&drift, driftID).Error; err != nil {
+			return err
+		}
+		if drift.Status != models.DriftStatusOpen {
+			return ErrDriftResolved
+		}
+
+		// ล็อกกระเป๋าก่อนไล่ยอดใหม่ กันรายการอื่นแทรกระหว่างแก้
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "credit").First(&user, drift.UserID).Error; err != nil {
+			return err
+		}
+		report, err := ReconcileUser(tx, drift.UserID)
+		if err != nil {
+			return err
+		}
+
+		for _, issue := range report.Issues {
+			if issue.Kind != "missing_transaction" {
+				continue
+			}
+			var journal models.LedgerJournal
+			if err := tx.Preload("Entries").First(&journal, issue.JournalID).Error; err != nil {
+				return err
+			}
+			before, after := WalletChange(&journal, drift.UserID)
+			if _, err := RecordTransaction(tx, &journal, drift.UserID, models.Transaction{
+				AdminID:   &adminID,
+				Type:      transactionTypeForJournal(journal.Type, after-before),
+				Note:      fmt.Sprintf("บันทึกย้อนหลังจากการกระทบยอด #%d", drift.ID),
+				CreatedAt: journal.CreatedAt,
+			}); err != nil {
+				return err
+			}
+		}
+
+		heldLedger, err := accountBalance(tx, models.LedgerAccountWalletHeld, drift.UserID)
+		if err != nil {
+			return err
+		}
+		bonusLedger, err := accountBalance(tx, models.LedgerAccountBonus, drift.UserID)
+		if err != nil {
+			return err
+		}
+		if err := tx.Exec("UPDATE users SET credit = ?, held_credit = ?, bonus_credit = ? WHERE id = ?", report.Ledger, heldLedger, bonusLedger, drift.UserID).Error; err != nil {
+			return err
+		}
+
+		if amount != 0 {
+			if note == "" {
+				note = fmt.Sprintf("ปรับยอดจากการกระทบยอด #%d", drift.ID)
+			}
+			journal, err := PostWallet(tx, drift.UserID, amount, models.LedgerAccountAdjustment, Journal{
+				Type:      "adjustment",
+				RefType:   "balance_drift",
+				RefID:     drift.ID,
+				Note:      note,
+				CreatedBy: &adminID,
+			})
+			if err != nil {
+				return err
+			}
+			if _, err := RecordTransaction(tx, journal, drift.UserID, models.Transaction{
+				AdminID: &adminID,
+				Type:    "adjustment",
+				Note:    note,
+			}); err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		drift.Status = models.DriftStatusCorrected
+		drift.CorrectionAmount = amount
+		drift.ResolutionNote = note
+		drift.ApprovedBy = &adminID
+		drift.ResolvedAt = &now
+		return tx.Save(&drift).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &drift, nil
+}
+
+// ==========================================
+// Admin API
+// ==========================================
+
+// GET /admin/reconcile/drifts?status=open&user_id=
+func GetBalanceDrifts(c *fiber.Ctx) error {
+	query := database.DB.Model(&models.BalanceDrift{}).Order("id desc")
+
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if userID := c.Query("user_id"); userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	var drifts []models.BalanceDrift
+	if err := query.Limit(500).Find(&drifts).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "ดึงข้อมูลไม่สำเร็จ"})
+	}
+	return c.JSON(drifts)
+}
+
+// GET /admin/reconcile/users/:id (ไล่ยอดสดของสมาชิก 1 คน ไม่บันทึกผล)
+func GetUserReconciliation(c *fiber.Ctx) error {
+	userID, err := c.ParamsInt("id")
+	if err != nil || userID <= 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "รหัสสมาชิกไม่ถูกต้อง"})
+	}
+
+	report, err := ReconcileUser(database.DB, uint(userID))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": "ไม่พบสมาชิก"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "กระทบยอดไม่สำเร็จ"})
+	}
+	return c.JSON(fiber.Map{"report": report, "balanced": report.Balanced()})
+}
+
+// POST /admin/reconcile/drifts/:id/correct {"amount": 0, "note": "..."}
+// amount คือยอดปรับเพิ่ม/ลดที่ Admin อนุมัติ (0 = ซิงก์ยอดสรุปและเติมประวัติเท่านั้น)
+func CorrectBalanceDrift(c *fiber.Ctx) error {
+	driftID, err := c.ParamsInt("id")
+	if err != nil || driftID <= 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "รหัสรายการไม่ถูกต้อง"})
+	}
+
+	var req struct {
+		Amount models.Money `json:"amount"`
+		Note   string       `json:"note"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "ข้อมูลไม่ถูกต้อง"})
+	}
+
+	adminID, _ := c.Locals("user_id").(uint)
+	drift, err := CorrectDrift(uint(driftID), req.Amount, req.Note, adminID)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(404).JSON(fiber.Map{"error": "ไม่พบรายการ"})
+	case errors.Is(err, ErrDriftResolved):
+		return c.Status(400).JSON(fiber.Map{"error": "รายการนี้ถูกดำเนินการไปแล้ว"})
+	case errors.Is(err, ErrInsufficientCredit):
+		return c.Status(400).JSON(fiber.Map{"error": "ยอดปรับลดมากกว่าเครดิตคงเหลือ"})
+	case err != nil:
+		return c.Status(500).JSON(fiber.Map{"error": "แก้ไขยอดไม่สำเร็จ"})
+	}
+	return c.JSON(fiber.Map{"message": "แก้ไขยอดเรียบร้อย", "drift": drift})
+}
+
+// POST /admin/reconcile/drifts/:id/dismiss {"note": "..."}
+func DismissBalanceDrift(c *fiber.Ctx) error {
+	var req struct {
+		Note string `json:"note"`
+	}
+	c.BodyParser(&req)
+
+	adminID, _ := c.Locals("user_id").(uint)
+	now := time.Now()
+	result := database.DB.Model(&models.BalanceDrift{}).
+		Where("id = ? AND status = ?", c.Params("id"), models.DriftStatusOpen).
+		Updates(map[string]interface{}{
+			"status":          models.DriftStatusDismissed,
+			"resolution_note": req.Note,
+			"approved_by":     adminID,
+			"resolved_at":     now,
+		})
+	if result.Error != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "บันทึกไม่สำเร็จ"})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(404).JSON(fiber.Map{"error": "ไม่พบรายการที่รอตรวจสอบ"})
+	}
+	return c.JSON(fiber.Map{"message": "ปิดรายการเรียบร้อย"})
+}
@@ -0,0 +1,130 @@
+package services
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/PawornpratKongdaeng/soccer/models"
+	"gorm.io/gorm"
+)
+
+// depositWithHistory: เติมเครดิตพร้อม Transaction (แบบที่ทุก flow ควรทำ)
+func depositWithHistory(t *testing.T, db *gorm.DB, userID uint, amount models.Money) *models.Transaction {
+	t.Helper()
+	journal, err := PostWallet(db, userID, amount, models.LedgerAccountCash, Journal{Type: "deposit"})
+	if err != nil {
+		t.Fatalf("PostWallet() error = %v", err)
+	}
+	tx, err := RecordTransaction(db, journal, userID, models.Transaction{Type: "deposit", Status: "approved"})
+	if err != nil {
+		t.Fatalf("RecordTransaction() error = %v", err)
+	}
+	return tx
+}
+
+func issueKinds(report *ReconcileReport) []string {
+	kinds := make([]string, 0, len(report.Issues))
+	for _, issue := range report.Issues {
+		kinds = append(kinds, issue.Kind)
+	}
+	return kinds
+}
+
+func TestReconcileUser(t *testing.T) {
+	db := openTestDB(t)
+
+	tests := []struct {
+		name      string
+		setup     func(t *testing.T, user models.User)
+		wantKinds []string
+	}{
+		{"balanced", func(t *testing.T, user models.User) {
+			depositWithHistory(t, db, user.ID, models.NewMoney(100))
+		}, nil},
+		{"ledger move without transaction", func(t *testing.T, user models.User) {
+			depositWithHistory(t, db, user.ID, models.NewMoney(100))
+			if _, err := PostWallet(db, user.ID, models.NewMoney(25), models.LedgerAccountAdjustment, Journal{Type: "adjustment"}); err != nil {
+				t.Fatal(err)
+			}
+		}, []string{"missing_transaction"}},
+		{"cached credit edited directly", func(t *testing.T, user models.User) {
+			depositWithHistory(t, db, user.ID, models.NewMoney(100))
+			db.Exec("UPDATE users SET credit = credit + 5 WHERE id = ?", user.ID)
+		}, []string{"cache_drift"}},
+		{"transaction amount differs", func(t *testing.T, user models.User) {
+			tx := depositWithHistory(t, db, user.ID, models.NewMoney(100))
+			db.Model(tx).Update("amount", models.NewMoney(90))
+		}, []string{"amount_mismatch"}},
+		{"held cache edited directly", func(t *testing.T, user models.User) {
+			db.Exec("UPDATE users SET held_credit = 10 WHERE id = ?", user.ID)
+		}, []string{"held_drift"}},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := createTestUser(t, db, "reconcile_"+strconv.Itoa(i), 0)
+			tt.setup(t, user)
+
+			report, err := ReconcileUser(db, user.ID)
+			if err != nil {
+				t.Fatalf("ReconcileUser() error = %v", err)
+			}
+			got := issueKinds(report)
+			if len(got) != len(tt.wantKinds) {
+				t.Fatalf("issues = %v, want %v", got, tt.wantKinds)
+			}
+			for j := range got {
+				if got[j] != tt.wantKinds[j] {
+					t.Errorf("issue %d = %s, want %s", j, got[j], tt.wantKinds[j])
+				}
+			}
+			if report.Balanced() != (len(tt.wantKinds) == 0) {
+				t.Errorf("Balanced() = %v with issues %v", report.Balanced(), got)
+			}
+		})
+	}
+}
+
+func TestCorrectDrift(t *testing.T) {
+	db := openTestDB(t)
+	admin := createTestUser(t, db, "reconcile_admin", 0)
+	user := createTestUser(t, db, "reconcile_fix", 0)
+	depositWithHistory(t, db, user.ID, models.NewMoney(100))
+	if _, err := PostWallet(db, user.ID, models.NewMoney(25), models.LedgerAccountAdjustment, Journal{Type: "transfer"}); err != nil {
+		t.Fatal(err)
+	}
+	db.Exec("UPDATE users SET credit = 0 WHERE id = ?", user.ID)
+
+	report, err := ReconcileUser(db, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := saveDrift(db, report, time.Now()); err != nil {
+		t.Fatalf("saveDrift() error = %v", err)
+	}
+	var drift models.BalanceDrift
+	if err := db.Where("user_id = ? AND status = ?", user.ID, models.DriftStatusOpen).First(&drift).Error; err != nil {
+		t.Fatalf("open drift: %v", err)
+	}
+
+	if _, err := CorrectDrift(drift.ID, models.NewMoney(-5), "", admin.ID); err != nil {
+		t.Fatalf("CorrectDrift() error = %v", err)
+	}
+	after, err := ReconcileUser(db, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !after.Balanced() || after.Ledger != models.NewMoney(120) {
+		t.Errorf("after correction = ledger %s, issues %v, want balanced 120.00", after.Ledger, issueKinds(after))
+	}
+	var backfilled models.Transaction
+	if err := db.Where("user_id = ? AND type = ?", user.ID, "transfer_in").First(&backfilled).Error; err != nil {
+		t.Errorf("backfilled transfer_in transaction: %v", err)
+	}
+
+	if _, err := CorrectDrift(drift.ID, 0, "", admin.ID); !errors.Is(err, ErrDriftResolved) {
+		t.Errorf("CorrectDrift() again = %v, want %v", err, ErrDriftResolved)
+	}
+}
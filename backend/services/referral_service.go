@@ -0,0 +1,327 @@
+package services
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/PawornpratKongdaeng/soccer/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ==========================================
+// แนะนำเพื่อน (Referral / Affiliate)
+// ==========================================
+// สมัครผ่านรหัสของ Master/Agent = อยู่ใต้คนนั้นโดยตรง
+// สมัครผ่านรหัสของสมาชิก = อยู่ใต้ต้นสายเดียวกับผู้แนะนำ (สมาชิกมีลูกสายไม่ได้) แต่ผู้แนะนำยังได้รางวัล
+// สมาชิกใหม่ใช้สกุลกระเป๋าเดียวกับผู้แนะนำเสมอ (สายงานต้องสกุลเดียวกัน และรางวัลคิดจากยอดฝากสกุลนั้น)
+// รางวัลจ่ายครั้งเดียวตอนยอดฝากแรกได้รับอนุมัติ (ไม่จ่ายตอนสมัคร กันสมัครปลอมเอารางวัล)
+
+var (
+	ErrReferralNotFound   = errors.New("referral code not found")
+	ErrReferralNotAllowed = errors.New("referral codes are not available for this account")
+	ErrInvalidReferral    = errors.New("invalid referral code")
+)
+
+var referralCodePattern = regexp.MustCompile(`^[A-Z0-9]{4,20}$`)
+
+// referralAlphabet: ไม่มี 0/O/1/I กันพิมพ์ผิด
+const referralAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// NormalizeReferralCode: รหัสแนะนำเก็บเป็นตัวพิมพ์ใหญ่ไม่มีช่องว่าง
+func NormalizeReferralCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+func randomReferralCode() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	for i, b := range buf {
+		buf[i] = referralAlphabet[int(b)%len(referralAlphabet)]
+	}
+	return string(buf), nil
+}
+
+// CanRefer: บัญชีนี้มีรหัสแนะนำได้หรือไม่
+func CanRefer(db *gorm.DB, user *models.User) bool {
+	switch user.Role {
+	case "master", "agent":
+		return true
+	case "user":
+		var settings models.SystemSetting
+		db.First(&settings, 1)
+		return settings.MemberReferral
+	}
+	return false
+}
+
+// CreateReferralCode: สร้างรหัสแนะนำ (code ว่าง = สุ่มให้)
+func CreateReferralCode(db *gorm.DB, owner *models.User, code, label string) (*models.ReferralCode, error) {
+	if !CanRefer(db, owner) {
+		return nil, ErrReferralNotAllowed
+	}
+
+	rc := models.ReferralCode{UserID: owner.ID, Label: strings.TrimSpace(label), Active: true}
+	code = NormalizeReferralCode(code)
+	if code != "" && !referralCodePattern.MatchString(code) {
+		return nil, fmt.Errorf("%w: use 4-20 letters or digits", ErrInvalidReferral)
+	}
+
+	// code ว่าง = สุ่มจนกว่าจะไม่ซ้ำ
+	for attempt := 0; attempt < 5; attempt++ {
+		candidate := code
+		if candidate == "" {
+			random, err := randomReferralCode()
+			if err != nil {
+				return nil, err
+			}
+			candidate = random
+		}
+
+		var count int64
+		if err := db.Model(&models.ReferralCode{}).Where("code = ?", candidate).Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			if code != "" {
+				return nil, fmt.Errorf("%w: %s is already taken", ErrInvalidReferral, code)
+			}
+			continue
+		}
+
+		rc.Code = candidate
+		if err := db.Create(&rc).Error; err != nil {
+			return nil, err
+		}
+		return &rc, nil
+	}
+	return nil, errors.New("could not generate a unique referral code")
+}
+
+// EnsureReferralCode: Master/Agent ที่ยังไม่มีรหัสได้รหัสแรกอัตโนมัติ
+func EnsureReferralCode(db *gorm.DB, owner *models.User) error {
+	if owner.Role != "master" && owner.Role != "agent" {
+		return nil
+	}
+	var count int64
+	if err := db.Model(&models.ReferralCode{}).Where("user_id = ?", owner.ID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	_, err := CreateReferralCode(db, owner, "", "")
+	return err
+}
+
+// ResolveReferral: หารหัสที่ยังใช้ได้พร้อมเจ้าของรหัส
+func ResolveReferral(db *gorm.DB, code string) (*models.ReferralCode, *models.User, error) {
+	var rc models.ReferralCode
+	if err := db.Where("code = ? AND active = ?", NormalizeReferralCode(code), true).First(&rc).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrReferralNotFound
+		}
+		return nil, nil, err
+	}
+
+	var owner models.User
+	if err := db.First(&owner, rc.UserID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrReferralNotFound
+		}
+		return nil, nil, err
+	}
+	if owner.Status != "active" || !CanRefer(db, &owner) {
+		return nil, nil, ErrReferralNotFound
+	}
+	return &rc, &owner, nil
+}
+
+// ReferralParent: ต้นสายของสมาชิกที่สมัครผ่านรหัสของ owner
+func ReferralParent(owner *models.User) *uint {
+	if owner.Role == "master" || owner.Role == "agent" {
+		id := owner.ID
+		return &id
+	}
+	return owner.ParentID
+}
+
+// AttachReferral: บันทึกว่าสมาชิกใหม่มาจากรหัสไหน (เรียกใน DB Transaction เดียวกับการสร้าง User)
+func AttachReferral(tx *gorm.DB, user *models.User, rc *models.ReferralCode, ip string) error {
+	return tx.Create(&models.ReferralAttribution{
+		UserID:     user.ID,
+		ReferrerID: rc.UserID,
+		CodeID:     rc.ID,
+		Code:       rc.Code,
+		ParentID:   user.ParentID,
+		IP:         ip,
+	}).Error
+}
+
+// RecordReferralClick: นับจำนวนครั้งที่เปิดลิงก์แนะนำ
+func RecordReferralClick(db *gorm.DB, code string) (*models.ReferralCode, error) {
+	rc, _, err := ResolveReferral(db, code)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Model(rc).UpdateColumn("clicks", gorm.Expr("clicks + 1")).Error; err != nil {
+		return nil, err
+	}
+	return rc, nil
+}
+
+// RewardReferral: จ่ายรางวัลแนะนำเมื่อยอดฝากแรกของสมาชิกที่ถูกแนะนำได้รับอนุมัติ
+// (เรียกใน DB Transaction เดียวกับการอนุมัติยอดฝาก) ไม่มีผู้แนะนำ / ไม่ใช่ยอดฝากแรก = nil, nil
+func RewardReferral(tx *gorm.DB, t *models.Transaction, adminID *uint) (*models.ReferralAttribution, error) {
+	var attr models.ReferralAttribution
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ? AND first_deposit_at IS NULL", t.UserID).First(&attr).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	attr.FirstDeposit = t.Amount
+	attr.FirstDepositAt = &now
+
+	var settings models.SystemSetting
+	tx.First(&settings, 1)
+
+	var referrer models.User
+	if err := tx.First(&referrer, attr.ReferrerID).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	// ผู้แนะนำถูกลบ/ระงับ = บันทึกยอดฝากแรกไว้ แต่ไม่จ่ายรางวัล
+	if settings.ReferralRewardPercent > 0 && referrer.ID != 0 && referrer.Status == "active" {
+		limit, err := CurrencyLimitFor(tx, referrer.Currency)
+		if err != nil {
+			return nil, err
+		}
+		reward := t.Amount.Percent(settings.ReferralRewardPercent)
+		if limit.MaxReferralReward > 0 && reward > limit.MaxReferralReward {
+			reward = limit.MaxReferralReward
+		}
+
+		if reward > 0 {
+			var referred models.User
+			tx.Select("id", "username").First(&referred, attr.UserID)
+			note := fmt.Sprintf("รางวัลแนะนำเพื่อน %s", referred.Username)
+
+			journal, err := PostWallet(tx, referrer.ID, reward, models.LedgerAccountReferral, Journal{
+				Type:      "referral",
+				RefType:   "referral",
+				RefID:     attr.ID,
+				Note:      note,
+				CreatedBy: adminID,
+			})
+			if err != nil {
+				return nil, err
+			}
+			rewardTx, err := RecordTransaction(tx, journal, referrer.ID, models.Transaction{
+				AdminID: adminID,
+				Type:    "referral",
+				Note:    note,
+			})
+			if err != nil {
+				return nil, err
+			}
+			attr.Reward = reward
+			attr.RewardTransactionID = &rewardTx.ID
+		}
+	}
+
+	if err := tx.Save(&attr).Error; err != nil {
+		return nil, err
+	}
+	return &attr, nil
+}
+
+// ReferralCodeStats: ยอดสรุปของรหัสแนะนำ 1 รหัส
+type ReferralCodeStats struct {
+	models.ReferralCode
+	Link         string       `json:"link"`
+	Signups      int64        `json:"signups"`
+	Depositors   int64        `json:"depositors"` // คนที่ฝากครั้งแรกแล้ว
+	FirstDeposit models.Money `json:"first_deposit"`
+	Rewards      models.Money `json:"rewards"`
+}
+
+// ReferralStats: ยอดสรุปของผู้แนะนำ 1 คน
+type ReferralStats struct {
+	Currency     string              `json:"currency"`
+	Signups      int64               `json:"signups"`
+	Depositors   int64               `json:"depositors"`
+	FirstDeposit models.Money        `json:"first_deposit"`
+	Rewards      models.Money        `json:"rewards"`
+	Codes        []ReferralCodeStats `json:"codes"`
+}
+
+// ReferralLink: ลิงก์สมัครของรหัส (ยังไม่ตั้ง ReferralBaseURL = ว่าง)
+func ReferralLink(baseURL, code string) string {
+	if baseURL == "" {
+		return ""
+	}
+	sep := "?"
+	if strings.Contains(baseURL, "?") {
+		sep = "&"
+	}
+	return baseURL + sep + "ref=" + code
+}
+
+// GetReferralStats: รหัสแนะนำทั้งหมดของ referrer พร้อมยอดสรุปรายรหัส
+func GetReferralStats(db *gorm.DB, referrer *models.User) (*ReferralStats, error) {
+	var codes []models.ReferralCode
+	if err := db.Where("user_id = ?", referrer.ID).Order("id").Find(&codes).Error; err != nil {
+		return nil, err
+	}
+
+	type codeRow struct {
+		CodeID       uint
+		Signups      int64
+		Depositors   int64
+		FirstDeposit models.Money
+		Rewards      models.Money
+	}
+	var rows []codeRow
+	if err := db.Model(&models.ReferralAttribution{}).
+		Select(`code_id, COUNT(*) AS signups, COUNT(first_deposit_at) AS depositors,
+			COALESCE(SUM(first_deposit), 0) AS first_deposit, COALESCE(SUM(reward), 0) AS rewards`).
+		Where("referrer_id = ?", referrer.ID).Group("code_id").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	byCode := make(map[uint]codeRow, len(rows))
+	for _, r := range rows {
+		byCode[r.CodeID] = r
+	}
+
+	var settings models.SystemSetting
+	db.First(&settings, 1)
+
+	stats := &ReferralStats{Currency: referrer.Currency, Codes: make([]ReferralCodeStats, 0, len(codes))}
+	for _, rc := range codes {
+		r := byCode[rc.ID]
+		stats.Signups += r.Signups
+		stats.Depositors += r.Depositors
+		stats.FirstDeposit += r.FirstDeposit
+		stats.Rewards += r.Rewards
+		stats.Codes = append(stats.Codes, ReferralCodeStats{
+			ReferralCode: rc,
+			Link:         ReferralLink(settings.ReferralBaseURL, rc.Code),
+			Signups:      r.Signups,
+			Depositors:   r.Depositors,
+			FirstDeposit: r.FirstDeposit,
+			Rewards:      r.Rewards,
+		})
+	}
+	return stats, nil
+}
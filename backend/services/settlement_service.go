@@ -1,11 +1,11 @@
 package services
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/PawornpratKongdaeng/soccer/database"
@@ -16,11 +16,6 @@ import (
 	"gorm.io/gorm"
 )
 
-var (
-	settleMutex  sync.Mutex
-	isProcessing bool
-)
-
 type ResultsResponse struct {
 	Status string `json:"status"`
 	Data   []struct {
@@ -35,176 +30,448 @@ type ResultsResponse struct {
 	} `json:"data"`
 }
 
-// 1. API สำหรับ Admin กดเริ่มเคลียร์บิล
+// 1. API สำหรับ Admin กดเริ่มเคลียร์บิล (สั่งงาน "settlement" ใน Job Registry ให้รันทันที)
 func ManualSettlement(c *fiber.Ctx) error {
-	settleMutex.Lock()
-	if isProcessing {
-		settleMutex.Unlock()
-		return c.Status(429).JSON(fiber.Map{"message": "กำลังดำเนินการเคลียร์บิลอยู่..."})
-	}
-	isProcessing = true
-	settleMutex.Unlock()
-
-	go func() {
-		defer func() {
-			settleMutex.Lock()
-			isProcessing = false
-			settleMutex.Unlock()
-		}()
-		AutoSettlement()
-	}()
+	if err := Jobs.Trigger("settlement"); err != nil {
+		if errors.Is(err, ErrJobRunning) {
+			return c.Status(429).JSON(fiber.Map{"message": "กำลังดำเนินการเคลียร์บิลอยู่..."})
+		}
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
 
 	return c.JSON(fiber.Map{"message": "ระบบเริ่มทำการตรวจสอบผลและเคลียร์บิลแล้ว"})
 }
 
-func AutoSettlement() {
-	log.Println("🔄 [Settlement] Starting process...")
+// MatchScore: ผลบอลที่ใช้คิดเงิน (มาจาก API หรือแอดมินกรอกเองตอน Preview)
+type MatchScore struct {
+	Home, Away int
+	IsFinished bool
+}
 
-	var pendingBets []models.BetSlip
-	// ดึงบิลที่ค้างอยู่
-	if err := database.DB.Where("status = ?", "pending").Find(&pendingBets).Error; err != nil {
-		log.Printf("❌ [Settlement] DB Error: %v", err)
-		return
-	}
+// SettlementLine: ผลการคำนวณของบิล 1 ใบ (ใช้ร่วมกันทั้ง Preview และการเคลียร์จริง)
+type SettlementLine struct {
+	TicketType    string       `json:"ticket_type"` // single, parlay
+	TicketID      uint         `json:"ticket_id"`
+	UserID        uint         `json:"user_id"`
+	Username      string       `json:"username"`
+	MatchID       string       `json:"match_id"`
+	Pick          string       `json:"pick"`
+	Amount        models.Money `json:"amount"`
+	Status        string       `json:"status"`
+	Payout        models.Money `json:"payout"`
+	BalanceBefore models.Money `json:"balance_before"`
+	BalanceAfter  models.Money `json:"balance_after"`
+	Multiplier    float64      `json:"multiplier,omitempty"` // เฉพาะบอลสเต็ป
 
-	if len(pendingBets) == 0 {
-		log.Println("ℹ️ [Settlement] No pending bets.")
-		return
-	}
+	legs []LegResult // ผลรายคู่ที่เพิ่งรู้ของบิลสเต็ปนี้ (บันทึกใน Transaction เดียวกับการปิดบิล)
+}
 
-	// เรียก API ผลบอล
+// LegResult: ผลรายคู่ของบอลสเต็ป (อัปเดตได้แม้บิลยังไม่จบ)
+type LegResult struct {
+	ItemID     uint    `json:"item_id"`
+	TicketID   uint    `json:"ticket_id"`
+	MatchID    string  `json:"match_id"`
+	Pick       string  `json:"pick"`
+	Hdp        float64 `json:"hdp"`
+	Price      int     `json:"price"`
+	HomeScore  int     `json:"home_score"`
+	AwayScore  int     `json:"away_score"`
+	Status     string  `json:"status"`
+	Multiplier float64 `json:"multiplier"`
+}
+
+// OutcomeTotal: ยอดรวมแยกตามผล (win, loss, draw ...)
+type OutcomeTotal struct {
+	Count  int          `json:"count"`
+	Stake  models.Money `json:"stake"`
+	Payout models.Money `json:"payout"`
+}
+
+// SettlementPlan: ผลรวมของการเคลียร์บิลทั้งรอบ
+type SettlementPlan struct {
+	Lines       []SettlementLine        `json:"lines"`
+	Legs        []LegResult             `json:"legs"`
+	Totals      map[string]OutcomeTotal `json:"totals"`
+	TotalStake  models.Money            `json:"total_stake"`
+	TotalPayout models.Money            `json:"total_payout"`
+	HouseNet    models.Money            `json:"house_net"` // ยอดได้เสียฝั่งเว็บ (stake - payout)
+}
+
+// FetchMatchResults: ดึงผลบอลจาก API แล้วทำเป็น Map ตาม match id
+func FetchMatchResults(feedPath string) (map[string]MatchScore, error) {
 	client := resty.New().SetTimeout(15 * time.Second)
-	url := "https://htayapi.com/mmk-autokyay/moung?key=eXBW5dl32piS2UbN75U1vikjWJJ9v7Ke"
+	url := fmt.Sprintf("https://htayapi.com/mmk-autokyay/%s?key=eXBW5dl32piS2UbN75U1vikjWJJ9v7Ke", feedPath)
 	var apiData ResultsResponse
 	resp, err := client.R().SetResult(&apiData).Get(url)
 
-	if err != nil || resp.IsError() {
-		log.Printf("❌ [Settlement] API Request Failed: %v", err)
-		return
+	if err != nil {
+		return nil, err
+	}
+	if resp.IsError() {
+		return nil, fmt.Errorf("results API returned status %d", resp.StatusCode())
 	}
 
 	// ทำ Map เพื่อความเร็วในการค้นหา
-	resultsMap := make(map[string]struct {
-		Home, Away int
-		IsFinished bool
-	})
+	resultsMap := make(map[string]MatchScore)
 	for _, r := range apiData.Data {
 		s := strings.ToUpper(r.Status)
 		// เพิ่ม "COMPLETED" เข้าไปเพื่อให้ระบบยอมรับผลบอลคู่นี้
 		finished := (s == "FT" || s == "FINISHED" || s == "CLOSED" || s == "COMPLETED")
 
-		resultsMap[r.ID] = struct {
-			Home, Away int
-			IsFinished bool
-		}{
-			r.Scores.FullTime.Home, // ดึงคะแนนทีมเหย้า
-			r.Scores.FullTime.Away, // ดึงคะแนนทีมเยือน
-			finished,
+		resultsMap[r.ID] = MatchScore{
+			Home:       r.Scores.FullTime.Home, // ดึงคะแนนทีมเหย้า
+			Away:       r.Scores.FullTime.Away, // ดึงคะแนนทีมเยือน
+			IsFinished: finished,
 		}
 	}
+	return resultsMap, nil
+}
+
+// BuildSettlementPlan: คำนวณผลบิลที่ค้างอยู่ทั้งหมด (หรือเฉพาะคู่ที่ระบุ) โดยไม่เขียนอะไรลง DB
+func BuildSettlementPlan(db *gorm.DB, results map[string]MatchScore, matchID string) (*SettlementPlan, error) {
+	plan := &SettlementPlan{
+		Lines:  []SettlementLine{},
+		Legs:   []LegResult{},
+		Totals: make(map[string]OutcomeTotal),
+	}
+
+	// เก็บยอดเงินล่าสุดของแต่ละ User เพื่อแสดงยอดหลังเคลียร์ทีละบิล
+	balances := make(map[uint]models.Money)
+
+	if err := plan.addSingles(db, results, matchID, balances); err != nil {
+		return nil, err
+	}
+	if err := plan.addParlays(db, results, matchID, balances); err != nil {
+		return nil, err
+	}
+
+	plan.HouseNet = plan.TotalStake - plan.TotalPayout
+	return plan, nil
+}
+
+// addLine: เพิ่มบิลเข้าแผน พร้อมคำนวณยอดเงินหลังเคลียร์และยอดรวมตามผล
+func (plan *SettlementPlan) addLine(line SettlementLine, user models.User, balances map[uint]models.Money) {
+	before, ok := balances[line.UserID]
+	if !ok {
+		before = user.Credit
+	}
+	line.Username = user.Username
+	line.BalanceBefore = before
+	line.BalanceAfter = before + line.Payout
+	balances[line.UserID] = line.BalanceAfter
+
+	plan.Lines = append(plan.Lines, line)
+
+	total := plan.Totals[line.Status]
+	total.Count++
+	total.Stake += line.Amount
+	total.Payout += line.Payout
+	plan.Totals[line.Status] = total
+
+	plan.TotalStake += line.Amount
+	plan.TotalPayout += line.Payout
+}
+
+// addSingles: คิดผลบอลเต็ง
+func (plan *SettlementPlan) addSingles(db *gorm.DB, results map[string]MatchScore, matchID string, balances map[uint]models.Money) error {
+	var pendingBets []models.BetSlip
+	query := db.Preload("User").Where("status = ?", models.BetStatusPending).Order("id asc")
+	if matchID != "" {
+		mID, err := strconv.ParseUint(matchID, 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid match id: %s", matchID)
+		}
+		query = query.Where("match_id = ?", uint(mID))
+	}
+	if err := query.Find(&pendingBets).Error; err != nil {
+		return err
+	}
 
 	for _, bet := range pendingBets {
-		matchKey := fmt.Sprintf("%d", bet.MatchID)
-		res, exists := resultsMap[matchKey]
+		if bet.MatchID == nil {
+			continue
+		}
+		matchKey := fmt.Sprintf("%d", *bet.MatchID)
+		res, exists := results[matchKey]
 
 		if !exists || !res.IsFinished {
 			continue
 		}
 
-		// คำนวณผลผ่าน Service
-		status, payout := CalculatePayout(bet.Amount, bet.Odds, bet.Hdp, bet.Pick, res.Home, res.Away)
+		// ราคาที่รับตอนแทง (Hdp/Price/IsHomeUpper) คิดแบบเดียวกับรายคู่ในสเต็ป
+		status, payout := SettleSingle(bet.Amount, res.Home, res.Away, bet.Hdp, bet.Price, bet.IsHomeUpper, bet.Pick)
+
+		plan.addLine(SettlementLine{
+			TicketType: "single",
+			TicketID:   bet.ID,
+			UserID:     bet.UserID,
+			MatchID:    matchKey,
+			Pick:       bet.Pick,
+			Amount:     bet.Amount,
+			Status:     status,
+			Payout:     payout,
+		}, bet.User, balances)
+	}
+	return nil
+}
+
+// addParlays: คิดผลรายคู่ของบอลสเต็ป แล้วปิดบิลที่รู้ผลแล้ว (เสียคู่ใดคู่หนึ่ง หรือจบครบทุกคู่)
+// ประเมินบิล pending ทุกใบทุกรอบ ไม่ใช่เฉพาะใบที่มีคู่เพิ่งรู้ผล (คู่ที่บันทึกไว้รอบก่อนแต่ปิดบิลไม่สำเร็จต้องปิดได้ในรอบถัดไป)
+func (plan *SettlementPlan) addParlays(db *gorm.DB, results map[string]MatchScore, matchID string, balances map[uint]models.Money) error {
+	var tickets []models.ParlayTicket
+	query := db.Preload("Items").Where("status = ?", models.BetStatusPending).Order("id asc")
+	if matchID != "" {
+		query = query.Where("id IN (?)", db.Model(&models.ParlayItem{}).Select("ticket_id").Where("match_id = ?", matchID))
+	}
+	if err := query.Find(&tickets).Error; err != nil {
+		return err
+	}
+	if len(tickets) == 0 {
+		return nil
+	}
+
+	// ดึงข้อมูล User ของบิลสเต็ป (ParlayTicket ไม่มี relation User)
+	userIDs := make([]uint, 0, len(tickets))
+	for _, t := range tickets {
+		userIDs = append(userIDs, t.UserID)
+	}
+	var users []models.User
+	if err := db.Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+		return err
+	}
+	userMap := make(map[uint]models.User, len(users))
+	for _, u := range users {
+		userMap[u.ID] = u
+	}
 
-		// เริ่มบันทึกผล
-		errTx := database.DB.Transaction(func(tx *gorm.DB) error {
-			// 1. อัปเดตสถานะบิล
-			updateResult := tx.Model(&bet).
-				Where("id = ? AND status = ?", bet.ID, "pending").
-				Updates(map[string]interface{}{
-					"status": status,
-					"payout": payout,
-				})
+	for _, ticket := range tickets {
+		var legs []LegResult
 
-			if updateResult.Error != nil {
-				return updateResult.Error
+		for i, item := range ticket.Items {
+			if item.Status != "" && item.Status != models.BetStatusPending {
+				continue
+			}
+			res, exists := results[item.MatchID]
+			if !exists || !res.IsFinished {
+				continue
 			}
 
-			// 2. ถ้าชนะหรือเสมอ ให้คืนเงิน/จ่ายรางวัล
-			if updateResult.RowsAffected > 0 && payout > 0 {
-				if err := tx.Model(&models.User{}).Where("id = ?", bet.UserID).
-					UpdateColumn("credit", gorm.Expr("credit + ?", payout)).Error; err != nil {
-					return err
-				}
-
-				tx.Create(&models.Transaction{
-					UserID: bet.UserID,
-					Amount: payout,
-					Type:   "payout",
-					Status: "success",
-				})
+			status := SettleLeg(res.Home, res.Away, item.Hdp, item.Price, item.IsHomeUpper, item.Pick)
+			ticket.Items[i].Status = status
+
+			legs = append(legs, LegResult{
+				ItemID:     item.ID,
+				TicketID:   ticket.ID,
+				MatchID:    item.MatchID,
+				Pick:       item.Pick,
+				Hdp:        item.Hdp,
+				Price:      item.Price,
+				HomeScore:  res.Home,
+				AwayScore:  res.Away,
+				Status:     status,
+				Multiplier: LegMultiplier(status, item.Price),
+			})
+		}
+
+		plan.Legs = append(plan.Legs, legs...)
+
+		outcome := EvaluateParlay(ticket.Amount, ticket.Items)
+		if !outcome.IsSettled {
+			continue
+		}
+
+		plan.addLine(SettlementLine{
+			TicketType: "parlay",
+			TicketID:   ticket.ID,
+			UserID:     ticket.UserID,
+			Amount:     ticket.Amount,
+			Status:     outcome.Status,
+			Payout:     outcome.Payout,
+			Multiplier: outcome.Multiplier,
+			legs:       legs,
+		}, userMap[ticket.UserID], balances)
+	}
+	return nil
+}
+
+// applyLegResult: บันทึกผลรายคู่ของบอลสเต็ป
+func applyLegResult(tx *gorm.DB, leg LegResult) error {
+	home, away := leg.HomeScore, leg.AwayScore
+	return tx.Model(&models.ParlayItem{}).
+		Where("id = ? AND (status = ? OR status = '' OR status IS NULL)", leg.ItemID, models.BetStatusPending).
+		Updates(map[string]interface{}{
+			"status":     leg.Status,
+			"multiplier": leg.Multiplier,
+			"score_home": &home,
+			"score_away": &away,
+		}).Error
+}
+
+// applySettlementLine: บันทึกผลบิล 1 ใบลง DB (สถานะ + จ่ายเงิน)
+func applySettlementLine(line SettlementLine) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		// 0. ผลรายคู่ของบิลสเต็ปบันทึกพร้อมปิดบิล (ล้มเหลว = ไม่มีอะไรถูกบันทึก รอบหน้าคิดใหม่ทั้งใบ)
+		for _, leg := range line.legs {
+			if err := applyLegResult(tx, leg); err != nil {
+				return err
 			}
+		}
+
+		// 1. อัปเดตสถานะบิล (เช็ค pending ซ้ำ กันเคลียร์ซ้ำ)
+		settledAt := time.Now()
+		updates := map[string]interface{}{
+			"status":     line.Status,
+			"payout":     line.Payout,
+			"settled_at": settledAt,
+		}
+
+		var model interface{} = &models.BetSlip{}
+		if line.TicketType == "parlay" {
+			model = &models.ParlayTicket{}
+			updates["total_odds"] = line.Multiplier
+		}
+
+		updateResult := tx.Model(model).
+			Where("id = ? AND status = ?", line.TicketID, models.BetStatusPending).
+			Updates(updates)
+
+		if updateResult.Error != nil {
+			return updateResult.Error
+		}
+		if updateResult.RowsAffected == 0 {
 			return nil
-		})
+		}
 
-		if errTx != nil {
-			log.Printf("❌ [Settlement] BetID %d Error: %v", bet.ID, errTx)
-		} else {
-			log.Printf("✅ [Settlement] BetID %d: %s (Payout: %.2f)", bet.ID, status, payout)
+		// 2. แบ่งได้เสียตามหุ้นถือสู้ของสายงาน
+		if err := allocateShares(tx, line, settledAt); err != nil {
+			return err
 		}
-	}
+
+		// 3. นับเทิร์นของโบนัสที่กำลังทำอยู่ (ครบแล้วย้ายโบนัสเข้ากระเป๋าหลัก)
+		if err := AddWagering(tx, line); err != nil {
+			return err
+		}
+
+		// 4. ถ้าชนะหรือเสมอ ให้คืนเงิน/จ่ายรางวัล (จ่ายจากบัญชีเจ้ามือ)
+		if line.Payout > 0 {
+			journal, err := PostWallet(tx, line.UserID, line.Payout, models.LedgerAccountHouse, Journal{
+				Type:    "payout",
+				RefType: line.TicketType,
+				RefID:   line.TicketID,
+				Note:    fmt.Sprintf("จ่ายผล %s #%d (%s)", line.TicketType, line.TicketID, line.Status),
+			})
+			if err != nil {
+				return err
+			}
+
+			_, err = RecordTransaction(tx, journal, line.UserID, models.Transaction{Type: "payout", Note: journal.Note})
+			return err
+		}
+		return nil
+	})
 }
 
-// 3. ฟังก์ชันคำนวณผล (แก้ไขสูตรราคาน้ำพม่าให้ถูกต้อง)
-func CalculatePayout(amount float64, odds float64, hdp float64, pick string, homeScore int, awayScore int) (string, float64) {
-	// คำนวณผลต่างประตู (Home - Away)
-	diff := float64(homeScore - awayScore)
+// AutoSettlement: เคลียร์บิลที่รู้ผลแล้วทั้งหมด (งาน "settlement" ใน Job Registry)
+func AutoSettlement(feedPath string) error {
+	log.Println("🔄 [Settlement] Starting process...")
+
+	var pendingSingles, pendingParlays int64
+	// เช็คว่ามีบิลค้างไหม ก่อนเรียก API
+	if err := database.DB.Model(&models.BetSlip{}).Where("status = ?", models.BetStatusPending).Count(&pendingSingles).Error; err != nil {
+		return fmt.Errorf("count pending bets: %w", err)
+	}
+	if err := database.DB.Model(&models.ParlayTicket{}).Where("status = ?", models.BetStatusPending).Count(&pendingParlays).Error; err != nil {
+		return fmt.Errorf("count pending parlays: %w", err)
+	}
+
+	if pendingSingles+pendingParlays == 0 {
+		log.Println("ℹ️ [Settlement] No pending bets.")
+		return nil
+	}
+
+	// เรียก API ผลบอล
+	results, err := FetchMatchResults(feedPath)
+	if err != nil {
+		return fmt.Errorf("results API request failed: %w", err)
+	}
+
+	plan, err := BuildSettlementPlan(database.DB, results, "")
+	if err != nil {
+		return fmt.Errorf("build settlement plan: %w", err)
+	}
+
+	// บันทึกผลรายคู่ของบิลที่ยังไม่จบ (บิลที่ปิดได้บันทึกผลรายคู่พร้อมกันใน applySettlementLine)
+	settling := make(map[uint]bool)
+	for _, line := range plan.Lines {
+		if line.TicketType == "parlay" {
+			settling[line.TicketID] = true
+		}
+	}
+	failed := 0
+	for _, leg := range plan.Legs {
+		if settling[leg.TicketID] {
+			continue
+		}
+		if err := applyLegResult(database.DB, leg); err != nil {
+			failed++
+			log.Printf("❌ [Settlement] ParlayItem %d Error: %v", leg.ItemID, err)
+		}
+	}
 
-	// สรุปผลในมุมของทีมต่อ (Home)
-	// สูตร: (ผลต่างประตู + ราคาต่อรอง)
-	result := diff + hdp
+	for _, line := range plan.Lines {
+		if errTx := applySettlementLine(line); errTx != nil {
+			failed++
+			log.Printf("❌ [Settlement] %s #%d Error: %v", line.TicketType, line.TicketID, errTx)
+		} else {
+			log.Printf("✅ [Settlement] %s #%d: %s (Payout: %s)", line.TicketType, line.TicketID, line.Status, line.Payout)
+		}
+	}
 
-	finalStatus := ""
-	multiplier := 0.0
+	if failed > 0 {
+		return fmt.Errorf("%d settlement records failed", failed)
+	}
+	return nil
+}
 
-	// แปลง Odds พม่า/มาเลย์ เป็นตัวคูณ (Decimal Odds)
-	// สมมติ odds ที่ส่งมาคือ 0.85 (น้ำดำ) ตัวคูณจะเป็น 1.85
-	decimalOdds := 1 + odds
+// PreviewSettlement: (Admin) ดูผลการเคลียร์บิลล่วงหน้า โดยไม่บันทึกอะไรลง DB
+// GET /admin/settle/preview?match_id=123&home_score=2&away_score=1
+// ถ้าส่งคะแนนมาด้วย จะใช้คะแนนนั้นแทนผลจาก API (สำหรับคู่ที่มีข้อโต้แย้ง)
+func PreviewSettlement(c *fiber.Ctx) error {
+	matchID := c.Query("match_id")
+	homeStr := c.Query("home_score")
+	awayStr := c.Query("away_score")
 
-	if result > 0.25 {
-		finalStatus = "win"
-		multiplier = decimalOdds
-	} else if result == 0.25 {
-		finalStatus = "win_half"
-		multiplier = 1 + (odds / 2) // ชนะครึ่ง: ได้ทุนคืน + กำไรครึ่งเดียว
-	} else if result == 0 {
-		finalStatus = "draw"
-		multiplier = 1.0 // เสมอ: คืนทุน
-	} else if result == -0.25 {
-		finalStatus = "lose_half"
-		multiplier = 0.5 // เสียครึ่ง: คืนทุนให้ครึ่งหนึ่ง
+	var results map[string]MatchScore
+	if homeStr != "" || awayStr != "" {
+		if matchID == "" {
+			return c.Status(400).JSON(fiber.Map{"error": "กรุณาระบุ match_id เมื่อกรอกคะแนนเอง"})
+		}
+		home, errHome := strconv.Atoi(homeStr)
+		away, errAway := strconv.Atoi(awayStr)
+		if errHome != nil || errAway != nil || home < 0 || away < 0 {
+			return c.Status(400).JSON(fiber.Map{"error": "คะแนนไม่ถูกต้อง"})
+		}
+		results = map[string]MatchScore{matchID: {Home: home, Away: away, IsFinished: true}}
 	} else {
-		finalStatus = "loss"
-		multiplier = 0.0 // เสียเต็ม
+		var err error
+		results, err = FetchMatchResults(Jobs.FeedPath("settlement"))
+		if err != nil {
+			return c.Status(502).JSON(fiber.Map{"error": "ดึงผลบอลไม่สำเร็จ", "details": err.Error()})
+		}
 	}
 
-	// ถ้า User แทงทีมรอง (Away) ให้สลับผลลัพธ์
-	if pick == "away" {
-		if finalStatus == "win" {
-			finalStatus = "loss"
-			multiplier = 0
-		} else if finalStatus == "win_half" {
-			finalStatus = "lose_half"
-			multiplier = 0.5
-		} else if finalStatus == "loss" {
-			finalStatus = "win"
-			multiplier = decimalOdds
-		} else if finalStatus == "lose_half" {
-			finalStatus = "win_half"
-			multiplier = 1 + (odds / 2)
+	if matchID != "" {
+		if _, err := strconv.ParseUint(matchID, 10, 32); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid Match ID"})
 		}
-		// draw ยังคงเป็น draw เหมือนเดิม
 	}
 
-	return finalStatus, amount * multiplier
+	plan, err := BuildSettlementPlan(database.DB, results, matchID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "คำนวณผลไม่สำเร็จ"})
+	}
+
+	return c.JSON(plan)
 }
 
 // ParseHdp แปลงค่า HDP จาก String เป็น Float64
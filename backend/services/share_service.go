@@ -0,0 +1,206 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/PawornpratKongdaeng/soccer/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ==========================================
+// หุ้นถือสู้ (Share / Position Taking)
+// ==========================================
+// User.Share คือ % รวมที่คนนั้นถือจากยอดของสายงานใต้ตัวเอง (ลูกถือได้ไม่เกินพ่อ)
+// ชั้นแรก (Upline ตรงของสมาชิก) ถือตาม Share ของตัวเอง
+// ชั้นถัดไปถือ "ส่วนต่าง" ระหว่าง Share ของตัวเองกับชั้นที่อยู่ต่ำกว่า
+// บริษัทรับส่วนที่เหลือทั้งหมด (100 - Share ของชั้นบนสุด)
+
+// ShareHolder: ผู้ถือหุ้น 1 ชั้นของบิล
+type ShareHolder struct {
+	HolderID *uint   `json:"holder_id"` // nil = บริษัท
+	Role     string  `json:"role"`
+	Level    int     `json:"level"`
+	SharePct float64 `json:"share_pct"`
+}
+
+// ShareChain: ไล่สาย ParentID ของสมาชิก แล้วคิด % ที่แต่ละชั้นถือ (ชั้นสุดท้ายคือบริษัทเสมอ)
+func ShareChain(db *gorm.DB, member models.User) []ShareHolder {
+	var holders []ShareHolder
+
+	taken := 0.0
+	level := 1
+	parentID := member.ParentID
+	for ; parentID != nil && level <= maxUplineDepth; level++ {
+		var parent models.User
+		if err := db.First(&parent, *parentID).Error; err != nil {
+			break
+		}
+		// ถึงบริษัทแล้ว (admin)
+		if parent.Role == "admin" {
+			break
+		}
+
+		share := math.Min(parent.Share, 100)
+		if pct := share - taken; pct > 0 {
+			id := parent.ID
+			holders = append(holders, ShareHolder{HolderID: &id, Role: parent.Role, Level: level, SharePct: pct})
+			taken = share
+		}
+		parentID = parent.ParentID
+	}
+
+	holders = append(holders, ShareHolder{HolderID: nil, Role: "company", Level: level, SharePct: 100 - taken})
+	return holders
+}
+
+// allocateShares: แบ่งได้เสียของบิลที่เคลียร์แล้วขึ้นไปตามสายงาน (เรียกใน Transaction เดียวกับการเคลียร์บิล)
+func allocateShares(tx *gorm.DB, line SettlementLine, settledAt time.Time) error {
+	var member models.User
+	if err := tx.First(&member, line.UserID).Error; err != nil {
+		return err
+	}
+
+	memberWinLoss := line.Payout - line.Amount
+	houseWinLoss := -memberWinLoss
+
+	holders := ShareChain(tx, member)
+	allocations := make([]models.ShareAllocation, 0, len(holders))
+	var allocated models.Money
+
+	for i, h := range holders {
+		amount := houseWinLoss.Percent(h.SharePct)
+		// ชั้นสุดท้าย (บริษัท) รับเศษที่เหลือ ให้ยอดรวมทุกชั้นเท่ากับยอดได้เสียพอดี
+		if i == len(holders)-1 {
+			amount = houseWinLoss - allocated
+		}
+		allocated += amount
+
+		allocations = append(allocations, models.ShareAllocation{
+			TicketType:    line.TicketType,
+			TicketID:      line.TicketID,
+			Level:         h.Level,
+			MemberID:      member.ID,
+			HolderID:      h.HolderID,
+			HolderRole:    h.Role,
+			SharePct:      h.SharePct,
+			Stake:         line.Amount,
+			MemberWinLoss: memberWinLoss,
+			Amount:        amount,
+			SettledAt:     settledAt,
+		})
+	}
+
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&allocations).Error
+}
+
+// DownlineIDs: ดึง ID ของสมาชิกทุกชั้นที่อยู่ใต้ rootID (ไม่รวมตัวเอง)
+func DownlineIDs(db *gorm.DB, rootID uint) ([]uint, error) {
+	var ids []uint
+	err := db.Raw(`
+		WITH RECURSIVE downline AS (
+			SELECT id FROM users WHERE parent_id = ? AND deleted_at IS NULL
+			UNION
+			SELECT u.id FROM users u INNER JOIN downline d ON u.parent_id = d.id WHERE u.deleted_at IS NULL
+		)
+		SELECT id FROM downline`, rootID).Scan(&ids).Error
+	return ids, err
+}
+
+// IsInDownline: เช็คว่า memberID อยู่ในสายงานของ rootID หรือไม่
+func IsInDownline(db *gorm.DB, rootID, memberID uint) bool {
+	ids, err := DownlineIDs(db, rootID)
+	if err != nil {
+		return false
+	}
+	for _, id := range ids {
+		if id == memberID {
+			return true
+		}
+	}
+	return false
+}
+
+// OpenPosition: ยอดที่ Agent ถือสู้อยู่ในบิลที่ยังไม่เคลียร์
+type OpenPosition struct {
+	TicketType string       `json:"ticket_type"`
+	TicketID   uint         `json:"ticket_id"`
+	MemberID   uint         `json:"member_id"`
+	Username   string       `json:"username"`
+	MatchID    string       `json:"match_id"`
+	Pick       string       `json:"pick"`
+	Stake      models.Money `json:"stake"`
+	Payout     models.Money `json:"potential_payout"`
+	SharePct   float64      `json:"share_pct"`
+	Exposure   models.Money `json:"exposure"` // ยอดที่ต้องจ่ายส่วนของเราถ้าสมาชิกชนะ
+	CreatedAt  time.Time    `json:"created_at"`
+}
+
+// OpenPositions: คิดยอดถือสู้ของ holderID จากบิลที่ยัง pending ของสายงานใต้ตัวเอง
+func OpenPositions(db *gorm.DB, holderID uint) ([]OpenPosition, error) {
+	memberIDs, err := DownlineIDs(db, holderID)
+	if err != nil {
+		return nil, err
+	}
+	positions := []OpenPosition{}
+	if len(memberIDs) == 0 {
+		return positions, nil
+	}
+
+	var members []models.User
+	if err := db.Where("id IN ?", memberIDs).Find(&members).Error; err != nil {
+		return nil, err
+	}
+
+	// หา % ที่เราถือของสมาชิกแต่ละคน
+	pctByMember := make(map[uint]float64, len(members))
+	nameByMember := make(map[uint]string, len(members))
+	for _, m := range members {
+		nameByMember[m.ID] = m.Username
+		for _, h := range ShareChain(db, m) {
+			if h.HolderID != nil && *h.HolderID == holderID {
+				pctByMember[m.ID] = h.SharePct
+			}
+		}
+	}
+
+	var singles []models.BetSlip
+	if err := db.Where("user_id IN ? AND status = ?", memberIDs, models.BetStatusPending).Find(&singles).Error; err != nil {
+		return nil, err
+	}
+	for _, b := range singles {
+		pct := pctByMember[b.UserID]
+		if pct <= 0 {
+			continue
+		}
+		matchID := ""
+		if b.MatchID != nil {
+			matchID = fmt.Sprintf("%d", *b.MatchID)
+		}
+		positions = append(positions, OpenPosition{
+			TicketType: "single", TicketID: b.ID, MemberID: b.UserID, Username: nameByMember[b.UserID],
+			MatchID: matchID, Pick: b.Pick, Stake: b.Amount, Payout: b.Payout, SharePct: pct,
+			Exposure: (b.Payout - b.Amount).Percent(pct), CreatedAt: b.CreatedAt,
+		})
+	}
+
+	var parlays []models.ParlayTicket
+	if err := db.Where("user_id IN ? AND status = ?", memberIDs, models.BetStatusPending).Find(&parlays).Error; err != nil {
+		return nil, err
+	}
+	for _, t := range parlays {
+		pct := pctByMember[t.UserID]
+		if pct <= 0 {
+			continue
+		}
+		positions = append(positions, OpenPosition{
+			TicketType: "parlay", TicketID: t.ID, MemberID: t.UserID, Username: nameByMember[t.UserID],
+			Stake: t.Amount, Payout: t.Payout, SharePct: pct,
+			Exposure: (t.Payout - t.Amount).Percent(pct), CreatedAt: t.CreatedAt,
+		})
+	}
+
+	return positions, nil
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/PawornpratKongdaeng/soccer/models"
+)
+
+func TestAllocateSharesRemainder(t *testing.T) {
+	db := openTestDB(t)
+	admin := createTestUser(t, db, "share_admin", 0)
+	admin = updateUser(t, db, admin.ID, map[string]interface{}{"role": "admin"})
+	master := createTestUser(t, db, "share_master", 0)
+	master = updateUser(t, db, master.ID, map[string]interface{}{"role": "master", "share": 66.67, "parent_id": admin.ID})
+	agent := createTestUser(t, db, "share_agent", 0)
+	agent = updateUser(t, db, agent.ID, map[string]interface{}{"role": "agent", "share": 33.33, "parent_id": master.ID})
+	member := createTestUser(t, db, "share_member", 0)
+	member = updateUser(t, db, member.ID, map[string]interface{}{"parent_id": agent.ID})
+
+	tests := []struct {
+		name   string
+		ticket uint
+		amount models.Money
+		payout models.Money
+		want   []models.Money // agent, master, company
+	}{
+		// 100.01 x 33.33% = 33.333... / x 33.34% = 33.343... บริษัทรับเศษที่เหลือ (33.34 ไม่ใช่ 33.33)
+		{"member loses", 1, models.NewMoney(100.01), 0, []models.Money{models.NewMoney(33.33), models.NewMoney(33.34), models.NewMoney(33.34)}},
+		{"member wins", 2, models.NewMoney(100.01), models.NewMoney(200.02), []models.Money{models.NewMoney(-33.33), models.NewMoney(-33.34), models.NewMoney(-33.34)}},
+		{"draw", 3, models.NewMoney(50), models.NewMoney(50), []models.Money{0, 0, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line := SettlementLine{TicketType: "single", TicketID: tt.ticket, UserID: member.ID, Amount: tt.amount, Payout: tt.payout}
+			if err := allocateShares(db, line, time.Now()); err != nil {
+				t.Fatalf("allocateShares() error = %v", err)
+			}
+
+			var rows []models.ShareAllocation
+			db.Where("ticket_type = ? AND ticket_id = ?", "single", tt.ticket).Order("level").Find(&rows)
+			if len(rows) != len(tt.want) {
+				t.Fatalf("allocations = %d, want %d", len(rows), len(tt.want))
+			}
+			holders := []*uint{&agent.ID, &master.ID, nil}
+			var sum models.Money
+			for i, r := range rows {
+				sum += r.Amount
+				if r.Amount != tt.want[i] {
+					t.Errorf("level %d amount = %s, want %s", r.Level, r.Amount, tt.want[i])
+				}
+				if (r.HolderID == nil) != (holders[i] == nil) || (r.HolderID != nil && *r.HolderID != *holders[i]) {
+					t.Errorf("level %d holder = %v, want %v", r.Level, r.HolderID, holders[i])
+				}
+			}
+			if houseWinLoss := tt.amount - tt.payout; sum != houseWinLoss {
+				t.Errorf("allocations sum = %s, want %s", sum, houseWinLoss)
+			}
+
+			// เคลียร์ซ้ำไม่สร้างรายการซ้ำ
+			if err := allocateShares(db, line, time.Now()); err != nil {
+				t.Fatalf("allocateShares() again error = %v", err)
+			}
+			var n int64
+			db.Model(&models.ShareAllocation{}).Where("ticket_type = ? AND ticket_id = ?", "single", tt.ticket).Count(&n)
+			if n != int64(len(tt.want)) {
+				t.Errorf("allocations after rerun = %d, want %d", n, len(tt.want))
+			}
+		})
+	}
+}
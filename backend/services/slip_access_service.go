@@ -0,0 +1,162 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/PawornpratKongdaeng/soccer/models"
+	"gorm.io/gorm"
+)
+
+// ==========================================
+// สิทธิ์เปิดดูสลิป (Slip Access)
+// ==========================================
+// สลิปไม่มี URL สาธารณะแล้ว เปิดได้ 2 ทาง:
+//   1. GET /api/v3/slips/:id          (ต้อง Login: Header หรือ Cookie)
+//   2. GET /api/v3/slip-files/:id?... (URL ลงลายเซ็น อายุ SlipURLTTL ใช้ใส่ <img> ได้ตรงๆ)
+// ผู้มีสิทธิ์: เจ้าของรายการ, Admin, Agent/Master ที่สมาชิกอยู่ในสายงาน
+// ทุกครั้งที่คนอื่นที่ไม่ใช่เจ้าของเปิดดู จะบันทึกลง SlipAccessLog
+
+// SlipURLTTL: อายุของ URL ลงลายเซ็น
+const SlipURLTTL = 5 * time.Minute
+
+var (
+	ErrSlipNotFound   = errors.New("slip not found")
+	ErrSlipForbidden  = errors.New("not allowed to view this slip")
+	ErrSlipURLInvalid = errors.New("invalid or expired slip url")
+)
+
+// LegacyUploads: สลิปเก่าก่อนมี Storage (slip_<id>_<ts>_<ชื่อเดิม> ใน ./uploads)
+var LegacyUploads = &LocalStorage{Dir: "./uploads"}
+
+var slipURLSecret = loadSlipURLSecret()
+
+const slipPathPrefix = "/uploads/" // Transaction.SlipPath = slipPathPrefix + key ใน Storage
+
+// loadSlipURLSecret: ใช้ SLIP_URL_SECRET ถ้าตั้งไว้ (หลายเครื่องต้องใช้ค่าเดียวกัน)
+// ไม่ตั้ง = สุ่มใหม่ทุกครั้งที่เปิดเซิร์ฟเวอร์ URL เก่าใช้ไม่ได้หลังรีสตาร์ท (อายุสั้นอยู่แล้ว)
+func loadSlipURLSecret() []byte {
+	if secret := os.Getenv("SLIP_URL_SECRET"); secret != "" {
+		return []byte(secret)
+	}
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		log.Fatalf("❌ [Slip] generate url secret: %v", err)
+	}
+	return buf
+}
+
+// CanViewSlip: เจ้าของ / Admin / Agent ต้นสาย
+func CanViewSlip(db *gorm.DB, viewer *models.User, t *models.Transaction) bool {
+	if viewer.ID == t.UserID {
+		return true
+	}
+	switch strings.ToLower(viewer.Role) {
+	case "admin":
+		return true
+	case "agent", "master":
+		return IsInDownline(db, viewer.ID, t.UserID)
+	}
+	return false
+}
+
+// LoadSlip: อ่านไฟล์สลิปของรายการ (variant = full/thumb) จาก Storage
+func LoadSlip(db *gorm.DB, transactionID uint, variant string) (*models.Transaction, []byte, string, error) {
+	var t models.Transaction
+	if err := db.First(&t, transactionID).Error; err != nil {
+		return nil, nil, "", ErrSlipNotFound
+	}
+
+	p := t.SlipPath
+	if variant == "thumb" && t.SlipThumbPath != "" {
+		p = t.SlipThumbPath
+	}
+	if !strings.HasPrefix(p, slipPathPrefix) {
+		return &t, nil, "", ErrSlipNotFound
+	}
+	key := strings.TrimPrefix(p, slipPathPrefix)
+
+	data, contentType, err := Uploads.Get(key)
+	if errors.Is(err, ErrObjectNotFound) {
+		data, contentType, err = LegacyUploads.Get(key)
+	}
+	if errors.Is(err, ErrObjectNotFound) {
+		return &t, nil, "", ErrSlipNotFound
+	}
+	if err != nil {
+		return &t, nil, "", err
+	}
+	return &t, data, contentType, nil
+}
+
+// OpenSlip: โหลดสลิป + ตรวจสิทธิ์ + บันทึกการเปิดดู (ใช้ทั้งทาง Login และ URL ลงลายเซ็น)
+func OpenSlip(db *gorm.DB, viewer *models.User, transactionID uint, variant, via, ip string) ([]byte, string, error) {
+	t, data, contentType, err := LoadSlip(db, transactionID, variant)
+	if t != nil && !CanViewSlip(db, viewer, t) {
+		// ไม่มีสิทธิ์ตอบเหมือนกันไม่ว่าไฟล์จะมีหรือไม่ (ไม่ให้เดาเลขรายการได้)
+		return nil, "", ErrSlipForbidden
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	LogSlipView(db, viewer, t, variant, via, ip)
+	return data, contentType, nil
+}
+
+// LogSlipView: บันทึกการเปิดดูสลิปของคนที่ไม่ใช่เจ้าของ (via = session/signed)
+func LogSlipView(db *gorm.DB, viewer *models.User, t *models.Transaction, variant, via, ip string) {
+	if viewer.ID == t.UserID {
+		return
+	}
+	entry := models.SlipAccessLog{
+		TransactionID: t.ID,
+		OwnerID:       t.UserID,
+		ViewerID:      viewer.ID,
+		Role:          strings.ToLower(viewer.Role),
+		Variant:       variant,
+		Via:           via,
+		IP:            ip,
+	}
+	if err := db.Create(&entry).Error; err != nil {
+		log.Printf("❌ [Slip] access log #%d by %d: %v", t.ID, viewer.ID, err)
+	}
+}
+
+// NormalizeSlipVariant: ค่าว่าง/ไม่รู้จัก = full
+func NormalizeSlipVariant(v string) string {
+	if v == "thumb" {
+		return v
+	}
+	return "full"
+}
+
+// SignSlipURL: URL ลงลายเซ็นของสลิป ผูกกับผู้ขอ (ใช้บันทึกว่าใครเปิด)
+func SignSlipURL(transactionID, viewerID uint, variant string, now time.Time) (string, time.Time) {
+	exp := now.Add(SlipURLTTL)
+	sig := slipSignature(transactionID, viewerID, variant, exp.Unix())
+	return fmt.Sprintf("/api/v3/slip-files/%d?size=%s&uid=%d&exp=%d&sig=%s",
+		transactionID, variant, viewerID, exp.Unix(), sig), exp
+}
+
+// VerifySlipURL: ตรวจลายเซ็นและเวลาหมดอายุของ URL
+func VerifySlipURL(transactionID, viewerID uint, variant string, exp int64, sig string, now time.Time) error {
+	if now.Unix() > exp {
+		return ErrSlipURLInvalid
+	}
+	want := slipSignature(transactionID, viewerID, variant, exp)
+	if !hmac.Equal([]byte(want), []byte(sig)) {
+		return ErrSlipURLInvalid
+	}
+	return nil
+}
+
+func slipSignature(transactionID, viewerID uint, variant string, exp int64) string {
+	return hex.EncodeToString(hmacSHA256(slipURLSecret, fmt.Sprintf("%d|%d|%s|%d", transactionID, viewerID, variant, exp)))
+}
@@ -0,0 +1,164 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"time"
+
+	"github.com/PawornpratKongdaeng/soccer/models"
+	"gorm.io/gorm"
+)
+
+// ==========================================
+// ตรวจสลิปซ้ำ (Slip Fingerprint)
+// ==========================================
+// สลิปทุกใบเก็บ SHA-256 ของไฟล์ (จับไฟล์เดิมเป๊ะ) และ dHash 64 บิตของภาพ
+// (จับสลิปเดิมที่ถูกบีบอัดใหม่/ย่อขนาด/ครอปขอบเล็กน้อย) แล้วเทียบกับสลิปของทุกคนตอนแจ้งฝาก
+
+// slipNearDistance: dHash ต่างกันไม่เกินกี่บิตถึงนับว่าเป็นภาพเดียวกัน
+const slipNearDistance = 10
+
+var ErrDuplicateSlip = errors.New("slip matches an earlier deposit")
+
+// FingerprintSlip: คำนวณลายนิ้วมือจากไฟล์สลิป (อ่านรูปไม่ได้ = มีแค่ SHA-256)
+func FingerprintSlip(data []byte) models.SlipFingerprint {
+	sum := sha256.Sum256(data)
+	fp := models.SlipFingerprint{SHA256: hex.EncodeToString(sum[:])}
+
+	if img, _, err := image.Decode(bytes.NewReader(data)); err == nil {
+		h := int64(slipDHash(img))
+		fp.PHash = &h
+	}
+	return fp
+}
+
+// slipDHash: ย่อภาพเป็นขาวดำ 9x8 แล้วเทียบความสว่างช่องติดกันในแต่ละแถว ได้ 64 บิต
+func slipDHash(img image.Image) uint64 {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w == 0 || h == 0 {
+		return 0
+	}
+
+	var gray [8][9]float64
+	for y := 0; y < 8; y++ {
+		y0, y1 := b.Min.Y+y*h/8, b.Min.Y+(y+1)*h/8
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < 9; x++ {
+			x0, x1 := b.Min.X+x*w/9, b.Min.X+(x+1)*w/9
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+			// ค่าเฉลี่ยความสว่างของช่อง (สุ่มทุก step พิกเซลพอ ไม่ต้องอ่านทั้งภาพ)
+			stepX, stepY := max((x1-x0)/16, 1), max((y1-y0)/16, 1)
+			var sum float64
+			var n int
+			for py := y0; py < y1 && py < b.Max.Y; py += stepY {
+				for px := x0; px < x1 && px < b.Max.X; px += stepX {
+					r, g, bl, _ := img.At(px, py).RGBA()
+					sum += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(bl)
+					n++
+				}
+			}
+			if n > 0 {
+				gray[y][x] = sum / float64(n)
+			}
+		}
+	}
+
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			hash <<= 1
+			if gray[y][x] > gray[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// findDuplicateSlip: หารายการฝากเดิม (ของใครก็ได้) ที่ใช้สลิปเดียวกัน ตรงเป๊ะก่อน แล้วค่อยดูภาพใกล้เคียง
+func findDuplicateSlip(db *gorm.DB, fp *models.SlipFingerprint, excludeTxID uint) (txID uint, match string, distance int, err error) {
+	var exact models.SlipFingerprint
+	err = db.Where("sha256 = ? AND transaction_id <> ?", fp.SHA256, excludeTxID).
+		Order("transaction_id").Limit(1).Find(&exact).Error
+	if err != nil {
+		return 0, "", 0, err
+	}
+	if exact.ID != 0 {
+		return exact.TransactionID, models.SlipMatchExact, 0, nil
+	}
+	if fp.PHash == nil {
+		return 0, "", 0, nil
+	}
+
+	// Hamming distance ของ dHash คำนวณใน Postgres: XOR แล้วนับบิต 1
+	var near struct {
+		TransactionID uint
+		Distance      int
+	}
+	err = db.Model(&models.SlipFingerprint{}).
+		Select("transaction_id, length(replace(((phash # ?)::bit(64))::text, '0', '')) AS distance", *fp.PHash).
+		Where("phash IS NOT NULL AND transaction_id <> ?", excludeTxID).
+		Order("distance, transaction_id").Limit(1).Scan(&near).Error
+	if err != nil {
+		return 0, "", 0, err
+	}
+	if near.TransactionID == 0 || near.Distance > slipNearDistance {
+		return 0, "", 0, nil
+	}
+	return near.TransactionID, models.SlipMatchNear, near.Distance, nil
+}
+
+// RecordSlip: บันทึกลายนิ้วมือสลิปของรายการฝาก พร้อมติดธงถ้าตรงกับรายการเดิม
+func RecordSlip(db *gorm.DB, t *models.Transaction, data []byte) (*models.SlipFingerprint, error) {
+	fp := FingerprintSlip(data)
+	fp.TransactionID = t.ID
+	fp.UserID = t.UserID
+
+	dupID, match, distance, err := findDuplicateSlip(db, &fp, t.ID)
+	if err != nil {
+		return nil, err
+	}
+	if dupID != 0 {
+		fp.DuplicateOfID = &dupID
+		fp.Match = match
+		fp.Distance = distance
+	}
+	if err := db.Create(&fp).Error; err != nil {
+		return nil, err
+	}
+	return &fp, nil
+}
+
+// CheckSlipApproval: เรียกก่อนอนุมัติรายการฝาก สลิปที่ติดธงซ้ำต้องมีเหตุผลจาก Admin
+// มีเหตุผล = บันทึกผู้ยืนยันไว้กับสลิป แล้วอนุมัติต่อได้
+func CheckSlipApproval(tx *gorm.DB, transactionID uint, reason string, adminID uint) error {
+	var fp models.SlipFingerprint
+	if err := tx.Where("transaction_id = ?", transactionID).Limit(1).Find(&fp).Error; err != nil {
+		return err
+	}
+	if fp.ID == 0 || !fp.Flagged() {
+		return nil
+	}
+	if reason == "" {
+		return fmt.Errorf("%w: #%d (%s)", ErrDuplicateSlip, *fp.DuplicateOfID, fp.Match)
+	}
+
+	now := time.Now()
+	return tx.Model(&fp).Updates(map[string]interface{}{
+		"override_reason": reason,
+		"override_by":     adminID,
+		"override_at":     now,
+	}).Error
+}
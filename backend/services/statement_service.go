@@ -0,0 +1,267 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/PawornpratKongdaeng/soccer/models"
+	"gorm.io/gorm"
+)
+
+// ==========================================
+// Statement: รายการเดินบัญชีเครดิตของสมาชิก
+// ==========================================
+// ดึงจาก ledger_entries ของ wallet ซึ่งครอบคลุมทุกการเปลี่ยนยอด (ฝาก/ถอน, แทง, จ่ายผล, ค่าคอม, โอนเครดิตของ Agent)
+// ยอดคงเหลือคิดสะสมจากยอดยกมา ณ วันเริ่ม ไม่อ่านจาก BalanceAfter ที่บันทึกไว้ (ให้ตรงกับผลรวม Ledger เสมอ)
+
+const statementMaxDays = 366
+
+var ErrStatementRange = errors.New("invalid statement range")
+
+// statementLabels: คำอธิบายของแต่ละประเภทรายการ [ไทย, อังกฤษ (ใช้ใน PDF)]
+var statementLabels = map[string][2]string{
+	"opening":         {"ยอดยกมา", "Opening balance"},
+	"deposit":         {"ฝากเงิน", "Deposit"},
+	"withdraw":        {"ถอนเงิน", "Withdrawal"},
+	"withdraw_refund": {"คืนเงินรายการถอน", "Withdrawal refund"},
+	"bet":             {"แทงบอล", "Bet stake"},
+	"payout":          {"จ่ายผลบิล", "Bet payout"},
+	"commission":      {"ค่าคอม", "Commission"},
+	"transfer":        {"โอนเครดิต", "Credit transfer"},
+	"adjustment":      {"ปรับยอด", "Adjustment"},
+	"credit_clear":    {"เคลียร์ยอดเครดิตประจำรอบ", "Credit line settlement"},
+	"bonus_release":   {"โบนัสทำเทิร์นครบ", "Bonus released"},
+	"referral":        {"รางวัลแนะนำเพื่อน", "Referral reward"},
+	"cashback":        {"คืนยอดเสีย", "Cashback"},
+}
+
+// StatementLine: 1 บรรทัดใน Statement (In = เงินเข้า, Out = เงินออก)
+type StatementLine struct {
+	Time         time.Time    `json:"time"`
+	EntryID      uint         `json:"entry_id"`
+	JournalID    uint         `json:"journal_id"`
+	Type         string       `json:"type"`
+	Description  string       `json:"description"`
+	RefType      string       `json:"ref_type"`
+	RefID        uint         `json:"ref_id"`
+	Counterparty string       `json:"counterparty"` // อีกฝั่งของการโอนเครดิต / ผู้ทำรายการ
+	Note         string       `json:"note"`
+	In           models.Money `json:"in"`
+	Out          models.Money `json:"out"`
+	Balance      models.Money `json:"balance"`
+}
+
+type Statement struct {
+	UserID   uint            `json:"user_id"`
+	Username string          `json:"username"`
+	Currency string          `json:"currency"` // ทุกยอดเป็นสกุลกระเป๋าของสมาชิก
+	From     time.Time       `json:"from"`
+	To       time.Time       `json:"to"` // สิ้นสุดช่วง (ไม่รวมเวลานี้)
+	Opening  models.Money    `json:"opening_balance"`
+	TotalIn  models.Money    `json:"total_in"`
+	TotalOut models.Money    `json:"total_out"`
+	Closing  models.Money    `json:"closing_balance"`
+	Lines    []StatementLine `json:"lines"`
+}
+
+// StatementRange: แปลงช่วงวันที่ ?start=2024-01-01&end=2024-01-31 (รวมวันสุดท้าย, เวลาไทย)
+// ไม่ระบุ = 30 วันล่าสุด
+func StatementRange(start, end string, now time.Time) (time.Time, time.Time, error) {
+	now = now.In(bangkokTZ)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, bangkokTZ)
+
+	to := today.AddDate(0, 0, 1)
+	if end != "" {
+		t, err := time.ParseInLocation("2006-01-02", end, bangkokTZ)
+		if err != nil {
+			return time.Time{}, time.Time{}, ErrStatementRange
+		}
+		to = t.AddDate(0, 0, 1)
+	}
+
+	from := to.AddDate(0, 0, -30)
+	if start != "" {
+		t, err := time.ParseInLocation("2006-01-02", start, bangkokTZ)
+		if err != nil {
+			return time.Time{}, time.Time{}, ErrStatementRange
+		}
+		from = t
+	}
+
+	if !to.After(from) || to.Sub(from) > statementMaxDays*24*time.Hour {
+		return time.Time{}, time.Time{}, ErrStatementRange
+	}
+	return from, to, nil
+}
+
+// BuildStatement: รวมรายการเดินบัญชีของ userID ในช่วง [from, to)
+func BuildStatement(db *gorm.DB, userID uint, from, to time.Time) (*Statement, error) {
+	var user models.User
+	if err := db.Unscoped().Select("id", "username", "currency").First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+
+	st := &Statement{UserID: user.ID, Username: user.Username, Currency: user.Currency, From: from, To: to, Lines: []StatementLine{}}
+
+	// 1. ยอดยกมา = ผลรวม Entry ก่อนวันเริ่ม
+	if err := db.Model(&models.LedgerEntry{}).
+		Where("account = ? AND user_id = ? AND created_at < ?", models.LedgerAccountWallet, userID, from).
+		Select("COALESCE(SUM(amount), 0)").Scan(&st.Opening).Error; err != nil {
+		return nil, err
+	}
+
+	// 2. รายการในช่วง พร้อมข้อมูล Journal และอีกฝั่งของรายการ (กระเป๋าอีกใบ หรือคนทำรายการ)
+	type row struct {
+		EntryID      uint
+		JournalID    uint
+		Amount       models.Money
+		CreatedAt    time.Time
+		Type         string
+		RefType      string
+		RefID        uint
+		Note         string
+		Counterparty string
+	}
+	var rows []row
+	err := db.Table("ledger_entries e").
+		Select(`e.id AS entry_id, e.journal_id, e.amount, e.created_at,
+			j.type, j.ref_type, j.ref_id, j.note,
+			COALESCE(
+				(SELECT u.username FROM ledger_entries o INNER JOIN users u ON u.id = o.user_id
+					WHERE o.journal_id = e.journal_id AND o.account = e.account AND o.user_id <> e.user_id
+					ORDER BY o.id LIMIT 1),
+				(SELECT u.username FROM users u WHERE u.id = j.created_by AND u.id <> e.user_id),
+				'') AS counterparty`).
+		Joins("INNER JOIN ledger_journals j ON j.id = e.journal_id").
+		Where("e.account = ? AND e.user_id = ? AND e.created_at >= ? AND e.created_at < ?",
+			models.LedgerAccountWallet, userID, from, to).
+		Order("e.created_at, e.id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	balance := st.Opening
+	for _, r := range rows {
+		balance += r.Amount
+		line := StatementLine{
+			Time:         r.CreatedAt,
+			EntryID:      r.EntryID,
+			JournalID:    r.JournalID,
+			Type:         r.Type,
+			Description:  statementDescription(r.Type, r.RefType, r.RefID, r.Counterparty, r.Amount, 0),
+			RefType:      r.RefType,
+			RefID:        r.RefID,
+			Counterparty: r.Counterparty,
+			Note:         r.Note,
+			Balance:      balance,
+		}
+		if r.Amount >= 0 {
+			line.In = r.Amount
+			st.TotalIn += r.Amount
+		} else {
+			line.Out = -r.Amount
+			st.TotalOut += -r.Amount
+		}
+		st.Lines = append(st.Lines, line)
+	}
+	st.Closing = balance
+
+	return st, nil
+}
+
+// statementDescription: คำอธิบายรายการ lang 0 = ไทย, 1 = อังกฤษ
+func statementDescription(journalType, refType string, refID uint, counterparty string, amount models.Money, lang int) string {
+	label, ok := statementLabels[journalType]
+	desc := journalType
+	if ok {
+		desc = label[lang]
+	}
+
+	switch refType {
+	case "single", "parlay":
+		desc = fmt.Sprintf("%s %s #%d", desc, refType, refID)
+	case "transaction", "commission_rebate", "balance_drift", "bonus_grant", "cashback_batch":
+		desc = fmt.Sprintf("%s #%d", desc, refID)
+	}
+
+	if counterparty != "" {
+		prep := [2][2]string{{"จาก", "ให้"}, {"from", "to"}}[lang]
+		if amount >= 0 {
+			desc += " " + prep[0] + " " + counterparty
+		} else {
+			desc += " " + prep[1] + " " + counterparty
+		}
+	}
+	return desc
+}
+
+// CSV: ไฟล์ CSV (ใส่ BOM ให้ Excel อ่านภาษาไทยได้)
+func (st *Statement) CSV() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("\ufeff")
+
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"วันที่", "ประเภท", "รายการ", "หมายเหตุ", "เงินเข้า", "เงินออก", "คงเหลือ"})
+	w.Write([]string{st.From.In(bangkokTZ).Format("2006-01-02 15:04:05"), "opening", "ยอดยกมา", "", "", "", st.Opening.String()})
+	for _, l := range st.Lines {
+		in, out := "", ""
+		if l.In != 0 {
+			in = l.In.String()
+		}
+		if l.Out != 0 {
+			out = l.Out.String()
+		}
+		w.Write([]string{
+			l.Time.In(bangkokTZ).Format("2006-01-02 15:04:05"),
+			l.Type, l.Description, l.Note, in, out, l.Balance.String(),
+		})
+	}
+	w.Write([]string{"", "", "รวม", "", st.TotalIn.String(), st.TotalOut.String(), st.Closing.String()})
+	w.Flush()
+
+	return buf.Bytes(), w.Error()
+}
+
+// PDF: เอกสารสำหรับพิมพ์ (ฟอนต์มาตรฐานของ PDF ไม่มีอักษรไทย จึงใช้คำอธิบายภาษาอังกฤษ)
+func (st *Statement) PDF() []byte {
+	const rowFormat = "%-16s  %-40s %13s %13s %14s"
+
+	header := []string{
+		"ACCOUNT STATEMENT",
+		"",
+		fmt.Sprintf("Member : %s (ID %d)", pdfSafe(st.Username), st.UserID),
+		fmt.Sprintf("Wallet : %s", st.Currency),
+		fmt.Sprintf("Period : %s to %s (GMT+7)", st.From.In(bangkokTZ).Format("2006-01-02"), st.To.In(bangkokTZ).AddDate(0, 0, -1).Format("2006-01-02")),
+		fmt.Sprintf("Printed: %s", time.Now().In(bangkokTZ).Format("2006-01-02 15:04")),
+		"",
+		fmt.Sprintf(rowFormat, "Date", "Description", "In", "Out", "Balance"),
+		strings.Repeat("-", 101),
+	}
+
+	body := []string{fmt.Sprintf(rowFormat, st.From.In(bangkokTZ).Format("2006-01-02 15:04"), "Opening balance", "", "", st.Opening)}
+	for _, l := range st.Lines {
+		in, out := "", ""
+		if l.In != 0 {
+			in = l.In.String()
+		}
+		if l.Out != 0 {
+			out = l.Out.String()
+		}
+		desc := pdfSafe(statementDescription(l.Type, l.RefType, l.RefID, l.Counterparty, l.In-l.Out, 1))
+		if len(desc) > 40 {
+			desc = desc[:39] + "~"
+		}
+		body = append(body, fmt.Sprintf(rowFormat, l.Time.In(bangkokTZ).Format("2006-01-02 15:04"), desc, in, out, l.Balance))
+	}
+	body = append(body,
+		header[len(header)-1],
+		fmt.Sprintf(rowFormat, "", "Total", st.TotalIn, st.TotalOut, st.Closing),
+	)
+
+	return renderTextPDF(header, body)
+}
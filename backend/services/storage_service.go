@@ -0,0 +1,369 @@
+package services
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ==========================================
+// ที่เก็บไฟล์อัปโหลด (Storage)
+// ==========================================
+// ตั้งผ่าน ENV:
+//   STORAGE_DRIVER = local (ค่าเริ่มต้น) | s3
+//   UPLOAD_DIR     = โฟลเดอร์ของ local (ค่าเริ่มต้น ./uploads)
+//   S3_ENDPOINT, S3_REGION, S3_BUCKET, S3_ACCESS_KEY, S3_SECRET_KEY
+//   (ใช้ได้กับ AWS S3 และตัวที่เข้ากันได้ เช่น MinIO ใน docker-compose)
+// key เป็น path แบบ "/" เสมอ เช่น slips/2026/10/<random>.jpg
+
+var ErrObjectNotFound = errors.New("object not found")
+
+// Storage: ที่เก็บไฟล์ 1 แห่ง
+type Storage interface {
+	Put(key string, data []byte, contentType string) error
+	Get(key string) (data []byte, contentType string, err error)
+	Delete(key string) error
+}
+
+// Uploads: ที่เก็บไฟล์อัปโหลดของระบบ (main.go เรียก InitStorage ก่อนเปิดรับ Request)
+var Uploads Storage = &LocalStorage{Dir: "./uploads"}
+
+// InitStorage: เลือกที่เก็บไฟล์ตาม ENV
+func InitStorage() error {
+	switch driver := strings.ToLower(os.Getenv("STORAGE_DRIVER")); driver {
+	case "", "local":
+		dir := os.Getenv("UPLOAD_DIR")
+		if dir == "" {
+			dir = "./uploads"
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		Uploads = &LocalStorage{Dir: dir}
+		log.Printf("✅ [Storage] local disk: %s", dir)
+	case "s3":
+		s3 := &S3Storage{
+			Endpoint:  strings.TrimRight(os.Getenv("S3_ENDPOINT"), "/"),
+			Region:    os.Getenv("S3_REGION"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+		}
+		if s3.Region == "" {
+			s3.Region = "us-east-1"
+		}
+		if s3.Endpoint == "" || s3.Bucket == "" || s3.AccessKey == "" || s3.SecretKey == "" {
+			return errors.New("S3_ENDPOINT, S3_BUCKET, S3_ACCESS_KEY and S3_SECRET_KEY are required")
+		}
+		if err := s3.EnsureBucket(); err != nil {
+			return err
+		}
+		Uploads = s3
+		log.Printf("✅ [Storage] s3: %s/%s", s3.Endpoint, s3.Bucket)
+	default:
+		return fmt.Errorf("unknown STORAGE_DRIVER %q", driver)
+	}
+	return nil
+}
+
+// cleanStorageKey: กัน key แบบ ../ หลุดออกนอกที่เก็บ
+func cleanStorageKey(key string) (string, error) {
+	clean := strings.TrimPrefix(path.Clean("/"+key), "/")
+	if clean == "" || clean == "." || strings.Contains(key, "\\") {
+		return "", fmt.Errorf("invalid storage key %q", key)
+	}
+	return clean, nil
+}
+
+// ------------------------------------------
+// Local disk
+// ------------------------------------------
+
+type LocalStorage struct {
+	Dir string
+}
+
+func (s *LocalStorage) path(key string) (string, error) {
+	clean, err := cleanStorageKey(key)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(s.Dir, filepath.FromSlash(clean)), nil
+}
+
+func (s *LocalStorage) Put(key string, data []byte, contentType string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(p, data, 0644)
+}
+
+func (s *LocalStorage) Get(key string) ([]byte, string, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, "", err
+	}
+	data, err := os.ReadFile(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, "", ErrObjectNotFound
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return data, http.DetectContentType(data), nil
+}
+
+func (s *LocalStorage) Delete(key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// ------------------------------------------
+// In-memory (เทสต์ / รันเครื่องตัวเองแบบไม่เก็บไฟล์)
+// ------------------------------------------
+
+type memoryObject struct {
+	data        []byte
+	contentType string
+}
+
+type MemoryStorage struct {
+	mu      sync.Mutex
+	objects map[string]memoryObject
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{objects: map[string]memoryObject{}}
+}
+
+func (s *MemoryStorage) Put(key string, data []byte, contentType string) error {
+	clean, err := cleanStorageKey(key)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[clean] = memoryObject{data: bytes.Clone(data), contentType: contentType}
+	return nil
+}
+
+func (s *MemoryStorage) Get(key string) ([]byte, string, error) {
+	clean, err := cleanStorageKey(key)
+	if err != nil {
+		return nil, "", err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	obj, ok := s.objects[clean]
+	if !ok {
+		return nil, "", ErrObjectNotFound
+	}
+	return bytes.Clone(obj.data), obj.contentType, nil
+}
+
+func (s *MemoryStorage) Delete(key string) error {
+	clean, err := cleanStorageKey(key)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.objects, clean)
+	return nil
+}
+
+// ------------------------------------------
+// S3-compatible (path-style + AWS Signature V4)
+// ------------------------------------------
+
+type S3Storage struct {
+	Endpoint  string // เช่น http://minio:9000, https://s3.ap-southeast-1.amazonaws.com
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+}
+
+var s3Client = &http.Client{Timeout: 30 * time.Second}
+
+func (s *S3Storage) Put(key string, data []byte, contentType string) error {
+	resp, err := s.do(http.MethodPut, key, data, contentType)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return s3Error(resp)
+	}
+	return nil
+}
+
+func (s *S3Storage) Get(key string) ([]byte, string, error) {
+	resp, err := s.do(http.MethodGet, key, nil, "")
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, "", ErrObjectNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", s3Error(resp)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, resp.Header.Get("Content-Type"), nil
+}
+
+func (s *S3Storage) Delete(key string) error {
+	resp, err := s.do(http.MethodDelete, key, nil, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return s3Error(resp)
+	}
+	return nil
+}
+
+// EnsureBucket: สร้าง Bucket ถ้ายังไม่มี (สะดวกตอนรันกับ MinIO เครื่องตัวเอง)
+func (s *S3Storage) EnsureBucket() error {
+	resp, err := s.request(http.MethodHead, "/"+s.Bucket, nil, "")
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	if resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("s3 head bucket: %s", resp.Status)
+	}
+
+	resp, err = s.request(http.MethodPut, "/"+s.Bucket, nil, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return s3Error(resp)
+	}
+	return nil
+}
+
+func (s *S3Storage) do(method, key string, body []byte, contentType string) (*http.Response, error) {
+	clean, err := cleanStorageKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return s.request(method, "/"+s.Bucket+"/"+s3EscapePath(clean), body, contentType)
+}
+
+// request: ส่ง Request พร้อมลงลายเซ็น AWS Signature V4 (payload hash เต็ม ไม่ใช้ UNSIGNED-PAYLOAD)
+func (s *S3Storage) request(method, uri string, body []byte, contentType string) (*http.Response, error) {
+	req, err := http.NewRequest(method, s.Endpoint+uri, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := amzDate[:8]
+	payloadHash := sha256Hex(body)
+
+	headers := map[string]string{
+		"host":                 req.URL.Host,
+		"x-amz-content-sha256": payloadHash,
+		"x-amz-date":           amzDate,
+	}
+	if contentType != "" {
+		headers["content-type"] = contentType
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+		if name != "host" {
+			req.Header.Set(name, headers[name])
+		}
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		method, uri, "", canonicalHeaders.String(), signedHeaders, payloadHash,
+	}, "\n")
+	scope := date + "/" + s.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretKey), date)
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.AccessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+	return s3Client.Do(req)
+}
+
+func s3Error(resp *http.Response) error {
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("s3 %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+}
+
+// s3EscapePath: URI encode ตามกติกา SigV4 (คง "/" ไว้)
+func s3EscapePath(p string) string {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		ch := p[i]
+		if ch == '/' || ch == '-' || ch == '_' || ch == '.' || ch == '~' ||
+			('A' <= ch && ch <= 'Z') || ('a' <= ch && ch <= 'z') || ('0' <= ch && ch <= '9') {
+			b.WriteByte(ch)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", ch)
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PawornpratKongdaeng/soccer/database"
+	"github.com/PawornpratKongdaeng/soccer/models"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var (
+	testDBOnce sync.Once
+	testDB     *gorm.DB
+	testDBErr  error
+)
+
+// openTestDB: Postgres สำหรับเทสต์ที่ต้องใช้ DB จริง (ตั้ง TEST_DATABASE_URL ไม่ตั้ง = ข้ามเทสต์)
+// แต่ละเทสต์รันใน Transaction ที่ rollback ตอนจบ (database.DB ชี้ไปที่ Transaction นั้นระหว่างเทสต์)
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	testDBOnce.Do(func() {
+		testDB, testDBErr = gorm.Open(postgres.Open(dsn), &gorm.Config{
+			DisableForeignKeyConstraintWhenMigrating: true,
+			Logger:                                   logger.Default.LogMode(logger.Silent),
+		})
+		if testDBErr == nil {
+			testDBErr = testDB.AutoMigrate(database.Models()...)
+		}
+	})
+	if testDBErr != nil {
+		t.Fatalf("open test db: %v", testDBErr)
+	}
+
+	tx := testDB.Begin()
+	prev := database.DB
+	database.DB = tx
+	t.Cleanup(func() {
+		database.DB = prev
+		tx.Rollback()
+	})
+	return tx
+}
+
+// createTestUser: สมาชิกใหม่พร้อมยอดกระเป๋าเริ่มต้น (ลงสมุดบัญชีผ่าน PostWallet)
+func createTestUser(t *testing.T, db *gorm.DB, username string, credit models.Money) models.User {
+	t.Helper()
+	user := models.User{Username: username, Password: "x", Role: "user", Currency: models.CurrencyTHB,
+		BankName: "KBANK", BankAccount: "1234567890", FullName: username}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("create user %s: %v", username, err)
+	}
+	if credit != 0 {
+		if _, err := PostWallet(db, user.ID, credit, models.LedgerAccountCash, Journal{Type: "adjustment", Note: "test"}); err != nil {
+			t.Fatalf("fund user %s: %v", username, err)
+		}
+	}
+	return reloadUser(t, db, user.ID)
+}
+
+func reloadUser(t *testing.T, db *gorm.DB, id uint) models.User {
+	t.Helper()
+	var user models.User
+	if err := db.First(&user, id).Error; err != nil {
+		t.Fatalf("load user %d: %v", id, err)
+	}
+	return user
+}
+
+// updateUser: แก้ฟิลด์ของสมาชิก (เช่น parent_id, com, share, role) แล้วโหลดใหม่
+func updateUser(t *testing.T, db *gorm.DB, id uint, fields map[string]interface{}) models.User {
+	t.Helper()
+	if err := db.Model(&models.User{}).Where("id = ?", id).Updates(fields).Error; err != nil {
+		t.Fatalf("update user %d: %v", id, err)
+	}
+	return reloadUser(t, db, id)
+}
+
+// settledSlip: บิลเต็งที่เคลียร์แล้วเมื่อ at
+func settledSlip(t *testing.T, db *gorm.DB, userID uint, amount, payout models.Money, status string, at time.Time) models.BetSlip {
+	t.Helper()
+	slip := models.BetSlip{UserID: userID, Pick: "home", Amount: amount, Payout: payout, Status: status, SettledAt: &at, CreatedAt: at}
+	if err := db.Omit("Match", "User").Create(&slip).Error; err != nil {
+		t.Fatalf("create slip: %v", err)
+	}
+	return slip
+}
+
+// setCurrencyLimit: ตั้งขั้นต่ำ/เพดานของสกุลในเทสต์ (แทนค่าเริ่มต้น)
+func setCurrencyLimit(t *testing.T, db *gorm.DB, limit models.CurrencyLimit) {
+	t.Helper()
+	if err := db.Save(&limit).Error; err != nil {
+		t.Fatalf("save currency limit: %v", err)
+	}
+}
+
+// setWithdrawSettings: ตั้งกติกาการถอนใน SystemSetting แถวที่ 1 (ตั้งตรงๆ เพราะค่า 0 จะโดน default ตอน Create)
+func setWithdrawSettings(t *testing.T, db *gorm.DB, turnoverPercent float64, cooldownHours int) {
+	t.Helper()
+	if err := db.FirstOrCreate(&models.SystemSetting{ID: 1}).Error; err != nil {
+		t.Fatalf("create settings: %v", err)
+	}
+	if err := db.Model(&models.SystemSetting{ID: 1}).Updates(map[string]interface{}{
+		"withdraw_turnover_percent":    turnoverPercent,
+		"withdraw_bank_cooldown_hours": cooldownHours,
+	}).Error; err != nil {
+		t.Fatalf("update settings: %v", err)
+	}
+}
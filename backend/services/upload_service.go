@@ -0,0 +1,243 @@
+package services
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"time"
+)
+
+// ==========================================
+// อัปโหลดสลิป (Upload Pipeline)
+// ==========================================
+// ไม่เชื่อชื่อไฟล์/Content-Type จากเครื่องลูกค้า: ดูจากไบต์จริง รับเฉพาะ JPG/PNG ที่เปิดเป็นรูปได้
+// ลบ Metadata (EXIF/GPS/ข้อความ) ก่อนเก็บ ตั้งชื่อไฟล์ใหม่แบบสุ่ม และทำรูปย่อไว้แสดงในรายการ
+
+const (
+	maxSlipBytes     = 5 << 20  // 5 MB
+	maxSlipPixels    = 40000000 // กันไฟล์เล็กที่ขยายเป็นภาพใหญ่มาก (decompression bomb)
+	slipThumbWidth   = 320
+	slipThumbQuality = 80
+)
+
+var (
+	ErrUploadTooLarge = errors.New("file too large")
+	ErrUploadType     = errors.New("unsupported file type")
+)
+
+// รูปแบบที่รับ: Content-Type (จากไบต์จริง) -> นามสกุลไฟล์
+var slipTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+}
+
+// SlipUpload: ผลการอัปโหลดสลิป 1 ใบ
+type SlipUpload struct {
+	Key         string // key ของไฟล์ใน Uploads (ลบ Metadata แล้ว)
+	ThumbKey    string
+	ContentType string
+	Original    []byte // ไบต์ตามที่ลูกค้าส่งมา (ใช้ทำลายนิ้วมือสลิป)
+}
+
+// SaveSlipUpload: ตรวจไฟล์ ลบ Metadata ทำรูปย่อ แล้วเก็บทั้งสองไฟล์ลง Uploads
+func SaveSlipUpload(fh *multipart.FileHeader) (*SlipUpload, error) {
+	if fh.Size > maxSlipBytes {
+		return nil, ErrUploadTooLarge
+	}
+	f, err := fh.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, maxSlipBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxSlipBytes {
+		return nil, ErrUploadTooLarge
+	}
+
+	contentType := http.DetectContentType(data)
+	ext, ok := slipTypes[contentType]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUploadType, contentType)
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUploadType, err)
+	}
+	if cfg.Width*cfg.Height > maxSlipPixels {
+		return nil, fmt.Errorf("%w: %dx%d", ErrUploadTooLarge, cfg.Width, cfg.Height)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUploadType, err)
+	}
+
+	var clean []byte
+	if contentType == "image/png" {
+		clean, err = stripPNGMetadata(data)
+	} else {
+		clean, err = stripJPEGMetadata(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUploadType, err)
+	}
+
+	var thumb bytes.Buffer
+	if err := jpeg.Encode(&thumb, resizeToWidth(img, slipThumbWidth), &jpeg.Options{Quality: slipThumbQuality}); err != nil {
+		return nil, err
+	}
+
+	name, err := randomUploadName()
+	if err != nil {
+		return nil, err
+	}
+	dir := "slips/" + time.Now().In(bangkokTZ).Format("2006/01") + "/"
+	up := &SlipUpload{
+		Key:         dir + name + ext,
+		ThumbKey:    dir + name + "_thumb.jpg",
+		ContentType: contentType,
+		Original:    data,
+	}
+
+	if err := Uploads.Put(up.Key, clean, contentType); err != nil {
+		return nil, err
+	}
+	if err := Uploads.Put(up.ThumbKey, thumb.Bytes(), "image/jpeg"); err != nil {
+		Uploads.Delete(up.Key)
+		return nil, err
+	}
+	return up, nil
+}
+
+// Remove: ลบไฟล์ที่อัปโหลดไปแล้ว (ใช้ตอนบันทึกรายการไม่สำเร็จ)
+func (u *SlipUpload) Remove() {
+	Uploads.Delete(u.Key)
+	Uploads.Delete(u.ThumbKey)
+}
+
+func randomUploadName() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// stripJPEGMetadata: ตัด segment APP1 (EXIF/XMP), APP13 (IPTC) และ COM ออกแบบไม่ถอดรหัสภาพใหม่
+// ส่วนอื่น (APP0/JFIF, APP2/ICC, APP14/Adobe) ต้องเก็บไว้ให้แสดงสีได้ถูก
+func stripJPEGMetadata(data []byte) ([]byte, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, errors.New("not a jpeg")
+	}
+	out := make([]byte, 0, len(data))
+	out = append(out, 0xFF, 0xD8)
+
+	i := 2
+	for i < len(data) {
+		if data[i] != 0xFF {
+			return nil, errors.New("corrupt jpeg marker")
+		}
+		// ข้าม fill byte (0xFF ซ้อนกัน)
+		for i+1 < len(data) && data[i+1] == 0xFF {
+			i++
+		}
+		if i+1 >= len(data) {
+			return nil, errors.New("truncated jpeg")
+		}
+		marker := data[i+1]
+
+		// SOS: ที่เหลือคือข้อมูลภาพ เก็บทั้งหมด
+		if marker == 0xDA {
+			return append(out, data[i:]...), nil
+		}
+		// marker ที่ไม่มีความยาวตามหลัง
+		if marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7) {
+			out = append(out, data[i:i+2]...)
+			i += 2
+			continue
+		}
+		if i+4 > len(data) {
+			return nil, errors.New("truncated jpeg")
+		}
+		end := i + 2 + int(binary.BigEndian.Uint16(data[i+2:i+4]))
+		if end > len(data) {
+			return nil, errors.New("truncated jpeg segment")
+		}
+		if marker != 0xE1 && marker != 0xED && marker != 0xFE {
+			out = append(out, data[i:end]...)
+		}
+		i = end
+	}
+	return nil, errors.New("jpeg without image data")
+}
+
+// stripPNGMetadata: ตัด chunk ข้อความ/EXIF/เวลา (tEXt, zTXt, iTXt, eXIf, tIME) ออก
+func stripPNGMetadata(data []byte) ([]byte, error) {
+	const sigLen = 8
+	if len(data) < sigLen || string(data[1:4]) != "PNG" {
+		return nil, errors.New("not a png")
+	}
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:sigLen]...)
+
+	for i := sigLen; i < len(data); {
+		if i+8 > len(data) {
+			return nil, errors.New("truncated png chunk")
+		}
+		end := i + 12 + int(binary.BigEndian.Uint32(data[i:i+4])) // length + type + data + crc
+		if end > len(data) || end < i {
+			return nil, errors.New("truncated png chunk")
+		}
+		switch string(data[i+4 : i+8]) {
+		case "tEXt", "zTXt", "iTXt", "eXIf", "tIME":
+		case "IEND":
+			return append(out, data[i:end]...), nil
+		default:
+			out = append(out, data[i:end]...)
+		}
+		i = end
+	}
+	return nil, errors.New("png without IEND")
+}
+
+// resizeToWidth: ย่อภาพแบบเฉลี่ยสีในช่อง (ไม่ขยายภาพที่เล็กกว่า width อยู่แล้ว)
+func resizeToWidth(img image.Image, width int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= width || w == 0 {
+		return img
+	}
+	height := max(h*width/w, 1)
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		y0, y1 := b.Min.Y+y*h/height, max(b.Min.Y+(y+1)*h/height, b.Min.Y+y*h/height+1)
+		for x := 0; x < width; x++ {
+			x0, x1 := b.Min.X+x*w/width, max(b.Min.X+(x+1)*w/width, b.Min.X+x*w/width+1)
+			// สุ่มไม่เกิน 4x4 จุดต่อช่อง พอสำหรับรูปย่อ
+			stepX, stepY := max((x1-x0)/4, 1), max((y1-y0)/4, 1)
+			var r, g, bl, a, n uint64
+			for py := y0; py < y1; py += stepY {
+				for px := x0; px < x1; px += stepX {
+					cr, cg, cb, ca := img.At(px, py).RGBA()
+					r, g, bl, a = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			dst.Set(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(bl / n), A: uint16(a / n)})
+		}
+	}
+	return dst
+}
@@ -0,0 +1,226 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"mime/multipart"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useMemoryStorage: สลับ Uploads เป็น MemoryStorage ระหว่างเทสต์
+func useMemoryStorage(t *testing.T, s Storage) {
+	t.Helper()
+	prev := Uploads
+	Uploads = s
+	t.Cleanup(func() { Uploads = prev })
+}
+
+// slipFileHeader: ไฟล์แนบใน multipart form เหมือนที่ Fiber ส่งให้ handler
+func slipFileHeader(t *testing.T, name string, data []byte) *multipart.FileHeader {
+	t.Helper()
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	part, err := w.CreateFormFile("slip", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(data)
+	w.Close()
+
+	form, err := multipart.NewReader(&body, w.Boundary()).ReadForm(maxSlipBytes * 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { form.RemoveAll() })
+	return form.File["slip"][0]
+}
+
+func testImage(w, h int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	return img
+}
+
+// jpegWithEXIF: JPEG ที่มี segment APP1 (EXIF) ต่อจาก SOI
+func jpegWithEXIF(t *testing.T, w, h int, exif string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, testImage(w, h), nil); err != nil {
+		t.Fatal(err)
+	}
+	payload := append([]byte("Exif\x00\x00"), exif...)
+	seg := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(seg[2:], uint16(len(payload)+2))
+	seg = append(seg, payload...)
+
+	data := buf.Bytes()
+	return append(append(append([]byte{}, data[:2]...), seg...), data[2:]...)
+}
+
+// pngWithText: PNG ที่มี chunk tEXt ต่อจาก IHDR
+func pngWithText(t *testing.T, w, h int, text string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, testImage(w, h)); err != nil {
+		t.Fatal(err)
+	}
+	chunkData := []byte("Comment\x00" + text)
+	chunk := make([]byte, 8, 12+len(chunkData))
+	binary.BigEndian.PutUint32(chunk, uint32(len(chunkData)))
+	copy(chunk[4:], "tEXt")
+	chunk = append(chunk, chunkData...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+
+	data := buf.Bytes()
+	const ihdrEnd = 8 + 12 + 13 // signature + IHDR (length/type/crc + data 13 ไบต์)
+	return append(append(append([]byte{}, data[:ihdrEnd]...), chunk...), data[ihdrEnd:]...)
+}
+
+func TestSaveSlipUpload(t *testing.T) {
+	const secret = "GPS 13.7563N 100.5018E"
+
+	tests := []struct {
+		name        string
+		data        []byte
+		wantType    string
+		wantExt     string
+		wantThumbW  int
+		wantErr     error
+		wantObjects int
+	}{
+		{"jpeg strips exif", jpegWithEXIF(t, 640, 400, secret), "image/jpeg", ".jpg", slipThumbWidth, nil, 2},
+		{"png strips text chunk", pngWithText(t, 100, 50, secret), "image/png", ".png", 100, nil, 2},
+		{"not an image", []byte("%PDF-1.4 " + secret), "", "", 0, ErrUploadType, 0},
+		{"jpeg header with broken body", []byte("\xFF\xD8\xFF\xE0garbage"), "", "", 0, ErrUploadType, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewMemoryStorage()
+			useMemoryStorage(t, store)
+
+			up, err := SaveSlipUpload(slipFileHeader(t, "slip.bin", tt.data))
+			if len(store.objects) != tt.wantObjects {
+				t.Errorf("stored objects = %d, want %d", len(store.objects), tt.wantObjects)
+			}
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("SaveSlipUpload() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SaveSlipUpload() error = %v", err)
+			}
+
+			dir := "slips/" + time.Now().In(bangkokTZ).Format("2006/01") + "/"
+			if !strings.HasPrefix(up.Key, dir) || !strings.HasSuffix(up.Key, tt.wantExt) || strings.Contains(up.Key, "slip.bin") {
+				t.Errorf("key = %q, want random name under %s with %s", up.Key, dir, tt.wantExt)
+			}
+			if up.ContentType != tt.wantType || !bytes.Equal(up.Original, tt.data) {
+				t.Errorf("upload = type %q, original kept %v", up.ContentType, bytes.Equal(up.Original, tt.data))
+			}
+
+			clean, contentType, err := store.Get(up.Key)
+			if err != nil || contentType != tt.wantType {
+				t.Fatalf("Get(key) = %q, %v", contentType, err)
+			}
+			if bytes.Contains(clean, []byte(secret)) {
+				t.Error("stored slip still contains metadata")
+			}
+			if _, _, err := image.Decode(bytes.NewReader(clean)); err != nil {
+				t.Errorf("stored slip does not decode: %v", err)
+			}
+
+			thumb, contentType, err := store.Get(up.ThumbKey)
+			if err != nil || contentType != "image/jpeg" {
+				t.Fatalf("Get(thumb) = %q, %v", contentType, err)
+			}
+			cfg, err := jpeg.DecodeConfig(bytes.NewReader(thumb))
+			if err != nil || cfg.Width != tt.wantThumbW {
+				t.Errorf("thumb width = %d, %v, want %d", cfg.Width, err, tt.wantThumbW)
+			}
+
+			up.Remove()
+			if len(store.objects) != 0 {
+				t.Errorf("objects after Remove() = %d, want 0", len(store.objects))
+			}
+		})
+	}
+}
+
+func TestSaveSlipUploadTooLarge(t *testing.T) {
+	store := NewMemoryStorage()
+	useMemoryStorage(t, store)
+
+	fh := slipFileHeader(t, "slip.jpg", jpegWithEXIF(t, 10, 10, ""))
+	fh.Size = maxSlipBytes + 1
+	if _, err := SaveSlipUpload(fh); !errors.Is(err, ErrUploadTooLarge) {
+		t.Errorf("SaveSlipUpload() error = %v, want %v", err, ErrUploadTooLarge)
+	}
+	if len(store.objects) != 0 {
+		t.Errorf("stored objects = %d, want 0", len(store.objects))
+	}
+}
+
+// failingThumbStorage: เก็บรูปย่อไม่สำเร็จ
+type failingThumbStorage struct {
+	*MemoryStorage
+}
+
+func (s failingThumbStorage) Put(key string, data []byte, contentType string) error {
+	if strings.HasSuffix(key, "_thumb.jpg") {
+		return errors.New("disk full")
+	}
+	return s.MemoryStorage.Put(key, data, contentType)
+}
+
+func TestSaveSlipUploadCleansUpOnThumbFailure(t *testing.T) {
+	store := NewMemoryStorage()
+	useMemoryStorage(t, failingThumbStorage{store})
+
+	if _, err := SaveSlipUpload(slipFileHeader(t, "slip.jpg", jpegWithEXIF(t, 400, 300, ""))); err == nil {
+		t.Fatal("SaveSlipUpload() error = nil, want storage error")
+	}
+	if len(store.objects) != 0 {
+		t.Errorf("stored objects = %d, want 0 (slip removed when thumb fails)", len(store.objects))
+	}
+}
+
+func TestMemoryStorage(t *testing.T) {
+	s := NewMemoryStorage()
+	data := []byte("slip")
+	if err := s.Put("slips/a.jpg", data, "image/jpeg"); err != nil {
+		t.Fatal(err)
+	}
+	data[0] = 'X' // แก้ไบต์ของผู้เรียกหลัง Put ต้องไม่กระทบของที่เก็บไว้
+
+	got, contentType, err := s.Get("/slips/./a.jpg")
+	if err != nil || string(got) != "slip" || contentType != "image/jpeg" {
+		t.Errorf("Get() = %q, %q, %v", got, contentType, err)
+	}
+	if err := s.Put("..\\evil", data, ""); err == nil {
+		t.Error("Put() accepted key with backslash")
+	}
+	if err := s.Delete("slips/a.jpg"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.Get("slips/a.jpg"); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("Get() after Delete = %v, want %v", err, ErrObjectNotFound)
+	}
+	if err := s.Delete("slips/a.jpg"); err != nil {
+		t.Errorf("Delete() missing key = %v, want nil", err)
+	}
+}
@@ -0,0 +1,164 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/PawornpratKongdaeng/soccer/models"
+	"gorm.io/gorm"
+)
+
+// ==========================================
+// กติกาการแจ้งถอน (Withdrawal Eligibility)
+// ==========================================
+// 1. ถอนเข้าได้เฉพาะบัญชีรับเงินในโปรไฟล์ (User.BankName/BankAccount) ที่ Admin ยืนยันแล้ว
+// 2. ตั้ง/เปลี่ยนบัญชีแล้วต้องรอ SystemSetting.WithdrawBankCooldownHours
+// 3. ยอดเล่นหลังฝากล่าสุดต้องถึง WithdrawTurnoverPercent % ของยอดฝากนั้น
+// 4. จำนวนครั้ง/ยอดรวมต่อวันไม่เกิน CurrencyLimit.MaxWithdrawCount / MaxWithdrawDaily (เวลาไทย)
+// ไม่ผ่านข้อไหนคืน WithdrawRuleError ที่มีเหตุผลภาษาไทยพร้อมแสดงให้สมาชิก
+
+var ErrWithdrawRule = errors.New("withdrawal rule not met")
+
+// กรณีที่แจ้งถอนไม่ผ่าน (WithdrawRuleError.Code)
+const (
+	WithdrawRuleNoBank       = "no_bank"
+	WithdrawRuleUnverified   = "bank_unverified"
+	WithdrawRuleBankCooldown = "bank_cooldown"
+	WithdrawRuleTurnover     = "turnover"
+	WithdrawRuleDailyCount   = "daily_count"
+	WithdrawRuleDailyAmount  = "daily_amount"
+)
+
+// WithdrawRuleError: เหตุผลที่แจ้งถอนไม่ผ่าน (Code ให้หน้าเว็บแยกกรณี, Reason แสดงให้สมาชิกได้เลย)
+type WithdrawRuleError struct {
+	Code   string
+	Reason string
+}
+
+func (e *WithdrawRuleError) Error() string { return e.Code + ": " + e.Reason }
+
+func (e *WithdrawRuleError) Is(target error) bool { return target == ErrWithdrawRule }
+
+func withdrawRule(code, format string, args ...interface{}) error {
+	return &WithdrawRuleError{Code: code, Reason: fmt.Sprintf(format, args...)}
+}
+
+// SetUserBank: ตั้ง/เปลี่ยนบัญชีรับเงินของสมาชิก (เปลี่ยนจริง = เริ่มนับช่วงรอใหม่ และต้องยืนยันใหม่ ยกเว้น Admin ยืนยันให้เลย)
+func SetUserBank(db *gorm.DB, user *models.User, bankName, bankAccount string, verifiedBy *uint, now time.Time) error {
+	bankName, bankAccount = strings.TrimSpace(bankName), strings.TrimSpace(bankAccount)
+	updates := map[string]interface{}{}
+	if TrackBankChange(user, bankName, bankAccount, now) {
+		updates["bank_name"], updates["bank_account"], updates["bank_changed_at"] = user.BankName, user.BankAccount, user.BankChangedAt
+	}
+	if verifiedBy != nil && user.BankAccount != "" {
+		user.BankVerifiedAt = &now
+	}
+	updates["bank_verified_at"] = user.BankVerifiedAt
+	return db.Model(user).Updates(updates).Error
+}
+
+// TrackBankChange: ใส่บัญชีใหม่ให้ user ถ้าต่างจากเดิม (ล้างการยืนยัน + บันทึกเวลาเปลี่ยน) คืน true ถ้าเปลี่ยน
+func TrackBankChange(user *models.User, bankName, bankAccount string, now time.Time) bool {
+	if user.BankName == bankName && user.BankAccount == bankAccount {
+		return false
+	}
+	user.BankName, user.BankAccount = bankName, bankAccount
+	user.BankChangedAt = &now
+	user.BankVerifiedAt = nil
+	return true
+}
+
+// CheckWithdrawRules: ตรวจกติกาการถอนของสมาชิกก่อนสร้างรายการถอนยอด amount
+func CheckWithdrawRules(db *gorm.DB, user *models.User, amount models.Money, now time.Time) error {
+	if user.BankName == "" || user.BankAccount == "" {
+		return withdrawRule(WithdrawRuleNoBank, "ยังไม่มีบัญชีรับเงินในโปรไฟล์ กรุณาเพิ่มบัญชีธนาคารก่อนแจ้งถอน")
+	}
+	if user.BankVerifiedAt == nil {
+		return withdrawRule(WithdrawRuleUnverified, "บัญชีรับเงิน %s %s ยังไม่ได้รับการยืนยันจากแอดมิน", user.BankName, user.BankAccount)
+	}
+
+	var settings models.SystemSetting
+	db.First(&settings, 1)
+	if settings.WithdrawBankCooldownHours > 0 && user.BankChangedAt != nil {
+		until := user.BankChangedAt.Add(time.Duration(settings.WithdrawBankCooldownHours) * time.Hour)
+		if now.Before(until) {
+			return withdrawRule(WithdrawRuleBankCooldown, "เพิ่งเปลี่ยนบัญชีรับเงิน แจ้งถอนได้หลัง %s (เวลาไทย)", until.In(bangkokTZ).Format("02/01/2006 15:04"))
+		}
+	}
+
+	currency := withdrawalCurrency(*user)
+	unit := models.CurrencyLabel(currency)
+	if settings.WithdrawTurnoverPercent > 0 {
+		played, required, err := DepositTurnover(db, user.ID, settings.WithdrawTurnoverPercent)
+		if err != nil {
+			return err
+		}
+		if played < required {
+			return withdrawRule(WithdrawRuleTurnover, "ยอดเล่นหลังฝากล่าสุดยังไม่ถึงเกณฑ์ (เล่นแล้ว %s จากที่ต้องมี %s %s)", played, required, unit)
+		}
+	}
+
+	limit, err := CurrencyLimitFor(db, currency)
+	if err != nil {
+		return err
+	}
+	if limit.MaxWithdrawCount == 0 && limit.MaxWithdrawDaily == 0 {
+		return nil
+	}
+	count, total, err := WithdrawnToday(db, user.ID, now)
+	if err != nil {
+		return err
+	}
+	if limit.MaxWithdrawCount > 0 && count >= int64(limit.MaxWithdrawCount) {
+		return withdrawRule(WithdrawRuleDailyCount, "แจ้งถอนได้ไม่เกิน %d ครั้งต่อวัน (วันนี้แจ้งไปแล้ว %d ครั้ง)", limit.MaxWithdrawCount, count)
+	}
+	if limit.MaxWithdrawDaily > 0 && total+amount > limit.MaxWithdrawDaily {
+		left := max(limit.MaxWithdrawDaily-total, 0)
+		return withdrawRule(WithdrawRuleDailyAmount, "ยอดถอนรวมต่อวันไม่เกิน %s %s (วันนี้ถอนไปแล้ว %s ถอนได้อีก %s %s)", limit.MaxWithdrawDaily, unit, total, left, unit)
+	}
+	return nil
+}
+
+// DepositTurnover: ยอดเล่นตั้งแต่ฝากล่าสุดที่อนุมัติแล้ว เทียบกับยอดที่ต้องมี (percent % ของยอดฝากนั้น)
+// ไม่นับบิลเสมอ (คืนทุน) ยังไม่เคยฝาก = ไม่ต้องมียอดเล่น
+func DepositTurnover(db *gorm.DB, userID uint, percent float64) (models.Money, models.Money, error) {
+	var deposit models.Transaction
+	if err := db.Where("user_id = ? AND type = ? AND status = ?", userID, "deposit", "approved").
+		Order("created_at desc").Limit(1).Find(&deposit).Error; err != nil {
+		return 0, 0, err
+	}
+	if deposit.ID == 0 {
+		return 0, 0, nil
+	}
+
+	var played models.Money
+	for _, model := range []interface{}{&models.BetSlip{}, &models.ParlayTicket{}} {
+		var sum models.Money
+		if err := db.Model(model).
+			Where("user_id = ? AND created_at >= ? AND status <> ?", userID, deposit.CreatedAt, models.BetStatusDraw).
+			Select("COALESCE(SUM(amount), 0)").Scan(&sum).Error; err != nil {
+			return 0, 0, err
+		}
+		played += sum
+	}
+	return played, deposit.Amount.Percent(percent), nil
+}
+
+// WithdrawnToday: จำนวนครั้ง/ยอดรวมที่สมาชิกแจ้งถอนวันนี้ (ไม่นับที่ถูกปฏิเสธหรือโอนไม่สำเร็จ)
+// นับเฉพาะรายการที่มีบัญชีรับเงิน (แจ้งถอนเอง) ไม่รวมรายการที่ Agent/Admin ดึงเครดิตคืนแบบเก่า
+func WithdrawnToday(db *gorm.DB, userID uint, now time.Time) (int64, models.Money, error) {
+	local := now.In(bangkokTZ)
+	start := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, bangkokTZ)
+
+	var row struct {
+		Count int64
+		Total models.Money
+	}
+	err := db.Model(&models.Transaction{}).
+		Select("COUNT(*) AS count, COALESCE(SUM(amount), 0) AS total").
+		Where("user_id = ? AND type = ? AND bank_account <> '' AND status NOT IN ? AND created_at >= ?", userID, "withdraw", []string{"rejected", "failed"}, start).
+		Scan(&row).Error
+	return row.Count, row.Total, err
+}
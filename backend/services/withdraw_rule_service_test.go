@@ -0,0 +1,119 @@
+package services
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/PawornpratKongdaeng/soccer/models"
+)
+
+func TestCheckWithdrawRules(t *testing.T) {
+	db := openTestDB(t)
+	setWithdrawSettings(t, db, 100, 24)
+	setCurrencyLimit(t, db, models.CurrencyLimit{
+		Currency:         models.CurrencyTHB,
+		MaxWithdrawCount: 2,
+		MaxWithdrawDaily: models.NewMoney(5000),
+	})
+	now := time.Now()
+	verified := now.Add(-72 * time.Hour)
+
+	// deposit: ฝากที่อนุมัติแล้วเมื่อ 2 ชั่วโมงก่อน ตามด้วยยอดเล่น played
+	deposit := func(t *testing.T, user models.User, amount, played models.Money) {
+		depositAt := now.Add(-2 * time.Hour)
+		if err := db.Create(&models.Transaction{UserID: user.ID, Amount: amount, Type: "deposit", Status: "approved", CreatedAt: depositAt}).Error; err != nil {
+			t.Fatal(err)
+		}
+		if played > 0 {
+			settledSlip(t, db, user.ID, played, 0, models.BetStatusLoss, now.Add(-time.Hour))
+		}
+	}
+	// withdrawn: รายการประเภท withdraw วันนี้ (bankAccount ว่าง = ดึงเครดิตคืนแบบเก่า)
+	withdrawn := func(t *testing.T, user models.User, amount models.Money, status, bankAccount string) {
+		if err := db.Create(&models.Transaction{UserID: user.ID, Amount: amount, Type: "withdraw", Status: status, BankAccount: bankAccount, CreatedAt: now}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		fields   map[string]interface{}
+		setup    func(t *testing.T, user models.User)
+		amount   models.Money
+		wantCode string // "" = ผ่าน
+	}{
+		{"no bank on file", map[string]interface{}{"bank_account": ""}, nil, models.NewMoney(100), WithdrawRuleNoBank},
+		{"bank not verified", map[string]interface{}{"bank_verified_at": nil}, nil, models.NewMoney(100), WithdrawRuleUnverified},
+		{"bank changed within cooldown", map[string]interface{}{"bank_changed_at": now.Add(-23 * time.Hour)}, nil, models.NewMoney(100), WithdrawRuleBankCooldown},
+		{"bank changed after cooldown", map[string]interface{}{"bank_changed_at": now.Add(-25 * time.Hour)}, nil, models.NewMoney(100), ""},
+		{"turnover not met", nil, func(t *testing.T, u models.User) { deposit(t, u, models.NewMoney(1000), models.NewMoney(999.99)) }, models.NewMoney(100), WithdrawRuleTurnover},
+		{"turnover met", nil, func(t *testing.T, u models.User) { deposit(t, u, models.NewMoney(1000), models.NewMoney(1000)) }, models.NewMoney(100), ""},
+		{"daily count reached", nil, func(t *testing.T, u models.User) {
+			withdrawn(t, u, models.NewMoney(100), "paid", u.BankAccount)
+			withdrawn(t, u, models.NewMoney(100), "pending", u.BankAccount)
+		}, models.NewMoney(100), WithdrawRuleDailyCount},
+		{"rejected and failed are not counted", nil, func(t *testing.T, u models.User) {
+			withdrawn(t, u, models.NewMoney(100), "rejected", u.BankAccount)
+			withdrawn(t, u, models.NewMoney(100), "failed", u.BankAccount)
+			withdrawn(t, u, models.NewMoney(100), "approved", u.BankAccount)
+		}, models.NewMoney(100), ""},
+		{"credit pull-backs are not counted", nil, func(t *testing.T, u models.User) {
+			withdrawn(t, u, models.NewMoney(3000), "paid", "")
+			withdrawn(t, u, models.NewMoney(3000), "paid", "")
+		}, models.NewMoney(5000), ""},
+		{"daily amount exceeded", nil, func(t *testing.T, u models.User) {
+			withdrawn(t, u, models.NewMoney(4000), "approved", u.BankAccount)
+		}, models.NewMoney(1000.01), WithdrawRuleDailyAmount},
+		{"daily amount exactly at limit", nil, func(t *testing.T, u models.User) {
+			withdrawn(t, u, models.NewMoney(4000), "approved", u.BankAccount)
+		}, models.NewMoney(1000), ""},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := createTestUser(t, db, "wd_rule_"+strconv.Itoa(i), 0)
+			fields := map[string]interface{}{"bank_verified_at": verified}
+			for k, v := range tt.fields {
+				fields[k] = v
+			}
+			user = updateUser(t, db, user.ID, fields)
+			if tt.setup != nil {
+				tt.setup(t, user)
+			}
+
+			err := CheckWithdrawRules(db, &user, tt.amount, now)
+			if tt.wantCode == "" {
+				if err != nil {
+					t.Fatalf("CheckWithdrawRules() error = %v, want nil", err)
+				}
+				return
+			}
+			var rule *WithdrawRuleError
+			if !errors.As(err, &rule) || !errors.Is(err, ErrWithdrawRule) {
+				t.Fatalf("CheckWithdrawRules() error = %v, want rule %s", err, tt.wantCode)
+			}
+			if rule.Code != tt.wantCode || rule.Reason == "" {
+				t.Errorf("rule = %s (%q), want %s", rule.Code, rule.Reason, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestWithdrawnTodayBangkokDay(t *testing.T) {
+	db := openTestDB(t)
+	user := createTestUser(t, db, "wd_today", 0)
+	// 23:30 เวลาไทยของเมื่อวาน = 16:30 UTC ไม่นับเป็นของวันนี้
+	now := time.Date(2026, 3, 3, 10, 0, 0, 0, bangkokTZ)
+	create := func(at time.Time) {
+		db.Create(&models.Transaction{UserID: user.ID, Amount: models.NewMoney(100), Type: "withdraw", Status: "paid", BankAccount: user.BankAccount, CreatedAt: at})
+	}
+	create(time.Date(2026, 3, 2, 23, 30, 0, 0, bangkokTZ))
+	create(time.Date(2026, 3, 3, 0, 0, 0, 0, bangkokTZ))
+
+	count, total, err := WithdrawnToday(db, user.ID, now.UTC())
+	if err != nil || count != 1 || total != models.NewMoney(100) {
+		t.Errorf("WithdrawnToday() = %d, %s, %v, want 1, 100.00", count, total, err)
+	}
+}
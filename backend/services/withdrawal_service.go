@@ -0,0 +1,206 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/PawornpratKongdaeng/soccer/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ==========================================
+// ขั้นตอนการถอน (Withdrawal Workflow)
+// ==========================================
+// pending (แจ้งถอน ยอดพักไว้) -> approved (อนุมัติครบ) -> paid (โอนแล้ว ตัดยอดพักออก) / failed (โอนไม่สำเร็จ คืนยอดพัก)
+// ยอดถอนถึง CurrencyLimit.WithdrawDualApproval ต้องมี Admin อนุมัติ 2 คนที่ไม่ซ้ำกัน
+// Admin แต่ละคนอนุมัติได้รวมไม่เกิน CurrencyLimit.AdminDailyApproval ต่อวัน (เวลาไทย แยกตามสกุล)
+// ทุกขั้นตอนบันทึกลง WithdrawalStep ผู้เรียกต้องล็อกแถว Transaction (FOR UPDATE) ไว้ก่อน
+
+var (
+	ErrWithdrawalState       = errors.New("withdrawal is not in the required state")
+	ErrAlreadyApproved       = errors.New("this admin has already approved the withdrawal")
+	ErrApprovalCapReached    = errors.New("daily withdrawal approval cap reached")
+	ErrBankReferenceRequired = errors.New("bank reference is required")
+	ErrFailReasonRequired    = errors.New("failure reason is required")
+)
+
+// withdrawalCurrency: สกุลของกระเป๋าสมาชิก (บัญชีเก่าที่ไม่มีค่า = THB)
+func withdrawalCurrency(member models.User) string {
+	if member.Currency == "" {
+		return models.CurrencyTHB
+	}
+	return member.Currency
+}
+
+// RequiredApprovals: จำนวนผู้อนุมัติที่รายการถอนยอดนี้ต้องมี
+func RequiredApprovals(amount models.Money, limit models.CurrencyLimit) int {
+	if limit.WithdrawDualApproval > 0 && amount >= limit.WithdrawDualApproval {
+		return 2
+	}
+	return 1
+}
+
+// AdminApprovedToday: ยอดถอนที่ Admin อนุมัติไปแล้ววันนี้ (เฉพาะสกุล currency)
+func AdminApprovedToday(db *gorm.DB, adminID uint, currency string, now time.Time) (models.Money, error) {
+	local := now.In(bangkokTZ)
+	start := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, bangkokTZ)
+
+	var total models.Money
+	err := db.Model(&models.WithdrawalStep{}).
+		Where("admin_id = ? AND action = ? AND currency = ? AND created_at >= ?", adminID, models.WithdrawStepApprove, currency, start).
+		Select("COALESCE(SUM(amount), 0)").Scan(&total).Error
+	return total, err
+}
+
+// ApproveWithdrawal: บันทึกการอนุมัติของ Admin 1 คน คืนจำนวนผู้อนุมัติที่ยังขาด (0 = เปลี่ยนเป็น approved แล้ว)
+// ยังไม่มีเงินเคลื่อนไหว ยอดยังพักอยู่จนกว่าจะบันทึกว่าโอนแล้ว/ไม่สำเร็จ
+func ApproveWithdrawal(tx *gorm.DB, t *models.Transaction, adminID uint, now time.Time) (int, error) {
+	if t.Type != "withdraw" || t.Status != "pending" {
+		return 0, ErrWithdrawalState
+	}
+	var member models.User
+	if err := tx.First(&member, t.UserID).Error; err != nil {
+		return 0, err
+	}
+	currency := withdrawalCurrency(member)
+	limit, err := CurrencyLimitFor(tx, currency)
+	if err != nil {
+		return 0, err
+	}
+
+	var approvers []uint
+	if err := tx.Model(&models.WithdrawalStep{}).
+		Where("transaction_id = ? AND action = ?", t.ID, models.WithdrawStepApprove).
+		Pluck("admin_id", &approvers).Error; err != nil {
+		return 0, err
+	}
+	for _, id := range approvers {
+		if id == adminID {
+			return 0, ErrAlreadyApproved
+		}
+	}
+
+	if limit.AdminDailyApproval > 0 {
+		// ล็อกแถว Admin กันอนุมัติหลายรายการพร้อมกันจนเกินเพดาน
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&models.User{}, adminID).Error; err != nil {
+			return 0, err
+		}
+		used, err := AdminApprovedToday(tx, adminID, currency, now)
+		if err != nil {
+			return 0, err
+		}
+		if used+t.Amount > limit.AdminDailyApproval {
+			return 0, fmt.Errorf("%w: approved %s of %s %s today", ErrApprovalCapReached, used, limit.AdminDailyApproval, currency)
+		}
+	}
+
+	if err := tx.Create(&models.WithdrawalStep{
+		TransactionID: t.ID,
+		AdminID:       adminID,
+		Action:        models.WithdrawStepApprove,
+		Amount:        t.Amount,
+		Currency:      currency,
+		CreatedAt:     now,
+	}).Error; err != nil {
+		return 0, err
+	}
+
+	if remaining := RequiredApprovals(t.Amount, limit) - len(approvers) - 1; remaining > 0 {
+		return remaining, nil
+	}
+	t.Status = "approved"
+	return 0, tx.Model(t).Update("status", t.Status).Error
+}
+
+// MarkWithdrawalPaid: โอนเงินแล้ว ตัดยอดพักเป็นเงินจ่ายออก เก็บเลขอ้างอิง/หลักฐาน และแจ้งสมาชิกกับ Agent ต้นสาย
+func MarkWithdrawalPaid(tx *gorm.DB, t *models.Transaction, adminID uint, bankReference string, proof *SlipUpload, now time.Time) error {
+	bankReference = strings.TrimSpace(bankReference)
+	if t.Type != "withdraw" || t.Status != "approved" {
+		return ErrWithdrawalState
+	}
+	if bankReference == "" {
+		return ErrBankReferenceRequired
+	}
+	var member models.User
+	if err := tx.First(&member, t.UserID).Error; err != nil {
+		return err
+	}
+
+	if err := PostWithdrawPaid(tx, t, &adminID); err != nil {
+		return err
+	}
+	updates := map[string]interface{}{"status": "paid"}
+	if proof != nil {
+		t.SlipPath, t.SlipThumbPath = slipPathPrefix+proof.Key, slipPathPrefix+proof.ThumbKey
+		updates["slip_url"], updates["slip_thumb_url"] = t.SlipPath, t.SlipThumbPath
+	}
+	if err := tx.Model(t).Updates(updates).Error; err != nil {
+		return err
+	}
+	t.Status = "paid"
+
+	currency := withdrawalCurrency(member)
+	if err := tx.Create(&models.WithdrawalStep{
+		TransactionID: t.ID,
+		AdminID:       adminID,
+		Action:        models.WithdrawStepPaid,
+		Amount:        t.Amount,
+		Currency:      currency,
+		BankReference: bankReference,
+		CreatedAt:     now,
+	}).Error; err != nil {
+		return err
+	}
+
+	amount := fmt.Sprintf("%s %s", t.Amount, models.CurrencyLabel(currency))
+	if err := Notify(tx, member.ID, "withdraw_paid", "โอนเงินถอนแล้ว",
+		fmt.Sprintf("รายการถอน #%d จำนวน %s โอนเข้าบัญชีแล้ว (อ้างอิง %s)", t.ID, amount, bankReference),
+		"transaction", t.ID); err != nil {
+		return err
+	}
+	return NotifyUplines(tx, member, "withdraw_paid", "สมาชิกได้รับเงินถอน",
+		fmt.Sprintf("สมาชิก %s ได้รับเงินถอน %s (รายการ #%d)", member.Username, amount, t.ID),
+		"transaction", t.ID)
+}
+
+// FailWithdrawal: โอนไม่สำเร็จ (เช่น บัญชีปลายทางผิด/ถูกปิด) คืนยอดพักเข้ากระเป๋าสมาชิก
+func FailWithdrawal(tx *gorm.DB, t *models.Transaction, adminID uint, reason string, now time.Time) error {
+	reason = strings.TrimSpace(reason)
+	if t.Type != "withdraw" || t.Status != "approved" {
+		return ErrWithdrawalState
+	}
+	if reason == "" {
+		return ErrFailReasonRequired
+	}
+	var member models.User
+	if err := tx.First(&member, t.UserID).Error; err != nil {
+		return err
+	}
+
+	if err := PostWithdrawRefund(tx, t, &adminID); err != nil {
+		return err
+	}
+	t.Status = "failed"
+	t.Note = strings.TrimSpace(t.Note + " โอนไม่สำเร็จ: " + reason)
+	if err := tx.Model(t).Updates(map[string]interface{}{"status": t.Status, "note": t.Note}).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Create(&models.WithdrawalStep{
+		TransactionID: t.ID,
+		AdminID:       adminID,
+		Action:        models.WithdrawStepFailed,
+		Amount:        t.Amount,
+		Currency:      withdrawalCurrency(member),
+		Reason:        reason,
+		CreatedAt:     now,
+	}).Error; err != nil {
+		return err
+	}
+	return Notify(tx, member.ID, "withdraw_failed", "โอนเงินถอนไม่สำเร็จ",
+		fmt.Sprintf("รายการถอน #%d โอนไม่สำเร็จ (%s) คืนยอดเข้ากระเป๋าแล้ว", t.ID, reason),
+		"transaction", t.ID)
+}
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/PawornpratKongdaeng/soccer/models"
+	"gorm.io/gorm"
+)
+
+// pendingWithdrawal: รายการแจ้งถอนที่ยอดพักไว้แล้ว รออนุมัติ
+func pendingWithdrawal(t *testing.T, db *gorm.DB, user models.User, amount models.Money) *models.Transaction {
+	t.Helper()
+	w := models.Transaction{UserID: user.ID, Amount: amount, Type: "withdraw", Status: "pending", BankName: user.BankName, BankAccount: user.BankAccount}
+	if err := db.Create(&w).Error; err != nil {
+		t.Fatalf("create withdraw: %v", err)
+	}
+	if err := PostWithdrawRequest(db, &w); err != nil {
+		t.Fatalf("PostWithdrawRequest() error = %v", err)
+	}
+	return &w
+}
+
+func TestApproveWithdrawal(t *testing.T) {
+	db := openTestDB(t)
+	setCurrencyLimit(t, db, models.CurrencyLimit{
+		Currency:             models.CurrencyTHB,
+		WithdrawDualApproval: models.NewMoney(5000),
+		AdminDailyApproval:   models.NewMoney(8000),
+	})
+	member := createTestUser(t, db, "wd_member", models.NewMoney(50000))
+	first := createTestUser(t, db, "wd_admin_1", 0)
+	second := createTestUser(t, db, "wd_admin_2", 0)
+	now := time.Now()
+
+	small := pendingWithdrawal(t, db, member, models.NewMoney(1000))
+	large := pendingWithdrawal(t, db, member, models.NewMoney(6000))
+	overCap := pendingWithdrawal(t, db, member, models.NewMoney(2000))
+
+	steps := []struct {
+		name          string
+		w             *models.Transaction
+		admin         models.User
+		wantErr       error
+		wantRemaining int
+		wantStatus    string
+	}{
+		{"small needs one approver", small, first, nil, 0, "approved"},
+		{"approved is not pending", small, second, ErrWithdrawalState, 0, "approved"},
+		{"large needs a second approver", large, first, nil, 1, "pending"},
+		{"same admin cannot approve twice", large, first, ErrAlreadyApproved, 0, "pending"},
+		{"second admin completes approval", large, second, nil, 0, "approved"},
+		// first อนุมัติไปแล้ว 1000 + 6000 วันนี้ อีก 2000 เกิน 8000
+		{"daily cap reached", overCap, first, ErrApprovalCapReached, 0, "pending"},
+		// second อนุมัติไป 6000 อีก 2000 = 8000 พอดีเพดาน
+		{"daily cap is inclusive", overCap, second, nil, 0, "approved"},
+	}
+
+	for _, s := range steps {
+		t.Run(s.name, func(t *testing.T) {
+			remaining, err := ApproveWithdrawal(db, s.w, s.admin.ID, now)
+			if !errors.Is(err, s.wantErr) {
+				t.Fatalf("ApproveWithdrawal() error = %v, want %v", err, s.wantErr)
+			}
+			if remaining != s.wantRemaining {
+				t.Errorf("remaining = %d, want %d", remaining, s.wantRemaining)
+			}
+			if got := loadTransaction(t, db, s.w.ID); got.Status != s.wantStatus {
+				t.Errorf("status = %q, want %q", got.Status, s.wantStatus)
+			}
+		})
+	}
+
+	// ยังไม่โอน ยอดยังพักอยู่ทั้งหมด
+	if u := reloadUser(t, db, member.ID); u.HeldCredit != models.NewMoney(9000) {
+		t.Errorf("held = %s, want 9000.00", u.HeldCredit)
+	}
+	if used, _ := AdminApprovedToday(db, first.ID, models.CurrencyTHB, now); used != models.NewMoney(7000) {
+		t.Errorf("AdminApprovedToday(first) = %s, want 7000.00", used)
+	}
+	if used, _ := AdminApprovedToday(db, first.ID, models.CurrencyTHB, now.AddDate(0, 0, 1)); used != 0 {
+		t.Errorf("AdminApprovedToday(tomorrow) = %s, want 0", used)
+	}
+}
+
+func TestWithdrawalPaidAndFailed(t *testing.T) {
+	db := openTestDB(t)
+	agent := createTestUser(t, db, "wd_paid_agent", 0)
+	agent = updateUser(t, db, agent.ID, map[string]interface{}{"role": "agent"})
+	member := createTestUser(t, db, "wd_paid_member", models.NewMoney(1000))
+	member = updateUser(t, db, member.ID, map[string]interface{}{"parent_id": agent.ID})
+	admin := createTestUser(t, db, "wd_paid_admin", 0)
+	now := time.Now()
+
+	paid := approvedWithdrawal(t, db, member, models.NewMoney(300))
+	failed := approvedWithdrawal(t, db, member, models.NewMoney(200))
+
+	if err := MarkWithdrawalPaid(db, &paid, admin.ID, " ", nil, now); !errors.Is(err, ErrBankReferenceRequired) {
+		t.Errorf("MarkWithdrawalPaid() without reference = %v, want %v", err, ErrBankReferenceRequired)
+	}
+	if err := MarkWithdrawalPaid(db, &paid, admin.ID, "KB123", nil, now); err != nil {
+		t.Fatalf("MarkWithdrawalPaid() error = %v", err)
+	}
+	if err := MarkWithdrawalPaid(db, &paid, admin.ID, "KB123", nil, now); !errors.Is(err, ErrWithdrawalState) {
+		t.Errorf("MarkWithdrawalPaid() again = %v, want %v", err, ErrWithdrawalState)
+	}
+
+	if err := FailWithdrawal(db, &failed, admin.ID, "", now); !errors.Is(err, ErrFailReasonRequired) {
+		t.Errorf("FailWithdrawal() without reason = %v, want %v", err, ErrFailReasonRequired)
+	}
+	if err := FailWithdrawal(db, &failed, admin.ID, "บัญชีปิด", now); err != nil {
+		t.Fatalf("FailWithdrawal() error = %v", err)
+	}
+
+	u := reloadUser(t, db, member.ID)
+	if u.Credit != models.NewMoney(700) || u.HeldCredit != 0 {
+		t.Errorf("credit/held = %s/%s, want 700.00/0.00", u.Credit, u.HeldCredit)
+	}
+	if got := loadTransaction(t, db, paid.ID); got.Status != "paid" {
+		t.Errorf("paid status = %q", got.Status)
+	}
+	if got := loadTransaction(t, db, failed.ID); got.Status != "failed" {
+		t.Errorf("failed status = %q", got.Status)
+	}
+
+	var agentNotes int64
+	db.Model(&models.Notification{}).Where("user_id = ? AND type = ?", agent.ID, "withdraw_paid").Count(&agentNotes)
+	if agentNotes != 1 {
+		t.Errorf("agent withdraw_paid notifications = %d, want 1", agentNotes)
+	}
+}
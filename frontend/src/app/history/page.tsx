@@ -141,7 +141,7 @@ export default function HistoryPage() {
                       {bet.items?.map((item: any, idx: number) => (
                         <div key={idx} className="flex justify-between text-[10px] border-b border-white/5 pb-1">
                           <span className="text-white/60">{item.home_team} vs {item.away_team}</span>
-                          <span className="text-emerald-400 font-bold">{item.side?.toUpperCase() || item.pick?.toUpperCase()} @{item.odds}</span>
+                          <span className="text-emerald-400 font-bold">{item.side?.toUpperCase() || item.pick?.toUpperCase()} @{item.price}</span>
                         </div>
                       ))}
                     </div>
@@ -142,7 +142,7 @@ export default function BetslipDetailModal({ isOpen, onClose, betslip }: Betslip
                     <td className="px-6 py-4 text-center">
                       <div className="flex flex-col items-center">
                         <span className="text-sm font-bold text-emerald-600">
-                          {item.odds}
+                          {item.odds ?? item.price}
                         </span>
                         <span className="text-[10px] text-slate-400 font-bold">
                            (Price: {item.price || "-"})